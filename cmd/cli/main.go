@@ -2,6 +2,7 @@ package main
 
 import (
 	"bufio"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
@@ -10,7 +11,11 @@ import (
 	"strings"
 	"time"
 
+	"github.com/rdimidov/kvstore/internal/domain"
+	"github.com/rdimidov/kvstore/internal/presentation/frame"
 	"github.com/rdimidov/kvstore/internal/presentation/tcpclient"
+	"github.com/rdimidov/kvstore/internal/presentation/tcpserver"
+	"github.com/rdimidov/kvstore/pkg/tlsconfig"
 	"go.uber.org/zap"
 )
 
@@ -23,9 +28,19 @@ func main() {
 	// Define and parse required CLI flag for server address
 	addrFlag := flag.String("address", "", "server address (required), e.g. 127.0.0.1:3223")
 	timeoutFlag := flag.Duration("timeout", defaultTimeout, "timeout for server connection, e.g. 5s, 1m")
-	bufSizeFlag := flag.Int("buf", defaultBufferSize, "buffer size in bytes, e.g 1024, 4096")
+	bufSizeFlag := flag.Int("buf", defaultBufferSize, "largest reply in bytes, 0 for no limit; a larger reply is reported and skipped")
+	protocolFlag := flag.String("protocol", string(tcpserver.TextProtocol), "protocol of the server, text or binary")
+	tlsCertFlag := flag.String("tls-cert", "", "client certificate file for mutual TLS")
+	tlsKeyFlag := flag.String("tls-key", "", "client key file for mutual TLS")
+	tlsCAFlag := flag.String("tls-ca", "", "CA file to verify the server with, connects over TLS when any tls flag is set")
+	hashFlag := flag.Bool("hash-password", false, "read a password from stdin and print its hash for the auth config")
 	flag.Parse()
 
+	if *hashFlag {
+		hashPassword()
+		return
+	}
+
 	// Exit if address is not provided
 	if *addrFlag == "" {
 		fmt.Fprintln(os.Stderr, "error: --address flag is required")
@@ -33,6 +48,13 @@ func main() {
 		os.Exit(1)
 	}
 
+	protocol, err := tcpserver.ParseProtocol(*protocolFlag)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		flag.Usage()
+		os.Exit(1)
+	}
+
 	// Initialize structured logger (Zap)
 	loggerCore, err := zap.NewProduction()
 	if err != nil {
@@ -41,11 +63,20 @@ func main() {
 	logger := loggerCore.Sugar()
 	defer logger.Sync() //nolint: all
 
-	// Create TCP client with timeout
-	client, err := tcpclient.New(*addrFlag,
+	options := []tcpclient.Option{
 		tcpclient.WithTimeout(*timeoutFlag),
 		tcpclient.WithBufferSize(*bufSizeFlag),
-	)
+	}
+	if *tlsCertFlag != "" || *tlsKeyFlag != "" || *tlsCAFlag != "" {
+		clientTLS, err := tlsconfig.Client(*tlsCertFlag, *tlsKeyFlag, *tlsCAFlag)
+		if err != nil {
+			logger.Fatalw("invalid tls flags", "error", err)
+		}
+		options = append(options, tcpclient.WithTLS(clientTLS))
+	}
+
+	// Create TCP client with timeout
+	client, err := tcpclient.New(*addrFlag, options...)
 	if err != nil {
 		logger.Fatalw("could not connect to server", "error", err)
 	}
@@ -72,12 +103,68 @@ func main() {
 			continue
 		}
 
+		if protocol == tcpserver.BinaryProtocol {
+			fmt.Println(do(client, input, logger))
+			continue
+		}
+
 		// Send user input to server and print response
 		resp, err := client.Send([]byte(input))
+		if errors.Is(err, tcpclient.ErrResponseTooLarge) {
+			fmt.Println("ERR " + err.Error())
+			continue
+		}
 		if err != nil {
 			logger.Fatalw("could not send message", "error", err)
 		}
 
-		fmt.Println(string(resp))
+		fmt.Println(render(resp))
+	}
+}
+
+// render prints a reply of the text protocol the way redis-cli does. Error
+// replies are not results and are printed as they are.
+func render(reply []byte) string {
+	line := strings.TrimRight(string(reply), "\r\n")
+	result, err := domain.ParseResult(line)
+	if err != nil {
+		return line
+	}
+	return result.String()
+}
+
+// do sends the input as a frame; arguments holding spaces or other bytes
+// are given quoted, as Go string literals. Only the loss of the connection
+// ends the CLI; a reply too large is skipped, like an error reply.
+func do(client *tcpclient.Client, input string, logger *zap.SugaredLogger) string {
+	args, err := domain.ParseCommand(input)
+	if err != nil {
+		return "ERR " + err.Error()
+	}
+
+	result, err := client.Do(args...)
+	var reply frame.Error
+	if errors.As(err, &reply) {
+		return reply.Error()
+	}
+	if errors.Is(err, frame.ErrTooLarge) {
+		return "ERR " + err.Error()
+	}
+	if err != nil {
+		logger.Fatalw("could not send message", "error", err)
+	}
+	return result.String()
+}
+
+// hashPassword prints the hash of the password on the first line of stdin.
+func hashPassword() {
+	password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		log.Fatalf("could not read password: %v", err)
+	}
+	hash, err := domain.HashPassword(strings.TrimRight(password, "\r\n"))
+	if err != nil {
+		log.Fatalf("could not hash password: %v", err)
 	}
+	fmt.Println(hash)
 }
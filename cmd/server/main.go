@@ -2,17 +2,23 @@ package main
 
 import (
 	"context"
+	"io"
 	"log"
 	"os/signal"
+	"sync"
 	"syscall"
+	"time"
 
 	"github.com/rdimidov/kvstore/internal/application/config"
 	"github.com/rdimidov/kvstore/internal/application/services"
+	"github.com/rdimidov/kvstore/internal/domain"
 
 	"github.com/rdimidov/kvstore/internal/infrastructure/storage"
 	"github.com/rdimidov/kvstore/internal/infrastructure/wal"
+	"github.com/rdimidov/kvstore/internal/presentation/httpserver"
 	"github.com/rdimidov/kvstore/internal/presentation/interpreter"
 	"github.com/rdimidov/kvstore/internal/presentation/tcpserver"
+	"github.com/rdimidov/kvstore/pkg/tlsconfig"
 	"go.uber.org/zap"
 )
 
@@ -25,10 +31,37 @@ func main() {
 
 	logger := cfg.Logger()
 
-	handler := mustInitHandler(ctx, cfg, logger)
+	repo := mustInitStorage(ctx, cfg, logger)
+	app, walCloser, stopSnapshots := mustInitApp(ctx, cfg, logger, repo)
+	users := mustInitUsers(cfg)
+	handler := mustInitHandler(logger, app, users)
+
+	// both servers stop on the same signal, and the WAL and the storage are
+	// closed only once neither serves requests anymore and no snapshot is
+	// being taken
+	var wg sync.WaitGroup
+	if cfg.HTTP.Enabled {
+		gateway := mustInitGateway(cfg, app, users)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			gateway.Start(ctx)
+		}()
+	}
 
 	server := mustInitServer(cfg, handler)
 	server.Start(ctx)
+	wg.Wait()
+	stopSnapshots()
+
+	if err := walCloser.Close(); err != nil {
+		logger.Errorw("failed to close WAL", "error", err)
+	}
+	if closer, ok := repo.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			logger.Errorw("failed to close storage", "error", err)
+		}
+	}
 	logger.Infow("server exited gracefully")
 }
 
@@ -40,21 +73,78 @@ func mustLoadConfig() *config.Config {
 	return cfg
 }
 
-func mustInitHandler(ctx context.Context, cfg *config.Config, logger *zap.SugaredLogger) *interpreter.RawInterpreter {
-	repo := storage.NewMemory()
+// engine is the contract shared by all storage engines selectable from config.
+type engine interface {
+	Get(context.Context, domain.Key) (*domain.Entry, error)
+	Put(context.Context, domain.Entry) error
+	Delete(context.Context, domain.Key) error
+	Expire(context.Context, domain.Key, time.Time) error
+	Persist(context.Context, domain.Key) error
+	Scan(context.Context, domain.Key, domain.Key, int) ([]domain.Entry, error)
+	OverLimit(context.Context, domain.Key) bool
+	Reclaim(context.Context, domain.Key) ([]domain.Key, error)
+	StartSweeper(context.Context, time.Duration)
+}
+
+func mustInitStorage(ctx context.Context, cfg *config.Config, logger *zap.SugaredLogger) engine {
+	policy, err := storage.ParseEvictionPolicy(cfg.Storage.EvictionPolicy)
+	if err != nil {
+		logger.Fatalw("invalid storage config", "error", err)
+	}
+	limit := storage.WithMaxMemory(cfg.StorageMaxMemory(), policy)
+
+	var repo engine
+	switch cfg.Storage.Engine {
+	case "memory":
+		repo = storage.NewMemory(limit)
+	case "sharded":
+		repo = storage.NewSharded(cfg.Storage.Shards, limit)
+	case "ordered":
+		repo = storage.NewOrdered(limit)
+	case "lsm":
+		repo, err = storage.OpenLSM(
+			cfg.Storage.LSM.Dir,
+			storage.WithMemtableSize(cfg.LSMMemtableSize()),
+			storage.WithCompactionThreshold(cfg.Storage.LSM.CompactionThreshold),
+		)
+		if err != nil {
+			logger.Fatalw("failed to open lsm storage", "error", err)
+		}
+	default:
+		logger.Fatalw("unknown storage engine", "engine", cfg.Storage.Engine)
+	}
+
+	repo.StartSweeper(ctx, cfg.Storage.SweepInterval)
+	return repo
+}
 
-	var w services.WALogger
+// mustInitApp returns the application, the WAL it logs to, which has to be
+// closed once no more writes come in, and what stops periodic snapshots
+// before that.
+func mustInitApp(ctx context.Context, cfg *config.Config, logger *zap.SugaredLogger, repo engine) (*services.Application, io.Closer, func()) {
+	var w interface {
+		services.WALogger
+		io.Closer
+	}
+	var logged *wal.WAL
 	if cfg.WAL.Enabled {
+		// replayed commands are applied without being logged again, and
+		// without evicting keys
+		replay, err := services.NewApplication(ctx, repo, logger, nil, services.WithoutEviction())
+		if err != nil {
+			logger.Fatalw("failed to initialize replay app", "error", err)
+		}
 
-		walHandler, err := interpreter.New(repo)
+		walHandler, err := interpreter.New(replay, interpreter.WithReplay())
 		if err != nil {
 			logger.Fatalw("failed to initialize interpreter", "error", err)
 		}
 
-		w, err = wal.New(ctx, cfg, walHandler)
+		logged, err = wal.New(cfg, walHandler)
 		if err != nil {
 			logger.Fatalw("failed to initialize wall", "error", err)
 		}
+		w = logged
 	} else {
 		w = &wal.Noop{}
 	}
@@ -63,8 +153,69 @@ func mustInitHandler(ctx context.Context, cfg *config.Config, logger *zap.Sugare
 	if err != nil {
 		logger.Fatalw("failed to initialize app", "error", err)
 	}
+	if logged != nil {
+		logRecovery(logger, logged.Report())
+	}
+	stopSnapshots := func() {}
+	if cfg.WAL.Enabled && cfg.WALSnapshotInterval() > 0 {
+		stopSnapshots = app.StartSnapshots(ctx, cfg.WALSnapshotInterval())
+	}
+	return app, w, stopSnapshots
+}
 
-	handler, err := interpreter.NewRaw(app)
+func logRecovery(logger *zap.SugaredLogger, report wal.RecoveryReport) {
+	for _, seg := range report.Segments {
+		logger.Debugw("WAL segment replayed", "segment", seg.Name, "records", seg.Records, "bytes", seg.Bytes)
+	}
+	for _, skipped := range report.Skipped {
+		logger.Warnw("WAL record skipped",
+			"segment", skipped.Segment, "offset", skipped.Offset, "reason", skipped.Reason)
+	}
+	if cut := report.Truncate; cut != nil {
+		logger.Warnw("WAL truncated",
+			"segment", cut.Segment, "offset", cut.Offset, "reason", cut.Reason, "removed", cut.Removed)
+	}
+	logger.Infow("WAL recovered",
+		"policy", report.Policy, "snapshot", report.Snapshot, "segments", len(report.Segments),
+		"applied", report.Applied, "skipped", len(report.Skipped))
+}
+
+// mustInitUsers returns the users of the config, nil when there are none
+// and clients need not authenticate.
+func mustInitUsers(config *config.Config) *domain.Users {
+	if len(config.Auth.Users) == 0 {
+		return nil
+	}
+
+	logger := config.Logger()
+	users := make([]domain.User, 0, len(config.Auth.Users))
+	for _, u := range config.Auth.Users {
+		permission, err := domain.ParsePermission(u.Role)
+		if err != nil {
+			logger.Fatalw("invalid auth config", "user", u.Name, "error", err)
+		}
+		users = append(users, domain.User{
+			Name:         u.Name,
+			PasswordHash: u.Password,
+			Permission:   permission,
+			KeyPatterns:  u.Keys,
+			Subject:      u.Subject,
+		})
+	}
+
+	known, err := domain.NewUsers(users)
+	if err != nil {
+		logger.Fatalw("invalid auth config", "error", err)
+	}
+	return known
+}
+
+func mustInitHandler(logger *zap.SugaredLogger, app *services.Application, users *domain.Users) *interpreter.RawInterpreter {
+	var options []interpreter.Option
+	if users != nil {
+		options = append(options, interpreter.WithUsers(users))
+	}
+	handler, err := interpreter.NewRaw(app, options...)
 	if err != nil {
 		logger.Fatalw("failed to initialize interpreter", "error", err)
 	}
@@ -73,13 +224,57 @@ func mustInitHandler(ctx context.Context, cfg *config.Config, logger *zap.Sugare
 
 func mustInitServer(config *config.Config, handler *interpreter.RawInterpreter) *tcpserver.Server {
 	logger := config.Logger()
-	server, err := tcpserver.New(
-		config.Network.Address, handler, logger,
+	protocol, err := tcpserver.ParseProtocol(config.Network.Protocol)
+	if err != nil {
+		logger.Fatalw("invalid network config", "error", err)
+	}
+	admission, err := tcpserver.ParseAdmissionPolicy(config.Network.Admission)
+	if err != nil {
+		logger.Fatalw("invalid network config", "error", err)
+	}
+	options := []tcpserver.Option{
 		tcpserver.WithBufferSize(config.Network.MaxMessageSize),
+		tcpserver.WithProtocol(protocol),
 		tcpserver.WithTimeouts(config.Network.ReadTimeout, config.Network.WriteTimeout),
-	)
+		tcpserver.WithMaxConnections(config.Network.MaxConnections, admission, config.Network.QueueTimeout),
+		tcpserver.WithMaxInFlight(config.Network.MaxInFlight),
+	}
+	if tlsCfg := config.Network.TLS; tlsCfg.Cert != "" || tlsCfg.Key != "" {
+		serverTLS, err := tlsconfig.Server(tlsCfg.Cert, tlsCfg.Key, tlsCfg.CA, tlsCfg.VerifyClient)
+		if err != nil {
+			logger.Fatalw("invalid network tls config", "error", err)
+		}
+		options = append(options, tcpserver.WithTLS(serverTLS))
+	}
+	if config.Network.ShutdownTimeout > 0 {
+		options = append(options, tcpserver.WithShutdownTimeout(config.Network.ShutdownTimeout))
+	}
+
+	server, err := tcpserver.New(config.Network.Address, handler, logger, options...)
 	if err != nil {
 		logger.Fatalw("failed to create TCP server", "error", err)
 	}
 	return server
 }
+
+func mustInitGateway(config *config.Config, app *services.Application, users *domain.Users) *httpserver.Server {
+	logger := config.Logger()
+	options := []httpserver.Option{
+		httpserver.WithTimeouts(config.HTTP.ReadTimeout, config.HTTP.WriteTimeout),
+	}
+	if size := config.HTTPMaxBodySize(); size > 0 {
+		options = append(options, httpserver.WithMaxBodySize(size))
+	}
+	if config.HTTP.ShutdownTimeout > 0 {
+		options = append(options, httpserver.WithShutdownTimeout(config.HTTP.ShutdownTimeout))
+	}
+	if users != nil {
+		options = append(options, httpserver.WithUsers(users))
+	}
+
+	gateway, err := httpserver.New(config.HTTP.Address, app, logger, options...)
+	if err != nil {
+		logger.Fatalw("failed to create HTTP server", "error", err)
+	}
+	return gateway
+}
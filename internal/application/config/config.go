@@ -2,6 +2,8 @@ package config
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/spf13/viper"
@@ -10,17 +12,66 @@ import (
 )
 
 const (
-	defaultServerAddr = "0.0.0.0:8080"
-	defaultLogLevel   = "info"
+	defaultServerAddr    = "0.0.0.0:8080"
+	defaultLogLevel      = "info"
+	defaultStorageEngine = "memory"
+	defaultLSMDir        = "./data"
+	defaultHTTPAddr      = "0.0.0.0:8081"
 )
 
 type Config struct {
 	Network struct {
 		Address        string        `mapstructure:"address"`
 		MaxMessageSize int           `mapstructure:"max_message_size"`
+		Protocol       string        `mapstructure:"protocol"`
 		ReadTimeout    time.Duration `mapstructure:"read_timeout"`
 		WriteTimeout   time.Duration `mapstructure:"write_timeout"`
+		// MaxConnections bounds the connections served at once, zero means
+		// no limit. Admission is reject or queue, for the ones over it.
+		MaxConnections int           `mapstructure:"max_connections"`
+		Admission      string        `mapstructure:"admission"`
+		QueueTimeout   time.Duration `mapstructure:"queue_timeout"`
+		// MaxInFlight bounds the commands executed at once, zero means no
+		// limit.
+		MaxInFlight int `mapstructure:"max_inflight_commands"`
+		// ShutdownTimeout is the grace period connections get at shutdown
+		// to finish the command they are running.
+		ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout"`
+		// TLS serves the protocol over TLS when a certificate is given.
+		TLS struct {
+			Cert string `mapstructure:"cert"`
+			Key  string `mapstructure:"key"`
+			CA   string `mapstructure:"ca"`
+			// VerifyClient requires clients to present a certificate
+			// signed by the CA.
+			VerifyClient bool `mapstructure:"verify_client"`
+		} `mapstructure:"tls"`
 	} `mapstructure:"network"`
+	// HTTP configures the JSON gateway served next to the TCP server.
+	HTTP struct {
+		Enabled         bool          `mapstructure:"enabled"`
+		Address         string        `mapstructure:"address"`
+		MaxBodySize     string        `mapstructure:"max_body_size"`
+		ReadTimeout     time.Duration `mapstructure:"read_timeout"`
+		WriteTimeout    time.Duration `mapstructure:"write_timeout"`
+		ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout"`
+	} `mapstructure:"http"`
+	// Auth requires clients to authenticate once any user is defined.
+	Auth struct {
+		Users []User `mapstructure:"users"`
+	} `mapstructure:"auth"`
+	Storage struct {
+		Engine         string        `mapstructure:"engine"`
+		Shards         int           `mapstructure:"shards"`
+		SweepInterval  time.Duration `mapstructure:"sweep_interval"`
+		MaxMemory      string        `mapstructure:"max_memory"`
+		EvictionPolicy string        `mapstructure:"eviction_policy"`
+		LSM            struct {
+			Dir                 string `mapstructure:"directory"`
+			MemtableSize        string `mapstructure:"memtable_size"`
+			CompactionThreshold int    `mapstructure:"compaction_threshold"`
+		} `mapstructure:"lsm"`
+	} `mapstructure:"storage"`
 	Logging struct {
 		Level string `mapstructure:"level"`
 	} `mapstructure:"logging"`
@@ -30,9 +81,34 @@ type Config struct {
 		FlushTimeout time.Duration `mapstructure:"flushTimeout"`
 		Dir          string        `mapstructure:"directory"`
 		MSS          int           `mapstructure:"maxSegmentSizeMB"`
+		// SnapshotInterval is how often snapshots are taken, zero disables
+		// the periodic ones.
+		SnapshotInterval time.Duration `mapstructure:"snapshotInterval"`
+		SnapshotDir      string        `mapstructure:"snapshotDirectory"`
+		// Recovery is strict, truncate or skip, see wal.RecoveryPolicy.
+		Recovery string `mapstructure:"recovery"`
+		// Fsync is always, batch, interval(<duration>) or never, see
+		// wal.FsyncPolicy.
+		Fsync string `mapstructure:"fsync"`
 	} `mapstructure:"wal"`
 
-	logger *zap.SugaredLogger
+	logger       *zap.SugaredLogger
+	maxMemory    int64
+	memtableSize int64
+	maxBodySize  int64
+}
+
+// User is a user clients may authenticate as, see domain.User.
+type User struct {
+	Name string `mapstructure:"name"`
+	// Password is a hash made by kvstore-cli -hash-password.
+	Password string `mapstructure:"password"`
+	// Role is read-only, read-write or admin.
+	Role string   `mapstructure:"role"`
+	Keys []string `mapstructure:"keys"`
+	// Subject is the subject of a client certificate authenticating as the
+	// user over mutual TLS, without AUTH.
+	Subject string `mapstructure:"subject"`
 }
 
 func LoadConfig() (*Config, error) {
@@ -40,6 +116,9 @@ func LoadConfig() (*Config, error) {
 
 	v.SetDefault("network.address", defaultServerAddr)
 	v.SetDefault("logging.level", defaultLogLevel)
+	v.SetDefault("storage.engine", defaultStorageEngine)
+	v.SetDefault("storage.lsm.directory", defaultLSMDir)
+	v.SetDefault("http.address", defaultHTTPAddr)
 
 	v.SetConfigName("config")
 	v.SetConfigType("yaml")
@@ -50,10 +129,22 @@ func LoadConfig() (*Config, error) {
 		return nil, fmt.Errorf("error reading config file: %w", err)
 	}
 
-	var cfg Config
-	if err := v.Unmarshal(&cfg); err != nil {
+	var (
+		cfg Config
+		err error
+	)
+	if err = v.Unmarshal(&cfg); err != nil {
 		return nil, fmt.Errorf("unable to decode config: %w", err)
 	}
+	if cfg.maxMemory, err = parseBytes(cfg.Storage.MaxMemory); err != nil {
+		return nil, fmt.Errorf("invalid storage.max_memory: %w", err)
+	}
+	if cfg.memtableSize, err = parseBytes(cfg.Storage.LSM.MemtableSize); err != nil {
+		return nil, fmt.Errorf("invalid storage.lsm.memtable_size: %w", err)
+	}
+	if cfg.maxBodySize, err = parseBytes(cfg.HTTP.MaxBodySize); err != nil {
+		return nil, fmt.Errorf("invalid http.max_body_size: %w", err)
+	}
 	if err := cfg.setLogger(); err != nil {
 		return nil, err
 	}
@@ -86,8 +177,45 @@ func (c *Config) Cleanup() {
 
 func (c *Config) Logger() *zap.SugaredLogger { return c.logger }
 
+// StorageMaxMemory is the storage memory limit in bytes, zero means no limit.
+func (c *Config) StorageMaxMemory() int64 { return c.maxMemory }
+
+// LSMMemtableSize is the memtable flush threshold in bytes, zero means the
+// engine default.
+func (c *Config) LSMMemtableSize() int64 { return c.memtableSize }
+
+// HTTPMaxBodySize is the request body limit of the gateway in bytes, zero
+// means the gateway default.
+func (c *Config) HTTPMaxBodySize() int64 { return c.maxBodySize }
+
 func (c *Config) WALEnabled() bool                    { return c.WAL.Enabled }
 func (c *Config) WALBatchSize() int                   { return c.WAL.BatchSize }
 func (c *Config) WALBatchFlushTimeout() time.Duration { return c.WAL.FlushTimeout }
 func (c *Config) WALDirName() string                  { return c.WAL.Dir }
 func (c *Config) WALMaxSegmentSize() int              { return c.WAL.MSS }
+func (c *Config) WALSnapshotDirName() string          { return c.WAL.SnapshotDir }
+func (c *Config) WALSnapshotInterval() time.Duration  { return c.WAL.SnapshotInterval }
+func (c *Config) WALRecoveryPolicy() string           { return c.WAL.Recovery }
+func (c *Config) WALFsyncPolicy() string              { return c.WAL.Fsync }
+
+// parseBytes parses sizes like "1024", "64kb", "100mb" or "2gb".
+func parseBytes(s string) (int64, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" {
+		return 0, nil
+	}
+
+	multiplier := int64(1)
+	for suffix, m := range map[string]int64{"kb": 1 << 10, "mb": 1 << 20, "gb": 1 << 30} {
+		if strings.HasSuffix(s, suffix) {
+			s, multiplier = strings.TrimSuffix(s, suffix), m
+			break
+		}
+	}
+
+	n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("not a size: %q", s)
+	}
+	return n * multiplier, nil
+}
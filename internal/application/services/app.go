@@ -1,8 +1,15 @@
 package services
 
 import (
+	"cmp"
 	"context"
 	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/rdimidov/kvstore/internal/domain"
 
@@ -10,75 +17,629 @@ import (
 )
 
 type repository interface {
-	Set(context.Context, domain.Key, domain.Value) error
+	Put(context.Context, domain.Entry) error
 	Get(context.Context, domain.Key) (*domain.Entry, error)
 	Delete(context.Context, domain.Key) error
+	Expire(context.Context, domain.Key, time.Time) error
+	Persist(context.Context, domain.Key) error
+	Scan(context.Context, domain.Key, domain.Key, int) ([]domain.Entry, error)
+	OverLimit(context.Context, domain.Key) bool
+	Reclaim(context.Context, domain.Key) ([]domain.Key, error)
 }
 
 type WALogger interface {
-	WriteSet(domain.Key, domain.Value) error
-	WriteDel(domain.Key) error
+	WriteSet(domain.Entry) (domain.LSN, error)
+	WriteDel(domain.Key) (domain.LSN, error)
+	WriteExpire(domain.Key, time.Time) (domain.LSN, error)
+	WritePersist(domain.Key) (domain.LSN, error)
+	WriteFlush(domain.Namespace) (domain.LSN, error)
+	WriteCollection(domain.Entry) (domain.LSN, error)
+	WriteMSet([]domain.Entry) (domain.LSN, error)
+	WriteMDel([]domain.Key) (domain.LSN, error)
 	Recover(ctx context.Context) error
+	Rotate() (string, error)
+	Sync() error
+	Begin()
+	Commit(id string) (domain.LSN, error)
+	Rollback()
+	WriteSnapshot(string, func() ([]domain.Entry, error)) error
 }
 
+// ErrSnapshotsDisabled is returned by Snapshot when there is no WAL whose
+// position a snapshot could be tied to.
+var ErrSnapshotsDisabled = errors.New("snapshots require the WAL")
+
+// ErrInTransaction is returned by Snapshot and Exec when called from a
+// transaction.
+var ErrInTransaction = errors.New("command is not allowed in a transaction")
+
 // Application defines application-level operations and coordinates between
-// the domain logic and the data persistence layer.
+// the domain logic and the data persistence layer. Keys are taken relative
+// to the namespace of the session in the context and stored scoped to it.
 type Application struct {
 	repo   repository
 	wal    WALogger
 	logger *zap.SugaredLogger
+
+	// writes is held shared by every logged write for the time between
+	// logging and applying it and by Snapshot reading a page, and
+	// exclusively by Snapshot to cut the WAL at a point where no write is
+	// halfway through, by FlushDB and by transactions.
+	writes sync.RWMutex
+	// snapshotting lets one snapshot be taken at a time.
+	snapshotting sync.Mutex
+
+	locks   keyLocks
+	version atomic.Uint64 // last version handed out or restored
+	lsn     atomic.Uint64 // greatest LSN a write was committed at
+
+	// noEviction is set for the application replaying the WAL, see
+	// WithoutEviction.
+	noEviction bool
 }
 
-func NewApplication(ctx context.Context, repo repository, logger *zap.SugaredLogger, wal WALogger) (*Application, error) {
+func NewApplication(ctx context.Context, repo repository, logger *zap.SugaredLogger, wal WALogger, options ...Option) (*Application, error) {
 	if wal != nil {
 		if err := wal.Recover(ctx); err != nil {
 			return nil, err
 		}
 	}
-	return &Application{
+	c := &Application{
 		repo:   repo,
 		wal:    wal,
 		logger: logger,
-	}, nil
+	}
+	for _, opt := range options {
+		opt(c)
+	}
+	return c, nil
 }
 
 func (c *Application) Set(ctx context.Context, key domain.Key, value domain.Value) error {
-	c.logger.Debugw("setting", "key", key, "value", value)
-
-	if c.wal != nil {
-		if err := c.wal.WriteSet(key, value); err != nil {
-			return err
-		}
-	}
-
-	err := c.repo.Set(ctx, key, value)
-	if err != nil {
-		c.logger.Errorf("failed to set key: %s, err: %v", key, err)
-	}
-	return err
+	return c.SetEx(ctx, key, value, time.Time{})
 }
 
 func (c *Application) Get(ctx context.Context, key domain.Key) (*domain.Entry, error) {
 	c.logger.Debugw("getting", "key", key)
-	entry, err := c.repo.Get(ctx, key)
+	entry, err := c.repo.Get(ctx, scoped(ctx, key))
 	if err != nil && !errors.Is(err, domain.ErrKeyNotFound) {
 		c.logger.Errorf("failed to get key: %s, err: %v", key, err)
 	}
+	if entry != nil {
+		e := unscoped(*entry)
+		entry = &e
+	}
 	return entry, err
 }
 
+// Scan returns up to limit entries with start <= key < end in key order.
+func (c *Application) Scan(ctx context.Context, start, end domain.Key, limit int) ([]domain.Entry, error) {
+	c.logger.Debugw("scanning", "start", start, "end", end, "limit", limit)
+	from, to := domain.NamespaceFrom(ctx).Bounds(start, end)
+	stored, err := c.repo.Scan(ctx, from, to, limit)
+	if err != nil {
+		c.logger.Errorf("failed to scan from key: %s, err: %v", start, err)
+		return nil, err
+	}
+	entries := make([]domain.Entry, len(stored))
+	for i, e := range stored {
+		entries[i] = unscoped(e)
+	}
+	return entries, nil
+}
+
 func (c *Application) Delete(ctx context.Context, key domain.Key) error {
 	c.logger.Debugw("deleting", "key", key)
+	key = scoped(ctx, key)
 
+	defer c.guard(ctx, key)()
+
+	var unsynced error
 	if c.wal != nil {
-		if err := c.wal.WriteDel(key); err != nil {
+		lsn, err := c.wal.WriteDel(key)
+		if unsynced, err = logged(err); err != nil {
 			return err
 		}
+		c.committed(ctx, lsn)
 	}
 
 	err := c.repo.Delete(ctx, key)
 	if err != nil && !errors.Is(err, domain.ErrKeyNotFound) {
 		c.logger.Errorf("failed to delete key: %s, err: %v", key, err)
 	}
-	return err
+	return cmp.Or(err, unsynced)
+}
+
+// SetEx stores the value with an absolute expiration deadline. The deadline,
+// not the relative TTL, goes to the WAL so replay never resurrects dead keys.
+func (c *Application) SetEx(ctx context.Context, key domain.Key, value domain.Value, deadline time.Time) error {
+	c.logger.Debugw("setting", "key", key, "value", value, "deadline", deadline)
+	key = scoped(ctx, key)
+
+	if err := c.reclaim(ctx, key); err != nil {
+		return err
+	}
+	defer c.guard(ctx, key)()
+
+	return c.put(ctx, domain.Entry{Key: key, Value: value, ExpiresAt: deadline, Version: c.nextVersion()})
+}
+
+// Restore stores the entry with the version it already carries. This is how
+// replayed WAL records and snapshots get their versions back.
+func (c *Application) Restore(ctx context.Context, entry domain.Entry) error {
+	c.logger.Debugw("restoring", "key", entry.Key, "version", entry.Version)
+	entry.Key = scoped(ctx, entry.Key)
+
+	if err := c.reclaim(ctx, entry.Key); err != nil {
+		return err
+	}
+	defer c.guard(ctx, entry.Key)()
+
+	c.observeVersion(entry.Version)
+	return c.put(ctx, entry)
+}
+
+// CompareAndSet stores the value only if the current version of the key is
+// expected, and fails with domain.ErrVersionMismatch otherwise. A missing key
+// has version zero, so expecting zero means "create".
+func (c *Application) CompareAndSet(ctx context.Context, key domain.Key, expected uint64, value domain.Value) error {
+	c.logger.Debugw("comparing and setting", "key", key, "expected", expected)
+	key = scoped(ctx, key)
+
+	if err := c.reclaim(ctx, key); err != nil {
+		return err
+	}
+	defer c.guard(ctx, key)()
+
+	current, err := c.lookup(ctx, key)
+	if err != nil {
+		return err
+	}
+	var version uint64
+	if current != nil {
+		version = current.Version
+	}
+	if version != expected {
+		return domain.ErrVersionMismatch
+	}
+	return c.put(ctx, domain.Entry{Key: key, Value: value, Version: c.nextVersion()})
+}
+
+// SetNX stores the value only if the key does not exist, and fails with
+// domain.ErrKeyExists otherwise.
+func (c *Application) SetNX(ctx context.Context, key domain.Key, value domain.Value) error {
+	c.logger.Debugw("setting if not exists", "key", key)
+	key = scoped(ctx, key)
+
+	if err := c.reclaim(ctx, key); err != nil {
+		return err
+	}
+	defer c.guard(ctx, key)()
+
+	current, err := c.lookup(ctx, key)
+	if err != nil {
+		return err
+	}
+	if current != nil {
+		return domain.ErrKeyExists
+	}
+	return c.put(ctx, domain.Entry{Key: key, Value: value, Version: c.nextVersion()})
+}
+
+// SetXX stores the value only if the key exists, and fails with
+// domain.ErrKeyNotFound otherwise.
+func (c *Application) SetXX(ctx context.Context, key domain.Key, value domain.Value) error {
+	c.logger.Debugw("setting if exists", "key", key)
+	key = scoped(ctx, key)
+
+	if err := c.reclaim(ctx, key); err != nil {
+		return err
+	}
+	defer c.guard(ctx, key)()
+
+	current, err := c.lookup(ctx, key)
+	if err != nil {
+		return err
+	}
+	if current == nil {
+		return domain.ErrKeyNotFound
+	}
+	return c.put(ctx, domain.Entry{Key: key, Value: value, Version: c.nextVersion()})
+}
+
+func (c *Application) Expire(ctx context.Context, key domain.Key, deadline time.Time) error {
+	c.logger.Debugw("expiring", "key", key, "deadline", deadline)
+	key = scoped(ctx, key)
+
+	defer c.guard(ctx, key)()
+
+	// nothing is logged for a missing key, which the deadline would not change
+	current, err := c.lookup(ctx, key)
+	if err != nil {
+		return err
+	}
+	if current == nil {
+		return domain.ErrKeyNotFound
+	}
+
+	var unsynced error
+	if c.wal != nil {
+		lsn, err := c.wal.WriteExpire(key, deadline)
+		if unsynced, err = logged(err); err != nil {
+			return err
+		}
+		c.committed(ctx, lsn)
+	}
+
+	err = c.repo.Expire(ctx, key, deadline)
+	if err != nil && !errors.Is(err, domain.ErrKeyNotFound) {
+		c.logger.Errorf("failed to expire key: %s, err: %v", key, err)
+	}
+	return cmp.Or(err, unsynced)
+}
+
+func (c *Application) Persist(ctx context.Context, key domain.Key) error {
+	c.logger.Debugw("persisting", "key", key)
+	key = scoped(ctx, key)
+
+	defer c.guard(ctx, key)()
+
+	var unsynced error
+	if c.wal != nil {
+		lsn, err := c.wal.WritePersist(key)
+		if unsynced, err = logged(err); err != nil {
+			return err
+		}
+		c.committed(ctx, lsn)
+	}
+
+	err := c.repo.Persist(ctx, key)
+	if err != nil && !errors.Is(err, domain.ErrKeyNotFound) {
+		c.logger.Errorf("failed to persist key: %s, err: %v", key, err)
+	}
+	return cmp.Or(err, unsynced)
+}
+
+// snapshotPageSize is how many entries Snapshot reads from the storage at a
+// time.
+const snapshotPageSize = 1024
+
+// Snapshot saves the current dataset and lets the WAL drop the segments it
+// covers. Writes wait only while the WAL is cut. The dataset is then streamed
+// to the snapshot a page at a time while they go on, so the snapshot may hold
+// writes logged after its position. Replaying their records over it again
+// ends the same way, as every record logs what its write left, collections
+// included, and stores it whatever the key holds by then. Pages are read
+// while no transaction is being applied, since one may still roll back.
+func (c *Application) Snapshot(ctx context.Context) error {
+	if c.wal == nil {
+		return ErrSnapshotsDisabled
+	}
+	if txFrom(ctx) != nil {
+		return ErrInTransaction
+	}
+
+	c.snapshotting.Lock()
+	defer c.snapshotting.Unlock()
+
+	c.writes.Lock()
+	position, err := c.wal.Rotate()
+	c.writes.Unlock()
+	if err != nil {
+		c.logger.Errorf("failed to take snapshot, err: %v", err)
+		return err
+	}
+
+	var keys int
+	var start domain.Key
+	var done bool
+	next := func() ([]domain.Entry, error) {
+		if done {
+			return nil, nil
+		}
+		c.writes.RLock()
+		page, err := c.repo.Scan(ctx, start, "", snapshotPageSize+1)
+		c.writes.RUnlock()
+		if err != nil {
+			return nil, err
+		}
+		if len(page) > snapshotPageSize {
+			start, page = page[snapshotPageSize].Key, page[:snapshotPageSize]
+		} else {
+			done = true
+		}
+		keys += len(page)
+		return page, nil
+	}
+	if err := c.wal.WriteSnapshot(position, next); err != nil {
+		c.logger.Errorf("failed to write snapshot, err: %v", err)
+		return err
+	}
+	c.logger.Infow("snapshot written", "position", position, "keys", keys)
+	return nil
+}
+
+// StartSnapshots takes a snapshot on every tick of interval until ctx is
+// cancelled or stop is called. Stop returns once a snapshot being taken is
+// done, so the WAL can be closed right after it.
+func (c *Application) StartSnapshots(ctx context.Context, interval time.Duration) (stop func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				// failures are logged by Snapshot and retried on the next tick
+				_ = c.Snapshot(ctx)
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-stopped
+	}
+}
+
+// FlushDB removes every key of the namespace. Writes wait until the
+// namespace is empty, so none of them is lost halfway through the flush.
+func (c *Application) FlushDB(ctx context.Context) error {
+	ns := domain.NamespaceFrom(ctx)
+	c.logger.Debugw("flushing", "namespace", ns)
+
+	// a transaction already holds writes exclusively
+	tx := txFrom(ctx)
+	if tx == nil {
+		c.writes.Lock()
+		defer c.writes.Unlock()
+	}
+
+	var unsynced error
+	if c.wal != nil {
+		lsn, err := c.wal.WriteFlush(ns)
+		if unsynced, err = logged(err); err != nil {
+			return err
+		}
+		c.committed(ctx, lsn)
+	}
+
+	start, end := ns.Bounds("", "")
+	entries, err := c.repo.Scan(ctx, start, end, 0)
+	if err != nil {
+		c.logger.Errorf("failed to flush namespace: %s, err: %v", ns, err)
+		return err
+	}
+	for _, e := range entries {
+		if tx != nil {
+			c.save(ctx, tx, e.Key)
+		}
+		if err := c.repo.Delete(ctx, e.Key); err != nil && !errors.Is(err, domain.ErrKeyNotFound) {
+			c.logger.Errorf("failed to flush namespace: %s, err: %v", ns, err)
+			return err
+		}
+	}
+	return unsynced
+}
+
+// DBSize returns the number of live keys in the namespace.
+func (c *Application) DBSize(ctx context.Context) (int, error) {
+	start, end := domain.NamespaceFrom(ctx).Bounds("", "")
+	entries, err := c.repo.Scan(ctx, start, end, 0)
+	if err != nil {
+		c.logger.Errorf("failed to count keys, err: %v", err)
+	}
+	return len(entries), err
+}
+
+// IncrBy adds delta to the integer stored at the key and returns the result.
+// A missing key counts as zero and an existing expiration is kept. Only the
+// resulting value is logged, so replay does not depend on the previous one.
+func (c *Application) IncrBy(ctx context.Context, key domain.Key, delta int64) (int64, error) {
+	c.logger.Debugw("incrementing", "key", key, "delta", delta)
+	key = scoped(ctx, key)
+
+	if err := c.reclaim(ctx, key); err != nil {
+		return 0, err
+	}
+	defer c.guard(ctx, key)()
+
+	current, err := c.lookup(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+
+	entry := domain.Entry{Key: key}
+	var n int64
+	if current != nil {
+		if current.Type != domain.TypeString {
+			return 0, domain.ErrWrongType
+		}
+		entry.ExpiresAt = current.ExpiresAt
+		if n, err = strconv.ParseInt(current.Value.String(), 10, 64); err != nil {
+			return 0, domain.ErrNotInteger
+		}
+	}
+	if (delta > 0 && n > math.MaxInt64-delta) || (delta < 0 && n < math.MinInt64-delta) {
+		return 0, domain.ErrOverflow
+	}
+
+	n += delta
+	entry.Value = domain.Value(strconv.FormatInt(n, 10))
+	entry.Version = c.nextVersion()
+	if err := c.put(ctx, entry); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// put logs the entry as a single record and stores it.
+// Conditional writes are logged only once resolved, so replay never has to
+// check their conditions again. Caller holds writes and the key lock.
+func (c *Application) put(ctx context.Context, entry domain.Entry) error {
+	var unsynced error
+	if c.wal != nil {
+		lsn, err := c.wal.WriteSet(entry)
+		if unsynced, err = logged(err); err != nil {
+			return err
+		}
+		c.committed(ctx, lsn)
+	}
+
+	err := c.repo.Put(ctx, entry)
+	if err != nil {
+		c.logger.Errorf("failed to set key: %s, err: %v", entry.Key, err)
+		return err
+	}
+	if unsynced != nil {
+		return unsynced
+	}
+	return c.sync(ctx)
+}
+
+// lookup returns the live entry of the key, or nil when there is none.
+func (c *Application) lookup(ctx context.Context, key domain.Key) (*domain.Entry, error) {
+	entry, err := c.repo.Get(ctx, key)
+	if errors.Is(err, domain.ErrKeyNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		c.logger.Errorf("failed to get key: %s, err: %v", key, err)
+	}
+	return entry, err
+}
+
+// scoped returns the stored key of key in the namespace of the session.
+func scoped(ctx context.Context, key domain.Key) domain.Key {
+	return domain.NamespaceFrom(ctx).Key(key)
+}
+
+// unscoped returns the entry with its key relative to its namespace.
+func unscoped(e domain.Entry) domain.Entry {
+	_, e.Key = domain.SplitKey(e.Key)
+	return e
+}
+
+// nextVersion returns a version greater than every version handed out or
+// restored so far. Versions follow the wall clock in nanoseconds, so they
+// keep growing across restarts without being stored separately.
+func (c *Application) nextVersion() uint64 {
+	for {
+		last := c.version.Load()
+		next := max(last+1, uint64(time.Now().UnixNano()))
+		if c.version.CompareAndSwap(last, next) {
+			return next
+		}
+	}
+}
+
+// committed records that a write of the session in ctx was logged at lsn.
+// Writes logged inside a transaction get no LSN of their own, only the
+// transaction as a whole when it commits.
+func (c *Application) committed(ctx context.Context, lsn domain.LSN) {
+	if lsn == 0 {
+		return
+	}
+	if s := domain.SessionFrom(ctx); s != nil && lsn > s.LSN {
+		s.LSN = lsn
+	}
+	for {
+		last := c.lsn.Load()
+		if uint64(lsn) <= last || c.lsn.CompareAndSwap(last, uint64(lsn)) {
+			return
+		}
+	}
+}
+
+// sync makes the write logged with ctx durable when ctx asks for it,
+// whatever the fsync policy. Inside a transaction, it is done on commit.
+// The write is logged already and stays applied, as replay would apply it if
+// it reached the disk anyway, so a failed sync is reported as
+// domain.ErrDurabilityUnknown.
+func (c *Application) sync(ctx context.Context) error {
+	if c.wal == nil || !domain.SyncRequested(ctx) {
+		return nil
+	}
+	if tx := txFrom(ctx); tx != nil {
+		tx.sync = true
+		return nil
+	}
+	return c.syncLogged()
+}
+
+func (c *Application) syncLogged() error {
+	if err := c.wal.Sync(); err != nil {
+		c.logger.Errorw("failed to sync WAL", "error", err)
+		return fmt.Errorf("%w: %w", domain.ErrDurabilityUnknown, err)
+	}
+	return nil
+}
+
+// logged splits the outcome of logging a write into the failure to sync its
+// record and any other error. A record that could not be synced is logged
+// all the same and replay would apply it, so the caller applies the write
+// too and returns the sync failure afterwards.
+func logged(err error) (unsynced, failed error) {
+	if errors.Is(err, domain.ErrDurabilityUnknown) {
+		return err, nil
+	}
+	return nil, err
+}
+
+// LSN returns the greatest LSN a write was committed at since the start,
+// zero when nothing was written or there is no WAL.
+func (c *Application) LSN() domain.LSN {
+	return domain.LSN(c.lsn.Load())
+}
+
+// observeVersion makes sure later versions are greater than v.
+func (c *Application) observeVersion(v uint64) {
+	for {
+		last := c.version.Load()
+		if v <= last || c.version.CompareAndSwap(last, v) {
+			return
+		}
+	}
+}
+
+// reclaim frees memory before a write that may grow the dataset. Evictions are
+// logged as deletes ahead of the write itself, so replay ends up with the same
+// dataset without having to repeat the eviction decisions. A victim may be any
+// key, so evictions hold writes exclusively: no write of a victim can then be
+// logged or applied between its removal and its delete record. Caller holds
+// no key lock.
+func (c *Application) reclaim(ctx context.Context, key domain.Key) error {
+	if c.noEviction || !c.repo.OverLimit(ctx, key) {
+		return nil
+	}
+	if txFrom(ctx) == nil {
+		c.writes.Lock()
+		defer c.writes.Unlock()
+	}
+
+	evicted, err := c.repo.Reclaim(ctx, key)
+	if tx := txFrom(ctx); tx != nil {
+		tx.evicted = append(tx.evicted, evicted...)
+	}
+	var unsynced error
+	for _, k := range evicted {
+		c.logger.Infow("evicted key", "key", k)
+		if c.wal != nil {
+			lsn, err := c.wal.WriteDel(k)
+			if errors.Is(err, domain.ErrDurabilityUnknown) {
+				unsynced = err
+			} else if err != nil {
+				return err
+			}
+			c.committed(ctx, lsn)
+		}
+	}
+	if err != nil && !errors.Is(err, domain.ErrOutOfMemory) {
+		c.logger.Errorf("failed to reclaim memory for key: %s, err: %v", key, err)
+	}
+	return cmp.Or(err, unsynced)
 }
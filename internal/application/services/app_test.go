@@ -3,7 +3,9 @@ package services
 import (
 	"context"
 	"errors"
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/rdimidov/kvstore/internal/domain"
 	"github.com/stretchr/testify/assert"
@@ -11,6 +13,13 @@ import (
 	"go.uber.org/zap"
 )
 
+// versioned matches an entry with the given fields and any assigned version.
+func versioned(key domain.Key, value domain.Value, deadline time.Time) any {
+	return mock.MatchedBy(func(e domain.Entry) bool {
+		return e.Key == key && e.Value == value && e.ExpiresAt.Equal(deadline) && e.Version > 0
+	})
+}
+
 func TestCompute_Set(t *testing.T) {
 	t.Parallel()
 
@@ -29,14 +38,16 @@ func TestCompute_Set(t *testing.T) {
 			name: "successfully sets key",
 			args: args{key: "foo", value: "bar"},
 			mockSetup: func(r *mockrepository) {
-				r.On("Set", mock.Anything, domain.Key("foo"), domain.Value("bar")).Return(nil)
+				r.On("OverLimit", mock.Anything, domain.Key("foo")).Return(false)
+				r.On("Put", mock.Anything, versioned("foo", "bar", time.Time{})).Return(nil)
 			},
 		},
 		{
 			name: "repo failure",
 			args: args{key: "foo", value: "bar"},
 			mockSetup: func(r *mockrepository) {
-				r.On("Set", mock.Anything, domain.Key("foo"), domain.Value("bar")).Return(errors.New("oops.."))
+				r.On("OverLimit", mock.Anything, domain.Key("foo")).Return(false)
+				r.On("Put", mock.Anything, versioned("foo", "bar", time.Time{})).Return(errors.New("oops.."))
 			},
 			expectError: true,
 		},
@@ -158,3 +169,630 @@ func TestCompute_Delete(t *testing.T) {
 		})
 	}
 }
+
+func TestCompute_Expirations(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	deadline := time.UnixMilli(1700000000000)
+
+	mockRepo := newMockrepository(t)
+	mockWAL := NewMockWALogger(t)
+	mockWAL.On("Recover", ctx).Return(nil)
+
+	mockRepo.On("OverLimit", ctx, domain.Key("foo")).Return(false).Once()
+	mockWAL.On("WriteSet", versioned("foo", "bar", deadline)).Return(domain.LSN(1), nil).Once()
+	mockRepo.On("Put", ctx, versioned("foo", "bar", deadline)).Return(nil).Once()
+
+	mockRepo.On("Get", ctx, domain.Key("foo")).Return(&domain.Entry{Key: "foo", Value: "bar"}, nil).Once()
+	mockWAL.On("WriteExpire", domain.Key("foo"), deadline).Return(domain.LSN(2), nil).Once()
+	mockRepo.On("Expire", ctx, domain.Key("foo"), deadline).Return(nil).Once()
+
+	// a missing key is not logged
+	mockRepo.On("Get", ctx, domain.Key("bar")).Return(nil, domain.ErrKeyNotFound).Once()
+
+	mockWAL.On("WritePersist", domain.Key("foo")).Return(domain.LSN(3), nil).Once()
+	mockRepo.On("Persist", ctx, domain.Key("foo")).Return(nil).Once()
+
+	app, err := NewApplication(ctx, mockRepo, zap.NewNop().Sugar(), mockWAL)
+	assert.NoError(t, err)
+
+	assert.NoError(t, app.SetEx(ctx, "foo", "bar", deadline))
+	assert.NoError(t, app.Expire(ctx, "foo", deadline))
+	assert.ErrorIs(t, app.Expire(ctx, "bar", deadline), domain.ErrKeyNotFound)
+	assert.NoError(t, app.Persist(ctx, "foo"))
+	assert.Equal(t, domain.LSN(3), app.LSN())
+}
+
+func TestCompute_WALFailureSkipsRepo(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	deadline := time.UnixMilli(1700000000000)
+
+	mockRepo := newMockrepository(t)
+	mockWAL := NewMockWALogger(t)
+	mockWAL.On("Recover", ctx).Return(nil)
+	mockRepo.On("OverLimit", ctx, domain.Key("foo")).Return(false)
+	mockWAL.On("WriteSet", versioned("foo", "bar", deadline)).Return(domain.LSN(0), errors.New("disk full"))
+
+	app, err := NewApplication(ctx, mockRepo, zap.NewNop().Sugar(), mockWAL)
+	assert.NoError(t, err)
+
+	assert.EqualError(t, app.SetEx(ctx, "foo", "bar", deadline), "disk full")
+	mockRepo.AssertNotCalled(t, "Put", mock.Anything, mock.Anything)
+}
+
+func TestCompute_SyncedWrites(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	newApp := func(t *testing.T) (*Application, *mockrepository, *MockWALogger) {
+		mockRepo := newMockrepository(t)
+		mockWAL := NewMockWALogger(t)
+		mockWAL.On("Recover", ctx).Return(nil)
+		mockRepo.On("OverLimit", mock.Anything, domain.Key("foo")).Return(false)
+		mockWAL.On("WriteSet", versioned("foo", "bar", time.Time{})).Return(domain.LSN(1), nil).Once()
+
+		app, err := NewApplication(ctx, mockRepo, zap.NewNop().Sugar(), mockWAL)
+		assert.NoError(t, err)
+		return app, mockRepo, mockWAL
+	}
+
+	t.Run("syncs before acknowledging", func(t *testing.T) {
+		app, mockRepo, mockWAL := newApp(t)
+		mockWAL.On("Sync").Return(nil).Once()
+		mockRepo.On("Put", mock.Anything, versioned("foo", "bar", time.Time{})).Return(nil).Once()
+
+		assert.NoError(t, app.Set(domain.WithSync(ctx), "foo", "bar"))
+	})
+
+	t.Run("sync failure keeps the logged write", func(t *testing.T) {
+		app, mockRepo, mockWAL := newApp(t)
+		mockRepo.On("Put", mock.Anything, versioned("foo", "bar", time.Time{})).Return(nil).Once()
+		mockWAL.On("Sync").Return(errors.New("disk gone")).Once()
+
+		err := app.Set(domain.WithSync(ctx), "foo", "bar")
+		assert.ErrorIs(t, err, domain.ErrDurabilityUnknown)
+		assert.EqualError(t, err, "write logged, durability unknown: disk gone")
+		assert.Equal(t, domain.LSN(1), app.LSN())
+	})
+
+	t.Run("unsynced record keeps the write", func(t *testing.T) {
+		mockRepo := newMockrepository(t)
+		mockWAL := NewMockWALogger(t)
+		mockWAL.On("Recover", ctx).Return(nil)
+		mockRepo.On("OverLimit", mock.Anything, domain.Key("foo")).Return(false)
+		unsynced := fmt.Errorf("%w: disk gone", domain.ErrDurabilityUnknown)
+		mockWAL.On("WriteSet", versioned("foo", "bar", time.Time{})).Return(domain.LSN(1), unsynced).Once()
+		// replay would apply the record, so the write is applied too
+		mockRepo.On("Put", mock.Anything, versioned("foo", "bar", time.Time{})).Return(nil).Once()
+
+		app, err := NewApplication(ctx, mockRepo, zap.NewNop().Sugar(), mockWAL)
+		assert.NoError(t, err)
+		assert.ErrorIs(t, app.Set(ctx, "foo", "bar"), domain.ErrDurabilityUnknown)
+		assert.Equal(t, domain.LSN(1), app.LSN())
+	})
+
+	t.Run("does not sync unless asked", func(t *testing.T) {
+		app, mockRepo, mockWAL := newApp(t)
+		mockRepo.On("Put", mock.Anything, versioned("foo", "bar", time.Time{})).Return(nil).Once()
+
+		assert.NoError(t, app.Set(ctx, "foo", "bar"))
+		mockWAL.AssertNotCalled(t, "Sync")
+	})
+}
+
+func TestCompute_Scan(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	entries := []domain.Entry{{Key: "users/1", Value: "a"}, {Key: "users/2", Value: "b"}}
+
+	mockRepo := newMockrepository(t)
+	mockRepo.On("Scan", ctx, domain.Key("users/"), domain.Key("users0"), 10).Return(entries, nil).Once()
+
+	app, err := NewApplication(ctx, mockRepo, zap.NewNop().Sugar(), nil)
+	assert.NoError(t, err)
+
+	got, err := app.Scan(ctx, "users/", "users0", 10)
+	assert.NoError(t, err)
+	assert.Equal(t, entries, got)
+}
+
+func TestCompute_SetLogsEvictionsFirst(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	mockRepo := newMockrepository(t)
+	mockWAL := NewMockWALogger(t)
+	mockWAL.On("Recover", ctx).Return(nil)
+
+	var (
+		app   *Application
+		order []string
+	)
+	mockRepo.On("OverLimit", ctx, domain.Key("foo")).Return(true).Once()
+	mockRepo.On("Reclaim", ctx, domain.Key("foo")).Return([]domain.Key{"old1", "old2"}, nil).Once()
+	mockWAL.On("WriteDel", mock.Anything).Run(func(args mock.Arguments) {
+		// no write of a victim may come between its removal and its delete
+		assert.False(t, app.writes.TryRLock(), "eviction must hold writes exclusively")
+		order = append(order, "DEL "+args.Get(0).(domain.Key).String())
+	}).Return(domain.LSN(1), nil).Twice()
+	mockWAL.On("WriteSet", versioned("foo", "bar", time.Time{})).Run(func(mock.Arguments) {
+		order = append(order, "SET foo")
+	}).Return(domain.LSN(1), nil).Once()
+	mockRepo.On("Put", ctx, versioned("foo", "bar", time.Time{})).Return(nil).Once()
+
+	var err error
+	app, err = NewApplication(ctx, mockRepo, zap.NewNop().Sugar(), mockWAL)
+	assert.NoError(t, err)
+
+	assert.NoError(t, app.Set(ctx, "foo", "bar"))
+	assert.Equal(t, []string{"DEL old1", "DEL old2", "SET foo"}, order)
+}
+
+func TestCompute_SetOutOfMemory(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	mockRepo := newMockrepository(t)
+	mockWAL := NewMockWALogger(t)
+	mockWAL.On("Recover", ctx).Return(nil)
+	mockRepo.On("OverLimit", ctx, domain.Key("foo")).Return(true).Once()
+	mockRepo.On("Reclaim", ctx, domain.Key("foo")).Return(nil, domain.ErrOutOfMemory).Once()
+
+	app, err := NewApplication(ctx, mockRepo, zap.NewNop().Sugar(), mockWAL)
+	assert.NoError(t, err)
+
+	assert.ErrorIs(t, app.Set(ctx, "foo", "bar"), domain.ErrOutOfMemory)
+	mockWAL.AssertNotCalled(t, "WriteSet", mock.Anything)
+}
+
+func TestCompute_ReplayWithoutEviction(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	restored := domain.Entry{Key: "foo", Value: "bar", Version: 7}
+
+	// no Reclaim: replay stores what it is given, evictions being logged
+	mockRepo := newMockrepository(t)
+	mockRepo.On("Put", ctx, restored).Return(nil).Once()
+
+	app, err := NewApplication(ctx, mockRepo, zap.NewNop().Sugar(), nil, WithoutEviction())
+	assert.NoError(t, err)
+	assert.NoError(t, app.Restore(ctx, restored))
+}
+
+func TestCompute_Snapshot(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	entries := make([]domain.Entry, snapshotPageSize+1)
+	for i := range entries {
+		entries[i] = domain.Entry{Key: domain.Key(fmt.Sprintf("k%05d", i)), Value: "v"}
+	}
+	last := entries[snapshotPageSize]
+
+	mockRepo := newMockrepository(t)
+	mockWAL := NewMockWALogger(t)
+	mockWAL.On("Recover", ctx).Return(nil)
+	mockWAL.On("Rotate").Return("20240101T000000.000000000.wal", nil).Once()
+	// the dataset is read a page at a time, each starting at the key the
+	// previous one stopped before
+	mockRepo.On("Scan", ctx, domain.Key(""), domain.Key(""), snapshotPageSize+1).Return(entries, nil).Once()
+	mockRepo.On("Scan", ctx, last.Key, domain.Key(""), snapshotPageSize+1).Return([]domain.Entry{last}, nil).Once()
+	var written []domain.Entry
+	mockWAL.On("WriteSnapshot", "20240101T000000.000000000.wal", mock.Anything).Return(func(_ string, next func() ([]domain.Entry, error)) error {
+		for {
+			page, err := next()
+			if err != nil || len(page) == 0 {
+				return err
+			}
+			written = append(written, page...)
+		}
+	}).Once()
+
+	app, err := NewApplication(ctx, mockRepo, zap.NewNop().Sugar(), mockWAL)
+	assert.NoError(t, err)
+	assert.NoError(t, app.Snapshot(ctx))
+	assert.Equal(t, entries, written)
+
+	withoutWAL, err := NewApplication(ctx, mockRepo, zap.NewNop().Sugar(), nil)
+	assert.NoError(t, err)
+	assert.ErrorIs(t, withoutWAL.Snapshot(ctx), ErrSnapshotsDisabled)
+}
+
+func TestCompute_ConditionalWrites(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	current := &domain.Entry{Key: "cfg", Value: "old", Version: 5}
+
+	mockRepo := newMockrepository(t)
+	mockWAL := NewMockWALogger(t)
+	mockWAL.On("Recover", ctx).Return(nil)
+	mockRepo.On("Get", ctx, domain.Key("cfg")).Return(current, nil)
+	mockRepo.On("Get", ctx, domain.Key("new")).Return(nil, domain.ErrKeyNotFound)
+	mockRepo.On("OverLimit", ctx, mock.Anything).Return(false)
+
+	var logged []domain.Entry
+	mockWAL.On("WriteSet", mock.Anything).Run(func(args mock.Arguments) {
+		logged = append(logged, args.Get(0).(domain.Entry))
+	}).Return(domain.LSN(1), nil)
+	mockRepo.On("Put", ctx, mock.Anything).Return(nil)
+
+	app, err := NewApplication(ctx, mockRepo, zap.NewNop().Sugar(), mockWAL)
+	assert.NoError(t, err)
+
+	assert.ErrorIs(t, app.CompareAndSet(ctx, "cfg", 4, "v"), domain.ErrVersionMismatch)
+	assert.ErrorIs(t, app.CompareAndSet(ctx, "new", 5, "v"), domain.ErrVersionMismatch)
+	assert.ErrorIs(t, app.SetNX(ctx, "cfg", "v"), domain.ErrKeyExists)
+	assert.ErrorIs(t, app.SetXX(ctx, "new", "v"), domain.ErrKeyNotFound)
+	assert.Empty(t, logged, "failed conditions must not be logged")
+
+	assert.NoError(t, app.CompareAndSet(ctx, "cfg", 5, "cas"))
+	assert.NoError(t, app.CompareAndSet(ctx, "new", 0, "created"))
+	assert.NoError(t, app.SetNX(ctx, "new", "nx"))
+	assert.NoError(t, app.SetXX(ctx, "cfg", "xx"))
+
+	if assert.Len(t, logged, 4) {
+		for i := 1; i < len(logged); i++ {
+			assert.Greater(t, logged[i].Version, logged[i-1].Version)
+		}
+	}
+}
+
+func TestCompute_RestoreKeepsVersionsIncreasing(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	future := uint64(time.Now().Add(time.Hour).UnixNano())
+	restored := domain.Entry{Key: "foo", Value: "bar", Version: future}
+
+	mockRepo := newMockrepository(t)
+	mockRepo.On("OverLimit", ctx, mock.Anything).Return(false)
+	mockRepo.On("Put", ctx, restored).Return(nil).Once()
+	mockRepo.On("Put", ctx, mock.MatchedBy(func(e domain.Entry) bool {
+		return e.Version > future
+	})).Return(nil).Once()
+
+	app, err := NewApplication(ctx, mockRepo, zap.NewNop().Sugar(), nil)
+	assert.NoError(t, err)
+
+	assert.NoError(t, app.Restore(ctx, restored))
+	assert.NoError(t, app.Set(ctx, "foo", "baz"))
+}
+
+func TestCompute_IncrBy(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	deadline := time.UnixMilli(1700000000000)
+
+	mockRepo := newMockrepository(t)
+	mockWAL := NewMockWALogger(t)
+	mockWAL.On("Recover", ctx).Return(nil)
+	mockRepo.On("OverLimit", ctx, mock.Anything).Return(false)
+	mockRepo.On("Get", ctx, domain.Key("hits")).Return(&domain.Entry{Key: "hits", Value: "41", ExpiresAt: deadline}, nil)
+	mockRepo.On("Get", ctx, domain.Key("new")).Return(nil, domain.ErrKeyNotFound)
+	mockRepo.On("Get", ctx, domain.Key("name")).Return(&domain.Entry{Key: "name", Value: "bob"}, nil)
+	mockRepo.On("Get", ctx, domain.Key("max")).Return(&domain.Entry{Key: "max", Value: "9223372036854775807"}, nil)
+
+	mockWAL.On("WriteSet", versioned("hits", "42", deadline)).Return(domain.LSN(1), nil).Once()
+	mockRepo.On("Put", ctx, versioned("hits", "42", deadline)).Return(nil).Once()
+	mockWAL.On("WriteSet", versioned("new", "-3", time.Time{})).Return(domain.LSN(1), nil).Once()
+	mockRepo.On("Put", ctx, versioned("new", "-3", time.Time{})).Return(nil).Once()
+
+	app, err := NewApplication(ctx, mockRepo, zap.NewNop().Sugar(), mockWAL)
+	assert.NoError(t, err)
+
+	n, err := app.IncrBy(ctx, "hits", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(42), n)
+
+	n, err = app.IncrBy(ctx, "new", -3)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(-3), n)
+
+	_, err = app.IncrBy(ctx, "name", 1)
+	assert.ErrorIs(t, err, domain.ErrNotInteger)
+
+	_, err = app.IncrBy(ctx, "max", 1)
+	assert.ErrorIs(t, err, domain.ErrOverflow)
+}
+
+func TestCompute_Namespaces(t *testing.T) {
+	t.Parallel()
+
+	ctx := domain.WithSession(context.Background(), &domain.Session{Namespace: "team"})
+
+	mockRepo := newMockrepository(t)
+	mockWAL := NewMockWALogger(t)
+	mockWAL.On("Recover", mock.Anything).Return(nil)
+
+	mockRepo.On("OverLimit", ctx, domain.Key("\x00team\x00foo")).Return(false).Once()
+	mockWAL.On("WriteSet", versioned("\x00team\x00foo", "bar", time.Time{})).Return(domain.LSN(1), nil).Once()
+	mockRepo.On("Put", ctx, versioned("\x00team\x00foo", "bar", time.Time{})).Return(nil).Once()
+	mockRepo.On("Get", ctx, domain.Key("\x00team\x00foo")).Return(&domain.Entry{Key: "\x00team\x00foo", Value: "bar"}, nil).Once()
+	mockRepo.On("Scan", ctx, domain.Key("\x00team\x00"), domain.Key("\x00team\x01"), 0).
+		Return([]domain.Entry{{Key: "\x00team\x00foo", Value: "bar"}}, nil).Times(3)
+	mockWAL.On("WriteFlush", domain.Namespace("team")).Return(domain.LSN(1), nil).Once()
+	mockRepo.On("Delete", ctx, domain.Key("\x00team\x00foo")).Return(nil).Once()
+
+	app, err := NewApplication(ctx, mockRepo, zap.NewNop().Sugar(), mockWAL)
+	assert.NoError(t, err)
+
+	assert.NoError(t, app.Set(ctx, "foo", "bar"))
+
+	entry, err := app.Get(ctx, "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, domain.Key("foo"), entry.Key)
+
+	entries, err := app.Scan(ctx, "", "", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []domain.Entry{{Key: "foo", Value: "bar"}}, entries)
+
+	size, err := app.DBSize(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, size)
+
+	assert.NoError(t, app.FlushDB(ctx))
+}
+
+func TestCompute_Collections(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	list := &domain.Entry{Key: "l", Type: domain.TypeList, Items: []domain.Value{"a"}, Version: 1}
+
+	mockRepo := newMockrepository(t)
+	mockWAL := NewMockWALogger(t)
+	mockWAL.On("Recover", ctx).Return(nil)
+
+	// pushing to a missing key creates the list
+	mockRepo.On("OverLimit", ctx, domain.Key("h")).Return(false).Once()
+	mockRepo.On("Get", ctx, domain.Key("h")).Return(nil, domain.ErrKeyNotFound).Once()
+	// collections are logged as they end up
+	mockWAL.On("WriteCollection", domain.Entry{Key: "h", Type: domain.TypeList, Items: []domain.Value{"x", "y"}}).Return(domain.LSN(1), nil).Once()
+	mockRepo.On("Put", ctx, mock.MatchedBy(func(e domain.Entry) bool {
+		return e.Type == domain.TypeList && assert.ObjectsAreEqual([]domain.Value{"x", "y"}, e.Items) && e.Version > 0
+	})).Return(nil).Once()
+
+	// popping the last element removes the key
+	mockRepo.On("Get", ctx, domain.Key("l")).Return(list, nil).Once()
+	mockWAL.On("WriteCollection", mock.MatchedBy(func(e domain.Entry) bool {
+		return e.Key == "l" && len(e.Items) == 0
+	})).Return(domain.LSN(1), nil).Once()
+	mockRepo.On("Delete", ctx, domain.Key("l")).Return(nil).Once()
+
+	// collection commands refuse other types
+	mockRepo.On("Get", ctx, domain.Key("s")).Return(&domain.Entry{Key: "s", Value: "1"}, nil).Twice()
+	mockRepo.On("OverLimit", ctx, domain.Key("s")).Return(false).Once()
+
+	app, err := NewApplication(ctx, mockRepo, zap.NewNop().Sugar(), mockWAL)
+	assert.NoError(t, err)
+
+	n, err := app.Push(ctx, "h", []domain.Value{"x", "y"}, false)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	v, err := app.Pop(ctx, "l", true)
+	assert.NoError(t, err)
+	assert.Equal(t, domain.Value("a"), v)
+
+	_, err = app.SAdd(ctx, "s", []domain.Value{"m"})
+	assert.ErrorIs(t, err, domain.ErrWrongType)
+	_, err = app.HGet(ctx, "s", "f")
+	assert.ErrorIs(t, err, domain.ErrWrongType)
+}
+
+func TestCompute_Exec(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	old := &domain.Entry{Key: "a", Value: "1", Version: 1}
+
+	t.Run("commits as one WAL record", func(t *testing.T) {
+		mockRepo := newMockrepository(t)
+		mockWAL := NewMockWALogger(t)
+		mockWAL.On("Recover", ctx).Return(nil)
+		mockWAL.On("Begin").Return().Once()
+		mockRepo.On("Get", mock.Anything, domain.Key("a")).Return(old, nil).Once()
+		mockRepo.On("OverLimit", mock.Anything, domain.Key("a")).Return(false).Once()
+		// records of a transaction get their LSN when it commits
+		mockWAL.On("WriteSet", versioned("a", "2", time.Time{})).Return(domain.LSN(0), nil).Once()
+		mockRepo.On("Put", mock.Anything, versioned("a", "2", time.Time{})).Return(nil).Once()
+		mockWAL.On("Commit", mock.AnythingOfType("string")).Return(domain.LSN(7), nil).Once()
+
+		app, err := NewApplication(ctx, mockRepo, zap.NewNop().Sugar(), mockWAL)
+		assert.NoError(t, err)
+		session := &domain.Session{}
+		assert.NoError(t, app.Exec(domain.WithSession(ctx, session), func(ctx context.Context) error {
+			return app.Set(ctx, "a", "2")
+		}))
+		assert.Equal(t, domain.LSN(7), session.LSN)
+		assert.Equal(t, domain.LSN(7), app.LSN())
+	})
+
+	t.Run("syncs on commit when a command asked", func(t *testing.T) {
+		mockRepo := newMockrepository(t)
+		mockWAL := NewMockWALogger(t)
+		mockWAL.On("Recover", ctx).Return(nil)
+		mockWAL.On("Begin").Return().Once()
+		mockRepo.On("Get", mock.Anything, domain.Key("a")).Return(old, nil).Once()
+		mockRepo.On("OverLimit", mock.Anything, domain.Key("a")).Return(false).Once()
+		mockWAL.On("WriteSet", versioned("a", "2", time.Time{})).Return(domain.LSN(0), nil).Once()
+		mockRepo.On("Put", mock.Anything, versioned("a", "2", time.Time{})).Return(nil).Once()
+		commit := mockWAL.On("Commit", mock.AnythingOfType("string")).Return(domain.LSN(7), nil).Once()
+		mockWAL.On("Sync").Return(nil).Once().NotBefore(commit)
+
+		app, err := NewApplication(ctx, mockRepo, zap.NewNop().Sugar(), mockWAL)
+		assert.NoError(t, err)
+		assert.NoError(t, app.Exec(ctx, func(ctx context.Context) error {
+			return app.Set(domain.WithSync(ctx), "a", "2")
+		}))
+	})
+
+	t.Run("sync failure keeps the committed transaction", func(t *testing.T) {
+		mockRepo := newMockrepository(t)
+		mockWAL := NewMockWALogger(t)
+		mockWAL.On("Recover", ctx).Return(nil)
+		mockWAL.On("Begin").Return().Once()
+		mockRepo.On("Get", mock.Anything, domain.Key("a")).Return(old, nil).Once()
+		mockRepo.On("OverLimit", mock.Anything, domain.Key("a")).Return(false).Once()
+		mockWAL.On("WriteSet", versioned("a", "2", time.Time{})).Return(domain.LSN(0), nil).Once()
+		mockRepo.On("Put", mock.Anything, versioned("a", "2", time.Time{})).Return(nil).Once()
+		mockWAL.On("Commit", mock.AnythingOfType("string")).Return(domain.LSN(7), nil).Once()
+		mockWAL.On("Sync").Return(errors.New("disk gone")).Once()
+
+		app, err := NewApplication(ctx, mockRepo, zap.NewNop().Sugar(), mockWAL)
+		assert.NoError(t, err)
+		err = app.Exec(ctx, func(ctx context.Context) error {
+			return app.Set(domain.WithSync(ctx), "a", "2")
+		})
+		assert.ErrorIs(t, err, domain.ErrDurabilityUnknown)
+		// no rollback: the old value is not put back
+		assert.Equal(t, domain.LSN(7), app.LSN())
+	})
+
+	t.Run("unsynced commit keeps the transaction", func(t *testing.T) {
+		mockRepo := newMockrepository(t)
+		mockWAL := NewMockWALogger(t)
+		mockWAL.On("Recover", ctx).Return(nil)
+		mockWAL.On("Begin").Return().Once()
+		mockRepo.On("Get", mock.Anything, domain.Key("a")).Return(old, nil).Once()
+		mockRepo.On("OverLimit", mock.Anything, domain.Key("a")).Return(false).Once()
+		mockWAL.On("WriteSet", versioned("a", "2", time.Time{})).Return(domain.LSN(0), nil).Once()
+		mockRepo.On("Put", mock.Anything, versioned("a", "2", time.Time{})).Return(nil).Once()
+		unsynced := fmt.Errorf("%w: disk gone", domain.ErrDurabilityUnknown)
+		mockWAL.On("Commit", mock.AnythingOfType("string")).Return(domain.LSN(7), unsynced).Once()
+
+		app, err := NewApplication(ctx, mockRepo, zap.NewNop().Sugar(), mockWAL)
+		assert.NoError(t, err)
+		err = app.Exec(ctx, func(ctx context.Context) error {
+			return app.Set(ctx, "a", "2")
+		})
+		assert.ErrorIs(t, err, domain.ErrDurabilityUnknown)
+		// no rollback: the record is in the log and replay would apply it
+		assert.Equal(t, domain.LSN(7), app.LSN())
+	})
+
+	t.Run("rolls back on failure", func(t *testing.T) {
+		mockRepo := newMockrepository(t)
+		mockWAL := NewMockWALogger(t)
+		mockWAL.On("Recover", ctx).Return(nil)
+		mockWAL.On("Begin").Return().Once()
+		mockRepo.On("Get", mock.Anything, domain.Key("a")).Return(old, nil).Once()
+		mockRepo.On("Get", mock.Anything, domain.Key("b")).Return(nil, domain.ErrKeyNotFound).Once()
+		mockRepo.On("OverLimit", mock.Anything, mock.Anything).Return(false).Twice()
+		mockWAL.On("WriteSet", mock.Anything).Return(domain.LSN(1), nil).Twice()
+		mockRepo.On("Put", mock.Anything, mock.Anything).Return(nil).Twice()
+		mockWAL.On("Rollback").Return().Once()
+		// the keys get back what they held before
+		mockRepo.On("Put", mock.Anything, *old).Return(nil).Once()
+		mockRepo.On("Delete", mock.Anything, domain.Key("b")).Return(nil).Once()
+
+		app, err := NewApplication(ctx, mockRepo, zap.NewNop().Sugar(), mockWAL)
+		assert.NoError(t, err)
+		failure := errors.New("queued command failed")
+		err = app.Exec(ctx, func(ctx context.Context) error {
+			assert.NoError(t, app.Set(ctx, "a", "2"))
+			assert.NoError(t, app.Set(ctx, "b", "3"))
+			return failure
+		})
+		assert.ErrorIs(t, err, failure)
+		mockWAL.AssertNotCalled(t, "Commit", mock.Anything)
+	})
+
+	t.Run("aborts when a watched key changed", func(t *testing.T) {
+		mockRepo := newMockrepository(t)
+		mockRepo.On("Get", mock.Anything, domain.Key("a")).Return(old, nil).Once()
+		mockRepo.On("Get", mock.Anything, domain.Key("a")).Return(&domain.Entry{Key: "a", Value: "9", Version: 2}, nil).Once()
+
+		app, err := NewApplication(ctx, mockRepo, zap.NewNop().Sugar(), nil)
+		assert.NoError(t, err)
+
+		session := &domain.Session{}
+		ctx := domain.WithSession(ctx, session)
+		assert.NoError(t, app.Watch(ctx, []domain.Key{"a"}))
+		err = app.Exec(ctx, func(context.Context) error {
+			t.Fatal("the transaction must not run")
+			return nil
+		})
+		assert.ErrorIs(t, err, domain.ErrWatchedKeyChanged)
+		assert.Nil(t, session.Watched)
+	})
+}
+
+func TestCompute_Batch(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	t.Run("MSET logs one record", func(t *testing.T) {
+		mockRepo := newMockrepository(t)
+		mockWAL := NewMockWALogger(t)
+		mockWAL.On("Recover", ctx).Return(nil)
+		mockRepo.On("OverLimit", mock.Anything, mock.Anything).Return(false).Twice()
+		mockWAL.On("WriteMSet", mock.MatchedBy(func(entries []domain.Entry) bool {
+			return len(entries) == 2 && entries[0].Key == "\x00team\x00a" && entries[1].Key == "\x00team\x00b" &&
+				entries[0].Version > 0 && entries[1].Version > entries[0].Version
+		})).Return(domain.LSN(1), nil).Once()
+		mockRepo.On("Put", mock.Anything, versioned("\x00team\x00a", "1", time.Time{})).Return(nil).Once()
+		mockRepo.On("Put", mock.Anything, versioned("\x00team\x00b", "2", time.Time{})).Return(nil).Once()
+
+		app, err := NewApplication(ctx, mockRepo, zap.NewNop().Sugar(), mockWAL)
+		assert.NoError(t, err)
+		ctx := domain.WithSession(ctx, &domain.Session{Namespace: "team"})
+		assert.NoError(t, app.MSet(ctx, []domain.Entry{
+			domain.NewEntryFromKV("a", "1"),
+			domain.NewEntryFromKV("b", "2"),
+		}))
+	})
+
+	t.Run("MSET writes nothing when memory is full", func(t *testing.T) {
+		mockRepo := newMockrepository(t)
+		mockWAL := NewMockWALogger(t)
+		mockWAL.On("Recover", ctx).Return(nil)
+		mockRepo.On("OverLimit", ctx, domain.Key("a")).Return(true).Once()
+		mockRepo.On("Reclaim", ctx, domain.Key("a")).Return(nil, domain.ErrOutOfMemory).Once()
+
+		app, err := NewApplication(ctx, mockRepo, zap.NewNop().Sugar(), mockWAL)
+		assert.NoError(t, err)
+		err = app.MSet(ctx, []domain.Entry{domain.NewEntryFromKV("a", "1"), domain.NewEntryFromKV("b", "2")})
+		assert.ErrorIs(t, err, domain.ErrOutOfMemory)
+		mockWAL.AssertNotCalled(t, "WriteMSet", mock.Anything)
+		mockRepo.AssertNotCalled(t, "Put", mock.Anything, mock.Anything)
+	})
+
+	t.Run("MGET marks missing keys with nil", func(t *testing.T) {
+		mockRepo := newMockrepository(t)
+		mockRepo.On("Get", ctx, domain.Key("a")).Return(&domain.Entry{Key: "a", Value: "1"}, nil).Once()
+		mockRepo.On("Get", ctx, domain.Key("b")).Return(nil, domain.ErrKeyNotFound).Once()
+
+		app, err := NewApplication(ctx, mockRepo, zap.NewNop().Sugar(), nil)
+		assert.NoError(t, err)
+		entries, err := app.MGet(ctx, []domain.Key{"a", "b"})
+		assert.NoError(t, err)
+		assert.Equal(t, []*domain.Entry{{Key: "a", Value: "1"}, nil}, entries)
+	})
+
+	t.Run("MDEL logs only existing keys", func(t *testing.T) {
+		mockRepo := newMockrepository(t)
+		mockWAL := NewMockWALogger(t)
+		mockWAL.On("Recover", ctx).Return(nil)
+		mockRepo.On("Get", ctx, domain.Key("a")).Return(&domain.Entry{Key: "a", Value: "1"}, nil).Once()
+		mockRepo.On("Get", ctx, domain.Key("b")).Return(nil, domain.ErrKeyNotFound).Once()
+		mockWAL.On("WriteMDel", []domain.Key{"a"}).Return(domain.LSN(1), nil).Once()
+		mockRepo.On("Delete", ctx, domain.Key("a")).Return(nil).Once()
+
+		app, err := NewApplication(ctx, mockRepo, zap.NewNop().Sugar(), mockWAL)
+		assert.NoError(t, err)
+		n, err := app.MDel(ctx, []domain.Key{"a", "b", "a"})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, n)
+	})
+}
@@ -0,0 +1,132 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/rdimidov/kvstore/internal/domain"
+)
+
+// Batch commands lock all of their keys for the whole batch, so a batch
+// write is never interleaved with writes of its keys, and MGet never sees
+// a part of an MSet run on its own. Inside a transaction no key is locked,
+// as Exec holds writes alone, and MGet may see a part of the transaction
+// like any read.
+
+// MGet returns the entries of the keys in order, with nil for the keys that
+// are missing.
+func (c *Application) MGet(ctx context.Context, keys []domain.Key) ([]*domain.Entry, error) {
+	c.logger.Debugw("getting keys", "keys", len(keys))
+
+	stored := make([]domain.Key, len(keys))
+	for i, key := range keys {
+		stored[i] = scoped(ctx, key)
+	}
+	defer c.locks.lockAll(stored)()
+
+	entries := make([]*domain.Entry, len(keys))
+	for i, key := range stored {
+		entry, err := c.lookup(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		if entry != nil {
+			e := unscoped(*entry)
+			entries[i] = &e
+		}
+	}
+	return entries, nil
+}
+
+// MSet stores the values of the entries under their keys as one write: all
+// of them are logged as a single WAL record and applied together. A key
+// given more than once ends up with its last value.
+func (c *Application) MSet(ctx context.Context, entries []domain.Entry) error {
+	c.logger.Debugw("setting keys", "keys", len(entries))
+
+	keys := make([]domain.Key, len(entries))
+	stored := make([]domain.Entry, len(entries))
+	for i, e := range entries {
+		keys[i] = scoped(ctx, e.Key)
+		stored[i] = domain.Entry{Key: keys[i], Value: e.Value}
+	}
+
+	for _, key := range keys {
+		if err := c.reclaim(ctx, key); err != nil {
+			return err
+		}
+	}
+	defer c.guardAll(ctx, keys)()
+
+	for i := range stored {
+		stored[i].Version = c.nextVersion()
+	}
+
+	var unsynced error
+	if c.wal != nil {
+		lsn, err := c.wal.WriteMSet(stored)
+		if unsynced, err = logged(err); err != nil {
+			return err
+		}
+		c.committed(ctx, lsn)
+	}
+
+	for _, e := range stored {
+		if err := c.repo.Put(ctx, e); err != nil {
+			c.logger.Errorf("failed to set key: %s, err: %v", e.Key, err)
+			return err
+		}
+	}
+	return unsynced
+}
+
+// MDel removes the keys as one write and returns how many of them existed.
+// Only those are logged, as a single WAL record.
+func (c *Application) MDel(ctx context.Context, keys []domain.Key) (int, error) {
+	c.logger.Debugw("deleting keys", "keys", len(keys))
+
+	stored := make([]domain.Key, len(keys))
+	for i, key := range keys {
+		stored[i] = scoped(ctx, key)
+	}
+
+	defer c.guardAll(ctx, stored)()
+
+	existing := make([]domain.Key, 0, len(stored))
+	seen := make(map[domain.Key]bool, len(stored))
+	for _, key := range stored {
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		entry, err := c.lookup(ctx, key)
+		if err != nil {
+			return 0, err
+		}
+		if entry != nil {
+			existing = append(existing, key)
+		}
+	}
+	if len(existing) == 0 {
+		return 0, nil
+	}
+
+	var unsynced error
+	if c.wal != nil {
+		lsn, err := c.wal.WriteMDel(existing)
+		if unsynced, err = logged(err); err != nil {
+			return 0, err
+		}
+		c.committed(ctx, lsn)
+	}
+
+	for _, key := range existing {
+		err := c.repo.Delete(ctx, key)
+		if err != nil && !errors.Is(err, domain.ErrKeyNotFound) {
+			c.logger.Errorf("failed to delete key: %s, err: %v", key, err)
+			return 0, err
+		}
+	}
+	return len(existing), unsynced
+}
@@ -0,0 +1,242 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/rdimidov/kvstore/internal/domain"
+)
+
+// Collection writes log the resulting collection rather than the operation.
+// A record grows with the collection, but replaying it again, even over a
+// key deleted or given another type by later writes, leaves the key as the
+// write did.
+
+// HSet sets fields of the hash at key and returns how many of them are new.
+func (c *Application) HSet(ctx context.Context, key domain.Key, fields []domain.HashField) (int, error) {
+	c.logger.Debugw("setting hash fields", "key", key, "fields", len(fields))
+	key = scoped(ctx, key)
+
+	// reclaim before reading, as it may evict the key itself
+	if err := c.reclaim(ctx, key); err != nil {
+		return 0, err
+	}
+	defer c.guard(ctx, key)()
+
+	current, err := c.collection(ctx, key, domain.TypeHash)
+	if err != nil {
+		return 0, err
+	}
+
+	items, added := domain.HashSet(current.Items, fields)
+	return added, c.update(ctx, current, items)
+}
+
+// HGet returns the value of a field of the hash at key, or
+// domain.ErrKeyNotFound when there is no such field.
+func (c *Application) HGet(ctx context.Context, key domain.Key, field domain.Value) (domain.Value, error) {
+	c.logger.Debugw("getting hash field", "key", key, "field", field)
+
+	current, err := c.collection(ctx, scoped(ctx, key), domain.TypeHash)
+	if err != nil {
+		return "", err
+	}
+	value, ok := domain.HashGet(current.Items, field)
+	if !ok {
+		return "", domain.ErrKeyNotFound
+	}
+	return value, nil
+}
+
+// HDel removes fields of the hash at key and returns how many were there.
+func (c *Application) HDel(ctx context.Context, key domain.Key, fields []domain.Value) (int, error) {
+	c.logger.Debugw("deleting hash fields", "key", key, "fields", len(fields))
+	key = scoped(ctx, key)
+
+	defer c.guard(ctx, key)()
+
+	current, err := c.collection(ctx, key, domain.TypeHash)
+	if err != nil {
+		return 0, err
+	}
+
+	items, removed := domain.HashDelete(current.Items, fields)
+	if removed == 0 {
+		return 0, nil
+	}
+	return removed, c.update(ctx, current, items)
+}
+
+// HGetAll returns the fields of the hash at key in field order.
+func (c *Application) HGetAll(ctx context.Context, key domain.Key) ([]domain.HashField, error) {
+	c.logger.Debugw("getting hash", "key", key)
+
+	current, err := c.collection(ctx, scoped(ctx, key), domain.TypeHash)
+	if err != nil {
+		return nil, err
+	}
+	return domain.HashFields(current.Items), nil
+}
+
+// Push adds values to the head of the list at key when left is set, or to
+// its tail otherwise, and returns the new length of the list.
+func (c *Application) Push(ctx context.Context, key domain.Key, values []domain.Value, left bool) (int, error) {
+	c.logger.Debugw("pushing", "key", key, "values", len(values), "left", left)
+	key = scoped(ctx, key)
+
+	if err := c.reclaim(ctx, key); err != nil {
+		return 0, err
+	}
+	defer c.guard(ctx, key)()
+
+	current, err := c.collection(ctx, key, domain.TypeList)
+	if err != nil {
+		return 0, err
+	}
+
+	items := domain.ListPush(current.Items, values, left)
+	return len(items), c.update(ctx, current, items)
+}
+
+// Pop removes and returns the head of the list at key when left is set, or
+// its tail otherwise. An empty list fails with domain.ErrKeyNotFound.
+func (c *Application) Pop(ctx context.Context, key domain.Key, left bool) (domain.Value, error) {
+	c.logger.Debugw("popping", "key", key, "left", left)
+	key = scoped(ctx, key)
+
+	defer c.guard(ctx, key)()
+
+	current, err := c.collection(ctx, key, domain.TypeList)
+	if err != nil {
+		return "", err
+	}
+	if len(current.Items) == 0 {
+		return "", domain.ErrKeyNotFound
+	}
+
+	items, value := current.Items[:len(current.Items)-1], current.Items[len(current.Items)-1]
+	if left {
+		items, value = current.Items[1:], current.Items[0]
+	}
+	return value, c.update(ctx, current, items)
+}
+
+// LRange returns the elements of the list at key from start to stop
+// inclusive. Negative indexes count from the end of the list.
+func (c *Application) LRange(ctx context.Context, key domain.Key, start, stop int) ([]domain.Value, error) {
+	c.logger.Debugw("getting list range", "key", key, "start", start, "stop", stop)
+
+	current, err := c.collection(ctx, scoped(ctx, key), domain.TypeList)
+	if err != nil {
+		return nil, err
+	}
+	return domain.ListRange(current.Items, start, stop), nil
+}
+
+// SAdd adds members to the set at key and returns how many of them are new.
+func (c *Application) SAdd(ctx context.Context, key domain.Key, members []domain.Value) (int, error) {
+	c.logger.Debugw("adding set members", "key", key, "members", len(members))
+	key = scoped(ctx, key)
+
+	if err := c.reclaim(ctx, key); err != nil {
+		return 0, err
+	}
+	defer c.guard(ctx, key)()
+
+	current, err := c.collection(ctx, key, domain.TypeSet)
+	if err != nil {
+		return 0, err
+	}
+
+	items, added := domain.SetAdd(current.Items, members)
+	if added == 0 {
+		return 0, nil
+	}
+	return added, c.update(ctx, current, items)
+}
+
+// SRem removes members from the set at key and returns how many were there.
+func (c *Application) SRem(ctx context.Context, key domain.Key, members []domain.Value) (int, error) {
+	c.logger.Debugw("removing set members", "key", key, "members", len(members))
+	key = scoped(ctx, key)
+
+	defer c.guard(ctx, key)()
+
+	current, err := c.collection(ctx, key, domain.TypeSet)
+	if err != nil {
+		return 0, err
+	}
+
+	items, removed := domain.SetRemove(current.Items, members)
+	if removed == 0 {
+		return 0, nil
+	}
+	return removed, c.update(ctx, current, items)
+}
+
+// SMembers returns the members of the set at key in order.
+func (c *Application) SMembers(ctx context.Context, key domain.Key) ([]domain.Value, error) {
+	c.logger.Debugw("getting set members", "key", key)
+
+	current, err := c.collection(ctx, scoped(ctx, key), domain.TypeSet)
+	if err != nil {
+		return nil, err
+	}
+	return current.Items, nil
+}
+
+// SIsMember reports whether member belongs to the set at key.
+func (c *Application) SIsMember(ctx context.Context, key domain.Key, member domain.Value) (bool, error) {
+	c.logger.Debugw("checking set member", "key", key, "member", member)
+
+	current, err := c.collection(ctx, scoped(ctx, key), domain.TypeSet)
+	if err != nil {
+		return false, err
+	}
+	return domain.SetContains(current.Items, member), nil
+}
+
+// collection returns the stored collection of the given type at key, or an
+// empty one when the key is missing. A key holding another type fails with
+// domain.ErrWrongType.
+func (c *Application) collection(ctx context.Context, key domain.Key, typ domain.Type) (domain.Entry, error) {
+	current, err := c.lookup(ctx, key)
+	if err != nil {
+		return domain.Entry{}, err
+	}
+	if current == nil {
+		return domain.Entry{Key: key, Type: typ}, nil
+	}
+	if current.Type != typ {
+		return domain.Entry{}, domain.ErrWrongType
+	}
+	return *current, nil
+}
+
+// update logs the collection with its new items and stores it, keeping its
+// expiration. An emptied collection removes the key. Caller holds writes and
+// the key lock.
+func (c *Application) update(ctx context.Context, entry domain.Entry, items []domain.Value) error {
+	entry.Items = items
+	var unsynced error
+	if c.wal != nil {
+		lsn, err := c.wal.WriteCollection(entry)
+		if unsynced, err = logged(err); err != nil {
+			return err
+		}
+		c.committed(ctx, lsn)
+	}
+
+	var err error
+	if len(items) == 0 {
+		err = c.repo.Delete(ctx, entry.Key)
+	} else {
+		entry.Version = c.nextVersion()
+		err = c.repo.Put(ctx, entry)
+	}
+	if err != nil && !errors.Is(err, domain.ErrKeyNotFound) {
+		c.logger.Errorf("failed to update key: %s, err: %v", entry.Key, err)
+		return err
+	}
+	return unsynced
+}
@@ -0,0 +1,50 @@
+package services
+
+import (
+	"hash/fnv"
+	"slices"
+	"sync"
+
+	"github.com/rdimidov/kvstore/internal/domain"
+)
+
+const keyLockStripes = 256
+
+// keyLocks serialises the writes of a key, so a conditional write sees no
+// change between its check and its write, and every key is logged in the
+// order its writes are applied. Keys share a fixed number of mutexes.
+type keyLocks [keyLockStripes]sync.Mutex
+
+// lock locks the stripe of key and returns its unlock function.
+func (l *keyLocks) lock(key domain.Key) func() {
+	m := &l[stripe(key)]
+	m.Lock()
+	return m.Unlock
+}
+
+// lockAll locks the stripes of all the keys and returns their unlock
+// function. Stripes are always locked in ascending order, so two callers
+// sharing some of them cannot deadlock.
+func (l *keyLocks) lockAll(keys []domain.Key) func() {
+	stripes := make([]uint32, 0, len(keys))
+	for _, key := range keys {
+		stripes = append(stripes, stripe(key))
+	}
+	slices.Sort(stripes)
+	stripes = slices.Compact(stripes)
+
+	for _, s := range stripes {
+		l[s].Lock()
+	}
+	return func() {
+		for _, s := range stripes {
+			l[s].Unlock()
+		}
+	}
+}
+
+func stripe(key domain.Key) uint32 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return h.Sum32() % keyLockStripes
+}
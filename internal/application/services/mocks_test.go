@@ -6,6 +6,7 @@ package services
 
 import (
 	"context"
+	"time"
 
 	"github.com/rdimidov/kvstore/internal/domain"
 	mock "github.com/stretchr/testify/mock"
@@ -84,6 +85,53 @@ func (_c *mockrepository_Delete_Call) RunAndReturn(run func(context1 context.Con
 	return _c
 }
 
+// Expire provides a mock function for the type mockrepository
+func (_mock *mockrepository) Expire(context1 context.Context, key domain.Key, time1 time.Time) error {
+	ret := _mock.Called(context1, key, time1)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Expire")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key, time.Time) error); ok {
+		r0 = returnFunc(context1, key, time1)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// mockrepository_Expire_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Expire'
+type mockrepository_Expire_Call struct {
+	*mock.Call
+}
+
+// Expire is a helper method to define mock.On call
+//   - context1
+//   - key
+//   - time1
+func (_e *mockrepository_Expecter) Expire(context1 interface{}, key interface{}, time1 interface{}) *mockrepository_Expire_Call {
+	return &mockrepository_Expire_Call{Call: _e.mock.On("Expire", context1, key, time1)}
+}
+
+func (_c *mockrepository_Expire_Call) Run(run func(context1 context.Context, key domain.Key, time1 time.Time)) *mockrepository_Expire_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.Key), args[2].(time.Time))
+	})
+	return _c
+}
+
+func (_c *mockrepository_Expire_Call) Return(err error) *mockrepository_Expire_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *mockrepository_Expire_Call) RunAndReturn(run func(context1 context.Context, key domain.Key, time1 time.Time) error) *mockrepository_Expire_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // Get provides a mock function for the type mockrepository
 func (_mock *mockrepository) Get(context1 context.Context, key domain.Key) (*domain.Entry, error) {
 	ret := _mock.Called(context1, key)
@@ -141,49 +189,256 @@ func (_c *mockrepository_Get_Call) RunAndReturn(run func(context1 context.Contex
 	return _c
 }
 
-// Set provides a mock function for the type mockrepository
-func (_mock *mockrepository) Set(context1 context.Context, key domain.Key, value domain.Value) error {
-	ret := _mock.Called(context1, key, value)
+// OverLimit provides a mock function for the type mockrepository
+func (_mock *mockrepository) OverLimit(context1 context.Context, key domain.Key) bool {
+	ret := _mock.Called(context1, key)
+
+	if len(ret) == 0 {
+		panic("no return value specified for OverLimit")
+	}
+
+	var r0 bool
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key) bool); ok {
+		r0 = returnFunc(context1, key)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+	return r0
+}
+
+// mockrepository_OverLimit_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'OverLimit'
+type mockrepository_OverLimit_Call struct {
+	*mock.Call
+}
+
+// OverLimit is a helper method to define mock.On call
+//   - context1
+//   - key
+func (_e *mockrepository_Expecter) OverLimit(context1 interface{}, key interface{}) *mockrepository_OverLimit_Call {
+	return &mockrepository_OverLimit_Call{Call: _e.mock.On("OverLimit", context1, key)}
+}
+
+func (_c *mockrepository_OverLimit_Call) Run(run func(context1 context.Context, key domain.Key)) *mockrepository_OverLimit_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.Key))
+	})
+	return _c
+}
+
+func (_c *mockrepository_OverLimit_Call) Return(bool1 bool) *mockrepository_OverLimit_Call {
+	_c.Call.Return(bool1)
+	return _c
+}
+
+func (_c *mockrepository_OverLimit_Call) RunAndReturn(run func(context1 context.Context, key domain.Key) bool) *mockrepository_OverLimit_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Persist provides a mock function for the type mockrepository
+func (_mock *mockrepository) Persist(context1 context.Context, key domain.Key) error {
+	ret := _mock.Called(context1, key)
 
 	if len(ret) == 0 {
-		panic("no return value specified for Set")
+		panic("no return value specified for Persist")
 	}
 
 	var r0 error
-	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key, domain.Value) error); ok {
-		r0 = returnFunc(context1, key, value)
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key) error); ok {
+		r0 = returnFunc(context1, key)
 	} else {
 		r0 = ret.Error(0)
 	}
 	return r0
 }
 
-// mockrepository_Set_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Set'
-type mockrepository_Set_Call struct {
+// mockrepository_Persist_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Persist'
+type mockrepository_Persist_Call struct {
 	*mock.Call
 }
 
-// Set is a helper method to define mock.On call
+// Persist is a helper method to define mock.On call
 //   - context1
 //   - key
-//   - value
-func (_e *mockrepository_Expecter) Set(context1 interface{}, key interface{}, value interface{}) *mockrepository_Set_Call {
-	return &mockrepository_Set_Call{Call: _e.mock.On("Set", context1, key, value)}
+func (_e *mockrepository_Expecter) Persist(context1 interface{}, key interface{}) *mockrepository_Persist_Call {
+	return &mockrepository_Persist_Call{Call: _e.mock.On("Persist", context1, key)}
+}
+
+func (_c *mockrepository_Persist_Call) Run(run func(context1 context.Context, key domain.Key)) *mockrepository_Persist_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.Key))
+	})
+	return _c
+}
+
+func (_c *mockrepository_Persist_Call) Return(err error) *mockrepository_Persist_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *mockrepository_Persist_Call) RunAndReturn(run func(context1 context.Context, key domain.Key) error) *mockrepository_Persist_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Put provides a mock function for the type mockrepository
+func (_mock *mockrepository) Put(context1 context.Context, entry domain.Entry) error {
+	ret := _mock.Called(context1, entry)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Put")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Entry) error); ok {
+		r0 = returnFunc(context1, entry)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// mockrepository_Put_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Put'
+type mockrepository_Put_Call struct {
+	*mock.Call
+}
+
+// Put is a helper method to define mock.On call
+//   - context1
+//   - entry
+func (_e *mockrepository_Expecter) Put(context1 interface{}, entry interface{}) *mockrepository_Put_Call {
+	return &mockrepository_Put_Call{Call: _e.mock.On("Put", context1, entry)}
 }
 
-func (_c *mockrepository_Set_Call) Run(run func(context1 context.Context, key domain.Key, value domain.Value)) *mockrepository_Set_Call {
+func (_c *mockrepository_Put_Call) Run(run func(context1 context.Context, entry domain.Entry)) *mockrepository_Put_Call {
 	_c.Call.Run(func(args mock.Arguments) {
-		run(args[0].(context.Context), args[1].(domain.Key), args[2].(domain.Value))
+		run(args[0].(context.Context), args[1].(domain.Entry))
 	})
 	return _c
 }
 
-func (_c *mockrepository_Set_Call) Return(err error) *mockrepository_Set_Call {
+func (_c *mockrepository_Put_Call) Return(err error) *mockrepository_Put_Call {
 	_c.Call.Return(err)
 	return _c
 }
 
-func (_c *mockrepository_Set_Call) RunAndReturn(run func(context1 context.Context, key domain.Key, value domain.Value) error) *mockrepository_Set_Call {
+func (_c *mockrepository_Put_Call) RunAndReturn(run func(context1 context.Context, entry domain.Entry) error) *mockrepository_Put_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Reclaim provides a mock function for the type mockrepository
+func (_mock *mockrepository) Reclaim(context1 context.Context, key domain.Key) ([]domain.Key, error) {
+	ret := _mock.Called(context1, key)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Reclaim")
+	}
+
+	var r0 []domain.Key
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key) ([]domain.Key, error)); ok {
+		return returnFunc(context1, key)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key) []domain.Key); ok {
+		r0 = returnFunc(context1, key)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]domain.Key)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, domain.Key) error); ok {
+		r1 = returnFunc(context1, key)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// mockrepository_Reclaim_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Reclaim'
+type mockrepository_Reclaim_Call struct {
+	*mock.Call
+}
+
+// Reclaim is a helper method to define mock.On call
+//   - context1
+//   - key
+func (_e *mockrepository_Expecter) Reclaim(context1 interface{}, key interface{}) *mockrepository_Reclaim_Call {
+	return &mockrepository_Reclaim_Call{Call: _e.mock.On("Reclaim", context1, key)}
+}
+
+func (_c *mockrepository_Reclaim_Call) Run(run func(context1 context.Context, key domain.Key)) *mockrepository_Reclaim_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.Key))
+	})
+	return _c
+}
+
+func (_c *mockrepository_Reclaim_Call) Return(keyMoqParams []domain.Key, err error) *mockrepository_Reclaim_Call {
+	_c.Call.Return(keyMoqParams, err)
+	return _c
+}
+
+func (_c *mockrepository_Reclaim_Call) RunAndReturn(run func(context1 context.Context, key domain.Key) ([]domain.Key, error)) *mockrepository_Reclaim_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Scan provides a mock function for the type mockrepository
+func (_mock *mockrepository) Scan(context1 context.Context, key domain.Key, key1 domain.Key, int1 int) ([]domain.Entry, error) {
+	ret := _mock.Called(context1, key, key1, int1)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Scan")
+	}
+
+	var r0 []domain.Entry
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key, domain.Key, int) ([]domain.Entry, error)); ok {
+		return returnFunc(context1, key, key1, int1)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key, domain.Key, int) []domain.Entry); ok {
+		r0 = returnFunc(context1, key, key1, int1)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]domain.Entry)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, domain.Key, domain.Key, int) error); ok {
+		r1 = returnFunc(context1, key, key1, int1)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// mockrepository_Scan_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Scan'
+type mockrepository_Scan_Call struct {
+	*mock.Call
+}
+
+// Scan is a helper method to define mock.On call
+//   - context1
+//   - key
+//   - key1
+//   - int1
+func (_e *mockrepository_Expecter) Scan(context1 interface{}, key interface{}, key1 interface{}, int1 interface{}) *mockrepository_Scan_Call {
+	return &mockrepository_Scan_Call{Call: _e.mock.On("Scan", context1, key, key1, int1)}
+}
+
+func (_c *mockrepository_Scan_Call) Run(run func(context1 context.Context, key domain.Key, key1 domain.Key, int1 int)) *mockrepository_Scan_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.Key), args[2].(domain.Key), args[3].(int))
+	})
+	return _c
+}
+
+func (_c *mockrepository_Scan_Call) Return(entryMoqParams []domain.Entry, err error) *mockrepository_Scan_Call {
+	_c.Call.Return(entryMoqParams, err)
+	return _c
+}
+
+func (_c *mockrepository_Scan_Call) RunAndReturn(run func(context1 context.Context, key domain.Key, key1 domain.Key, int1 int) ([]domain.Entry, error)) *mockrepository_Scan_Call {
 	_c.Call.Return(run)
 	return _c
 }
@@ -215,6 +470,93 @@ func (_m *MockWALogger) EXPECT() *MockWALogger_Expecter {
 	return &MockWALogger_Expecter{mock: &_m.Mock}
 }
 
+// Begin provides a mock function for the type MockWALogger
+func (_mock *MockWALogger) Begin() {
+	_mock.Called()
+	return
+}
+
+// MockWALogger_Begin_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Begin'
+type MockWALogger_Begin_Call struct {
+	*mock.Call
+}
+
+// Begin is a helper method to define mock.On call
+func (_e *MockWALogger_Expecter) Begin() *MockWALogger_Begin_Call {
+	return &MockWALogger_Begin_Call{Call: _e.mock.On("Begin")}
+}
+
+func (_c *MockWALogger_Begin_Call) Run(run func()) *MockWALogger_Begin_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *MockWALogger_Begin_Call) Return() *MockWALogger_Begin_Call {
+	_c.Call.Return()
+	return _c
+}
+
+func (_c *MockWALogger_Begin_Call) RunAndReturn(run func()) *MockWALogger_Begin_Call {
+	_c.Run(run)
+	return _c
+}
+
+// Commit provides a mock function for the type MockWALogger
+func (_mock *MockWALogger) Commit(id string) (domain.LSN, error) {
+	ret := _mock.Called(id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Commit")
+	}
+
+	var r0 domain.LSN
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(string) (domain.LSN, error)); ok {
+		return returnFunc(id)
+	}
+	if returnFunc, ok := ret.Get(0).(func(string) domain.LSN); ok {
+		r0 = returnFunc(id)
+	} else {
+		r0 = ret.Get(0).(domain.LSN)
+	}
+	if returnFunc, ok := ret.Get(1).(func(string) error); ok {
+		r1 = returnFunc(id)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockWALogger_Commit_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Commit'
+type MockWALogger_Commit_Call struct {
+	*mock.Call
+}
+
+// Commit is a helper method to define mock.On call
+//   - id
+func (_e *MockWALogger_Expecter) Commit(id interface{}) *MockWALogger_Commit_Call {
+	return &MockWALogger_Commit_Call{Call: _e.mock.On("Commit", id)}
+}
+
+func (_c *MockWALogger_Commit_Call) Run(run func(id string)) *MockWALogger_Commit_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(string))
+	})
+	return _c
+}
+
+func (_c *MockWALogger_Commit_Call) Return(lsn domain.LSN, err error) *MockWALogger_Commit_Call {
+	_c.Call.Return(lsn, err)
+	return _c
+}
+
+func (_c *MockWALogger_Commit_Call) RunAndReturn(run func(id string) (domain.LSN, error)) *MockWALogger_Commit_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // Recover provides a mock function for the type MockWALogger
 func (_mock *MockWALogger) Recover(ctx context.Context) error {
 	ret := _mock.Called(ctx)
@@ -260,93 +602,611 @@ func (_c *MockWALogger_Recover_Call) RunAndReturn(run func(ctx context.Context)
 	return _c
 }
 
-// WriteDel provides a mock function for the type MockWALogger
-func (_mock *MockWALogger) WriteDel(key domain.Key) error {
-	ret := _mock.Called(key)
-
-	if len(ret) == 0 {
-		panic("no return value specified for WriteDel")
-	}
-
-	var r0 error
-	if returnFunc, ok := ret.Get(0).(func(domain.Key) error); ok {
-		r0 = returnFunc(key)
-	} else {
-		r0 = ret.Error(0)
-	}
-	return r0
+// Rollback provides a mock function for the type MockWALogger
+func (_mock *MockWALogger) Rollback() {
+	_mock.Called()
+	return
 }
 
-// MockWALogger_WriteDel_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'WriteDel'
-type MockWALogger_WriteDel_Call struct {
+// MockWALogger_Rollback_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Rollback'
+type MockWALogger_Rollback_Call struct {
 	*mock.Call
 }
 
-// WriteDel is a helper method to define mock.On call
-//   - key
-func (_e *MockWALogger_Expecter) WriteDel(key interface{}) *MockWALogger_WriteDel_Call {
-	return &MockWALogger_WriteDel_Call{Call: _e.mock.On("WriteDel", key)}
+// Rollback is a helper method to define mock.On call
+func (_e *MockWALogger_Expecter) Rollback() *MockWALogger_Rollback_Call {
+	return &MockWALogger_Rollback_Call{Call: _e.mock.On("Rollback")}
 }
 
-func (_c *MockWALogger_WriteDel_Call) Run(run func(key domain.Key)) *MockWALogger_WriteDel_Call {
+func (_c *MockWALogger_Rollback_Call) Run(run func()) *MockWALogger_Rollback_Call {
 	_c.Call.Run(func(args mock.Arguments) {
-		run(args[0].(domain.Key))
+		run()
 	})
 	return _c
 }
 
-func (_c *MockWALogger_WriteDel_Call) Return(err error) *MockWALogger_WriteDel_Call {
-	_c.Call.Return(err)
+func (_c *MockWALogger_Rollback_Call) Return() *MockWALogger_Rollback_Call {
+	_c.Call.Return()
 	return _c
 }
 
-func (_c *MockWALogger_WriteDel_Call) RunAndReturn(run func(key domain.Key) error) *MockWALogger_WriteDel_Call {
-	_c.Call.Return(run)
+func (_c *MockWALogger_Rollback_Call) RunAndReturn(run func()) *MockWALogger_Rollback_Call {
+	_c.Run(run)
 	return _c
 }
 
-// WriteSet provides a mock function for the type MockWALogger
-func (_mock *MockWALogger) WriteSet(key domain.Key, value domain.Value) error {
-	ret := _mock.Called(key, value)
+// Rotate provides a mock function for the type MockWALogger
+func (_mock *MockWALogger) Rotate() (string, error) {
+	ret := _mock.Called()
 
 	if len(ret) == 0 {
-		panic("no return value specified for WriteSet")
+		panic("no return value specified for Rotate")
 	}
 
-	var r0 error
-	if returnFunc, ok := ret.Get(0).(func(domain.Key, domain.Value) error); ok {
-		r0 = returnFunc(key, value)
+	var r0 string
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func() (string, error)); ok {
+		return returnFunc()
+	}
+	if returnFunc, ok := ret.Get(0).(func() string); ok {
+		r0 = returnFunc()
 	} else {
-		r0 = ret.Error(0)
+		r0 = ret.Get(0).(string)
 	}
-	return r0
+	if returnFunc, ok := ret.Get(1).(func() error); ok {
+		r1 = returnFunc()
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
 }
 
-// MockWALogger_WriteSet_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'WriteSet'
+// MockWALogger_Rotate_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Rotate'
+type MockWALogger_Rotate_Call struct {
+	*mock.Call
+}
+
+// Rotate is a helper method to define mock.On call
+func (_e *MockWALogger_Expecter) Rotate() *MockWALogger_Rotate_Call {
+	return &MockWALogger_Rotate_Call{Call: _e.mock.On("Rotate")}
+}
+
+func (_c *MockWALogger_Rotate_Call) Run(run func()) *MockWALogger_Rotate_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *MockWALogger_Rotate_Call) Return(string1 string, err error) *MockWALogger_Rotate_Call {
+	_c.Call.Return(string1, err)
+	return _c
+}
+
+func (_c *MockWALogger_Rotate_Call) RunAndReturn(run func() (string, error)) *MockWALogger_Rotate_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Sync provides a mock function for the type MockWALogger
+func (_mock *MockWALogger) Sync() error {
+	ret := _mock.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for Sync")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func() error); ok {
+		r0 = returnFunc()
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockWALogger_Sync_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Sync'
+type MockWALogger_Sync_Call struct {
+	*mock.Call
+}
+
+// Sync is a helper method to define mock.On call
+func (_e *MockWALogger_Expecter) Sync() *MockWALogger_Sync_Call {
+	return &MockWALogger_Sync_Call{Call: _e.mock.On("Sync")}
+}
+
+func (_c *MockWALogger_Sync_Call) Run(run func()) *MockWALogger_Sync_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *MockWALogger_Sync_Call) Return(err error) *MockWALogger_Sync_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *MockWALogger_Sync_Call) RunAndReturn(run func() error) *MockWALogger_Sync_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// WriteCollection provides a mock function for the type MockWALogger
+func (_mock *MockWALogger) WriteCollection(entry domain.Entry) (domain.LSN, error) {
+	ret := _mock.Called(entry)
+
+	if len(ret) == 0 {
+		panic("no return value specified for WriteCollection")
+	}
+
+	var r0 domain.LSN
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(domain.Entry) (domain.LSN, error)); ok {
+		return returnFunc(entry)
+	}
+	if returnFunc, ok := ret.Get(0).(func(domain.Entry) domain.LSN); ok {
+		r0 = returnFunc(entry)
+	} else {
+		r0 = ret.Get(0).(domain.LSN)
+	}
+	if returnFunc, ok := ret.Get(1).(func(domain.Entry) error); ok {
+		r1 = returnFunc(entry)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockWALogger_WriteCollection_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'WriteCollection'
+type MockWALogger_WriteCollection_Call struct {
+	*mock.Call
+}
+
+// WriteCollection is a helper method to define mock.On call
+//   - entry
+func (_e *MockWALogger_Expecter) WriteCollection(entry interface{}) *MockWALogger_WriteCollection_Call {
+	return &MockWALogger_WriteCollection_Call{Call: _e.mock.On("WriteCollection", entry)}
+}
+
+func (_c *MockWALogger_WriteCollection_Call) Run(run func(entry domain.Entry)) *MockWALogger_WriteCollection_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(domain.Entry))
+	})
+	return _c
+}
+
+func (_c *MockWALogger_WriteCollection_Call) Return(lsn domain.LSN, err error) *MockWALogger_WriteCollection_Call {
+	_c.Call.Return(lsn, err)
+	return _c
+}
+
+func (_c *MockWALogger_WriteCollection_Call) RunAndReturn(run func(entry domain.Entry) (domain.LSN, error)) *MockWALogger_WriteCollection_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// WriteDel provides a mock function for the type MockWALogger
+func (_mock *MockWALogger) WriteDel(key domain.Key) (domain.LSN, error) {
+	ret := _mock.Called(key)
+
+	if len(ret) == 0 {
+		panic("no return value specified for WriteDel")
+	}
+
+	var r0 domain.LSN
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(domain.Key) (domain.LSN, error)); ok {
+		return returnFunc(key)
+	}
+	if returnFunc, ok := ret.Get(0).(func(domain.Key) domain.LSN); ok {
+		r0 = returnFunc(key)
+	} else {
+		r0 = ret.Get(0).(domain.LSN)
+	}
+	if returnFunc, ok := ret.Get(1).(func(domain.Key) error); ok {
+		r1 = returnFunc(key)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockWALogger_WriteDel_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'WriteDel'
+type MockWALogger_WriteDel_Call struct {
+	*mock.Call
+}
+
+// WriteDel is a helper method to define mock.On call
+//   - key
+func (_e *MockWALogger_Expecter) WriteDel(key interface{}) *MockWALogger_WriteDel_Call {
+	return &MockWALogger_WriteDel_Call{Call: _e.mock.On("WriteDel", key)}
+}
+
+func (_c *MockWALogger_WriteDel_Call) Run(run func(key domain.Key)) *MockWALogger_WriteDel_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(domain.Key))
+	})
+	return _c
+}
+
+func (_c *MockWALogger_WriteDel_Call) Return(lsn domain.LSN, err error) *MockWALogger_WriteDel_Call {
+	_c.Call.Return(lsn, err)
+	return _c
+}
+
+func (_c *MockWALogger_WriteDel_Call) RunAndReturn(run func(key domain.Key) (domain.LSN, error)) *MockWALogger_WriteDel_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// WriteExpire provides a mock function for the type MockWALogger
+func (_mock *MockWALogger) WriteExpire(key domain.Key, time1 time.Time) (domain.LSN, error) {
+	ret := _mock.Called(key, time1)
+
+	if len(ret) == 0 {
+		panic("no return value specified for WriteExpire")
+	}
+
+	var r0 domain.LSN
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(domain.Key, time.Time) (domain.LSN, error)); ok {
+		return returnFunc(key, time1)
+	}
+	if returnFunc, ok := ret.Get(0).(func(domain.Key, time.Time) domain.LSN); ok {
+		r0 = returnFunc(key, time1)
+	} else {
+		r0 = ret.Get(0).(domain.LSN)
+	}
+	if returnFunc, ok := ret.Get(1).(func(domain.Key, time.Time) error); ok {
+		r1 = returnFunc(key, time1)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockWALogger_WriteExpire_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'WriteExpire'
+type MockWALogger_WriteExpire_Call struct {
+	*mock.Call
+}
+
+// WriteExpire is a helper method to define mock.On call
+//   - key
+//   - time1
+func (_e *MockWALogger_Expecter) WriteExpire(key interface{}, time1 interface{}) *MockWALogger_WriteExpire_Call {
+	return &MockWALogger_WriteExpire_Call{Call: _e.mock.On("WriteExpire", key, time1)}
+}
+
+func (_c *MockWALogger_WriteExpire_Call) Run(run func(key domain.Key, time1 time.Time)) *MockWALogger_WriteExpire_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(domain.Key), args[1].(time.Time))
+	})
+	return _c
+}
+
+func (_c *MockWALogger_WriteExpire_Call) Return(lsn domain.LSN, err error) *MockWALogger_WriteExpire_Call {
+	_c.Call.Return(lsn, err)
+	return _c
+}
+
+func (_c *MockWALogger_WriteExpire_Call) RunAndReturn(run func(key domain.Key, time1 time.Time) (domain.LSN, error)) *MockWALogger_WriteExpire_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// WriteFlush provides a mock function for the type MockWALogger
+func (_mock *MockWALogger) WriteFlush(namespace domain.Namespace) (domain.LSN, error) {
+	ret := _mock.Called(namespace)
+
+	if len(ret) == 0 {
+		panic("no return value specified for WriteFlush")
+	}
+
+	var r0 domain.LSN
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(domain.Namespace) (domain.LSN, error)); ok {
+		return returnFunc(namespace)
+	}
+	if returnFunc, ok := ret.Get(0).(func(domain.Namespace) domain.LSN); ok {
+		r0 = returnFunc(namespace)
+	} else {
+		r0 = ret.Get(0).(domain.LSN)
+	}
+	if returnFunc, ok := ret.Get(1).(func(domain.Namespace) error); ok {
+		r1 = returnFunc(namespace)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockWALogger_WriteFlush_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'WriteFlush'
+type MockWALogger_WriteFlush_Call struct {
+	*mock.Call
+}
+
+// WriteFlush is a helper method to define mock.On call
+//   - namespace
+func (_e *MockWALogger_Expecter) WriteFlush(namespace interface{}) *MockWALogger_WriteFlush_Call {
+	return &MockWALogger_WriteFlush_Call{Call: _e.mock.On("WriteFlush", namespace)}
+}
+
+func (_c *MockWALogger_WriteFlush_Call) Run(run func(namespace domain.Namespace)) *MockWALogger_WriteFlush_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(domain.Namespace))
+	})
+	return _c
+}
+
+func (_c *MockWALogger_WriteFlush_Call) Return(lsn domain.LSN, err error) *MockWALogger_WriteFlush_Call {
+	_c.Call.Return(lsn, err)
+	return _c
+}
+
+func (_c *MockWALogger_WriteFlush_Call) RunAndReturn(run func(namespace domain.Namespace) (domain.LSN, error)) *MockWALogger_WriteFlush_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// WriteMDel provides a mock function for the type MockWALogger
+func (_mock *MockWALogger) WriteMDel(keyMoqParams []domain.Key) (domain.LSN, error) {
+	ret := _mock.Called(keyMoqParams)
+
+	if len(ret) == 0 {
+		panic("no return value specified for WriteMDel")
+	}
+
+	var r0 domain.LSN
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func([]domain.Key) (domain.LSN, error)); ok {
+		return returnFunc(keyMoqParams)
+	}
+	if returnFunc, ok := ret.Get(0).(func([]domain.Key) domain.LSN); ok {
+		r0 = returnFunc(keyMoqParams)
+	} else {
+		r0 = ret.Get(0).(domain.LSN)
+	}
+	if returnFunc, ok := ret.Get(1).(func([]domain.Key) error); ok {
+		r1 = returnFunc(keyMoqParams)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockWALogger_WriteMDel_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'WriteMDel'
+type MockWALogger_WriteMDel_Call struct {
+	*mock.Call
+}
+
+// WriteMDel is a helper method to define mock.On call
+//   - keyMoqParams
+func (_e *MockWALogger_Expecter) WriteMDel(keyMoqParams interface{}) *MockWALogger_WriteMDel_Call {
+	return &MockWALogger_WriteMDel_Call{Call: _e.mock.On("WriteMDel", keyMoqParams)}
+}
+
+func (_c *MockWALogger_WriteMDel_Call) Run(run func(keyMoqParams []domain.Key)) *MockWALogger_WriteMDel_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].([]domain.Key))
+	})
+	return _c
+}
+
+func (_c *MockWALogger_WriteMDel_Call) Return(lsn domain.LSN, err error) *MockWALogger_WriteMDel_Call {
+	_c.Call.Return(lsn, err)
+	return _c
+}
+
+func (_c *MockWALogger_WriteMDel_Call) RunAndReturn(run func(keyMoqParams []domain.Key) (domain.LSN, error)) *MockWALogger_WriteMDel_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// WriteMSet provides a mock function for the type MockWALogger
+func (_mock *MockWALogger) WriteMSet(entryMoqParams []domain.Entry) (domain.LSN, error) {
+	ret := _mock.Called(entryMoqParams)
+
+	if len(ret) == 0 {
+		panic("no return value specified for WriteMSet")
+	}
+
+	var r0 domain.LSN
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func([]domain.Entry) (domain.LSN, error)); ok {
+		return returnFunc(entryMoqParams)
+	}
+	if returnFunc, ok := ret.Get(0).(func([]domain.Entry) domain.LSN); ok {
+		r0 = returnFunc(entryMoqParams)
+	} else {
+		r0 = ret.Get(0).(domain.LSN)
+	}
+	if returnFunc, ok := ret.Get(1).(func([]domain.Entry) error); ok {
+		r1 = returnFunc(entryMoqParams)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockWALogger_WriteMSet_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'WriteMSet'
+type MockWALogger_WriteMSet_Call struct {
+	*mock.Call
+}
+
+// WriteMSet is a helper method to define mock.On call
+//   - entryMoqParams
+func (_e *MockWALogger_Expecter) WriteMSet(entryMoqParams interface{}) *MockWALogger_WriteMSet_Call {
+	return &MockWALogger_WriteMSet_Call{Call: _e.mock.On("WriteMSet", entryMoqParams)}
+}
+
+func (_c *MockWALogger_WriteMSet_Call) Run(run func(entryMoqParams []domain.Entry)) *MockWALogger_WriteMSet_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].([]domain.Entry))
+	})
+	return _c
+}
+
+func (_c *MockWALogger_WriteMSet_Call) Return(lsn domain.LSN, err error) *MockWALogger_WriteMSet_Call {
+	_c.Call.Return(lsn, err)
+	return _c
+}
+
+func (_c *MockWALogger_WriteMSet_Call) RunAndReturn(run func(entryMoqParams []domain.Entry) (domain.LSN, error)) *MockWALogger_WriteMSet_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// WritePersist provides a mock function for the type MockWALogger
+func (_mock *MockWALogger) WritePersist(key domain.Key) (domain.LSN, error) {
+	ret := _mock.Called(key)
+
+	if len(ret) == 0 {
+		panic("no return value specified for WritePersist")
+	}
+
+	var r0 domain.LSN
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(domain.Key) (domain.LSN, error)); ok {
+		return returnFunc(key)
+	}
+	if returnFunc, ok := ret.Get(0).(func(domain.Key) domain.LSN); ok {
+		r0 = returnFunc(key)
+	} else {
+		r0 = ret.Get(0).(domain.LSN)
+	}
+	if returnFunc, ok := ret.Get(1).(func(domain.Key) error); ok {
+		r1 = returnFunc(key)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockWALogger_WritePersist_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'WritePersist'
+type MockWALogger_WritePersist_Call struct {
+	*mock.Call
+}
+
+// WritePersist is a helper method to define mock.On call
+//   - key
+func (_e *MockWALogger_Expecter) WritePersist(key interface{}) *MockWALogger_WritePersist_Call {
+	return &MockWALogger_WritePersist_Call{Call: _e.mock.On("WritePersist", key)}
+}
+
+func (_c *MockWALogger_WritePersist_Call) Run(run func(key domain.Key)) *MockWALogger_WritePersist_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(domain.Key))
+	})
+	return _c
+}
+
+func (_c *MockWALogger_WritePersist_Call) Return(lsn domain.LSN, err error) *MockWALogger_WritePersist_Call {
+	_c.Call.Return(lsn, err)
+	return _c
+}
+
+func (_c *MockWALogger_WritePersist_Call) RunAndReturn(run func(key domain.Key) (domain.LSN, error)) *MockWALogger_WritePersist_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// WriteSet provides a mock function for the type MockWALogger
+func (_mock *MockWALogger) WriteSet(entry domain.Entry) (domain.LSN, error) {
+	ret := _mock.Called(entry)
+
+	if len(ret) == 0 {
+		panic("no return value specified for WriteSet")
+	}
+
+	var r0 domain.LSN
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(domain.Entry) (domain.LSN, error)); ok {
+		return returnFunc(entry)
+	}
+	if returnFunc, ok := ret.Get(0).(func(domain.Entry) domain.LSN); ok {
+		r0 = returnFunc(entry)
+	} else {
+		r0 = ret.Get(0).(domain.LSN)
+	}
+	if returnFunc, ok := ret.Get(1).(func(domain.Entry) error); ok {
+		r1 = returnFunc(entry)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockWALogger_WriteSet_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'WriteSet'
 type MockWALogger_WriteSet_Call struct {
 	*mock.Call
 }
 
 // WriteSet is a helper method to define mock.On call
-//   - key
-//   - value
-func (_e *MockWALogger_Expecter) WriteSet(key interface{}, value interface{}) *MockWALogger_WriteSet_Call {
-	return &MockWALogger_WriteSet_Call{Call: _e.mock.On("WriteSet", key, value)}
+//   - entry
+func (_e *MockWALogger_Expecter) WriteSet(entry interface{}) *MockWALogger_WriteSet_Call {
+	return &MockWALogger_WriteSet_Call{Call: _e.mock.On("WriteSet", entry)}
+}
+
+func (_c *MockWALogger_WriteSet_Call) Run(run func(entry domain.Entry)) *MockWALogger_WriteSet_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(domain.Entry))
+	})
+	return _c
+}
+
+func (_c *MockWALogger_WriteSet_Call) Return(lsn domain.LSN, err error) *MockWALogger_WriteSet_Call {
+	_c.Call.Return(lsn, err)
+	return _c
+}
+
+func (_c *MockWALogger_WriteSet_Call) RunAndReturn(run func(entry domain.Entry) (domain.LSN, error)) *MockWALogger_WriteSet_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// WriteSnapshot provides a mock function for the type MockWALogger
+func (_mock *MockWALogger) WriteSnapshot(string1 string, fn func() ([]domain.Entry, error)) error {
+	ret := _mock.Called(string1, fn)
+
+	if len(ret) == 0 {
+		panic("no return value specified for WriteSnapshot")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(string, func() ([]domain.Entry, error)) error); ok {
+		r0 = returnFunc(string1, fn)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockWALogger_WriteSnapshot_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'WriteSnapshot'
+type MockWALogger_WriteSnapshot_Call struct {
+	*mock.Call
+}
+
+// WriteSnapshot is a helper method to define mock.On call
+//   - string1
+//   - fn
+func (_e *MockWALogger_Expecter) WriteSnapshot(string1 interface{}, fn interface{}) *MockWALogger_WriteSnapshot_Call {
+	return &MockWALogger_WriteSnapshot_Call{Call: _e.mock.On("WriteSnapshot", string1, fn)}
 }
 
-func (_c *MockWALogger_WriteSet_Call) Run(run func(key domain.Key, value domain.Value)) *MockWALogger_WriteSet_Call {
+func (_c *MockWALogger_WriteSnapshot_Call) Run(run func(string1 string, fn func() ([]domain.Entry, error))) *MockWALogger_WriteSnapshot_Call {
 	_c.Call.Run(func(args mock.Arguments) {
-		run(args[0].(domain.Key), args[1].(domain.Value))
+		run(args[0].(string), args[1].(func() ([]domain.Entry, error)))
 	})
 	return _c
 }
 
-func (_c *MockWALogger_WriteSet_Call) Return(err error) *MockWALogger_WriteSet_Call {
+func (_c *MockWALogger_WriteSnapshot_Call) Return(err error) *MockWALogger_WriteSnapshot_Call {
 	_c.Call.Return(err)
 	return _c
 }
 
-func (_c *MockWALogger_WriteSet_Call) RunAndReturn(run func(key domain.Key, value domain.Value) error) *MockWALogger_WriteSet_Call {
+func (_c *MockWALogger_WriteSnapshot_Call) RunAndReturn(run func(string1 string, fn func() ([]domain.Entry, error)) error) *MockWALogger_WriteSnapshot_Call {
 	_c.Call.Return(run)
 	return _c
 }
@@ -0,0 +1,13 @@
+package services
+
+type Option func(*Application)
+
+// WithoutEviction builds the application replaying the WAL and snapshots. It
+// stores what it is given whatever the memory limit: the evictions made
+// before were logged as deletes, and evicting more keys than these would
+// lose them. The limit holds again from the first write after startup.
+func WithoutEviction() Option {
+	return func(c *Application) {
+		c.noEviction = true
+	}
+}
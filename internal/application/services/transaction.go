@@ -0,0 +1,223 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/rdimidov/kvstore/internal/domain"
+)
+
+// transaction is the state of a transaction being run by Exec.
+type transaction struct {
+	// undo holds what the keys written by the transaction held before it,
+	// nil for keys that did not exist.
+	undo map[domain.Key]*domain.Entry
+	// evicted lists the keys evicted to make room for the transaction.
+	evicted []domain.Key
+	// err remembers a failure to save a key for undo.
+	err error
+	// sync is set when a write of the transaction asked to be synced, which
+	// is done once the transaction is logged.
+	sync bool
+}
+
+type txKey struct{}
+
+func txFrom(ctx context.Context) *transaction {
+	tx, _ := ctx.Value(txKey{}).(*transaction)
+	return tx
+}
+
+// Watch remembers the current versions of the keys in the session, so the
+// next Exec of the session only runs if none of them was written since.
+func (c *Application) Watch(ctx context.Context, keys []domain.Key) error {
+	c.logger.Debugw("watching", "keys", keys)
+
+	session := domain.SessionFrom(ctx)
+	if session == nil {
+		return domain.ErrNoSession
+	}
+	if session.Watched == nil {
+		session.Watched = make(map[domain.Key]uint64, len(keys))
+	}
+	for _, key := range keys {
+		key = scoped(ctx, key)
+		if _, ok := session.Watched[key]; ok {
+			continue
+		}
+		version, err := c.currentVersion(ctx, key)
+		if err != nil {
+			return err
+		}
+		session.Watched[key] = version
+	}
+	return nil
+}
+
+// Unwatch forgets the keys watched in the session.
+func (c *Application) Unwatch(ctx context.Context) {
+	if session := domain.SessionFrom(ctx); session != nil {
+		session.Watched = nil
+	}
+}
+
+// Exec runs fn as a transaction. Other writes wait until it is done, and
+// its own writes are applied all or none: when fn fails, every key it wrote
+// gets back what it held before. A committed transaction is logged as a
+// single WAL record, so recovery never applies a part of it. Reads of other
+// sessions are not blocked and may see a transaction in progress.
+//
+// Exec fails with domain.ErrWatchedKeyChanged without running fn when a key the
+// session watches has changed. Either way the session stops watching.
+func (c *Application) Exec(ctx context.Context, fn func(context.Context) error) error {
+	if txFrom(ctx) != nil {
+		return ErrInTransaction
+	}
+
+	var watched map[domain.Key]uint64
+	if session := domain.SessionFrom(ctx); session != nil {
+		watched, session.Watched = session.Watched, nil
+	}
+
+	c.writes.Lock()
+	defer c.writes.Unlock()
+
+	for key, version := range watched {
+		current, err := c.currentVersion(ctx, key)
+		if err != nil {
+			return err
+		}
+		if current != version {
+			return domain.ErrWatchedKeyChanged
+		}
+	}
+
+	id := uuid.NewString()
+	c.logger.Debugw("starting transaction", "tx", id)
+
+	tx := &transaction{undo: make(map[domain.Key]*domain.Entry)}
+	if c.wal != nil {
+		c.wal.Begin()
+	}
+
+	err := fn(context.WithValue(ctx, txKey{}, tx))
+	if err == nil {
+		err = tx.err
+	}
+	if err != nil {
+		if c.wal != nil {
+			c.wal.Rollback()
+		}
+		c.rollback(ctx, tx)
+		return err
+	}
+
+	if c.wal != nil {
+		lsn, err := c.wal.Commit(id)
+		unsynced, err := logged(err)
+		if err != nil {
+			c.logger.Errorf("failed to log transaction: %s, err: %v", id, err)
+			c.rollback(ctx, tx)
+			return err
+		}
+		c.committed(ctx, lsn)
+		if unsynced != nil {
+			return unsynced
+		}
+		if tx.sync {
+			if err := c.syncLogged(); err != nil {
+				return err
+			}
+		}
+	}
+	c.logger.Debugw("transaction committed", "tx", id, "keys", len(tx.undo))
+	return nil
+}
+
+// guard takes the locks a write of key needs and returns the function
+// releasing them. A transaction holds writes exclusively already, so inside
+// one guard only saves what the key holds for a rollback.
+func (c *Application) guard(ctx context.Context, key domain.Key) func() {
+	if tx := txFrom(ctx); tx != nil {
+		c.save(ctx, tx, key)
+		return func() {}
+	}
+
+	c.writes.RLock()
+	unlock := c.locks.lock(key)
+	return func() {
+		unlock()
+		c.writes.RUnlock()
+	}
+}
+
+// guardAll is guard for a write of several keys at once.
+func (c *Application) guardAll(ctx context.Context, keys []domain.Key) func() {
+	if tx := txFrom(ctx); tx != nil {
+		for _, key := range keys {
+			c.save(ctx, tx, key)
+		}
+		return func() {}
+	}
+
+	c.writes.RLock()
+	unlock := c.locks.lockAll(keys)
+	return func() {
+		unlock()
+		c.writes.RUnlock()
+	}
+}
+
+// save remembers what key holds before the transaction first writes it.
+func (c *Application) save(ctx context.Context, tx *transaction, key domain.Key) {
+	if _, ok := tx.undo[key]; ok {
+		return
+	}
+	entry, err := c.lookup(ctx, key)
+	if err != nil {
+		tx.err = errors.Join(tx.err, err)
+		return
+	}
+	tx.undo[key] = entry
+}
+
+// rollback gives the keys written by a failed transaction back what they
+// held before. Keys evicted on its behalf stay evicted, so their deletes
+// are logged on their own.
+func (c *Application) rollback(ctx context.Context, tx *transaction) {
+	for key, entry := range tx.undo {
+		var err error
+		if entry == nil {
+			err = c.repo.Delete(ctx, key)
+		} else {
+			err = c.repo.Put(ctx, *entry)
+		}
+		if err != nil && !errors.Is(err, domain.ErrKeyNotFound) {
+			c.logger.Errorf("failed to roll back key: %s, err: %v", key, err)
+		}
+	}
+
+	for _, key := range tx.evicted {
+		if entry, ok := tx.undo[key]; ok && entry != nil {
+			continue // restored above
+		}
+		if c.wal != nil {
+			lsn, err := c.wal.WriteDel(key)
+			if _, err = logged(err); err != nil {
+				c.logger.Errorf("failed to log eviction of key: %s, err: %v", key, err)
+				continue
+			}
+			c.committed(ctx, lsn)
+		}
+	}
+}
+
+// currentVersion returns the version of the stored key, zero when missing.
+func (c *Application) currentVersion(ctx context.Context, key domain.Key) (uint64, error) {
+	current, err := c.lookup(ctx, key)
+	if err != nil || current == nil {
+		return 0, err
+	}
+	return current.Version, nil
+}
@@ -0,0 +1,232 @@
+package domain
+
+import (
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Permission is what a user may do, each one includes the ones before it.
+type Permission int
+
+const (
+	// PermissionNone is needed by commands that touch no data, such as PING.
+	PermissionNone Permission = iota
+	PermissionRead
+	PermissionWrite
+	// PermissionAdmin is needed by commands over the whole store, such as
+	// FLUSHDB.
+	PermissionAdmin
+)
+
+// ParsePermission parses the role of a user: read-only, read-write or admin.
+func ParsePermission(role string) (Permission, error) {
+	switch role {
+	case "read-only":
+		return PermissionRead, nil
+	case "read-write":
+		return PermissionWrite, nil
+	case "admin":
+		return PermissionAdmin, nil
+	}
+	return PermissionNone, fmt.Errorf("unknown role: %q", role)
+}
+
+// User is a client allowed to connect once authenticated.
+type User struct {
+	Name string
+	// PasswordHash is made by HashPassword.
+	PasswordHash string
+	Permission   Permission
+	// KeyPatterns are globs of the keys the user may access, within any
+	// namespace. A * matches any run of bytes, slashes included, and a ?
+	// any single byte. A user without patterns may access no key.
+	KeyPatterns []string
+	// Subject authenticates, without AUTH, the clients presenting a
+	// certificate with this subject over mutual TLS.
+	Subject string
+}
+
+// Authorize tells whether the user may run a command needing permission
+// over keys.
+func (u *User) Authorize(permission Permission, keys ...Key) error {
+	if permission > u.Permission {
+		return fmt.Errorf("%w: user %s may not run the command", ErrPermissionDenied, u.Name)
+	}
+	for _, key := range keys {
+		if !u.CanAccess(key) {
+			return fmt.Errorf("%w: user %s may not access the key", ErrPermissionDenied, u.Name)
+		}
+	}
+	return nil
+}
+
+// CanAccess reports whether a key matches one of the patterns of the user.
+func (u *User) CanAccess(key Key) bool {
+	for _, pattern := range u.KeyPatterns {
+		if matchPattern(pattern, string(key)) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPattern matches s against a glob of * and ?, backtracking only to
+// the last star, which is enough as a star matches any run.
+func matchPattern(pattern, s string) bool {
+	p, i := 0, 0
+	star, next := -1, 0
+	for i < len(s) {
+		switch {
+		case p < len(pattern) && (pattern[p] == '?' || pattern[p] == s[i]):
+			p++
+			i++
+		case p < len(pattern) && pattern[p] == '*':
+			star, next = p, i
+			p++
+		case star >= 0:
+			next++
+			p, i = star+1, next
+		default:
+			return false
+		}
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+// Users are the users a server knows.
+type Users struct {
+	byName    map[string]*User
+	bySubject map[string]*User
+
+	// hashing is slow on purpose, so the digest of the password last
+	// verified for each user is kept, for clients that authenticate every
+	// request, such as those of the HTTP gateway
+	mu       sync.Mutex
+	verified map[string][sha256.Size]byte
+}
+
+func NewUsers(users []User) (*Users, error) {
+	u := &Users{
+		byName:    make(map[string]*User, len(users)),
+		bySubject: make(map[string]*User),
+		verified:  make(map[string][sha256.Size]byte),
+	}
+	for i := range users {
+		user := &users[i]
+		if user.Name == "" {
+			return nil, fmt.Errorf("user %d has no name", i)
+		}
+		if _, ok := u.byName[user.Name]; ok {
+			return nil, fmt.Errorf("user %s is defined twice", user.Name)
+		}
+		if _, _, _, err := parsePasswordHash(user.PasswordHash); err != nil {
+			return nil, fmt.Errorf("user %s: %w", user.Name, err)
+		}
+		u.byName[user.Name] = user
+
+		if user.Subject == "" {
+			continue
+		}
+		if other, ok := u.bySubject[user.Subject]; ok {
+			return nil, fmt.Errorf("users %s and %s have the same subject", other.Name, user.Name)
+		}
+		u.bySubject[user.Subject] = user
+	}
+	return u, nil
+}
+
+// Authenticate returns the user of a name and password, or
+// ErrInvalidCredentials without telling which of the two is wrong.
+func (u *Users) Authenticate(name, password string) (*User, error) {
+	user, ok := u.byName[name]
+	if !ok {
+		return nil, ErrInvalidCredentials
+	}
+
+	digest := sha256.Sum256([]byte(password))
+	u.mu.Lock()
+	verified, ok := u.verified[name]
+	u.mu.Unlock()
+	if ok && subtle.ConstantTimeCompare(digest[:], verified[:]) == 1 {
+		return user, nil
+	}
+
+	if !checkPassword(user.PasswordHash, password) {
+		return nil, ErrInvalidCredentials
+	}
+	u.mu.Lock()
+	u.verified[name] = digest
+	u.mu.Unlock()
+	return user, nil
+}
+
+// BySubject returns the user a certificate subject authenticates, or nil.
+func (u *Users) BySubject(subject string) *User {
+	if subject == "" {
+		return nil
+	}
+	return u.bySubject[subject]
+}
+
+// Passwords are hashed with PBKDF2 over SHA-256 and kept as
+// "pbkdf2-sha256$<iterations>$<salt>$<hash>", salt and hash in base64.
+const (
+	passwordScheme     = "pbkdf2-sha256"
+	passwordIterations = 100_000
+	passwordSaltSize   = 16
+	passwordKeySize    = 32
+)
+
+// HashPassword hashes a password with a random salt.
+func HashPassword(password string) (string, error) {
+	salt := make([]byte, passwordSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key, err := pbkdf2.Key(sha256.New, password, salt, passwordIterations, passwordKeySize)
+	if err != nil {
+		return "", err
+	}
+	return strings.Join([]string{
+		passwordScheme,
+		strconv.Itoa(passwordIterations),
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	}, "$"), nil
+}
+
+func checkPassword(hash, password string) bool {
+	iterations, salt, want, err := parsePasswordHash(hash)
+	if err != nil {
+		return false
+	}
+	got, err := pbkdf2.Key(sha256.New, password, salt, iterations, len(want))
+	return err == nil && subtle.ConstantTimeCompare(got, want) == 1
+}
+
+func parsePasswordHash(hash string) (iterations int, salt, key []byte, err error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 4 || parts[0] != passwordScheme {
+		return 0, nil, nil, ErrInvalidPasswordHash
+	}
+	if iterations, err = strconv.Atoi(parts[1]); err != nil || iterations <= 0 {
+		return 0, nil, nil, ErrInvalidPasswordHash
+	}
+	if salt, err = base64.RawStdEncoding.DecodeString(parts[2]); err != nil {
+		return 0, nil, nil, ErrInvalidPasswordHash
+	}
+	if key, err = base64.RawStdEncoding.DecodeString(parts[3]); err != nil || len(key) == 0 {
+		return 0, nil, nil, ErrInvalidPasswordHash
+	}
+	return iterations, salt, key, nil
+}
@@ -0,0 +1,100 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchPattern(t *testing.T) {
+	tests := []struct {
+		pattern, s string
+		want       bool
+	}{
+		{"*", "anything", true},
+		{"*", "", true},
+		{"billing/*", "billing/2024/march", true},
+		{"billing/*", "billing/", true},
+		{"billing/*", "billing", false},
+		{"billing/*", "ops/billing/a", false},
+		{"*/invoice", "billing/a/invoice", true},
+		{"*/invoice", "billing/a/invoices", false},
+		{"user:?", "user:1", true},
+		{"user:?", "user:12", false},
+		{"a*b*c", "aXbYbZc", true},
+		{"a*b*c", "aXcYb", false},
+		{"exact", "exact", true},
+		{"exact", "exactly", false},
+	}
+	for _, tt := range tests {
+		require.Equal(t, tt.want, matchPattern(tt.pattern, tt.s), "%q %q", tt.pattern, tt.s)
+	}
+}
+
+func TestUser_Authorize(t *testing.T) {
+	user := &User{Name: "billing", Permission: PermissionWrite, KeyPatterns: []string{"billing/*", "shared"}}
+
+	require.NoError(t, user.Authorize(PermissionRead, "billing/a", "shared"))
+	require.NoError(t, user.Authorize(PermissionWrite, "billing/a"))
+	require.NoError(t, user.Authorize(PermissionNone))
+	require.ErrorIs(t, user.Authorize(PermissionAdmin), ErrPermissionDenied)
+	require.ErrorIs(t, user.Authorize(PermissionRead, "billing/a", "ops/a"), ErrPermissionDenied)
+
+	// a user without patterns may access no key
+	require.ErrorIs(t, (&User{Permission: PermissionAdmin}).Authorize(PermissionRead, "a"), ErrPermissionDenied)
+}
+
+func TestParsePermission(t *testing.T) {
+	for role, want := range map[string]Permission{
+		"read-only":  PermissionRead,
+		"read-write": PermissionWrite,
+		"admin":      PermissionAdmin,
+	} {
+		got, err := ParsePermission(role)
+		require.NoError(t, err)
+		require.Equal(t, want, got)
+	}
+	_, err := ParsePermission("root")
+	require.Error(t, err)
+}
+
+func TestUsers_Authenticate(t *testing.T) {
+	hash, err := HashPassword("secret")
+	require.NoError(t, err)
+
+	users, err := NewUsers([]User{
+		{Name: "alice", PasswordHash: hash, Permission: PermissionRead, Subject: "CN=alice"},
+		{Name: "bob", PasswordHash: hash, Permission: PermissionAdmin},
+	})
+	require.NoError(t, err)
+
+	// the second time the password is known without hashing it again
+	for range 2 {
+		user, err := users.Authenticate("alice", "secret")
+		require.NoError(t, err)
+		require.Equal(t, "alice", user.Name)
+	}
+	_, err = users.Authenticate("alice", "wrong")
+	require.ErrorIs(t, err, ErrInvalidCredentials)
+	_, err = users.Authenticate("carol", "secret")
+	require.ErrorIs(t, err, ErrInvalidCredentials)
+
+	require.Equal(t, "alice", users.BySubject("CN=alice").Name)
+	require.Nil(t, users.BySubject("CN=bob"))
+	require.Nil(t, users.BySubject(""))
+}
+
+func TestNewUsers_Invalid(t *testing.T) {
+	hash, err := HashPassword("secret")
+	require.NoError(t, err)
+
+	for name, users := range map[string][]User{
+		"no name":        {{PasswordHash: hash}},
+		"duplicate":      {{Name: "a", PasswordHash: hash}, {Name: "a", PasswordHash: hash}},
+		"plain password": {{Name: "a", PasswordHash: "secret"}},
+		"same subject":   {{Name: "a", PasswordHash: hash, Subject: "CN=a"}, {Name: "b", PasswordHash: hash, Subject: "CN=a"}},
+	} {
+		_, err := NewUsers(users)
+		require.Error(t, err, name)
+	}
+}
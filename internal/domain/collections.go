@@ -0,0 +1,156 @@
+package domain
+
+import "sort"
+
+// The functions below implement collection writes on the Items of an entry.
+// None of them modifies the items it is given, so an entry that was read
+// can be shared with other readers while a new version of it is built.
+
+// HashField is a field of a hash together with its value.
+type HashField struct {
+	Field Value
+	Value Value
+}
+
+// HashFields returns the fields of hash items in field order.
+func HashFields(items []Value) []HashField {
+	fields := make([]HashField, 0, len(items)/2)
+	for i := 0; i+1 < len(items); i += 2 {
+		fields = append(fields, HashField{Field: items[i], Value: items[i+1]})
+	}
+	return fields
+}
+
+// HashGet returns the value of a field of hash items.
+func HashGet(items []Value, field Value) (Value, bool) {
+	i, ok := hashSearch(items, field)
+	if !ok {
+		return "", false
+	}
+	return items[2*i+1], true
+}
+
+// HashSet returns hash items with the fields set, along with the number of
+// fields that were not there before. A field given twice keeps the last value.
+func HashSet(items []Value, fields []HashField) ([]Value, int) {
+	set := make(map[Value]Value, len(fields))
+	for _, f := range fields {
+		set[f.Field] = f.Value
+	}
+
+	updated := make([]Value, 0, len(items)+2*len(set))
+	for _, f := range HashFields(items) {
+		if v, ok := set[f.Field]; ok {
+			f.Value = v
+			delete(set, f.Field)
+		}
+		updated = append(updated, f.Field, f.Value)
+	}
+	added := len(set)
+	for f, v := range set {
+		updated = append(updated, f, v)
+	}
+	return sortHash(updated), added
+}
+
+// HashDelete returns hash items without the fields, along with the number
+// of fields removed.
+func HashDelete(items []Value, fields []Value) ([]Value, int) {
+	remove := make(map[Value]bool, len(fields))
+	for _, f := range fields {
+		remove[f] = true
+	}
+
+	updated := make([]Value, 0, len(items))
+	for _, f := range HashFields(items) {
+		if !remove[f.Field] {
+			updated = append(updated, f.Field, f.Value)
+		}
+	}
+	return updated, (len(items) - len(updated)) / 2
+}
+
+func hashSearch(items []Value, field Value) (int, bool) {
+	n := len(items) / 2
+	i := sort.Search(n, func(i int) bool { return items[2*i] >= field })
+	return i, i < n && items[2*i] == field
+}
+
+func sortHash(items []Value) []Value {
+	fields := HashFields(items)
+	sort.Slice(fields, func(i, j int) bool { return fields[i].Field < fields[j].Field })
+	sorted := make([]Value, 0, len(items))
+	for _, f := range fields {
+		sorted = append(sorted, f.Field, f.Value)
+	}
+	return sorted
+}
+
+// ListPush returns list items with the values added at the head when left
+// is set, or at the tail otherwise. Pushing to the head reverses the values,
+// as pushing them one by one would.
+func ListPush(items []Value, values []Value, left bool) []Value {
+	updated := make([]Value, 0, len(items)+len(values))
+	if !left {
+		return append(append(updated, items...), values...)
+	}
+	for i := len(values) - 1; i >= 0; i-- {
+		updated = append(updated, values[i])
+	}
+	return append(updated, items...)
+}
+
+// ListRange returns the list items from start to stop inclusive. Negative
+// indexes count from the end of the list, and out of range ones are clamped.
+func ListRange(items []Value, start, stop int) []Value {
+	n := len(items)
+	if start < 0 {
+		start = max(n+start, 0)
+	}
+	if stop < 0 {
+		stop = n + stop
+	}
+	stop = min(stop, n-1)
+	if start > stop {
+		return nil
+	}
+	return append([]Value(nil), items[start:stop+1]...)
+}
+
+// SetContains reports whether member belongs to set items.
+func SetContains(items []Value, member Value) bool {
+	i := sort.Search(len(items), func(i int) bool { return items[i] >= member })
+	return i < len(items) && items[i] == member
+}
+
+// SetAdd returns set items with the members added, along with the number
+// of members that were not there before.
+func SetAdd(items []Value, members []Value) ([]Value, int) {
+	added := make(map[Value]bool, len(members))
+	updated := append([]Value(nil), items...)
+	for _, m := range members {
+		if !added[m] && !SetContains(items, m) {
+			added[m] = true
+			updated = append(updated, m)
+		}
+	}
+	sort.Slice(updated, func(i, j int) bool { return updated[i] < updated[j] })
+	return updated, len(added)
+}
+
+// SetRemove returns set items without the members, along with the number
+// of members removed.
+func SetRemove(items []Value, members []Value) ([]Value, int) {
+	remove := make(map[Value]bool, len(members))
+	for _, m := range members {
+		remove[m] = true
+	}
+
+	updated := make([]Value, 0, len(items))
+	for _, m := range items {
+		if !remove[m] {
+			updated = append(updated, m)
+		}
+	}
+	return updated, len(items) - len(updated)
+}
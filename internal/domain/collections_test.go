@@ -0,0 +1,55 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHash(t *testing.T) {
+	items, added := HashSet(nil, []HashField{{"b", "2"}, {"a", "1"}, {"b", "3"}})
+	require.Equal(t, 2, added)
+	require.Equal(t, []Value{"a", "1", "b", "3"}, items)
+
+	updated, added := HashSet(items, []HashField{{"a", "9"}, {"c", "4"}})
+	require.Equal(t, 1, added)
+	require.Equal(t, []Value{"a", "9", "b", "3", "c", "4"}, updated)
+	require.Equal(t, []Value{"a", "1", "b", "3"}, items, "the original items are kept")
+
+	v, ok := HashGet(updated, "c")
+	require.True(t, ok)
+	require.Equal(t, Value("4"), v)
+	_, ok = HashGet(updated, "d")
+	require.False(t, ok)
+
+	updated, removed := HashDelete(updated, []Value{"a", "d"})
+	require.Equal(t, 1, removed)
+	require.Equal(t, []HashField{{"b", "3"}, {"c", "4"}}, HashFields(updated))
+}
+
+func TestList(t *testing.T) {
+	items := ListPush(nil, []Value{"a", "b"}, false)
+	items = ListPush(items, []Value{"c", "d"}, true)
+	require.Equal(t, []Value{"d", "c", "a", "b"}, items)
+
+	require.Equal(t, items, ListRange(items, 0, -1))
+	require.Equal(t, []Value{"c", "a"}, ListRange(items, 1, 2))
+	require.Equal(t, []Value{"a", "b"}, ListRange(items, -2, 100))
+	require.Empty(t, ListRange(items, 3, 1))
+	require.Empty(t, ListRange(items, 5, 10))
+}
+
+func TestSet(t *testing.T) {
+	items, added := SetAdd(nil, []Value{"b", "a", "b"})
+	require.Equal(t, 2, added)
+	require.Equal(t, []Value{"a", "b"}, items)
+
+	items, added = SetAdd(items, []Value{"c", "a"})
+	require.Equal(t, 1, added)
+	require.True(t, SetContains(items, "c"))
+	require.False(t, SetContains(items, "d"))
+
+	items, removed := SetRemove(items, []Value{"a", "d"})
+	require.Equal(t, 1, removed)
+	require.Equal(t, []Value{"b", "c"}, items)
+}
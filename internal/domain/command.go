@@ -0,0 +1,95 @@
+package domain
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Commands of the text protocol, the WAL and snapshots are lines of
+// arguments separated by spaces. An argument starting with a double quote is
+// a Go string literal, so arguments can hold any bytes, spaces and newlines
+// included.
+
+// commandSeparator is an argument that separates commands in a line.
+const commandSeparator = ";"
+
+// FormatCommand joins args into a line, quoting the ones that are empty, hold
+// anything but printable ASCII, quotes or backslashes, or would be taken for
+// a separator of commands. A quoted argument never holds a plain space.
+func FormatCommand(args ...string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = arg
+		if needsQuotes(arg) {
+			quoted[i] = quote(arg)
+		}
+	}
+	return strings.Join(quoted, " ")
+}
+
+// quote writes arg as a Go string literal holding no plain space.
+func quote(arg string) string {
+	return strings.ReplaceAll(strconv.QuoteToASCII(arg), " ", `\x20`)
+}
+
+func needsQuotes(arg string) bool {
+	if arg == "" || arg == commandSeparator {
+		return true
+	}
+	for i := 0; i < len(arg); i++ {
+		if c := arg[i]; c <= ' ' || c >= 0x7f || c == '"' || c == '\\' {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseCommand splits a line into its arguments, unquoting the quoted ones.
+func ParseCommand(line string) ([]string, error) {
+	var args []string
+	for {
+		line = strings.TrimLeftFunc(line, unicode.IsSpace)
+		if line == "" {
+			return args, nil
+		}
+
+		if line[0] != '"' {
+			end := strings.IndexFunc(line, unicode.IsSpace)
+			if end < 0 {
+				end = len(line)
+			}
+			args = append(args, line[:end])
+			line = line[end:]
+			continue
+		}
+
+		end := closingQuote(line)
+		if end < 0 {
+			return nil, ErrInvalidQuoting
+		}
+		arg, err := strconv.Unquote(line[:end+1])
+		if err != nil {
+			return nil, ErrInvalidQuoting
+		}
+		line = line[end+1:]
+		if line != "" && !unicode.IsSpace(rune(line[0])) {
+			return nil, ErrInvalidQuoting
+		}
+		args = append(args, arg)
+	}
+}
+
+// closingQuote returns the index of the quote ending the quoted argument s
+// starts with, or -1 when there is none.
+func closingQuote(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,71 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatCommand(t *testing.T) {
+	require.Equal(t, "SET foo bar", FormatCommand("SET", "foo", "bar"))
+	require.Equal(t, `SET "a\x20b" "" ";" "\"\n\x00\xff"`, FormatCommand("SET", "a b", "", ";", "\"\n\x00\xff"))
+}
+
+func TestParseCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		want    []string
+		wantErr error
+	}{
+		{
+			name: "plain arguments",
+			line: "  SET foo   bar ",
+			want: []string{"SET", "foo", "bar"},
+		},
+		{
+			name: "quoted arguments",
+			line: `SET "hello world" "a\"b\n" ""`,
+			want: []string{"SET", "hello world", "a\"b\n", ""},
+		},
+		{
+			name: "quote inside an argument",
+			line: `SET a"b c`,
+			want: []string{"SET", `a"b`, "c"},
+		},
+		{
+			name: "empty line",
+			line: "   ",
+		},
+		{
+			name:    "unterminated quote",
+			line:    `SET "foo`,
+			wantErr: ErrInvalidQuoting,
+		},
+		{
+			name:    "text after a closing quote",
+			line:    `SET "foo"bar`,
+			wantErr: ErrInvalidQuoting,
+		},
+		{
+			name:    "invalid escape",
+			line:    `SET "\q"`,
+			wantErr: ErrInvalidQuoting,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseCommand(tt.line)
+			require.ErrorIs(t, err, tt.wantErr)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseCommand_RoundTrip(t *testing.T) {
+	args := []string{"SET", "k\x00ey", "any \"bytes\"\r\n\t\\ \xfe", ";", ""}
+	got, err := ParseCommand(FormatCommand(args...))
+	require.NoError(t, err)
+	require.Equal(t, args, got)
+}
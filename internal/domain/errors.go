@@ -3,7 +3,23 @@ package domain
 import "errors"
 
 var (
-	ErrKeyNotFound     = errors.New("key not found")
-	ErrKeyIsNotValid   = errors.New("key is not valid")
-	ErrValueIsNotValid = errors.New("value is not valid")
+	ErrKeyNotFound         = errors.New("key not found")
+	ErrKeyIsNotValid       = errors.New("key is not valid")
+	ErrValueIsNotValid     = errors.New("value is not valid")
+	ErrOutOfMemory         = errors.New("memory limit reached")
+	ErrDurabilityUnknown   = errors.New("write logged, durability unknown")
+	ErrKeyExists           = errors.New("key already exists")
+	ErrVersionMismatch     = errors.New("version mismatch")
+	ErrNotInteger          = errors.New("value is not an integer")
+	ErrOverflow            = errors.New("increment or decrement would overflow")
+	ErrNamespaceIsNotValid = errors.New("namespace is not valid")
+	ErrNoSession           = errors.New("command requires a session")
+	ErrWatchedKeyChanged   = errors.New("watched key changed")
+	ErrWrongType           = errors.New("operation against a key holding the wrong kind of value")
+	ErrInvalidQuoting      = errors.New("invalid quoted argument")
+	ErrInvalidReply        = errors.New("invalid reply")
+	ErrAuthRequired        = errors.New("authentication required")
+	ErrInvalidCredentials  = errors.New("invalid username-password pair")
+	ErrPermissionDenied    = errors.New("permission denied")
+	ErrInvalidPasswordHash = errors.New("password hash is not valid")
 )
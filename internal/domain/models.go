@@ -1,11 +1,21 @@
 package domain
 
-import "github.com/rdimidov/kvstore/internal/domain/validator"
+import (
+	"strings"
+	"time"
+)
+
+// Keys and values are arbitrary bytes. A key cannot be empty nor start with
+// the namespace mark, which is reserved for keys of other namespaces.
+const (
+	MaxKeySize   = 64 << 10
+	MaxValueSize = 512 << 20
+)
 
 type Key string
 
 func NewKey(k string) (Key, error) {
-	if !validator.IsValidString(k) {
+	if k == "" || len(k) > MaxKeySize || strings.HasPrefix(k, namespaceMark) {
 		return "", ErrKeyIsNotValid
 	}
 	return Key(k), nil
@@ -18,7 +28,7 @@ func (k Key) String() string {
 type Value string
 
 func NewValue(v string) (Value, error) {
-	if !validator.IsValidString(v) {
+	if len(v) > MaxValueSize {
 		return "", ErrValueIsNotValid
 	}
 	return Value(v), nil
@@ -28,11 +38,56 @@ func (v Value) String() string {
 	return string(v)
 }
 
+// Type is the kind of value a key holds.
+type Type uint8
+
+const (
+	TypeString Type = iota
+	TypeHash
+	TypeList
+	TypeSet
+)
+
+func (t Type) String() string {
+	switch t {
+	case TypeHash:
+		return "hash"
+	case TypeList:
+		return "list"
+	case TypeSet:
+		return "set"
+	}
+	return "string"
+}
+
 type Entry struct {
 	Key   Key
 	Value Value
+	// Type tells Value of a string from Items of a collection.
+	Type Type
+	// Items hold a collection: the field and value pairs of a hash sorted
+	// by field, the elements of a list in order, or the members of a set
+	// sorted. Items are never changed in place, writes build new ones.
+	Items []Value
+	// ExpiresAt is the absolute deadline after which the entry is gone.
+	// Zero value means the entry never expires.
+	ExpiresAt time.Time
+	// Version grows with every write of the value, so a client can tell
+	// whether the value changed since it was read. Zero means the entry
+	// was never versioned; a missing key is treated as version zero.
+	Version uint64
 }
 
 func NewEntryFromKV(k Key, v Value) Entry {
-	return Entry{k, v}
+	return Entry{Key: k, Value: v}
+}
+
+// HasExpiry reports whether the entry carries an expiration deadline.
+func (e Entry) HasExpiry() bool {
+	return !e.ExpiresAt.IsZero()
+}
+
+// IsExpired reports whether the entry is already expired at the given moment.
+func (e Entry) IsExpired(now time.Time) bool {
+	return e.HasExpiry() && !now.Before(e.ExpiresAt)
 }
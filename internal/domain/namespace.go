@@ -0,0 +1,139 @@
+package domain
+
+import (
+	"context"
+	"strings"
+
+	"github.com/rdimidov/kvstore/internal/domain/validator"
+)
+
+// Namespace is an isolated keyspace. Keys of different namespaces never
+// collide, and a client works in one namespace at a time.
+type Namespace string
+
+// DefaultNamespace is the namespace of clients that never selected one.
+// Its keys are stored as they are, so data written before namespaces
+// existed belongs to it.
+const DefaultNamespace Namespace = "0"
+
+// namespaceMark cannot appear in namespace names nor start a key. Keys of
+// other namespaces are stored as "\x00<namespace>\x00<key>", which sorts
+// them before every key of the default namespace and keeps each namespace
+// contiguous.
+const namespaceMark = "\x00"
+
+func NewNamespace(ns string) (Namespace, error) {
+	if !validator.IsValidString(ns) {
+		return "", ErrNamespaceIsNotValid
+	}
+	return Namespace(ns), nil
+}
+
+func (n Namespace) String() string {
+	return string(n)
+}
+
+// Key returns the key under which k of the namespace is stored.
+func (n Namespace) Key(k Key) Key {
+	if n == DefaultNamespace {
+		return k
+	}
+	return Key(namespaceMark + string(n) + namespaceMark + string(k))
+}
+
+// Bounds turns a key range of the namespace into the range of stored keys.
+// Empty bounds stay the bounds of the namespace rather than of the storage.
+func (n Namespace) Bounds(start, end Key) (Key, Key) {
+	// the mark sorts right before the byte that follows it
+	after := string(namespaceMark[0] + 1)
+
+	if n == DefaultNamespace {
+		return max(start, Key(after)), end
+	}
+	if end == "" {
+		return n.Key(start), Key(namespaceMark + string(n) + after)
+	}
+	return n.Key(start), n.Key(end)
+}
+
+// SplitKey returns the namespace of a stored key and the key within it.
+func SplitKey(k Key) (Namespace, Key) {
+	rest, ok := strings.CutPrefix(string(k), namespaceMark)
+	if !ok {
+		return DefaultNamespace, k
+	}
+	ns, key, ok := strings.Cut(rest, namespaceMark)
+	if !ok {
+		return DefaultNamespace, k
+	}
+	return Namespace(ns), Key(key)
+}
+
+// Session is the state a client keeps between its commands.
+type Session struct {
+	Namespace Namespace
+	// Queued holds the commands given since MULTI, and is nil outside of
+	// a transaction.
+	Queued []string
+	// Watched holds the versions the stored keys given to WATCH had then.
+	Watched map[Key]uint64
+	// RESPVersion is the version of RESP replies the client chose with
+	// HELLO, zero when it did not.
+	RESPVersion int
+	// Subject is the subject of the certificate the client presented over
+	// TLS, such as "CN=alice,O=ops", and is empty when it presented none.
+	Subject string
+	// User is the user the client authenticated as, nil until it did.
+	User *User
+	// LSN is the one the last write of the client was committed at, zero
+	// until it wrote. It tells how far the log has to be for a later read to
+	// see the writes of the client.
+	LSN LSN
+}
+
+// LSN is the log sequence number of a record of the WAL. It grows by one
+// with every record, so it orders writes.
+type LSN uint64
+
+// InTransaction reports whether commands are being queued after MULTI.
+func (s *Session) InTransaction() bool {
+	return s.Queued != nil
+}
+
+type sessionKey struct{}
+
+// WithSession returns a context carrying the session of the client the
+// commands executed with it come from.
+func WithSession(ctx context.Context, s *Session) context.Context {
+	return context.WithValue(ctx, sessionKey{}, s)
+}
+
+// SessionFrom returns the session carried by ctx, or nil when there is none.
+func SessionFrom(ctx context.Context) *Session {
+	s, _ := ctx.Value(sessionKey{}).(*Session)
+	return s
+}
+
+type syncKey struct{}
+
+// WithSync returns a context asking that the writes made with it be synced
+// to disk before they are acknowledged, whatever the fsync policy of the
+// WAL.
+func WithSync(ctx context.Context) context.Context {
+	return context.WithValue(ctx, syncKey{}, true)
+}
+
+// SyncRequested reports whether ctx asks for synced writes.
+func SyncRequested(ctx context.Context) bool {
+	sync, _ := ctx.Value(syncKey{}).(bool)
+	return sync
+}
+
+// NamespaceFrom returns the namespace selected in the session carried by
+// ctx, or the default one.
+func NamespaceFrom(ctx context.Context) Namespace {
+	if s := SessionFrom(ctx); s != nil && s.Namespace != "" {
+		return s.Namespace
+	}
+	return DefaultNamespace
+}
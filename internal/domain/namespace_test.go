@@ -0,0 +1,53 @@
+package domain
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNamespace_Key(t *testing.T) {
+	require.Equal(t, Key("foo"), DefaultNamespace.Key("foo"))
+	require.Equal(t, Key("\x00team\x00foo"), Namespace("team").Key("foo"))
+
+	ns, key := SplitKey("\x00team\x00foo")
+	require.Equal(t, Namespace("team"), ns)
+	require.Equal(t, Key("foo"), key)
+
+	ns, key = SplitKey("foo")
+	require.Equal(t, DefaultNamespace, ns)
+	require.Equal(t, Key("foo"), key)
+}
+
+func TestNamespace_Bounds(t *testing.T) {
+	team := Namespace("team")
+	start, end := team.Bounds("", "")
+	for _, k := range []Key{"\x00team\x00a", "\x00team\x00\xff"} {
+		require.True(t, k >= start && k < end, k)
+	}
+	for _, k := range []Key{"zz", "\x00te\x00a", "\x00teama\x00a", "\x00tean\x00a"} {
+		require.False(t, k >= start && k < end, k)
+	}
+
+	start, end = DefaultNamespace.Bounds("", "")
+	require.Equal(t, Key(""), end)
+	require.True(t, Key("\x01") >= start)
+	require.False(t, Key("\x00team\x00a") >= start)
+
+	start, end = team.Bounds("a", "c")
+	require.Equal(t, Key("\x00team\x00a"), start)
+	require.Equal(t, Key("\x00team\x00c"), end)
+}
+
+func TestNamespaceFrom(t *testing.T) {
+	ctx := context.Background()
+	require.Equal(t, DefaultNamespace, NamespaceFrom(ctx))
+
+	session := &Session{}
+	ctx = WithSession(ctx, session)
+	require.Equal(t, DefaultNamespace, NamespaceFrom(ctx))
+
+	session.Namespace = "team"
+	require.Equal(t, Namespace("team"), NamespaceFrom(ctx))
+}
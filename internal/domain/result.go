@@ -0,0 +1,190 @@
+package domain
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Replies of the text protocol take a single line, so clients can tell where
+// one ends whatever it holds. A value is written the way FormatCommand writes
+// an argument, and also quoted when it holds brackets or reads as nil; a list
+// is its items between brackets. A value reading as a number or OK is read
+// back as one, which prints the same.
+
+// nilReply stands for a nil result, in replies and when printed.
+const nilReply = "(nil)"
+
+// ResultKind tells how a command result should be presented to a client.
+type ResultKind int
+
+const (
+	ResultOK ResultKind = iota
+	ResultValue
+	ResultInteger
+	ResultNil
+	ResultList
+)
+
+// Result is the reply produced by a successfully executed command.
+type Result struct {
+	Kind    ResultKind
+	Value   Value
+	Integer int64
+	List    []Result
+}
+
+func OKResult() Result {
+	return Result{Kind: ResultOK}
+}
+
+func ValueResult(v Value) Result {
+	return Result{Kind: ResultValue, Value: v}
+}
+
+func IntegerResult(n int64) Result {
+	return Result{Kind: ResultInteger, Integer: n}
+}
+
+// NilResult marks an explicitly absent value, e.g. the end of a scan.
+func NilResult() Result {
+	return Result{Kind: ResultNil}
+}
+
+func ListResult(items ...Result) Result {
+	return Result{Kind: ResultList, List: items}
+}
+
+// String renders the result for humans; nested lists are numbered
+// and indented the way redis-cli prints them.
+func (r Result) String() string {
+	switch r.Kind {
+	case ResultValue:
+		return r.Value.String()
+	case ResultInteger:
+		return strconv.FormatInt(r.Integer, 10)
+	case ResultNil:
+		return nilReply
+	case ResultList:
+		if len(r.List) == 0 {
+			return "(empty list)"
+		}
+		var b strings.Builder
+		for i, item := range r.List {
+			prefix := strconv.Itoa(i+1) + ") "
+			indent := strings.Repeat(" ", len(prefix))
+			lines := strings.Split(item.String(), "\n")
+			for j, line := range lines {
+				if i > 0 || j > 0 {
+					b.WriteByte('\n')
+				}
+				if j == 0 {
+					b.WriteString(prefix)
+				} else {
+					b.WriteString(indent)
+				}
+				b.WriteString(line)
+			}
+		}
+		return b.String()
+	}
+	return "OK"
+}
+
+// FormatResult writes the result on a single line, see ParseResult.
+func FormatResult(r Result) string {
+	return string(appendResult(nil, r))
+}
+
+func appendResult(b []byte, r Result) []byte {
+	switch r.Kind {
+	case ResultValue:
+		v := string(r.Value)
+		if !needsQuotes(v) && v != nilReply && !strings.ContainsAny(v, "[]") {
+			return append(b, v...)
+		}
+		return append(b, quote(v)...)
+	case ResultList:
+		b = append(b, '[')
+		for i, item := range r.List {
+			if i > 0 {
+				b = append(b, ' ')
+			}
+			b = appendResult(b, item)
+		}
+		return append(b, ']')
+	}
+	return append(b, r.String()...)
+}
+
+// ParseResult reads back a result written by FormatResult. Error replies of
+// the text protocol, words separated by spaces, fail with ErrInvalidReply.
+func ParseResult(line string) (Result, error) {
+	r, rest, err := parseResult(line)
+	if err != nil {
+		return Result{}, err
+	}
+	if rest != "" {
+		return Result{}, ErrInvalidReply
+	}
+	return r, nil
+}
+
+// parseResult reads the result s starts with and returns what follows it.
+func parseResult(s string) (Result, string, error) {
+	if s == "" {
+		return Result{}, "", ErrInvalidReply
+	}
+
+	switch s[0] {
+	case '[':
+		var items []Result
+		for s = s[1:]; ; {
+			if s == "" {
+				return Result{}, "", ErrInvalidReply
+			}
+			if s[0] == ']' {
+				return ListResult(items...), s[1:], nil
+			}
+			if len(items) > 0 {
+				if s[0] != ' ' {
+					return Result{}, "", ErrInvalidReply
+				}
+				s = s[1:]
+			}
+			item, rest, err := parseResult(s)
+			if err != nil {
+				return Result{}, "", err
+			}
+			items, s = append(items, item), rest
+		}
+
+	case '"':
+		end := closingQuote(s)
+		if end < 0 {
+			return Result{}, "", ErrInvalidReply
+		}
+		v, err := strconv.Unquote(s[:end+1])
+		if err != nil {
+			return Result{}, "", ErrInvalidReply
+		}
+		return ValueResult(Value(v)), s[end+1:], nil
+	}
+
+	end := strings.IndexAny(s, " []")
+	if end < 0 {
+		end = len(s)
+	}
+	token, rest := s[:end], s[end:]
+	switch token {
+	case "":
+		return Result{}, "", ErrInvalidReply
+	case "OK":
+		return OKResult(), rest, nil
+	case nilReply:
+		return NilResult(), rest, nil
+	}
+	if n, err := strconv.ParseInt(token, 10, 64); err == nil {
+		return IntegerResult(n), rest, nil
+	}
+	return ValueResult(Value(token)), rest, nil
+}
@@ -0,0 +1,100 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResult_String(t *testing.T) {
+	tests := []struct {
+		name   string
+		result Result
+		want   string
+	}{
+		{
+			name:   "ok",
+			result: OKResult(),
+			want:   "OK",
+		},
+		{
+			name:   "value",
+			result: ValueResult("bar"),
+			want:   "bar",
+		},
+		{
+			name:   "integer",
+			result: IntegerResult(-2),
+			want:   "-2",
+		},
+		{
+			name:   "empty list",
+			result: ListResult(),
+			want:   "(empty list)",
+		},
+		{
+			name:   "nested list",
+			result: ListResult(NilResult(), ListResult(ValueResult("k"), ValueResult("v"))),
+			want:   "1) (nil)\n2) 1) k\n   2) v",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, tt.result.String())
+		})
+	}
+}
+
+func TestFormatResult(t *testing.T) {
+	tests := []struct {
+		name   string
+		result Result
+		want   string
+	}{
+		{
+			name:   "ok",
+			result: OKResult(),
+			want:   "OK",
+		},
+		{
+			name:   "value",
+			result: ValueResult("bar"),
+			want:   "bar",
+		},
+		{
+			name:   "value with a newline",
+			result: ValueResult("a b\nc"),
+			want:   `"a\x20b\nc"`,
+		},
+		{
+			name:   "value that reads as nil or a list",
+			result: ListResult(ValueResult(nilReply), ValueResult("[x]")),
+			want:   `["(nil)" "[x]"]`,
+		},
+		{
+			name:   "nested list",
+			result: ListResult(NilResult(), ListResult(ValueResult("k"), IntegerResult(7)), ListResult()),
+			want:   "[(nil) [k 7] []]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			line := FormatResult(tt.result)
+			require.Equal(t, tt.want, line)
+			require.NotContains(t, line, "\n")
+
+			got, err := ParseResult(line)
+			require.NoError(t, err)
+			require.Equal(t, tt.result, got)
+		})
+	}
+}
+
+func TestParseResult_Invalid(t *testing.T) {
+	for _, line := range []string{"", "ERR key not found", "[a b", "[a]]", "[a  b]", `"foo`, "[a[b]]"} {
+		_, err := ParseResult(line)
+		require.ErrorIs(t, err, ErrInvalidReply, line)
+	}
+}
@@ -4,7 +4,8 @@ import "regexp"
 
 var re = regexp.MustCompile(`^[A-Za-z0-9*/_]+$`)
 
-// At the moment we have same requirements for keys and values
+// IsValidString reports whether s may be used as a name, such as the name
+// of a namespace. Keys and values are not restricted.
 func IsValidString(s string) bool {
 	return re.MatchString(s)
 }
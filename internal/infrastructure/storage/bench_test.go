@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/rdimidov/kvstore/internal/domain"
+)
+
+const benchKeys = 1 << 14
+
+type benchEngine interface {
+	Get(context.Context, domain.Key) (*domain.Entry, error)
+	Set(context.Context, domain.Key, domain.Value) error
+}
+
+var benchKeySet = func() []domain.Key {
+	keys := make([]domain.Key, benchKeys)
+	for i := range keys {
+		keys[i] = domain.Key("key-" + strconv.Itoa(i))
+	}
+	return keys
+}()
+
+// benchmarkMixed runs a parallel workload where every writeEvery-th operation
+// is a SET and the rest are GETs over a pre-populated key set.
+func benchmarkMixed(b *testing.B, engine benchEngine, writeEvery int) {
+	ctx := context.Background()
+	for _, k := range benchKeySet {
+		_ = engine.Set(ctx, k, "value")
+	}
+
+	var seed atomic.Int64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := int(seed.Add(1)) * 7919
+		for pb.Next() {
+			key := benchKeySet[i%benchKeys]
+			if i%writeEvery == 0 {
+				_ = engine.Set(ctx, key, "value")
+			} else {
+				_, _ = engine.Get(ctx, key)
+			}
+			i++
+		}
+	})
+}
+
+func BenchmarkMemory_ReadHeavy(b *testing.B)  { benchmarkMixed(b, NewMemory(), 10) }
+func BenchmarkSharded_ReadHeavy(b *testing.B) { benchmarkMixed(b, NewSharded(DefaultShardCount), 10) }
+func BenchmarkOrdered_ReadHeavy(b *testing.B) { benchmarkMixed(b, NewOrdered(), 10) }
+func BenchmarkLSM_ReadHeavy(b *testing.B)     { benchmarkMixed(b, openBenchLSM(b), 10) }
+
+func BenchmarkMemory_Mixed(b *testing.B)  { benchmarkMixed(b, NewMemory(), 2) }
+func BenchmarkSharded_Mixed(b *testing.B) { benchmarkMixed(b, NewSharded(DefaultShardCount), 2) }
+func BenchmarkOrdered_Mixed(b *testing.B) { benchmarkMixed(b, NewOrdered(), 2) }
+func BenchmarkLSM_Mixed(b *testing.B)     { benchmarkMixed(b, openBenchLSM(b), 2) }
+
+func BenchmarkMemory_WriteOnly(b *testing.B)  { benchmarkMixed(b, NewMemory(), 1) }
+func BenchmarkSharded_WriteOnly(b *testing.B) { benchmarkMixed(b, NewSharded(DefaultShardCount), 1) }
+func BenchmarkOrdered_WriteOnly(b *testing.B) { benchmarkMixed(b, NewOrdered(), 1) }
+func BenchmarkLSM_WriteOnly(b *testing.B)     { benchmarkMixed(b, openBenchLSM(b), 1) }
+
+// openBenchLSM uses a small memtable so the pre-populated keys end up in
+// SSTables and reads go through the bloom filters and index blocks.
+func openBenchLSM(b *testing.B) *LSM {
+	l, err := OpenLSM(b.TempDir(), WithMemtableSize(64<<10))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { _ = l.Close() })
+	return l
+}
+
+// benchmarkScan pages through a prefix of the pre-populated key set.
+func benchmarkScan(b *testing.B, engine interface {
+	benchEngine
+	Scan(context.Context, domain.Key, domain.Key, int) ([]domain.Entry, error)
+}) {
+	ctx := context.Background()
+	for _, k := range benchKeySet {
+		_ = engine.Set(ctx, k, "value")
+	}
+
+	b.ResetTimer()
+	for range b.N {
+		_, _ = engine.Scan(ctx, "key-1", "key-2", 100)
+	}
+}
+
+func BenchmarkMemory_Scan(b *testing.B)  { benchmarkScan(b, NewMemory()) }
+func BenchmarkOrdered_Scan(b *testing.B) { benchmarkScan(b, NewOrdered()) }
+func BenchmarkLSM_Scan(b *testing.B)     { benchmarkScan(b, openBenchLSM(b)) }
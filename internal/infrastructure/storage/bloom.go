@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+)
+
+const (
+	// bloomBitsPerKey with bloomHashes probes gives a false positive rate
+	// of about 1%.
+	bloomBitsPerKey = 10
+	bloomHashes     = 7
+)
+
+// bloomFilter answers "definitely absent" for most keys that are not in an
+// SSTable, so point lookups skip reading its data blocks.
+type bloomFilter struct {
+	bits   []byte
+	hashes uint32
+}
+
+func newBloomFilter(keys int) *bloomFilter {
+	nbits := max(keys*bloomBitsPerKey, 64)
+	return &bloomFilter{bits: make([]byte, (nbits+7)/8), hashes: bloomHashes}
+}
+
+func bloomHash(key string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	return h.Sum64()
+}
+
+// probes derives every probe position from two halves of one 64-bit hash
+// (Kirsch-Mitzenmacher double hashing).
+func (b *bloomFilter) probes(sum uint64, fn func(bit uint32) bool) {
+	h1, h2 := uint32(sum), uint32(sum>>32)
+
+	nbits := uint32(len(b.bits) * 8)
+	for i := range b.hashes {
+		if !fn((h1 + i*h2) % nbits) {
+			return
+		}
+	}
+}
+
+// add inserts a key by its bloomHash.
+func (b *bloomFilter) add(sum uint64) {
+	b.probes(sum, func(bit uint32) bool {
+		b.bits[bit/8] |= 1 << (bit % 8)
+		return true
+	})
+}
+
+func (b *bloomFilter) mayContain(key string) bool {
+	found := true
+	b.probes(bloomHash(key), func(bit uint32) bool {
+		found = b.bits[bit/8]&(1<<(bit%8)) != 0
+		return found
+	})
+	return found
+}
+
+func (b *bloomFilter) marshal() []byte {
+	out := binary.LittleEndian.AppendUint32(nil, b.hashes)
+	return append(out, b.bits...)
+}
+
+func unmarshalBloomFilter(data []byte) (*bloomFilter, error) {
+	if len(data) <= 4 {
+		return nil, errors.New("bloom filter is truncated")
+	}
+	return &bloomFilter{
+		hashes: binary.LittleEndian.Uint32(data),
+		bits:   data[4:],
+	}, nil
+}
@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/rdimidov/kvstore/internal/domain"
+)
+
+// EvictionPolicy decides what happens when an engine reaches its memory limit.
+type EvictionPolicy string
+
+const (
+	// NoEviction rejects writes with domain.ErrOutOfMemory.
+	NoEviction EvictionPolicy = "noeviction"
+	// AllKeysLRU evicts the least recently used keys.
+	AllKeysLRU EvictionPolicy = "allkeys-lru"
+	// AllKeysLFU evicts the least frequently used keys.
+	AllKeysLFU EvictionPolicy = "allkeys-lfu"
+	// VolatileTTL evicts keys with an expiration, nearest deadline first.
+	VolatileTTL EvictionPolicy = "volatile-ttl"
+)
+
+const (
+	// evictionSample is how many candidates are compared to pick one victim.
+	// Like Redis, eviction is approximated by sampling instead of keeping
+	// every key in a global LRU/LFU order.
+	evictionSample = 5
+	// lfuDecayPeriod halves the hit counter of a key for every period it
+	// was not accessed, so keys that were hot long ago can be evicted.
+	lfuDecayPeriod = time.Minute
+	// entryOverhead approximates the bookkeeping bytes of one entry
+	// (map bucket, entry struct, string headers).
+	entryOverhead = 64
+	// itemOverhead is the string header of one item of a collection.
+	itemOverhead = 16
+)
+
+func ParseEvictionPolicy(s string) (EvictionPolicy, error) {
+	switch p := EvictionPolicy(s); p {
+	case NoEviction, AllKeysLRU, AllKeysLFU, VolatileTTL:
+		return p, nil
+	case "":
+		return NoEviction, nil
+	}
+	return "", fmt.Errorf("unknown eviction policy: %q", s)
+}
+
+// tracksAccess reports whether the policy needs per-key access statistics.
+func (p EvictionPolicy) tracksAccess() bool {
+	return p == AllKeysLRU || p == AllKeysLFU
+}
+
+type options struct {
+	maxMemory int64
+	policy    EvictionPolicy
+}
+
+type Option func(*options)
+
+// WithMaxMemory bounds the estimated memory used by the stored entries.
+// Zero means no limit.
+func WithMaxMemory(bytes int64, policy EvictionPolicy) Option {
+	return func(o *options) {
+		o.maxMemory = bytes
+		o.policy = policy
+	}
+}
+
+// accessStats is updated by readers holding only the read lock,
+// hence the atomics.
+type accessStats struct {
+	lastAccess atomic.Int64 // unix nanoseconds
+	hits       atomic.Uint32
+}
+
+func (a *accessStats) touch(now time.Time) {
+	a.lastAccess.Store(now.UnixNano())
+	if a.hits.Load() < 1<<31 {
+		a.hits.Add(1)
+	}
+}
+
+// frequency is the hit counter decayed by the time since the last access.
+func (a *accessStats) frequency(now time.Time) uint32 {
+	idle := now.Sub(time.Unix(0, a.lastAccess.Load()))
+	halvings := min(idle/lfuDecayPeriod, 31)
+	return a.hits.Load() >> uint(halvings)
+}
+
+func entrySize(e domain.Entry) int64 {
+	size := len(e.Key) + len(e.Value) + entryOverhead
+	for _, item := range e.Items {
+		size += len(item) + itemOverhead
+	}
+	return int64(size)
+}
+
+// victim samples candidates according to the policy and returns the key to
+// evict. Caller holds mu.
+func (m *Memory) victim(now time.Time) (string, bool) {
+	var (
+		best  string
+		found bool
+		score int64
+	)
+
+	consider := func(k string, s int64) {
+		if !found || s < score {
+			best, score, found = k, s, true
+		}
+	}
+
+	switch m.opts.policy {
+	case AllKeysLRU, AllKeysLFU:
+		sampled := 0
+		for k, stats := range m.access {
+			if sampled == evictionSample {
+				break
+			}
+			sampled++
+			if m.opts.policy == AllKeysLRU {
+				consider(k, stats.lastAccess.Load())
+			} else {
+				consider(k, int64(stats.frequency(now)))
+			}
+		}
+
+	case VolatileTTL:
+		sampled := 0
+		for k := range m.volatile {
+			if sampled == evictionSample {
+				break
+			}
+			sampled++
+			entry, _ := m.index.get(k)
+			consider(k, entry.ExpiresAt.UnixNano())
+		}
+	}
+
+	return best, found
+}
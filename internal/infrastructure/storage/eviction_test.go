@@ -0,0 +1,167 @@
+package storage
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/rdimidov/kvstore/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fill writes n keys of roughly entryOverhead+10 bytes each.
+func fill(t *testing.T, m *Memory, n int) {
+	t.Helper()
+	for i := range n {
+		k := domain.Key("key-" + strconv.Itoa(i))
+		_, err := m.Reclaim(context.Background(), k)
+		require.NoError(t, err)
+		require.NoError(t, m.Set(context.Background(), k, "val"))
+	}
+}
+
+func TestParseEvictionPolicy(t *testing.T) {
+	t.Parallel()
+
+	p, err := ParseEvictionPolicy("")
+	assert.NoError(t, err)
+	assert.Equal(t, NoEviction, p)
+
+	p, err = ParseEvictionPolicy("allkeys-lfu")
+	assert.NoError(t, err)
+	assert.Equal(t, AllKeysLFU, p)
+
+	_, err = ParseEvictionPolicy("random")
+	assert.Error(t, err)
+}
+
+func TestMemory_ReclaimWithoutLimit(t *testing.T) {
+	t.Parallel()
+
+	m := NewMemory()
+	fill(t, m, 100)
+	assert.False(t, m.OverLimit(context.Background(), "key"))
+
+	evicted, err := m.Reclaim(context.Background(), "key")
+	assert.NoError(t, err)
+	assert.Empty(t, evicted)
+}
+
+func TestMemory_ReclaimNoEviction(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	m := NewMemory(WithMaxMemory(10*entryOverhead, NoEviction))
+
+	for i := 0; ; i++ {
+		k := domain.Key("key-" + strconv.Itoa(i))
+		full := m.OverLimit(ctx, k)
+		evicted, err := m.Reclaim(ctx, k)
+		assert.Empty(t, evicted)
+		assert.Equal(t, full, err != nil)
+		if err != nil {
+			assert.ErrorIs(t, err, domain.ErrOutOfMemory)
+			break
+		}
+		require.NoError(t, m.Set(ctx, k, "val"))
+		require.Less(t, i, 20, "limit was never enforced")
+	}
+
+	// deletes still make room
+	require.NoError(t, m.Delete(ctx, "key-0"))
+	require.NoError(t, m.Delete(ctx, "key-1"))
+	assert.False(t, m.OverLimit(ctx, "key"))
+	_, err := m.Reclaim(ctx, "key")
+	assert.NoError(t, err)
+}
+
+func TestMemory_ReclaimAllKeysLRU(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	limit := int64(50 * (entryOverhead + 10))
+	m := NewMemory(WithMaxMemory(limit, AllKeysLRU))
+
+	require.NoError(t, m.Set(ctx, "hot", "val"))
+	for i := range 500 {
+		k := domain.Key("key-" + strconv.Itoa(i))
+		_, err := m.Reclaim(ctx, k)
+		require.NoError(t, err)
+		require.NoError(t, m.Set(ctx, k, "val"))
+
+		// keep the hot key the most recently used one
+		_, err = m.Get(ctx, "hot")
+		require.NoError(t, err)
+	}
+
+	assert.LessOrEqual(t, m.used, limit+entrySize(domain.Entry{Key: "key-499", Value: "val"}))
+	assert.Equal(t, m.index.len(), len(m.access))
+	_, err := m.Get(ctx, "hot")
+	assert.NoError(t, err, "recently used key must survive eviction")
+}
+
+func TestMemory_ReclaimAllKeysLFU(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	m := NewMemory(WithMaxMemory(50*(entryOverhead+10), AllKeysLFU))
+
+	require.NoError(t, m.Set(ctx, "hot", "val"))
+	for range 100 {
+		_, err := m.Get(ctx, "hot")
+		require.NoError(t, err)
+	}
+	fill(t, m, 500)
+
+	_, err := m.Get(ctx, "hot")
+	assert.NoError(t, err, "frequently used key must survive eviction")
+}
+
+func TestMemory_ReclaimVolatileTTL(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	m := NewMemory(WithMaxMemory(5*(entryOverhead+10), VolatileTTL))
+
+	fill(t, m, 5)
+	require.NoError(t, m.SetEx(ctx, "soon", "val", time.Now().Add(time.Minute)))
+	require.NoError(t, m.SetEx(ctx, "later", "val", time.Now().Add(time.Hour)))
+
+	evicted, err := m.Reclaim(ctx, "key")
+	assert.NoError(t, err)
+	assert.Equal(t, []domain.Key{"soon", "later"}, evicted)
+
+	// nothing volatile is left to evict
+	require.NoError(t, m.Set(ctx, "more", "val"))
+	_, err = m.Reclaim(ctx, "key")
+	assert.ErrorIs(t, err, domain.ErrOutOfMemory)
+}
+
+func TestSharded_ReclaimSplitsLimit(t *testing.T) {
+	t.Parallel()
+
+	sh := NewSharded(4, WithMaxMemory(4000, AllKeysLRU))
+	for _, shard := range sh.shards {
+		assert.Equal(t, int64(1000), shard.opts.maxMemory)
+		assert.Equal(t, AllKeysLRU, shard.opts.policy)
+	}
+}
+
+func TestSharded_ReclaimSplitsSmallLimit(t *testing.T) {
+	t.Parallel()
+
+	sh := NewSharded(4, WithMaxMemory(6, NoEviction))
+	var total int64
+	for i, shard := range sh.shards {
+		assert.Positive(t, shard.opts.maxMemory, "shard %d is unlimited", i)
+		total += shard.opts.maxMemory
+	}
+	assert.Equal(t, int64(6), total)
+
+	sh = NewSharded(4, WithMaxMemory(2, NoEviction))
+	for _, shard := range sh.shards {
+		assert.Equal(t, int64(1), shard.opts.maxMemory)
+	}
+}
@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"sort"
+
+	"github.com/rdimidov/kvstore/internal/domain"
+)
+
+// index is the container behind Memory. It is not safe for concurrent use,
+// Memory guards it with its own lock.
+type index interface {
+	get(key string) (domain.Entry, bool)
+	put(entry domain.Entry)
+	remove(key string)
+	len() int
+	// ascend calls fn for every entry with start <= key < end in key order
+	// until fn returns false. Empty end means there is no upper bound.
+	ascend(start, end string, fn func(domain.Entry) bool)
+}
+
+// hashIndex is an unordered index with O(1) point operations. Range scans
+// have to collect and sort the matching keys, so they cost O(n log n).
+type hashIndex map[string]domain.Entry
+
+func newHashIndex() hashIndex {
+	return make(hashIndex)
+}
+
+func (h hashIndex) get(key string) (domain.Entry, bool) {
+	entry, ok := h[key]
+	return entry, ok
+}
+
+func (h hashIndex) put(entry domain.Entry) {
+	h[entry.Key.String()] = entry
+}
+
+func (h hashIndex) remove(key string) {
+	delete(h, key)
+}
+
+func (h hashIndex) len() int {
+	return len(h)
+}
+
+func (h hashIndex) ascend(start, end string, fn func(domain.Entry) bool) {
+	var keys []string
+	for k := range h {
+		if inRange(k, start, end) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if !fn(h[k]) {
+			return
+		}
+	}
+}
+
+func inRange(key, start, end string) bool {
+	return key >= start && (end == "" || key < end)
+}
+
+// orderedIndex keeps entries in a skiplist, so range scans walk only the
+// keys in range.
+type orderedIndex struct {
+	list *skipList[domain.Entry]
+}
+
+func newOrderedIndex() orderedIndex {
+	return orderedIndex{list: newSkipList[domain.Entry]()}
+}
+
+func (o orderedIndex) get(key string) (domain.Entry, bool) {
+	return o.list.get(key)
+}
+
+func (o orderedIndex) put(entry domain.Entry) {
+	o.list.put(entry.Key.String(), entry)
+}
+
+func (o orderedIndex) remove(key string) {
+	o.list.remove(key)
+}
+
+func (o orderedIndex) len() int {
+	return o.list.len()
+}
+
+func (o orderedIndex) ascend(start, end string, fn func(domain.Entry) bool) {
+	o.list.ascend(start, end, func(_ string, entry domain.Entry) bool {
+		return fn(entry)
+	})
+}
@@ -0,0 +1,643 @@
+package storage
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rdimidov/kvstore/internal/domain"
+)
+
+const (
+	defaultMemtableSize        = 4 << 20
+	defaultCompactionThreshold = 4
+	// tierRatio is how much bigger a table has to be than the memtable size
+	// to land in the next compaction tier.
+	tierRatio = 4
+
+	manifestName = "MANIFEST"
+	tableExt     = ".sst"
+)
+
+type lsmOptions struct {
+	memtableSize        int64
+	compactionThreshold int
+}
+
+type LSMOption func(*lsmOptions)
+
+// WithMemtableSize sets the estimated memtable size at which it is frozen
+// and flushed to a new SSTable.
+func WithMemtableSize(bytes int64) LSMOption {
+	return func(o *lsmOptions) {
+		if bytes > 0 {
+			o.memtableSize = bytes
+		}
+	}
+}
+
+// WithCompactionThreshold sets how many SSTables of the same tier are merged
+// into one.
+func WithCompactionThreshold(n int) LSMOption {
+	return func(o *lsmOptions) {
+		if n > 1 {
+			o.compactionThreshold = n
+		}
+	}
+}
+
+// LSM is a disk-based engine built as a log-structured merge tree. Writes go
+// to an in-memory memtable; full memtables are flushed in the background to
+// immutable SSTables, and SSTables of similar size are merged by size-tiered
+// compaction. The MANIFEST file lists the live tables from oldest to newest.
+//
+// Unflushed writes are not persisted by the engine itself: they are
+// recovered by replaying the WAL over the tables. The engine keeps no log
+// position, so the tables may hold the effect of replayed records, and of
+// the ones after them, already. Replay relies on records logging what a
+// write left, never the operation: whole entries, collections deleted and
+// rebuilt, absolute deadlines. Applying one again over a key written since,
+// even as another type, then stores what the write did, and the records
+// after it bring the key back to its last state.
+type LSM struct {
+	dir  string
+	opts lsmOptions
+
+	mu        sync.RWMutex
+	mem       *skipList[record]
+	memSize   int64
+	immutable []*skipList[record] // frozen memtables, newest first
+	tables    []*table            // oldest first
+	nextSeq   uint64
+	bgErr     error // sticky failure of a flush or compaction
+
+	work      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// OpenLSM opens the engine stored in dir, creating it when needed, and
+// starts its background flushes and compactions.
+func OpenLSM(dir string, opts ...LSMOption) (*LSM, error) {
+	l := &LSM{
+		dir: dir,
+		opts: lsmOptions{
+			memtableSize:        defaultMemtableSize,
+			compactionThreshold: defaultCompactionThreshold,
+		},
+		mem:     newSkipList[record](),
+		nextSeq: 1,
+		work:    make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(&l.opts)
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	if err := l.load(); err != nil {
+		l.closeTables()
+		return nil, err
+	}
+
+	l.wg.Add(1)
+	go l.run()
+	return l, nil
+}
+
+// load opens the tables listed in the manifest and removes leftovers of
+// flushes and compactions interrupted by a crash.
+func (l *LSM) load() error {
+	names, err := l.readManifest()
+	if err != nil {
+		return err
+	}
+
+	live := make(map[string]bool, len(names))
+	for _, name := range names {
+		t, err := openTable(filepath.Join(l.dir, name))
+		if err != nil {
+			return err
+		}
+		l.tables = append(l.tables, t)
+		live[name] = true
+		if seq, err := strconv.ParseUint(strings.TrimSuffix(name, tableExt), 10, 64); err == nil {
+			l.nextSeq = max(l.nextSeq, seq+1)
+		}
+	}
+
+	files, err := os.ReadDir(l.dir)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		name := f.Name()
+		if (strings.HasSuffix(name, tableExt) || strings.HasSuffix(name, ".tmp")) && !live[name] {
+			if err := os.Remove(filepath.Join(l.dir, name)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (l *LSM) readManifest() ([]string, error) {
+	f, err := os.Open(filepath.Join(l.dir, manifestName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var names []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if name := strings.TrimSpace(scanner.Text()); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names, scanner.Err()
+}
+
+// writeManifest atomically replaces the manifest with the current table
+// list. Caller holds mu.
+func (l *LSM) writeManifest() error {
+	var b strings.Builder
+	for _, t := range l.tables {
+		b.WriteString(t.name)
+		b.WriteByte('\n')
+	}
+
+	path := filepath.Join(l.dir, manifestName)
+	f, err := os.Create(path + ".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(b.String()); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+func (l *LSM) Set(ctx context.Context, key domain.Key, value domain.Value) error {
+	return l.SetEx(ctx, key, value, time.Time{})
+}
+
+// SetEx stores the value with an absolute expiration deadline. Zero deadline
+// means the key never expires.
+func (l *LSM) SetEx(ctx context.Context, key domain.Key, value domain.Value, deadline time.Time) error {
+	entry := domain.NewEntryFromKV(key, value)
+	entry.ExpiresAt = deadline
+	return l.Put(ctx, entry)
+}
+
+// Put stores the entry as is, version included. An entry whose deadline is
+// already in the past deletes the key.
+func (l *LSM) Put(_ context.Context, entry domain.Entry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if entry.IsExpired(time.Now()) {
+		return l.put(record{entry: domain.Entry{Key: entry.Key}, deleted: true})
+	}
+	return l.put(record{entry: entry})
+}
+
+func (l *LSM) Get(_ context.Context, key domain.Key) (*domain.Entry, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	r, ok, err := l.lookup(key.String())
+	if err != nil {
+		return nil, err
+	}
+	if !ok || !r.live(time.Now()) {
+		return nil, domain.ErrKeyNotFound
+	}
+	return &r.entry, nil
+}
+
+// Scan returns live entries with start <= key < end in key order, at most
+// limit of them. Empty end means no upper bound, non-positive limit means
+// no limit.
+func (l *LSM) Scan(_ context.Context, start, end domain.Key, limit int) ([]domain.Entry, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	its := make([]iterator, 0, 1+len(l.immutable)+len(l.tables))
+	its = append(its, seekMemtable(l.mem, start.String()))
+	for _, mem := range l.immutable {
+		its = append(its, seekMemtable(mem, start.String()))
+	}
+	for _, t := range slices.Backward(l.tables) {
+		its = append(its, t.seek(start.String()))
+	}
+
+	var entries []domain.Entry
+	now := time.Now()
+	err := merge(its, func(r record) bool {
+		if end != "" && r.entry.Key >= end {
+			return false
+		}
+		if r.live(now) {
+			entries = append(entries, r.entry)
+		}
+		return limit <= 0 || len(entries) < limit
+	})
+	return entries, err
+}
+
+// Delete writes a tombstone that shadows older versions of the key until
+// compaction drops them all.
+func (l *LSM) Delete(_ context.Context, key domain.Key) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.put(record{entry: domain.Entry{Key: key}, deleted: true})
+}
+
+// Expire sets an absolute expiration deadline on an existing key.
+func (l *LSM) Expire(_ context.Context, key domain.Key, deadline time.Time) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	r, ok, err := l.lookup(key.String())
+	if err != nil {
+		return err
+	}
+	if !ok || !r.live(now) {
+		return domain.ErrKeyNotFound
+	}
+
+	r.entry.ExpiresAt = deadline
+	if r.entry.IsExpired(now) {
+		return l.put(record{entry: domain.Entry{Key: key}, deleted: true})
+	}
+	return l.put(r)
+}
+
+// Persist removes the expiration deadline from an existing key.
+func (l *LSM) Persist(_ context.Context, key domain.Key) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	r, ok, err := l.lookup(key.String())
+	if err != nil {
+		return err
+	}
+	if !ok || !r.live(time.Now()) {
+		return domain.ErrKeyNotFound
+	}
+
+	r.entry.ExpiresAt = time.Time{}
+	return l.put(r)
+}
+
+// OverLimit is always false, as the LSM engine never evicts.
+func (l *LSM) OverLimit(_ context.Context, _ domain.Key) bool {
+	return false
+}
+
+// Reclaim never evicts: the dataset lives on disk and only the memtable,
+// bounded by its own size, is kept in memory.
+func (l *LSM) Reclaim(_ context.Context, _ domain.Key) ([]domain.Key, error) {
+	return nil, nil
+}
+
+// StartSweeper is a no-op: expired entries are invisible to reads and are
+// dropped from disk by compaction.
+func (l *LSM) StartSweeper(_ context.Context, _ time.Duration) {}
+
+// Close stops the background work, flushes the memtables and closes the
+// tables. The engine must not be used afterwards.
+func (l *LSM) Close() error {
+	l.closeOnce.Do(func() { close(l.done) })
+	l.wg.Wait()
+
+	l.mu.Lock()
+	if l.mem.len() > 0 {
+		l.rotate()
+	}
+	l.mu.Unlock()
+
+	err := l.flush()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return errors.Join(err, l.closeTables())
+}
+
+func (l *LSM) closeTables() error {
+	var errs []error
+	for _, t := range l.tables {
+		errs = append(errs, t.close())
+	}
+	l.tables = nil
+	return errors.Join(errs...)
+}
+
+// lookup returns the newest record for key, tombstones included.
+// Caller holds mu.
+func (l *LSM) lookup(key string) (record, bool, error) {
+	if r, ok := l.mem.get(key); ok {
+		return r, true, nil
+	}
+	for _, mem := range l.immutable {
+		if r, ok := mem.get(key); ok {
+			return r, true, nil
+		}
+	}
+	for _, t := range slices.Backward(l.tables) {
+		r, ok, err := t.get(key)
+		if err != nil || ok {
+			return r, ok, err
+		}
+	}
+	return record{}, false, nil
+}
+
+// put writes the record into the memtable and freezes the memtable once
+// it is full. Caller holds mu.
+func (l *LSM) put(r record) error {
+	if l.bgErr != nil {
+		return l.bgErr
+	}
+
+	k := r.entry.Key.String()
+	if old, ok := l.mem.get(k); ok {
+		l.memSize -= entrySize(old.entry)
+	}
+	l.mem.put(k, r)
+	l.memSize += entrySize(r.entry)
+
+	if l.memSize >= l.opts.memtableSize {
+		l.rotate()
+	}
+	return nil
+}
+
+// rotate freezes the memtable and wakes up the background worker.
+// Caller holds mu.
+func (l *LSM) rotate() {
+	l.immutable = append([]*skipList[record]{l.mem}, l.immutable...)
+	l.mem = newSkipList[record]()
+	l.memSize = 0
+
+	select {
+	case l.work <- struct{}{}:
+	default:
+	}
+}
+
+func (l *LSM) run() {
+	defer l.wg.Done()
+	for {
+		select {
+		case <-l.done:
+			return
+		case <-l.work:
+		}
+
+		err := l.flush()
+		if err == nil {
+			err = l.compact()
+		}
+		if err != nil {
+			l.mu.Lock()
+			l.bgErr = fmt.Errorf("lsm background work: %w", err)
+			l.mu.Unlock()
+		}
+	}
+}
+
+// flush writes the frozen memtables to SSTables, oldest first.
+func (l *LSM) flush() error {
+	for {
+		l.mu.Lock()
+		if len(l.immutable) == 0 {
+			l.mu.Unlock()
+			return nil
+		}
+		mem := l.immutable[len(l.immutable)-1]
+		name := l.newTableName()
+		l.mu.Unlock()
+
+		// frozen memtables are never written to, so they can be read
+		// without holding mu
+		t, err := l.writeTable(name, []iterator{seekMemtable(mem, "")}, false)
+		if err != nil {
+			return err
+		}
+
+		l.mu.Lock()
+		if t != nil {
+			l.tables = append(l.tables, t)
+		}
+		l.immutable = l.immutable[:len(l.immutable)-1]
+		err = l.writeManifest()
+		l.mu.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// compact merges runs of tables from the same size tier until no tier
+// holds enough of them. Only the background worker changes the table list,
+// so the run can be read without holding mu.
+func (l *LSM) compact() error {
+	for {
+		l.mu.Lock()
+		from, to := l.pickRun()
+		if from == to {
+			l.mu.Unlock()
+			return nil
+		}
+		run := slices.Clone(l.tables[from:to])
+		name := l.newTableName()
+		l.mu.Unlock()
+
+		its := make([]iterator, 0, len(run))
+		for _, t := range slices.Backward(run) {
+			its = append(its, t.seek(""))
+		}
+		// tombstones and expired entries may only be dropped when nothing
+		// older is left for them to shadow
+		t, err := l.writeTable(name, its, from == 0)
+		if err != nil {
+			return err
+		}
+
+		l.mu.Lock()
+		var merged []*table
+		if t != nil {
+			merged = append(merged, t)
+		}
+		l.tables = slices.Replace(l.tables, from, to, merged...)
+		err = l.writeManifest()
+		l.mu.Unlock()
+		if err != nil {
+			return err
+		}
+
+		for _, old := range run {
+			_ = old.close()
+			if err := os.Remove(filepath.Join(l.dir, old.name)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// pickRun returns the newest run [from, to) of adjacent tables in the same
+// tier that is long enough to compact. Merging only adjacent tables keeps
+// the newest-wins order between the result and the rest.
+// Caller holds mu.
+func (l *LSM) pickRun() (int, int) {
+	to := len(l.tables)
+	for to > 0 {
+		tier := l.tier(l.tables[to-1])
+		from := to - 1
+		for from > 0 && l.tier(l.tables[from-1]) == tier {
+			from--
+		}
+		if to-from >= l.opts.compactionThreshold {
+			return from, to
+		}
+		to = from
+	}
+	return 0, 0
+}
+
+func (l *LSM) tier(t *table) int {
+	tier := 0
+	for size := t.size; size >= l.opts.memtableSize*tierRatio; size /= tierRatio {
+		tier++
+	}
+	return tier
+}
+
+// newTableName reserves the file name of the next table. Caller holds mu.
+func (l *LSM) newTableName() string {
+	name := fmt.Sprintf("%06d%s", l.nextSeq, tableExt)
+	l.nextSeq++
+	return name
+}
+
+// writeTable merges the iterators into a new table. When final is set, the
+// records that are not live are left out. A nil table is returned when
+// nothing was written.
+func (l *LSM) writeTable(name string, its []iterator, final bool) (*table, error) {
+	path := filepath.Join(l.dir, name)
+	w, err := newTableWriter(path)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var werr error
+	err = merge(its, func(r record) bool {
+		if final && !r.live(now) {
+			return true
+		}
+		werr = w.add(r)
+		return werr == nil
+	})
+	if err = errors.Join(err, werr); err != nil {
+		w.abort()
+		return nil, err
+	}
+	if w.empty() {
+		w.abort()
+		return nil, nil
+	}
+
+	if err := w.finish(); err != nil {
+		return nil, err
+	}
+	return openTable(path)
+}
+
+// iterator walks records in key order.
+type iterator interface {
+	valid() bool
+	record() record
+	next()
+	error() error
+}
+
+// merge calls fn in key order with the newest record of every key until fn
+// returns false. its are ordered from the newest source to the oldest.
+func merge(its []iterator, fn func(record) bool) error {
+	for {
+		var (
+			key   string
+			found bool
+		)
+		for _, it := range its {
+			if it.valid() {
+				if k := it.record().entry.Key.String(); !found || k < key {
+					key, found = k, true
+				}
+			}
+		}
+		if !found {
+			break
+		}
+
+		var newest record
+		won := false
+		for _, it := range its {
+			if it.valid() && it.record().entry.Key.String() == key {
+				if !won {
+					newest, won = it.record(), true
+				}
+				it.next()
+			}
+		}
+		if !fn(newest) {
+			break
+		}
+	}
+
+	for _, it := range its {
+		if err := it.error(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type memtableIterator struct {
+	node *skipNode[record]
+}
+
+func seekMemtable(mem *skipList[record], start string) *memtableIterator {
+	return &memtableIterator{node: mem.seek(start, nil)}
+}
+
+func (it *memtableIterator) valid() bool    { return it.node != nil }
+func (it *memtableIterator) record() record { return it.node.value }
+func (it *memtableIterator) next()          { it.node = it.node.next[0] }
+func (it *memtableIterator) error() error   { return nil }
@@ -0,0 +1,197 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/rdimidov/kvstore/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func openTestLSM(t *testing.T, dir string, opts ...LSMOption) *LSM {
+	t.Helper()
+	l, err := OpenLSM(dir, opts...)
+	require.NoError(t, err)
+	return l
+}
+
+// waitFlushed blocks until the background worker has flushed every frozen
+// memtable.
+func waitFlushed(t *testing.T, l *LSM) {
+	t.Helper()
+	require.Eventually(t, func() bool {
+		l.mu.RLock()
+		defer l.mu.RUnlock()
+		return len(l.immutable) == 0
+	}, 5*time.Second, time.Millisecond)
+}
+
+func TestLSM_SetGetDelete(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	l := openTestLSM(t, t.TempDir())
+	defer l.Close()
+
+	require.NoError(t, l.Set(ctx, "foo", "bar"))
+	entry, err := l.Get(ctx, "foo")
+	require.NoError(t, err)
+	assert.Equal(t, domain.Value("bar"), entry.Value)
+
+	require.NoError(t, l.Delete(ctx, "foo"))
+	_, err = l.Get(ctx, "foo")
+	assert.ErrorIs(t, err, domain.ErrKeyNotFound)
+	assert.ErrorIs(t, l.Expire(ctx, "foo", time.Now().Add(time.Minute)), domain.ErrKeyNotFound)
+	assert.ErrorIs(t, l.Persist(ctx, "foo"), domain.ErrKeyNotFound)
+}
+
+func TestLSM_Expiration(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	l := openTestLSM(t, t.TempDir())
+	defer l.Close()
+
+	require.NoError(t, l.SetEx(ctx, "short", "v", time.Now().Add(20*time.Millisecond)))
+	require.NoError(t, l.SetEx(ctx, "kept", "v", time.Now().Add(20*time.Millisecond)))
+	require.NoError(t, l.Persist(ctx, "kept"))
+
+	time.Sleep(40 * time.Millisecond)
+
+	_, err := l.Get(ctx, "short")
+	assert.ErrorIs(t, err, domain.ErrKeyNotFound)
+	entry, err := l.Get(ctx, "kept")
+	require.NoError(t, err)
+	assert.False(t, entry.HasExpiry())
+
+	require.NoError(t, l.Expire(ctx, "kept", time.Now().Add(-time.Second)))
+	_, err = l.Get(ctx, "kept")
+	assert.ErrorIs(t, err, domain.ErrKeyNotFound)
+}
+
+func TestLSM_FlushCompactAndReopen(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	dir := t.TempDir()
+	opts := []LSMOption{WithMemtableSize(4 << 10), WithCompactionThreshold(2)}
+	l := openTestLSM(t, dir, opts...)
+
+	const n = 3000
+	for i := range n {
+		require.NoError(t, l.Set(ctx, domain.Key("key-"+strconv.Itoa(i)), domain.Value("v1")))
+	}
+	// overwrite and delete keys spread over older tables
+	for i := 0; i < n; i += 3 {
+		require.NoError(t, l.Set(ctx, domain.Key("key-"+strconv.Itoa(i)), domain.Value("v2")))
+	}
+	for i := 1; i < n; i += 3 {
+		require.NoError(t, l.Delete(ctx, domain.Key("key-"+strconv.Itoa(i))))
+	}
+	waitFlushed(t, l)
+
+	check := func(l *LSM) {
+		t.Helper()
+		for i := range n {
+			entry, err := l.Get(ctx, domain.Key("key-"+strconv.Itoa(i)))
+			switch i % 3 {
+			case 0:
+				require.NoError(t, err)
+				assert.Equal(t, domain.Value("v2"), entry.Value)
+			case 1:
+				assert.ErrorIs(t, err, domain.ErrKeyNotFound)
+			case 2:
+				require.NoError(t, err)
+				assert.Equal(t, domain.Value("v1"), entry.Value)
+			}
+		}
+
+		entries, err := l.Scan(ctx, "", "", 0)
+		require.NoError(t, err)
+		assert.Len(t, entries, n-n/3)
+		for i := 1; i < len(entries); i++ {
+			assert.Less(t, entries[i-1].Key, entries[i].Key)
+		}
+	}
+
+	check(l)
+	l.mu.RLock()
+	assert.NotEmpty(t, l.tables)
+	assert.Less(t, len(l.tables), 10, "tables should have been compacted")
+	l.mu.RUnlock()
+	require.NoError(t, l.Close())
+
+	reopened := openTestLSM(t, dir, opts...)
+	defer reopened.Close()
+	check(reopened)
+}
+
+func TestLSM_ScanShadowsOlderVersions(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	l := openTestLSM(t, t.TempDir(), WithCompactionThreshold(100))
+	defer l.Close()
+
+	for _, k := range []domain.Key{"a", "b", "c", "d"} {
+		require.NoError(t, l.Set(ctx, k, "old"))
+	}
+	l.mu.Lock()
+	l.rotate()
+	l.mu.Unlock()
+	waitFlushed(t, l)
+
+	require.NoError(t, l.Set(ctx, "b", "new"))
+	require.NoError(t, l.Delete(ctx, "c"))
+
+	entries, err := l.Scan(ctx, "b", "", 0)
+	require.NoError(t, err)
+	assert.Equal(t, []domain.Entry{{Key: "b", Value: "new"}, {Key: "d", Value: "old"}}, entries)
+
+	entries, err = l.Scan(ctx, "a", "d", 1)
+	require.NoError(t, err)
+	assert.Equal(t, []domain.Entry{{Key: "a", Value: "old"}}, entries)
+}
+
+func TestLSM_RemovesUnlistedTables(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	l := openTestLSM(t, dir)
+	require.NoError(t, l.Set(context.Background(), "a", "1"))
+	require.NoError(t, l.Close())
+
+	// leftovers of a compaction interrupted before the manifest was written
+	orphan := filepath.Join(dir, "999999.sst")
+	require.NoError(t, os.WriteFile(orphan, []byte("partial"), 0o644))
+
+	reopened := openTestLSM(t, dir)
+	defer reopened.Close()
+
+	assert.NoFileExists(t, orphan)
+	entry, err := reopened.Get(context.Background(), "a")
+	require.NoError(t, err)
+	assert.Equal(t, domain.Value("1"), entry.Value)
+}
+
+func TestLSM_PersistsVersions(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	dir := t.TempDir()
+	l := openTestLSM(t, dir)
+	require.NoError(t, l.Put(ctx, domain.Entry{Key: "foo", Value: "bar", Version: 1 << 60}))
+	require.NoError(t, l.Close())
+
+	reopened := openTestLSM(t, dir)
+	defer reopened.Close()
+
+	entry, err := reopened.Get(ctx, "foo")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1<<60), entry.Version)
+}
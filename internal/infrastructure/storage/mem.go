@@ -3,40 +3,280 @@ package storage
 import (
 	"context"
 	"sync"
+	"time"
 
 	"github.com/rdimidov/kvstore/internal/domain"
 )
 
+const (
+	defaultSweepInterval = 100 * time.Millisecond
+	// sweepSample is how many volatile keys are checked per sweep round.
+	sweepSample = 20
+	// sweepRepeatRatio: when more than 1/sweepRepeatRatio of the sample was
+	// expired, another round is run straight away.
+	sweepRepeatRatio = 4
+)
+
+// Memory is an in-memory engine guarded by a single lock. Its index decides
+// whether keys are kept in a hash map or in key order.
 type Memory struct {
-	mu sync.RWMutex
-	hm map[string]domain.Entry
+	mu       sync.RWMutex
+	index    index
+	volatile map[string]struct{} // keys that carry an expiration deadline
+
+	opts   options
+	used   int64                   // estimated bytes taken by stored entries
+	access map[string]*accessStats // only kept for LRU/LFU policies
+}
+
+// NewMemory creates an engine backed by a hash map.
+func NewMemory(opts ...Option) *Memory {
+	return newMemory(newHashIndex(), opts...)
 }
 
-func NewMemory() *Memory {
-	return &Memory{
-		hm: make(map[string]domain.Entry),
+// NewOrdered creates an engine backed by a skiplist, which keeps keys sorted
+// and serves range scans without sorting the whole keyspace.
+func NewOrdered(opts ...Option) *Memory {
+	return newMemory(newOrderedIndex(), opts...)
+}
+
+func newMemory(idx index, opts ...Option) *Memory {
+	m := &Memory{
+		index:    idx,
+		volatile: make(map[string]struct{}),
+		opts:     options{policy: NoEviction},
+	}
+	for _, opt := range opts {
+		opt(&m.opts)
 	}
+	if m.opts.policy.tracksAccess() {
+		m.access = make(map[string]*accessStats)
+	}
+	return m
 }
 
-func (m *Memory) Set(_ context.Context, key domain.Key, value domain.Value) error {
+func (m *Memory) Set(ctx context.Context, key domain.Key, value domain.Value) error {
+	return m.SetEx(ctx, key, value, time.Time{})
+}
+
+// SetEx stores the value with an absolute expiration deadline.
+// Zero deadline means the key never expires.
+func (m *Memory) SetEx(ctx context.Context, key domain.Key, value domain.Value, deadline time.Time) error {
+	entry := domain.NewEntryFromKV(key, value)
+	entry.ExpiresAt = deadline
+	return m.Put(ctx, entry)
+}
+
+// Put stores the entry as is, version included. An entry whose deadline is
+// already in the past removes the key right away.
+func (m *Memory) Put(_ context.Context, entry domain.Entry) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	m.hm[key.String()] = domain.NewEntryFromKV(key, value)
+
+	if entry.IsExpired(time.Now()) {
+		m.remove(entry.Key.String())
+		return nil
+	}
+	m.put(entry)
 	return nil
 }
 
 func (m *Memory) Get(_ context.Context, key domain.Key) (*domain.Entry, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
-	if entry, ok := m.hm[key.String()]; ok {
+	now := time.Now()
+	if entry, ok := m.index.get(key.String()); ok && !entry.IsExpired(now) {
+		if stats := m.access[key.String()]; stats != nil {
+			stats.touch(now)
+		}
 		return &entry, nil
 	}
 	return nil, domain.ErrKeyNotFound
 }
 
+// Scan returns live entries with start <= key < end in key order, at most
+// limit of them. Empty end means no upper bound, non-positive limit means
+// no limit.
+func (m *Memory) Scan(_ context.Context, start, end domain.Key, limit int) ([]domain.Entry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var entries []domain.Entry
+	now := time.Now()
+	m.index.ascend(start.String(), end.String(), func(entry domain.Entry) bool {
+		if !entry.IsExpired(now) {
+			entries = append(entries, entry)
+		}
+		return limit <= 0 || len(entries) < limit
+	})
+	return entries, nil
+}
+
 func (m *Memory) Delete(_ context.Context, key domain.Key) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	delete(m.hm, key.String())
+	m.remove(key.String())
+	return nil
+}
+
+// Expire sets an absolute expiration deadline on an existing key.
+func (m *Memory) Expire(_ context.Context, key domain.Key, deadline time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	entry, ok := m.index.get(key.String())
+	if !ok || entry.IsExpired(now) {
+		return domain.ErrKeyNotFound
+	}
+
+	entry.ExpiresAt = deadline
+	if entry.IsExpired(now) {
+		m.remove(key.String())
+		return nil
+	}
+	m.put(entry)
+	return nil
+}
+
+// Persist removes the expiration deadline from an existing key.
+func (m *Memory) Persist(_ context.Context, key domain.Key) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.index.get(key.String())
+	if !ok || entry.IsExpired(time.Now()) {
+		return domain.ErrKeyNotFound
+	}
+
+	entry.ExpiresAt = time.Time{}
+	m.put(entry)
 	return nil
 }
+
+// OverLimit reports whether a write would first have to reclaim memory.
+func (m *Memory) OverLimit(_ context.Context, _ domain.Key) bool {
+	if m.opts.maxMemory <= 0 {
+		return false
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.used > m.opts.maxMemory
+}
+
+// Reclaim makes room for a write when the memory limit is exceeded. Evicted
+// keys are returned so the caller can log them; under NoEviction, or when the
+// policy finds nothing to evict, domain.ErrOutOfMemory is returned instead.
+// Like in Redis, the limit is checked before a write, so a single admitted
+// write may overshoot it.
+func (m *Memory) Reclaim(_ context.Context, _ domain.Key) ([]domain.Key, error) {
+	if m.opts.maxMemory <= 0 {
+		return nil, nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var evicted []domain.Key
+	now := time.Now()
+	for m.used > m.opts.maxMemory {
+		if m.opts.policy == NoEviction {
+			return evicted, domain.ErrOutOfMemory
+		}
+		k, ok := m.victim(now)
+		if !ok {
+			return evicted, domain.ErrOutOfMemory
+		}
+		m.remove(k)
+		evicted = append(evicted, domain.Key(k))
+	}
+	return evicted, nil
+}
+
+// StartSweeper runs a background goroutine that reclaims expired keys
+// until ctx is cancelled.
+func (m *Memory) StartSweeper(ctx context.Context, interval time.Duration) {
+	startSweeper(ctx, interval, func(now time.Time) {
+		for m.sweep(now) {
+		}
+	})
+}
+
+// startSweeper calls sweep on every tick of interval until ctx is cancelled.
+func startSweeper(ctx context.Context, interval time.Duration, sweep func(now time.Time)) {
+	if interval <= 0 {
+		interval = defaultSweepInterval
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				sweep(now)
+			}
+		}
+	}()
+}
+
+// sweep checks a random sample of volatile keys and removes the expired ones.
+// It reports whether enough keys were expired that another round is worth it.
+func (m *Memory) sweep(now time.Time) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var checked, expired int
+	// map iteration order is random, which gives us the sample for free
+	for k := range m.volatile {
+		if checked == sweepSample {
+			break
+		}
+		checked++
+		if entry, _ := m.index.get(k); entry.IsExpired(now) {
+			m.remove(k)
+			expired++
+		}
+	}
+	return checked == sweepSample && expired*sweepRepeatRatio > checked
+}
+
+// put stores the entry and keeps the expiration and eviction bookkeeping
+// in sync. Caller holds mu.
+func (m *Memory) put(entry domain.Entry) {
+	k := entry.Key.String()
+	if old, ok := m.index.get(k); ok {
+		m.used -= entrySize(old)
+	}
+	m.index.put(entry)
+	m.used += entrySize(entry)
+
+	if entry.HasExpiry() {
+		m.volatile[k] = struct{}{}
+	} else {
+		delete(m.volatile, k)
+	}
+
+	if m.access != nil {
+		stats := m.access[k]
+		if stats == nil {
+			stats = &accessStats{}
+			m.access[k] = stats
+		}
+		stats.touch(time.Now())
+	}
+}
+
+// remove deletes the key and its bookkeeping. Caller holds mu.
+func (m *Memory) remove(k string) {
+	if old, ok := m.index.get(k); ok {
+		m.used -= entrySize(old)
+	}
+	m.index.remove(k)
+	delete(m.volatile, k)
+	delete(m.access, k)
+}
@@ -5,6 +5,7 @@ import (
 	"strconv"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/rdimidov/kvstore/internal/domain"
 	"github.com/stretchr/testify/assert"
@@ -103,3 +104,158 @@ func TestMemory_ConcurrentAccess(t *testing.T) {
 	}
 	wg.Wait()
 }
+
+func TestMemory_SetExExpires(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	mem := NewMemory()
+	key := domain.Key("session")
+
+	assert.NoError(t, mem.SetEx(ctx, key, "token", time.Now().Add(50*time.Millisecond)))
+
+	entry, err := mem.Get(ctx, key)
+	assert.NoError(t, err)
+	assert.True(t, entry.HasExpiry())
+
+	time.Sleep(60 * time.Millisecond)
+
+	// Expired keys are never returned, even before the sweeper reclaims them
+	entry, err = mem.Get(ctx, key)
+	assert.Nil(t, entry)
+	assert.ErrorIs(t, err, domain.ErrKeyNotFound)
+}
+
+func TestMemory_SetExPastDeadline(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	mem := NewMemory()
+	key := domain.Key("dead")
+
+	assert.NoError(t, mem.Set(ctx, key, "value"))
+	assert.NoError(t, mem.SetEx(ctx, key, "value", time.Now().Add(-time.Second)))
+
+	_, err := mem.Get(ctx, key)
+	assert.ErrorIs(t, err, domain.ErrKeyNotFound)
+	assert.Zero(t, mem.index.len())
+}
+
+func TestMemory_SetClearsExpiry(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	mem := NewMemory()
+	key := domain.Key("foo")
+
+	assert.NoError(t, mem.SetEx(ctx, key, "bar", time.Now().Add(time.Minute)))
+	assert.NoError(t, mem.Set(ctx, key, "baz"))
+
+	entry, err := mem.Get(ctx, key)
+	assert.NoError(t, err)
+	assert.False(t, entry.HasExpiry())
+	assert.Empty(t, mem.volatile)
+}
+
+func TestMemory_ExpireAndPersist(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	mem := NewMemory()
+	key := domain.Key("foo")
+	deadline := time.Now().Add(time.Minute)
+
+	assert.ErrorIs(t, mem.Expire(ctx, key, deadline), domain.ErrKeyNotFound)
+	assert.ErrorIs(t, mem.Persist(ctx, key), domain.ErrKeyNotFound)
+
+	assert.NoError(t, mem.Set(ctx, key, "bar"))
+	assert.NoError(t, mem.Expire(ctx, key, deadline))
+
+	entry, err := mem.Get(ctx, key)
+	assert.NoError(t, err)
+	assert.True(t, entry.ExpiresAt.Equal(deadline))
+
+	assert.NoError(t, mem.Persist(ctx, key))
+	entry, err = mem.Get(ctx, key)
+	assert.NoError(t, err)
+	assert.False(t, entry.HasExpiry())
+
+	// Expiring into the past removes the key
+	assert.NoError(t, mem.Expire(ctx, key, time.Now().Add(-time.Second)))
+	_, err = mem.Get(ctx, key)
+	assert.ErrorIs(t, err, domain.ErrKeyNotFound)
+}
+
+func TestMemory_SweeperReclaimsExpiredKeys(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mem := NewMemory()
+	mem.StartSweeper(ctx, 10*time.Millisecond)
+
+	deadline := time.Now().Add(20 * time.Millisecond)
+	for i := range 100 {
+		key := domain.Key("key-" + strconv.Itoa(i))
+		assert.NoError(t, mem.SetEx(ctx, key, "val", deadline))
+	}
+	assert.NoError(t, mem.Set(ctx, "forever", "val"))
+
+	assert.Eventually(t, func() bool {
+		mem.mu.RLock()
+		defer mem.mu.RUnlock()
+		return mem.index.len() == 1 && len(mem.volatile) == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestMemory_Scan(t *testing.T) {
+	t.Parallel()
+
+	engines := map[string]*Memory{
+		"hash":    NewMemory(),
+		"ordered": NewOrdered(),
+	}
+	for name, mem := range engines {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			ctx := context.Background()
+
+			for _, k := range []string{"users/2/name", "users/1/name", "orders/1", "users/10/name", "zzz"} {
+				assert.NoError(t, mem.Set(ctx, domain.Key(k), domain.Value(k)))
+			}
+			assert.NoError(t, mem.SetEx(ctx, "users/3/name", "gone", time.Now().Add(-time.Second)))
+
+			entries, err := mem.Scan(ctx, "users/", "users0", 0)
+			assert.NoError(t, err)
+			assert.Equal(t, []domain.Key{"users/1/name", "users/10/name", "users/2/name"}, keysOf(entries))
+
+			entries, err = mem.Scan(ctx, "users/10", "", 2)
+			assert.NoError(t, err)
+			assert.Equal(t, []domain.Key{"users/10/name", "users/2/name"}, keysOf(entries))
+		})
+	}
+}
+
+func keysOf(entries []domain.Entry) []domain.Key {
+	keys := make([]domain.Key, 0, len(entries))
+	for _, e := range entries {
+		keys = append(keys, e.Key)
+	}
+	return keys
+}
+
+func TestMemory_PutKeepsVersion(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	mem := NewMemory()
+
+	assert.NoError(t, mem.Put(ctx, domain.Entry{Key: "foo", Value: "bar", Version: 7}))
+	assert.NoError(t, mem.Expire(ctx, "foo", time.Now().Add(time.Minute)))
+	assert.NoError(t, mem.Persist(ctx, "foo"))
+
+	entry, err := mem.Get(ctx, "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(7), entry.Version, "expiration changes do not touch the version")
+}
@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/rdimidov/kvstore/internal/domain"
+)
+
+const DefaultShardCount = 32
+
+// Sharded spreads keys over independent Memory shards, each guarded by its own
+// lock, so a write to one shard never blocks reads and writes of the others.
+type Sharded struct {
+	shards []*Memory
+}
+
+// NewSharded creates an engine with the given number of shards.
+// Non-positive count falls back to DefaultShardCount. A memory limit is split
+// evenly between the shards, and each shard evicts on its own.
+// Every shard gets at least a byte, so a limit smaller than the shard count
+// still limits each of them.
+func NewSharded(count int, opts ...Option) *Sharded {
+	if count <= 0 {
+		count = DefaultShardCount
+	}
+
+	o := options{policy: NoEviction}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	shards := make([]*Memory, count)
+	for i := range shards {
+		shards[i] = NewMemory(WithMaxMemory(shardMemory(o.maxMemory, count, i), o.policy))
+	}
+	return &Sharded{shards: shards}
+}
+
+// shardMemory returns the share of the memory limit given to shard i: the
+// limit divided by count, with the remainder spread over the first shards.
+// Zero means no limit, so a limited shard gets no less than a byte.
+func shardMemory(limit int64, count, i int) int64 {
+	if limit <= 0 {
+		return limit
+	}
+	share := limit / int64(count)
+	if int64(i) < limit%int64(count) {
+		share++
+	}
+	return max(share, 1)
+}
+
+func (s *Sharded) Set(ctx context.Context, key domain.Key, value domain.Value) error {
+	return s.shard(key).Set(ctx, key, value)
+}
+
+func (s *Sharded) SetEx(ctx context.Context, key domain.Key, value domain.Value, deadline time.Time) error {
+	return s.shard(key).SetEx(ctx, key, value, deadline)
+}
+
+func (s *Sharded) Put(ctx context.Context, entry domain.Entry) error {
+	return s.shard(entry.Key).Put(ctx, entry)
+}
+
+func (s *Sharded) Get(ctx context.Context, key domain.Key) (*domain.Entry, error) {
+	return s.shard(key).Get(ctx, key)
+}
+
+// Scan merges the ranges of all shards. Each shard contributes at most limit
+// entries, so the merged result is truncated to the first limit keys.
+func (s *Sharded) Scan(ctx context.Context, start, end domain.Key, limit int) ([]domain.Entry, error) {
+	var entries []domain.Entry
+	for _, shard := range s.shards {
+		part, err := shard.Scan(ctx, start, end, limit)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, part...)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Key < entries[j].Key
+	})
+	if limit > 0 && len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries, nil
+}
+
+func (s *Sharded) Delete(ctx context.Context, key domain.Key) error {
+	return s.shard(key).Delete(ctx, key)
+}
+
+func (s *Sharded) Expire(ctx context.Context, key domain.Key, deadline time.Time) error {
+	return s.shard(key).Expire(ctx, key, deadline)
+}
+
+func (s *Sharded) Persist(ctx context.Context, key domain.Key) error {
+	return s.shard(key).Persist(ctx, key)
+}
+
+// OverLimit reports whether the shard about to receive the key is full.
+func (s *Sharded) OverLimit(ctx context.Context, key domain.Key) bool {
+	return s.shard(key).OverLimit(ctx, key)
+}
+
+// Reclaim makes room in the shard that is about to receive the key.
+func (s *Sharded) Reclaim(ctx context.Context, key domain.Key) ([]domain.Key, error) {
+	return s.shard(key).Reclaim(ctx, key)
+}
+
+// StartSweeper runs a single background goroutine that reclaims expired keys
+// shard by shard, so only one shard is locked by the sweeper at a time.
+func (s *Sharded) StartSweeper(ctx context.Context, interval time.Duration) {
+	startSweeper(ctx, interval, func(now time.Time) {
+		for _, shard := range s.shards {
+			for shard.sweep(now) {
+			}
+		}
+	})
+}
+
+// shard picks the shard owning the key using FNV-1a.
+func (s *Sharded) shard(key domain.Key) *Memory {
+	const (
+		offset32 = 2166136261
+		prime32  = 16777619
+	)
+
+	h := uint32(offset32)
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= prime32
+	}
+	return s.shards[h%uint32(len(s.shards))]
+}
@@ -0,0 +1,132 @@
+package storage
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rdimidov/kvstore/internal/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSharded_SetGetDelete(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	sh := NewSharded(4)
+	key := domain.Key("foo")
+
+	assert.NoError(t, sh.Set(ctx, key, "bar"))
+
+	entry, err := sh.Get(ctx, key)
+	assert.NoError(t, err)
+	assert.Equal(t, domain.Value("bar"), entry.Value)
+
+	assert.NoError(t, sh.Delete(ctx, key))
+	_, err = sh.Get(ctx, key)
+	assert.ErrorIs(t, err, domain.ErrKeyNotFound)
+}
+
+func TestSharded_DefaultShardCount(t *testing.T) {
+	t.Parallel()
+
+	assert.Len(t, NewSharded(0).shards, DefaultShardCount)
+	assert.Len(t, NewSharded(-1).shards, DefaultShardCount)
+	assert.Len(t, NewSharded(3).shards, 3)
+}
+
+func TestSharded_KeysSpreadAcrossShards(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	sh := NewSharded(8)
+
+	for i := range 1000 {
+		assert.NoError(t, sh.Set(ctx, domain.Key("key-"+strconv.Itoa(i)), "val"))
+	}
+
+	total := 0
+	for _, shard := range sh.shards {
+		assert.NotZero(t, shard.index.len(), "every shard should receive some keys")
+		total += shard.index.len()
+	}
+	assert.Equal(t, 1000, total)
+}
+
+func TestSharded_Expiration(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sh := NewSharded(4)
+	sh.StartSweeper(ctx, 10*time.Millisecond)
+
+	deadline := time.Now().Add(20 * time.Millisecond)
+	for i := range 100 {
+		assert.NoError(t, sh.SetEx(ctx, domain.Key("key-"+strconv.Itoa(i)), "val", deadline))
+	}
+	assert.NoError(t, sh.Set(ctx, "forever", "val"))
+	assert.NoError(t, sh.Expire(ctx, "forever", time.Now().Add(time.Hour)))
+	assert.NoError(t, sh.Persist(ctx, "forever"))
+
+	assert.Eventually(t, func() bool {
+		total := 0
+		for _, shard := range sh.shards {
+			shard.mu.RLock()
+			total += shard.index.len()
+			shard.mu.RUnlock()
+		}
+		return total == 1
+	}, time.Second, 10*time.Millisecond)
+
+	entry, err := sh.Get(ctx, "forever")
+	assert.NoError(t, err)
+	assert.False(t, entry.HasExpiry())
+}
+
+func TestSharded_ConcurrentAccess(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	sh := NewSharded(16)
+
+	const n = 10000
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := range n {
+		go func(i int) {
+			defer wg.Done()
+			key := domain.Key("key-" + strconv.Itoa(i))
+			assert.NoError(t, sh.Set(ctx, key, "val"))
+
+			entry, err := sh.Get(ctx, key)
+			assert.NoError(t, err)
+			assert.Equal(t, key, entry.Key)
+
+			assert.NoError(t, sh.Delete(ctx, key))
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestSharded_ScanMergesShards(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	sh := NewSharded(8)
+
+	for i := range 50 {
+		assert.NoError(t, sh.Set(ctx, domain.Key("key-"+strconv.Itoa(100+i)), "val"))
+	}
+
+	entries, err := sh.Scan(ctx, "key-110", "key-120", 5)
+	assert.NoError(t, err)
+	assert.Equal(t, []domain.Key{"key-110", "key-111", "key-112", "key-113", "key-114"}, keysOf(entries))
+
+	entries, err = sh.Scan(ctx, "key-", "", 0)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 50)
+}
@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"math/rand/v2"
+)
+
+const (
+	skipListMaxLevel = 32
+	// skipListP is the inverse probability of promoting a node one level up.
+	skipListP = 4
+)
+
+type skipNode[V any] struct {
+	key   string
+	value V
+	next  []*skipNode[V]
+}
+
+// skipList is an ordered map with O(log n) point operations and range
+// scans that walk the bottom level from the first key in range.
+// It is not safe for concurrent use.
+type skipList[V any] struct {
+	head  *skipNode[V]
+	level int
+	size  int
+}
+
+func newSkipList[V any]() *skipList[V] {
+	return &skipList[V]{
+		head:  &skipNode[V]{next: make([]*skipNode[V], skipListMaxLevel)},
+		level: 1,
+	}
+}
+
+// seek fills update with the rightmost node before key on every level
+// and returns the first node whose key is >= key.
+func (s *skipList[V]) seek(key string, update []*skipNode[V]) *skipNode[V] {
+	x := s.head
+	for i := s.level - 1; i >= 0; i-- {
+		for x.next[i] != nil && x.next[i].key < key {
+			x = x.next[i]
+		}
+		if update != nil {
+			update[i] = x
+		}
+	}
+	return x.next[0]
+}
+
+func (s *skipList[V]) get(key string) (V, bool) {
+	x := s.seek(key, nil)
+	if x != nil && x.key == key {
+		return x.value, true
+	}
+	var zero V
+	return zero, false
+}
+
+func (s *skipList[V]) put(key string, value V) {
+	var update [skipListMaxLevel]*skipNode[V]
+
+	x := s.seek(key, update[:])
+	if x != nil && x.key == key {
+		x.value = value
+		return
+	}
+
+	level := randomLevel()
+	if level > s.level {
+		for i := s.level; i < level; i++ {
+			update[i] = s.head
+		}
+		s.level = level
+	}
+
+	node := &skipNode[V]{key: key, value: value, next: make([]*skipNode[V], level)}
+	for i := range level {
+		node.next[i] = update[i].next[i]
+		update[i].next[i] = node
+	}
+	s.size++
+}
+
+func (s *skipList[V]) remove(key string) {
+	var update [skipListMaxLevel]*skipNode[V]
+
+	x := s.seek(key, update[:])
+	if x == nil || x.key != key {
+		return
+	}
+
+	for i := range x.next {
+		update[i].next[i] = x.next[i]
+	}
+	for s.level > 1 && s.head.next[s.level-1] == nil {
+		s.level--
+	}
+	s.size--
+}
+
+func (s *skipList[V]) len() int {
+	return s.size
+}
+
+// ascend calls fn for every node with start <= key < end in key order
+// until fn returns false. Empty end means there is no upper bound.
+func (s *skipList[V]) ascend(start, end string, fn func(key string, value V) bool) {
+	for x := s.seek(start, nil); x != nil; x = x.next[0] {
+		if end != "" && x.key >= end {
+			return
+		}
+		if !fn(x.key, x.value) {
+			return
+		}
+	}
+}
+
+func randomLevel() int {
+	level := 1
+	for level < skipListMaxLevel && rand.IntN(skipListP) == 0 {
+		level++
+	}
+	return level
+}
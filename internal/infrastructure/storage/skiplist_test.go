@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"math/rand/v2"
+	"sort"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSkipList_MatchesSortedMap(t *testing.T) {
+	t.Parallel()
+
+	sl := newSkipList[int]()
+	model := make(map[string]int)
+
+	for i := range 5000 {
+		k := "k" + strconv.Itoa(rand.IntN(1000))
+		switch rand.IntN(3) {
+		case 0, 1:
+			sl.put(k, i)
+			model[k] = i
+		case 2:
+			sl.remove(k)
+			delete(model, k)
+		}
+	}
+
+	assert.Equal(t, len(model), sl.len())
+
+	var want []string
+	for k := range model {
+		want = append(want, k)
+	}
+	sort.Strings(want)
+
+	var got []string
+	sl.ascend("", "", func(k string, v int) bool {
+		got = append(got, k)
+		assert.Equal(t, model[k], v)
+		return true
+	})
+	assert.Equal(t, want, got)
+
+	for k, v := range model {
+		got, ok := sl.get(k)
+		assert.True(t, ok)
+		assert.Equal(t, v, got)
+	}
+	_, ok := sl.get("missing")
+	assert.False(t, ok)
+}
+
+func TestSkipList_AscendRange(t *testing.T) {
+	t.Parallel()
+
+	sl := newSkipList[struct{}]()
+	for _, k := range []string{"a", "b", "c", "d", "e"} {
+		sl.put(k, struct{}{})
+	}
+
+	collect := func(start, end string, limit int) []string {
+		var keys []string
+		sl.ascend(start, end, func(k string, _ struct{}) bool {
+			keys = append(keys, k)
+			return len(keys) < limit
+		})
+		return keys
+	}
+
+	assert.Equal(t, []string{"b", "c", "d"}, collect("b", "e", 10))
+	assert.Equal(t, []string{"b", "c"}, collect("b", "e", 2))
+	assert.Equal(t, []string{"c", "d", "e"}, collect("bb", "", 10))
+	assert.Empty(t, collect("f", "", 10))
+}
@@ -0,0 +1,483 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/rdimidov/kvstore/internal/domain"
+)
+
+// An SSTable file is laid out as
+//
+//	[data block]...[index block][bloom filter][footer]
+//
+// Data blocks hold records sorted by key. The index block lists the first
+// key, position and CRC32 of every data block, and the footer locates the
+// index and the bloom filter. Both are loaded when the table is opened, so
+// a point lookup costs at most one block read.
+
+const (
+	// tableBlockSize is the size after which a data block is closed.
+	tableBlockSize = 4 << 10
+	tableFooterLen = 40
+	tableMagic     = 0x6b76_6c73 // "kvls"
+
+	recordTombstone  = 1 << 0
+	recordExpiring   = 1 << 1
+	recordCollection = 1 << 2
+)
+
+var errCorruptTable = errors.New("sstable is corrupt")
+
+// record is a single version of a key in the LSM tree: either an entry or
+// a tombstone that shadows older versions of the key.
+type record struct {
+	entry   domain.Entry
+	deleted bool
+}
+
+// live reports whether the record holds a value that is visible at now.
+func (r record) live(now time.Time) bool {
+	return !r.deleted && !r.entry.IsExpired(now)
+}
+
+func appendRecord(buf []byte, r record) []byte {
+	var flags byte
+	if r.deleted {
+		flags |= recordTombstone
+	}
+	if r.entry.HasExpiry() {
+		flags |= recordExpiring
+	}
+	if r.entry.Type != domain.TypeString {
+		flags |= recordCollection
+	}
+
+	buf = binary.AppendUvarint(buf, uint64(len(r.entry.Key)))
+	buf = append(buf, r.entry.Key...)
+	buf = append(buf, flags)
+	if r.entry.HasExpiry() {
+		buf = binary.AppendVarint(buf, r.entry.ExpiresAt.UnixNano())
+	}
+	buf = binary.AppendUvarint(buf, r.entry.Version)
+	if r.entry.Type != domain.TypeString {
+		buf = append(buf, byte(r.entry.Type))
+		buf = binary.AppendUvarint(buf, uint64(len(r.entry.Items)))
+		for _, item := range r.entry.Items {
+			buf = binary.AppendUvarint(buf, uint64(len(item)))
+			buf = append(buf, item...)
+		}
+		return buf
+	}
+	buf = binary.AppendUvarint(buf, uint64(len(r.entry.Value)))
+	return append(buf, r.entry.Value...)
+}
+
+// decodeRecord reads one record from the start of buf and returns it along
+// with the number of bytes consumed.
+func decodeRecord(buf []byte) (record, int, error) {
+	var (
+		r   record
+		pos int
+	)
+
+	readBytes := func() (string, bool) {
+		n, w := binary.Uvarint(buf[pos:])
+		if w <= 0 || uint64(len(buf)-pos-w) < n {
+			return "", false
+		}
+		pos += w
+		s := string(buf[pos : pos+int(n)])
+		pos += int(n)
+		return s, true
+	}
+
+	key, ok := readBytes()
+	if !ok || pos >= len(buf) {
+		return r, 0, errCorruptTable
+	}
+	flags := buf[pos]
+	pos++
+
+	r.entry.Key = domain.Key(key)
+	r.deleted = flags&recordTombstone != 0
+	if flags&recordExpiring != 0 {
+		nanos, w := binary.Varint(buf[pos:])
+		if w <= 0 {
+			return r, 0, errCorruptTable
+		}
+		pos += w
+		r.entry.ExpiresAt = time.Unix(0, nanos)
+	}
+
+	version, w := binary.Uvarint(buf[pos:])
+	if w <= 0 {
+		return r, 0, errCorruptTable
+	}
+	pos += w
+	r.entry.Version = version
+
+	if flags&recordCollection != 0 {
+		if pos >= len(buf) {
+			return r, 0, errCorruptTable
+		}
+		r.entry.Type = domain.Type(buf[pos])
+		pos++
+		count, w := binary.Uvarint(buf[pos:])
+		if w <= 0 || count > uint64(len(buf)-pos) {
+			return r, 0, errCorruptTable
+		}
+		pos += w
+		r.entry.Items = make([]domain.Value, 0, count)
+		for range count {
+			item, ok := readBytes()
+			if !ok {
+				return r, 0, errCorruptTable
+			}
+			r.entry.Items = append(r.entry.Items, domain.Value(item))
+		}
+		return r, pos, nil
+	}
+
+	value, ok := readBytes()
+	if !ok {
+		return r, 0, errCorruptTable
+	}
+	r.entry.Value = domain.Value(value)
+	return r, pos, nil
+}
+
+type blockHandle struct {
+	firstKey string
+	offset   uint64
+	length   uint64
+	crc      uint32
+}
+
+// tableWriter streams records in key order into a new SSTable. The file
+// only appears under its final name once finish succeeds.
+type tableWriter struct {
+	path   string
+	f      *os.File
+	w      *bufio.Writer
+	offset uint64
+
+	block      []byte
+	blockFirst string
+	index      []blockHandle
+	hashes     []uint64
+}
+
+func newTableWriter(path string) (*tableWriter, error) {
+	f, err := os.Create(path + ".tmp")
+	if err != nil {
+		return nil, err
+	}
+	return &tableWriter{path: path, f: f, w: bufio.NewWriter(f)}, nil
+}
+
+// add appends a record; keys must arrive in strictly increasing order.
+func (t *tableWriter) add(r record) error {
+	if len(t.block) == 0 {
+		t.blockFirst = r.entry.Key.String()
+	}
+	t.block = appendRecord(t.block, r)
+	t.hashes = append(t.hashes, bloomHash(r.entry.Key.String()))
+
+	if len(t.block) >= tableBlockSize {
+		return t.flushBlock()
+	}
+	return nil
+}
+
+func (t *tableWriter) empty() bool {
+	return len(t.hashes) == 0
+}
+
+func (t *tableWriter) flushBlock() error {
+	if len(t.block) == 0 {
+		return nil
+	}
+	t.index = append(t.index, blockHandle{
+		firstKey: t.blockFirst,
+		offset:   t.offset,
+		length:   uint64(len(t.block)),
+		crc:      crc32.ChecksumIEEE(t.block),
+	})
+	if err := t.write(t.block); err != nil {
+		return err
+	}
+	t.block = t.block[:0]
+	return nil
+}
+
+func (t *tableWriter) write(p []byte) error {
+	n, err := t.w.Write(p)
+	t.offset += uint64(n)
+	return err
+}
+
+// finish writes the index, bloom filter and footer, syncs the file and
+// moves it to its final name.
+func (t *tableWriter) finish() error {
+	if err := t.flushBlock(); err != nil {
+		t.abort()
+		return err
+	}
+
+	var index []byte
+	for _, h := range t.index {
+		index = binary.AppendUvarint(index, uint64(len(h.firstKey)))
+		index = append(index, h.firstKey...)
+		index = binary.AppendUvarint(index, h.offset)
+		index = binary.AppendUvarint(index, h.length)
+		index = binary.LittleEndian.AppendUint32(index, h.crc)
+	}
+
+	bloom := newBloomFilter(len(t.hashes))
+	for _, h := range t.hashes {
+		bloom.add(h)
+	}
+	filter := bloom.marshal()
+
+	footer := make([]byte, 0, tableFooterLen)
+	footer = binary.LittleEndian.AppendUint64(footer, t.offset)
+	footer = binary.LittleEndian.AppendUint64(footer, uint64(len(index)))
+	footer = binary.LittleEndian.AppendUint64(footer, t.offset+uint64(len(index)))
+	footer = binary.LittleEndian.AppendUint64(footer, uint64(len(filter)))
+	footer = binary.LittleEndian.AppendUint32(footer, crc32.ChecksumIEEE(append(index, filter...)))
+	footer = binary.LittleEndian.AppendUint32(footer, tableMagic)
+
+	for _, p := range [][]byte{index, filter, footer} {
+		if err := t.write(p); err != nil {
+			t.abort()
+			return err
+		}
+	}
+
+	if err := t.w.Flush(); err != nil {
+		t.abort()
+		return err
+	}
+	if err := t.f.Sync(); err != nil {
+		t.abort()
+		return err
+	}
+	if err := t.f.Close(); err != nil {
+		_ = os.Remove(t.f.Name())
+		return err
+	}
+	return os.Rename(t.f.Name(), t.path)
+}
+
+// abort drops the partially written file.
+func (t *tableWriter) abort() {
+	_ = t.f.Close()
+	_ = os.Remove(t.f.Name())
+}
+
+// table is an open, immutable SSTable.
+type table struct {
+	name  string
+	f     *os.File
+	size  int64
+	index []blockHandle
+	bloom *bloomFilter
+}
+
+func openTable(path string) (*table, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	t, err := loadTable(f)
+	if err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("open %s: %w", path, err)
+	}
+	return t, nil
+}
+
+func loadTable(f *os.File) (*table, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() < tableFooterLen {
+		return nil, errCorruptTable
+	}
+
+	footer := make([]byte, tableFooterLen)
+	if _, err := f.ReadAt(footer, info.Size()-tableFooterLen); err != nil {
+		return nil, err
+	}
+	if binary.LittleEndian.Uint32(footer[36:]) != tableMagic {
+		return nil, errCorruptTable
+	}
+
+	indexOff := binary.LittleEndian.Uint64(footer[0:])
+	indexLen := binary.LittleEndian.Uint64(footer[8:])
+	bloomLen := binary.LittleEndian.Uint64(footer[24:])
+	if indexOff+indexLen+bloomLen+tableFooterLen != uint64(info.Size()) {
+		return nil, errCorruptTable
+	}
+
+	meta := make([]byte, indexLen+bloomLen)
+	if _, err := f.ReadAt(meta, int64(indexOff)); err != nil {
+		return nil, err
+	}
+	if crc32.ChecksumIEEE(meta) != binary.LittleEndian.Uint32(footer[32:]) {
+		return nil, errCorruptTable
+	}
+
+	index, err := decodeIndex(meta[:indexLen])
+	if err != nil {
+		return nil, err
+	}
+	bloom, err := unmarshalBloomFilter(meta[indexLen:])
+	if err != nil {
+		return nil, err
+	}
+
+	return &table{name: info.Name(), f: f, size: info.Size(), index: index, bloom: bloom}, nil
+}
+
+func decodeIndex(buf []byte) ([]blockHandle, error) {
+	var index []blockHandle
+	for len(buf) > 0 {
+		var h blockHandle
+
+		n, w := binary.Uvarint(buf)
+		if w <= 0 || uint64(len(buf)-w) < n {
+			return nil, errCorruptTable
+		}
+		h.firstKey = string(buf[w : w+int(n)])
+		buf = buf[w+int(n):]
+
+		if h.offset, w = binary.Uvarint(buf); w <= 0 {
+			return nil, errCorruptTable
+		}
+		buf = buf[w:]
+		if h.length, w = binary.Uvarint(buf); w <= 0 {
+			return nil, errCorruptTable
+		}
+		buf = buf[w:]
+		if len(buf) < 4 {
+			return nil, errCorruptTable
+		}
+		h.crc = binary.LittleEndian.Uint32(buf)
+		buf = buf[4:]
+
+		index = append(index, h)
+	}
+	return index, nil
+}
+
+// blockFor returns the index of the only block that may hold key,
+// or -1 when key sorts before the whole table.
+func (t *table) blockFor(key string) int {
+	return sort.Search(len(t.index), func(i int) bool {
+		return t.index[i].firstKey > key
+	}) - 1
+}
+
+func (t *table) readBlock(i int) ([]record, error) {
+	h := t.index[i]
+	buf := make([]byte, h.length)
+	if _, err := t.f.ReadAt(buf, int64(h.offset)); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	if crc32.ChecksumIEEE(buf) != h.crc {
+		return nil, fmt.Errorf("%s block %d: %w", t.name, i, errCorruptTable)
+	}
+
+	var records []record
+	for len(buf) > 0 {
+		r, n, err := decodeRecord(buf)
+		if err != nil {
+			return nil, fmt.Errorf("%s block %d: %w", t.name, i, err)
+		}
+		records = append(records, r)
+		buf = buf[n:]
+	}
+	return records, nil
+}
+
+// get returns the record stored for key, tombstones included.
+func (t *table) get(key string) (record, bool, error) {
+	if !t.bloom.mayContain(key) {
+		return record{}, false, nil
+	}
+	i := t.blockFor(key)
+	if i < 0 {
+		return record{}, false, nil
+	}
+
+	records, err := t.readBlock(i)
+	if err != nil {
+		return record{}, false, err
+	}
+	j := sort.Search(len(records), func(j int) bool {
+		return records[j].entry.Key.String() >= key
+	})
+	if j < len(records) && records[j].entry.Key.String() == key {
+		return records[j], true, nil
+	}
+	return record{}, false, nil
+}
+
+func (t *table) close() error {
+	return t.f.Close()
+}
+
+// tableIterator walks a table in key order, one block in memory at a time.
+type tableIterator struct {
+	t       *table
+	block   int
+	records []record
+	pos     int
+	err     error
+}
+
+func (t *table) seek(start string) *tableIterator {
+	it := &tableIterator{t: t, block: max(t.blockFor(start), 0) - 1}
+	it.nextBlock()
+	for it.valid() && it.record().entry.Key.String() < start {
+		it.next()
+	}
+	return it
+}
+
+func (it *tableIterator) nextBlock() {
+	it.records, it.pos = nil, 0
+	for it.err == nil && len(it.records) == 0 && it.block+1 < len(it.t.index) {
+		it.block++
+		it.records, it.err = it.t.readBlock(it.block)
+	}
+}
+
+func (it *tableIterator) valid() bool {
+	return it.err == nil && it.pos < len(it.records)
+}
+
+func (it *tableIterator) record() record {
+	return it.records[it.pos]
+}
+
+func (it *tableIterator) next() {
+	it.pos++
+	if it.pos >= len(it.records) {
+		it.nextBlock()
+	}
+}
+
+func (it *tableIterator) error() error {
+	return it.err
+}
@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/rdimidov/kvstore/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestTable(t *testing.T, path string, records []record) *table {
+	t.Helper()
+
+	w, err := newTableWriter(path)
+	require.NoError(t, err)
+	for _, r := range records {
+		require.NoError(t, w.add(r))
+	}
+	require.NoError(t, w.finish())
+
+	tbl, err := openTable(path)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = tbl.close() })
+	return tbl
+}
+
+func TestTable_GetAndSeek(t *testing.T) {
+	t.Parallel()
+
+	deadline := time.UnixMilli(time.Now().Add(time.Hour).UnixMilli())
+	var records []record
+	for i := range 2000 {
+		k := domain.Key("key-" + strconv.Itoa(100000+i))
+		r := record{entry: domain.Entry{Key: k, Value: domain.Value("value-" + strconv.Itoa(i))}}
+		switch i % 10 {
+		case 3:
+			r = record{entry: domain.Entry{Key: k}, deleted: true}
+		case 7:
+			r.entry.ExpiresAt = deadline
+		case 9:
+			r.entry = domain.Entry{Key: k, Type: domain.TypeList, Items: []domain.Value{"a", "", "c"}}
+		}
+		records = append(records, r)
+	}
+
+	tbl := writeTestTable(t, filepath.Join(t.TempDir(), "1.sst"), records)
+	assert.Greater(t, len(tbl.index), 1, "records should span several blocks")
+
+	for _, want := range records {
+		got, ok, err := tbl.get(want.entry.Key.String())
+		require.NoError(t, err)
+		require.True(t, ok)
+		assert.Equal(t, want.deleted, got.deleted)
+		assert.Equal(t, want.entry.Value, got.entry.Value)
+		assert.Equal(t, want.entry.Type, got.entry.Type)
+		assert.Equal(t, want.entry.Items, got.entry.Items)
+		assert.True(t, want.entry.ExpiresAt.Equal(got.entry.ExpiresAt))
+	}
+
+	_, ok, err := tbl.get("key-0")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	it := tbl.seek("key-101500")
+	var keys int
+	for ; it.valid(); it.next() {
+		keys++
+	}
+	require.NoError(t, it.error())
+	assert.Equal(t, 500, keys)
+}
+
+func TestTable_DetectsCorruption(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "1.sst")
+	writeTestTable(t, path, []record{{entry: domain.Entry{Key: "a", Value: "1"}}})
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	data[0] ^= 0xff
+	require.NoError(t, os.WriteFile(path, data, 0o644))
+
+	tbl, err := openTable(path)
+	require.NoError(t, err)
+	defer tbl.close()
+
+	_, _, err = tbl.get("a")
+	assert.ErrorIs(t, err, errCorruptTable)
+
+	require.NoError(t, os.WriteFile(path, data[:len(data)-1], 0o644))
+	_, err = openTable(path)
+	assert.ErrorIs(t, err, errCorruptTable)
+}
+
+func TestBloomFilter(t *testing.T) {
+	t.Parallel()
+
+	const n = 10000
+	bloom := newBloomFilter(n)
+	for i := range n {
+		bloom.add(bloomHash("in-" + strconv.Itoa(i)))
+	}
+
+	restored, err := unmarshalBloomFilter(bloom.marshal())
+	require.NoError(t, err)
+
+	for i := range n {
+		require.True(t, restored.mayContain("in-"+strconv.Itoa(i)))
+	}
+
+	var falsePositives int
+	for i := range n {
+		if restored.mayContain("out-" + strconv.Itoa(i)) {
+			falsePositives++
+		}
+	}
+	assert.Less(t, falsePositives, n/20)
+}
@@ -0,0 +1,162 @@
+package wal
+
+import (
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rdimidov/kvstore/internal/domain"
+)
+
+const (
+	benchBatchSize = 64
+	benchWriters   = 32
+)
+
+type benchCommitter interface {
+	commit(data string) (domain.LSN, error)
+	Close() error
+}
+
+func (w *WAL) commit(data string) (domain.LSN, error) {
+	return wait(w.processInput(data))
+}
+
+// dispatchCommitter is the commit path the WAL had before the ordered
+// pipeline: every full batch is written by a goroutine of its own, so
+// batches may be synced in any order. It is kept for comparison only.
+type dispatchCommitter struct {
+	writer     *rotatingWalWriter
+	batchLimit int
+
+	readyCh chan []entry
+	mu      sync.Mutex
+	batch   []entry
+	writes  sync.WaitGroup
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+func newDispatchCommitter(writer *rotatingWalWriter, batchLimit int, timeout time.Duration) *dispatchCommitter {
+	d := &dispatchCommitter{
+		writer:     writer,
+		batchLimit: batchLimit,
+		readyCh:    make(chan []entry, 1),
+		done:       make(chan struct{}),
+		stopped:    make(chan struct{}),
+	}
+	go func() {
+		defer close(d.stopped)
+		ticker := time.NewTicker(timeout)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-d.done:
+				return
+			case <-ticker.C:
+				d.dumpBatch()
+			case batch := <-d.readyCh:
+				d.writes.Add(1)
+				go func() {
+					defer d.writes.Done()
+					d.writer.Write(batch)
+				}()
+				ticker.Reset(timeout)
+			}
+		}
+	}()
+	return d
+}
+
+func (d *dispatchCommitter) commit(data string) (domain.LSN, error) {
+	e := newEntry(data)
+	d.mu.Lock()
+	d.batch = append(d.batch, e)
+	if len(d.batch) == d.batchLimit {
+		d.readyCh <- d.batch
+		d.batch = nil
+	}
+	d.mu.Unlock()
+	return wait(e.FutureResponse())
+}
+
+func (d *dispatchCommitter) dumpBatch() {
+	d.mu.Lock()
+	batch := d.batch
+	d.batch = nil
+	d.mu.Unlock()
+	if len(batch) != 0 {
+		d.writer.Write(batch)
+	}
+}
+
+func (d *dispatchCommitter) Close() error {
+	close(d.done)
+	<-d.stopped
+	d.dumpBatch()
+	d.writes.Wait()
+	return d.writer.Close()
+}
+
+func newBenchWriter(b *testing.B) *rotatingWalWriter {
+	w, err := newRotatingWalWriter(b.TempDir(), 64<<20)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return w
+}
+
+func openBenchPipeline(b *testing.B) benchCommitter {
+	w := &WAL{writer: newBenchWriter(b), batchLimit: benchBatchSize, timeout: defaultFlushTimeout}
+	w.start()
+	return w
+}
+
+func openBenchDispatch(b *testing.B) benchCommitter {
+	return newDispatchCommitter(newBenchWriter(b), benchBatchSize, defaultFlushTimeout)
+}
+
+// benchmarkCommit logs records from benchWriters goroutines per CPU, each
+// waiting for its record to be synced before logging the next, and reports
+// the commit latency percentiles along with the throughput.
+func benchmarkCommit(b *testing.B, open func(*testing.B) benchCommitter) {
+	c := open(b)
+
+	var mu sync.Mutex
+	latencies := make([]time.Duration, 0, b.N)
+
+	b.SetParallelism(benchWriters)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		var local []time.Duration
+		for pb.Next() {
+			start := time.Now()
+			if _, err := c.commit("SET key value"); err != nil {
+				b.Error(err)
+				return
+			}
+			local = append(local, time.Since(start))
+		}
+		mu.Lock()
+		latencies = append(latencies, local...)
+		mu.Unlock()
+	})
+	b.StopTimer()
+
+	if err := c.Close(); err != nil {
+		b.Fatal(err)
+	}
+	if len(latencies) == 0 {
+		return
+	}
+	slices.Sort(latencies)
+	percentile := func(p float64) float64 {
+		return float64(latencies[int(p*float64(len(latencies)-1))].Microseconds())
+	}
+	b.ReportMetric(percentile(0.5), "p50-µs")
+	b.ReportMetric(percentile(0.99), "p99-µs")
+}
+
+func BenchmarkCommit_Pipeline(b *testing.B) { benchmarkCommit(b, openBenchPipeline) }
+func BenchmarkCommit_Dispatch(b *testing.B) { benchmarkCommit(b, openBenchDispatch) }
@@ -1,28 +1,42 @@
 package wal
 
 import (
+	"github.com/rdimidov/kvstore/internal/domain"
 	"github.com/rdimidov/kvstore/pkg/concurrency"
 )
 
-type FutureError = concurrency.Future[error]
-type PromiseError = concurrency.Promise[error]
+// Commit is the outcome of logging a record: the LSN it was committed at,
+// or the error that kept it from being logged.
+type Commit struct {
+	LSN domain.LSN
+	Err error
+}
+
+type FutureCommit = concurrency.Future[Commit]
+type PromiseCommit = concurrency.Promise[Commit]
 
 type entry struct {
 	data    string
-	promise *PromiseError
+	promise *PromiseCommit
 }
 
 func newEntry(s string) entry {
 	return entry{
 		data:    s,
-		promise: concurrency.NewPromise[error](),
+		promise: concurrency.NewPromise[Commit](),
 	}
 }
 
-func (e *entry) FutureResponse() FutureError {
+func (e *entry) FutureResponse() FutureCommit {
 	return e.promise.GetFuture()
 }
 
-func (e *entry) SetResponse(err error) {
-	e.promise.Set(err)
+func (e *entry) SetResponse(lsn domain.LSN, err error) {
+	e.promise.Set(Commit{LSN: lsn, Err: err})
+}
+
+// wait returns the outcome of logging a record once it is known.
+func wait(fut FutureCommit) (domain.LSN, error) {
+	c := fut.Get()
+	return c.LSN, c.Err
 }
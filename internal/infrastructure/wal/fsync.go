@@ -0,0 +1,79 @@
+package wal
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FsyncMode tells when written records are synced to disk, and so what a
+// write being acknowledged guarantees.
+type FsyncMode string
+
+const (
+	// FsyncAlways syncs every record on its own before acknowledging it.
+	// An acknowledged write survives a crash of the machine, at the cost
+	// of a sync per write.
+	FsyncAlways FsyncMode = "always"
+	// FsyncBatch syncs each batch once before acknowledging its records,
+	// which share the sync. The guarantee is that of FsyncAlways.
+	FsyncBatch FsyncMode = "batch"
+	// FsyncInterval acknowledges records once they are handed to the OS and
+	// syncs them periodically. An acknowledged write survives a crash of
+	// the server, but a crash of the machine loses up to an interval.
+	FsyncInterval FsyncMode = "interval"
+	// FsyncNever leaves syncing to the OS, but for full segments and
+	// shutdown. An acknowledged write survives a crash of the server only.
+	FsyncNever FsyncMode = "never"
+)
+
+// FsyncPolicy is the fsync mode of the WAL, with the interval of
+// FsyncInterval. Whatever the policy, a command asking for SYNC is synced
+// before it is acknowledged.
+type FsyncPolicy struct {
+	Mode     FsyncMode
+	Interval time.Duration
+}
+
+// ParseFsyncPolicy parses "always", "batch", "never" or "interval(<d>)",
+// where d is a duration such as 100ms or a number of milliseconds. Empty s
+// is FsyncBatch.
+func ParseFsyncPolicy(s string) (FsyncPolicy, error) {
+	switch m := FsyncMode(s); m {
+	case FsyncAlways, FsyncBatch, FsyncNever:
+		return FsyncPolicy{Mode: m}, nil
+	case "":
+		return FsyncPolicy{Mode: FsyncBatch}, nil
+	}
+
+	arg, ok := strings.CutPrefix(s, string(FsyncInterval)+"(")
+	if arg, ok = strings.CutSuffix(arg, ")"); !ok {
+		return FsyncPolicy{}, fmt.Errorf("unknown fsync policy: %q", s)
+	}
+	interval, err := time.ParseDuration(arg)
+	if err != nil {
+		ms, nerr := strconv.Atoi(arg)
+		if nerr != nil {
+			return FsyncPolicy{}, fmt.Errorf("fsync interval: %w", err)
+		}
+		interval = time.Duration(ms) * time.Millisecond
+	}
+	if interval <= 0 {
+		return FsyncPolicy{}, fmt.Errorf("fsync interval must be positive: %q", s)
+	}
+	return FsyncPolicy{Mode: FsyncInterval, Interval: interval}, nil
+}
+
+func (p FsyncPolicy) String() string {
+	if p.Mode == FsyncInterval {
+		return fmt.Sprintf("%s(%s)", p.Mode, p.Interval)
+	}
+	return string(p.Mode)
+}
+
+// syncsWrites tells whether records are synced before they are
+// acknowledged. The zero policy does, as FsyncBatch.
+func (p FsyncPolicy) syncsWrites() bool {
+	return p.Mode != FsyncInterval && p.Mode != FsyncNever
+}
@@ -0,0 +1,148 @@
+package wal
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rdimidov/kvstore/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFsyncPolicy(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    FsyncPolicy
+		wantErr bool
+	}{
+		{in: "always", want: FsyncPolicy{Mode: FsyncAlways}},
+		{in: "batch", want: FsyncPolicy{Mode: FsyncBatch}},
+		{in: "never", want: FsyncPolicy{Mode: FsyncNever}},
+		{in: "", want: FsyncPolicy{Mode: FsyncBatch}},
+		{in: "interval(100ms)", want: FsyncPolicy{Mode: FsyncInterval, Interval: 100 * time.Millisecond}},
+		{in: "interval(250)", want: FsyncPolicy{Mode: FsyncInterval, Interval: 250 * time.Millisecond}},
+		{in: "interval(0)", wantErr: true},
+		{in: "interval()", wantErr: true},
+		{in: "interval", wantErr: true},
+		{in: "sometimes", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseFsyncPolicy(tt.in)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestFsyncPolicy_String(t *testing.T) {
+	assert.Equal(t, "batch", FsyncPolicy{Mode: FsyncBatch}.String())
+	assert.Equal(t, "interval(1s)", FsyncPolicy{Mode: FsyncInterval, Interval: time.Second}.String())
+}
+
+func TestRotatingWalWriter_Fsync(t *testing.T) {
+	newWriter := func(t *testing.T, mode FsyncMode) *rotatingWalWriter {
+		w, err := newRotatingWalWriter(t.TempDir(), 1<<20)
+		require.NoError(t, err)
+		w.fsync = FsyncPolicy{Mode: mode}
+		t.Cleanup(func() { _ = w.Close() })
+		return w
+	}
+	write := func(t *testing.T, w *rotatingWalWriter, data ...string) {
+		batch := make([]entry, len(data))
+		for i, d := range data {
+			batch[i] = newEntry(d)
+		}
+		w.Write(batch)
+		for _, e := range batch {
+			_, err := wait(e.FutureResponse())
+			require.NoError(t, err)
+		}
+	}
+
+	t.Run("batch syncs before acknowledging", func(t *testing.T) {
+		w := newWriter(t, FsyncBatch)
+		write(t, w, "SET a 1", "SET b 2")
+		assert.Equal(t, uint64(2), w.synced)
+	})
+
+	t.Run("always syncs before acknowledging", func(t *testing.T) {
+		w := newWriter(t, FsyncAlways)
+		write(t, w, "SET a 1", "SET b 2", "SET c 3")
+		assert.Equal(t, uint64(3), w.synced)
+	})
+
+	t.Run("never leaves records to Sync", func(t *testing.T) {
+		w := newWriter(t, FsyncNever)
+		write(t, w, "SET a 1", "SET b 2")
+		assert.Zero(t, w.synced)
+
+		require.NoError(t, w.Sync())
+		assert.Equal(t, uint64(2), w.synced)
+	})
+
+	t.Run("failed sync leaves records logged", func(t *testing.T) {
+		w := newWriter(t, FsyncAlways)
+		write(t, w, "SET a 1")
+		w.curFile = failingSync{w.curFile}
+
+		e := newEntry("SET b 2")
+		w.Write([]entry{e})
+		lsn, err := wait(e.FutureResponse())
+		assert.ErrorIs(t, err, domain.ErrDurabilityUnknown)
+		assert.Equal(t, domain.LSN(2), lsn)
+		assert.Equal(t, uint64(2), w.lsn)
+		assert.Equal(t, uint64(1), w.synced)
+
+		records, _, err := readSegment(filepath.Join(w.dir, w.curName))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"SET a 1", "SET b 2"}, records)
+	})
+}
+
+// failingSync is a segment whose syncs fail.
+type failingSync struct {
+	segmentFile
+}
+
+func (failingSync) Sync() error { return errors.New("sync failed") }
+
+type fsyncConfig struct {
+	testConfig
+	policy string
+}
+
+func (c fsyncConfig) WALFsyncPolicy() string { return c.policy }
+
+func TestNewRejectsUnknownFsyncPolicy(t *testing.T) {
+	cfg := fsyncConfig{policy: "sometimes"}
+	defer cleanupTestDir(t, cfg.WALDirName())
+
+	_, err := New(cfg, newMockinterpreter(t))
+	assert.Error(t, err)
+}
+
+func TestSyncEveryInterval(t *testing.T) {
+	cfg := fsyncConfig{policy: "interval(5ms)"}
+	defer cleanupTestDir(t, cfg.WALDirName())
+	w, err := New(cfg, newMockinterpreter(t))
+	require.NoError(t, err)
+	defer w.Close()
+
+	_, err = w.WriteDel("somekey")
+	require.NoError(t, err)
+
+	writer := w.writer.(*rotatingWalWriter)
+	assert.Eventually(t, func() bool {
+		writer.mu.Lock()
+		defer writer.mu.Unlock()
+		return writer.lsn == 1 && writer.synced == 1
+	}, time.Second, 5*time.Millisecond)
+}
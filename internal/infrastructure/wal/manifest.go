@@ -0,0 +1,154 @@
+package wal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// The manifest lists the segments of the log in order. It is replaced as a
+// whole whenever a segment is added or removed, so it never lists a segment
+// that was only partly created, and files left over in the directory are not
+// taken for segments.
+const (
+	manifestName    = "MANIFEST"
+	manifestVersion = 1
+)
+
+type manifest struct {
+	Version  int      `json:"version"`
+	Segments []string `json:"segments"`
+}
+
+// manifestMu serializes the updates of the manifest made by the writer
+// rotating and by the WAL truncating.
+var manifestMu sync.Mutex
+
+// segmentName names the segment whose first record has the given LSN. The
+// LSN is zero padded, so names sort in log order.
+func segmentName(firstLSN uint64) string {
+	return fmt.Sprintf("%020d.%s", firstLSN, baseFileName)
+}
+
+// segmentLSN returns the LSN the segment of the given name starts at. Names
+// given by creation time, before segments were named by LSN, do not parse.
+func segmentLSN(name string) (uint64, bool) {
+	s, ok := strings.CutSuffix(name, "."+baseFileName)
+	if !ok || len(s) != 20 {
+		return 0, false
+	}
+	lsn, err := strconv.ParseUint(s, 10, 64)
+	return lsn, err == nil
+}
+
+// readManifest returns the manifest of dir, nil when there is none yet.
+func readManifest(dir string) (*manifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, manifestName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("read WAL manifest: %w", err)
+	}
+	if m.Version != manifestVersion {
+		return nil, fmt.Errorf("read WAL manifest: unsupported version %d", m.Version)
+	}
+	return &m, nil
+}
+
+func writeManifest(dir string, segments []string) error {
+	data, err := json.Marshal(manifest{Version: manifestVersion, Segments: segments})
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(dir, manifestName), data)
+}
+
+// updateManifest replaces the segment list of dir with what fn makes of it.
+func updateManifest(dir string, fn func([]string) []string) error {
+	manifestMu.Lock()
+	defer manifestMu.Unlock()
+
+	segments, err := segmentNames(dir)
+	if err != nil {
+		return err
+	}
+	return writeManifest(dir, fn(segments))
+}
+
+// addSegment appends a segment to the manifest, unless it is the last one
+// already, as when an empty segment was created anew.
+func addSegment(dir, name string) error {
+	return updateManifest(dir, func(segments []string) []string {
+		if n := len(segments); n > 0 && segments[n-1] == name {
+			return segments
+		}
+		return append(segments, name)
+	})
+}
+
+// removeSegments drops the given segments from the manifest and deletes
+// them. They are dropped first, so a crash in between leaves files that are
+// no longer part of the log.
+func removeSegments(dir string, names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+	err := updateManifest(dir, func(segments []string) []string {
+		return slices.DeleteFunc(segments, func(s string) bool {
+			return slices.Contains(names, s)
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		if err := os.Remove(filepath.Join(dir, name)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return syncDir(dir)
+}
+
+// segmentNames returns the names of the segments in dir in log order: those
+// of the manifest, or those found in dir when there is no manifest yet.
+func segmentNames(dir string) ([]string, error) {
+	m, err := readManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+	if m != nil {
+		return m.Segments, nil
+	}
+
+	files, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, f := range files {
+		if !f.IsDir() && isSegment(f.Name()) {
+			names = append(names, f.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
@@ -0,0 +1,149 @@
+package wal
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// migrateTextSegments rewrites the segments of the former text format, a
+// command per line, in the binary format, numbering their records after
+// those of the segments before them. Each segment is replaced at once, so an
+// interrupted migration resumes where it stopped on the next start. A last
+// line without its newline was torn by a crash and is dropped.
+func migrateTextSegments(dir string) error {
+	names, err := segmentNames(dir)
+	if err != nil {
+		return err
+	}
+
+	nextLSN := uint64(1)
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		if strings.HasPrefix(string(data), segmentMagic) {
+			sr, _, err := scanSegment(path)
+			if err != nil {
+				return err
+			}
+			nextLSN = sr.nextLSN
+			continue
+		}
+
+		text := string(data)
+		text = text[:strings.LastIndexByte(text, '\n')+1]
+		buf := appendSegmentHeader(nil, nextLSN)
+		for _, line := range strings.Split(text, "\n") {
+			if line == "" {
+				continue
+			}
+			buf = appendRecord(buf, newRecord(nextLSN, line))
+			nextLSN++
+		}
+		if err := writeFileAtomic(path, buf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateSegmentNames renames the segments named by their creation time
+// after the LSN they start at, renames the snapshots taken at them alike and
+// writes the manifest. It does nothing once the manifest exists. Snapshots
+// are renamed first and the manifest written last, so an interrupted
+// migration is finished on the next start.
+func migrateSegmentNames(dir, snapshotDir string) error {
+	m, err := readManifest(dir)
+	if err != nil || m != nil {
+		return err
+	}
+	names, err := segmentNames(dir)
+	if err != nil {
+		return err
+	}
+
+	type segment struct {
+		name        string
+		first, next uint64
+	}
+	segments := make([]segment, 0, len(names))
+	firstOf := make(map[string]uint64, len(names))
+	for _, name := range names {
+		sr, _, err := scanSegment(filepath.Join(dir, name))
+		if err != nil {
+			return err
+		}
+		segments = append(segments, segment{name: name, first: sr.firstLSN, next: sr.nextLSN})
+		firstOf[name] = sr.firstLSN
+	}
+	sort.SliceStable(segments, func(i, j int) bool { return segments[i].first < segments[j].first })
+
+	// rotating with no write in between left empty segments starting where
+	// the next one does; a single segment per LSN is kept
+	var kept, dropped []segment
+	for _, s := range segments {
+		n := len(kept)
+		switch {
+		case n == 0 || kept[n-1].first != s.first:
+			kept = append(kept, s)
+		case s.next == s.first:
+			dropped = append(dropped, s)
+		default:
+			dropped = append(dropped, kept[n-1])
+			kept[n-1] = s
+		}
+	}
+	nextLSN := uint64(1)
+	if n := len(kept); n > 0 {
+		nextLSN = kept[n-1].next
+	}
+
+	positions, err := listSnapshots(snapshotDir)
+	if err != nil {
+		return err
+	}
+	for _, p := range positions {
+		if _, ok := segmentLSN(p); ok {
+			continue
+		}
+		// a snapshot is taken at the segment the records after it start,
+		// or at the end of the log when that segment is gone
+		to := segmentName(nextLSN)
+		if i := sort.SearchStrings(names, p); i < len(names) {
+			to = segmentName(firstOf[names[i]])
+		}
+		if err := os.Rename(filepath.Join(snapshotDir, p+snapshotExt), filepath.Join(snapshotDir, to+snapshotExt)); err != nil {
+			return err
+		}
+	}
+	if len(positions) > 0 {
+		if err := syncDir(snapshotDir); err != nil {
+			return err
+		}
+	}
+
+	for _, s := range dropped {
+		if err := os.Remove(filepath.Join(dir, s.name)); err != nil {
+			return err
+		}
+	}
+	order := make([]string, 0, len(kept))
+	for _, s := range kept {
+		name := segmentName(s.first)
+		if name != s.name {
+			if err := os.Rename(filepath.Join(dir, s.name), filepath.Join(dir, name)); err != nil {
+				return err
+			}
+		}
+		order = append(order, name)
+	}
+	if err := syncDir(dir); err != nil {
+		return err
+	}
+	return writeManifest(dir, order)
+}
@@ -12,13 +12,13 @@ import (
 	mock "github.com/stretchr/testify/mock"
 )
 
-// newMockrepository creates a new instance of mockrepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// newMockwriter creates a new instance of mockwriter. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
-func newMockrepository(t interface {
+func newMockwriter(t interface {
 	mock.TestingT
 	Cleanup(func())
-}) *mockrepository {
-	mock := &mockrepository{}
+}) *mockwriter {
+	mock := &mockwriter{}
 	mock.Mock.Test(t)
 
 	t.Cleanup(func() { mock.AssertExpectations(t) })
@@ -26,137 +26,158 @@ func newMockrepository(t interface {
 	return mock
 }
 
-// mockrepository is an autogenerated mock type for the repository type
-type mockrepository struct {
+// mockwriter is an autogenerated mock type for the writer type
+type mockwriter struct {
 	mock.Mock
 }
 
-type mockrepository_Expecter struct {
+type mockwriter_Expecter struct {
 	mock *mock.Mock
 }
 
-func (_m *mockrepository) EXPECT() *mockrepository_Expecter {
-	return &mockrepository_Expecter{mock: &_m.Mock}
+func (_m *mockwriter) EXPECT() *mockwriter_Expecter {
+	return &mockwriter_Expecter{mock: &_m.Mock}
 }
 
-// Delete provides a mock function for the type mockrepository
-func (_mock *mockrepository) Delete(context1 context.Context, key domain.Key) error {
-	ret := _mock.Called(context1, key)
+// Close provides a mock function for the type mockwriter
+func (_mock *mockwriter) Close() error {
+	ret := _mock.Called()
 
 	if len(ret) == 0 {
-		panic("no return value specified for Delete")
+		panic("no return value specified for Close")
 	}
 
 	var r0 error
-	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key) error); ok {
-		r0 = returnFunc(context1, key)
+	if returnFunc, ok := ret.Get(0).(func() error); ok {
+		r0 = returnFunc()
 	} else {
 		r0 = ret.Error(0)
 	}
 	return r0
 }
 
-// mockrepository_Delete_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Delete'
-type mockrepository_Delete_Call struct {
+// mockwriter_Close_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Close'
+type mockwriter_Close_Call struct {
 	*mock.Call
 }
 
-// Delete is a helper method to define mock.On call
-//   - context1
-//   - key
-func (_e *mockrepository_Expecter) Delete(context1 interface{}, key interface{}) *mockrepository_Delete_Call {
-	return &mockrepository_Delete_Call{Call: _e.mock.On("Delete", context1, key)}
+// Close is a helper method to define mock.On call
+func (_e *mockwriter_Expecter) Close() *mockwriter_Close_Call {
+	return &mockwriter_Close_Call{Call: _e.mock.On("Close")}
 }
 
-func (_c *mockrepository_Delete_Call) Run(run func(context1 context.Context, key domain.Key)) *mockrepository_Delete_Call {
+func (_c *mockwriter_Close_Call) Run(run func()) *mockwriter_Close_Call {
 	_c.Call.Run(func(args mock.Arguments) {
-		run(args[0].(context.Context), args[1].(domain.Key))
+		run()
 	})
 	return _c
 }
 
-func (_c *mockrepository_Delete_Call) Return(err error) *mockrepository_Delete_Call {
+func (_c *mockwriter_Close_Call) Return(err error) *mockwriter_Close_Call {
 	_c.Call.Return(err)
 	return _c
 }
 
-func (_c *mockrepository_Delete_Call) RunAndReturn(run func(context1 context.Context, key domain.Key) error) *mockrepository_Delete_Call {
+func (_c *mockwriter_Close_Call) RunAndReturn(run func() error) *mockwriter_Close_Call {
 	_c.Call.Return(run)
 	return _c
 }
 
-// Set provides a mock function for the type mockrepository
-func (_mock *mockrepository) Set(context1 context.Context, key domain.Key, value domain.Value) error {
-	ret := _mock.Called(context1, key, value)
+// Rotate provides a mock function for the type mockwriter
+func (_mock *mockwriter) Rotate() (string, error) {
+	ret := _mock.Called()
 
 	if len(ret) == 0 {
-		panic("no return value specified for Set")
+		panic("no return value specified for Rotate")
 	}
 
-	var r0 error
-	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key, domain.Value) error); ok {
-		r0 = returnFunc(context1, key, value)
+	var r0 string
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func() (string, error)); ok {
+		return returnFunc()
+	}
+	if returnFunc, ok := ret.Get(0).(func() string); ok {
+		r0 = returnFunc()
 	} else {
-		r0 = ret.Error(0)
+		r0 = ret.Get(0).(string)
 	}
-	return r0
+	if returnFunc, ok := ret.Get(1).(func() error); ok {
+		r1 = returnFunc()
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
 }
 
-// mockrepository_Set_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Set'
-type mockrepository_Set_Call struct {
+// mockwriter_Rotate_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Rotate'
+type mockwriter_Rotate_Call struct {
 	*mock.Call
 }
 
-// Set is a helper method to define mock.On call
-//   - context1
-//   - key
-//   - value
-func (_e *mockrepository_Expecter) Set(context1 interface{}, key interface{}, value interface{}) *mockrepository_Set_Call {
-	return &mockrepository_Set_Call{Call: _e.mock.On("Set", context1, key, value)}
+// Rotate is a helper method to define mock.On call
+func (_e *mockwriter_Expecter) Rotate() *mockwriter_Rotate_Call {
+	return &mockwriter_Rotate_Call{Call: _e.mock.On("Rotate")}
 }
 
-func (_c *mockrepository_Set_Call) Run(run func(context1 context.Context, key domain.Key, value domain.Value)) *mockrepository_Set_Call {
+func (_c *mockwriter_Rotate_Call) Run(run func()) *mockwriter_Rotate_Call {
 	_c.Call.Run(func(args mock.Arguments) {
-		run(args[0].(context.Context), args[1].(domain.Key), args[2].(domain.Value))
+		run()
 	})
 	return _c
 }
 
-func (_c *mockrepository_Set_Call) Return(err error) *mockrepository_Set_Call {
-	_c.Call.Return(err)
+func (_c *mockwriter_Rotate_Call) Return(string1 string, err error) *mockwriter_Rotate_Call {
+	_c.Call.Return(string1, err)
 	return _c
 }
 
-func (_c *mockrepository_Set_Call) RunAndReturn(run func(context1 context.Context, key domain.Key, value domain.Value) error) *mockrepository_Set_Call {
+func (_c *mockwriter_Rotate_Call) RunAndReturn(run func() (string, error)) *mockwriter_Rotate_Call {
 	_c.Call.Return(run)
 	return _c
 }
 
-// newMockwriter creates a new instance of mockwriter. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
-// The first argument is typically a *testing.T value.
-func newMockwriter(t interface {
-	mock.TestingT
-	Cleanup(func())
-}) *mockwriter {
-	mock := &mockwriter{}
-	mock.Mock.Test(t)
+// Sync provides a mock function for the type mockwriter
+func (_mock *mockwriter) Sync() error {
+	ret := _mock.Called()
 
-	t.Cleanup(func() { mock.AssertExpectations(t) })
+	if len(ret) == 0 {
+		panic("no return value specified for Sync")
+	}
 
-	return mock
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func() error); ok {
+		r0 = returnFunc()
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
 }
 
-// mockwriter is an autogenerated mock type for the writer type
-type mockwriter struct {
-	mock.Mock
+// mockwriter_Sync_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Sync'
+type mockwriter_Sync_Call struct {
+	*mock.Call
 }
 
-type mockwriter_Expecter struct {
-	mock *mock.Mock
+// Sync is a helper method to define mock.On call
+func (_e *mockwriter_Expecter) Sync() *mockwriter_Sync_Call {
+	return &mockwriter_Sync_Call{Call: _e.mock.On("Sync")}
 }
 
-func (_m *mockwriter) EXPECT() *mockwriter_Expecter {
-	return &mockwriter_Expecter{mock: &_m.Mock}
+func (_c *mockwriter_Sync_Call) Run(run func()) *mockwriter_Sync_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *mockwriter_Sync_Call) Return(err error) *mockwriter_Sync_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *mockwriter_Sync_Call) RunAndReturn(run func() error) *mockwriter_Sync_Call {
+	_c.Call.Return(run)
+	return _c
 }
 
 // Write provides a mock function for the type mockwriter
@@ -298,8 +319,8 @@ func (_c *mockconfig_WALBatchSize_Call) Run(run func()) *mockconfig_WALBatchSize
 	return _c
 }
 
-func (_c *mockconfig_WALBatchSize_Call) Return(n int) *mockconfig_WALBatchSize_Call {
-	_c.Call.Return(n)
+func (_c *mockconfig_WALBatchSize_Call) Return(int1 int) *mockconfig_WALBatchSize_Call {
+	_c.Call.Return(int1)
 	return _c
 }
 
@@ -342,8 +363,8 @@ func (_c *mockconfig_WALDirName_Call) Run(run func()) *mockconfig_WALDirName_Cal
 	return _c
 }
 
-func (_c *mockconfig_WALDirName_Call) Return(s string) *mockconfig_WALDirName_Call {
-	_c.Call.Return(s)
+func (_c *mockconfig_WALDirName_Call) Return(string1 string) *mockconfig_WALDirName_Call {
+	_c.Call.Return(string1)
 	return _c
 }
 
@@ -352,6 +373,50 @@ func (_c *mockconfig_WALDirName_Call) RunAndReturn(run func() string) *mockconfi
 	return _c
 }
 
+// WALFsyncPolicy provides a mock function for the type mockconfig
+func (_mock *mockconfig) WALFsyncPolicy() string {
+	ret := _mock.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for WALFsyncPolicy")
+	}
+
+	var r0 string
+	if returnFunc, ok := ret.Get(0).(func() string); ok {
+		r0 = returnFunc()
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+	return r0
+}
+
+// mockconfig_WALFsyncPolicy_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'WALFsyncPolicy'
+type mockconfig_WALFsyncPolicy_Call struct {
+	*mock.Call
+}
+
+// WALFsyncPolicy is a helper method to define mock.On call
+func (_e *mockconfig_Expecter) WALFsyncPolicy() *mockconfig_WALFsyncPolicy_Call {
+	return &mockconfig_WALFsyncPolicy_Call{Call: _e.mock.On("WALFsyncPolicy")}
+}
+
+func (_c *mockconfig_WALFsyncPolicy_Call) Run(run func()) *mockconfig_WALFsyncPolicy_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *mockconfig_WALFsyncPolicy_Call) Return(string1 string) *mockconfig_WALFsyncPolicy_Call {
+	_c.Call.Return(string1)
+	return _c
+}
+
+func (_c *mockconfig_WALFsyncPolicy_Call) RunAndReturn(run func() string) *mockconfig_WALFsyncPolicy_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // WALMaxSegmentSize provides a mock function for the type mockconfig
 func (_mock *mockconfig) WALMaxSegmentSize() int {
 	ret := _mock.Called()
@@ -386,8 +451,8 @@ func (_c *mockconfig_WALMaxSegmentSize_Call) Run(run func()) *mockconfig_WALMaxS
 	return _c
 }
 
-func (_c *mockconfig_WALMaxSegmentSize_Call) Return(n int) *mockconfig_WALMaxSegmentSize_Call {
-	_c.Call.Return(n)
+func (_c *mockconfig_WALMaxSegmentSize_Call) Return(int1 int) *mockconfig_WALMaxSegmentSize_Call {
+	_c.Call.Return(int1)
 	return _c
 }
 
@@ -396,6 +461,94 @@ func (_c *mockconfig_WALMaxSegmentSize_Call) RunAndReturn(run func() int) *mockc
 	return _c
 }
 
+// WALRecoveryPolicy provides a mock function for the type mockconfig
+func (_mock *mockconfig) WALRecoveryPolicy() string {
+	ret := _mock.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for WALRecoveryPolicy")
+	}
+
+	var r0 string
+	if returnFunc, ok := ret.Get(0).(func() string); ok {
+		r0 = returnFunc()
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+	return r0
+}
+
+// mockconfig_WALRecoveryPolicy_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'WALRecoveryPolicy'
+type mockconfig_WALRecoveryPolicy_Call struct {
+	*mock.Call
+}
+
+// WALRecoveryPolicy is a helper method to define mock.On call
+func (_e *mockconfig_Expecter) WALRecoveryPolicy() *mockconfig_WALRecoveryPolicy_Call {
+	return &mockconfig_WALRecoveryPolicy_Call{Call: _e.mock.On("WALRecoveryPolicy")}
+}
+
+func (_c *mockconfig_WALRecoveryPolicy_Call) Run(run func()) *mockconfig_WALRecoveryPolicy_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *mockconfig_WALRecoveryPolicy_Call) Return(string1 string) *mockconfig_WALRecoveryPolicy_Call {
+	_c.Call.Return(string1)
+	return _c
+}
+
+func (_c *mockconfig_WALRecoveryPolicy_Call) RunAndReturn(run func() string) *mockconfig_WALRecoveryPolicy_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// WALSnapshotDirName provides a mock function for the type mockconfig
+func (_mock *mockconfig) WALSnapshotDirName() string {
+	ret := _mock.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for WALSnapshotDirName")
+	}
+
+	var r0 string
+	if returnFunc, ok := ret.Get(0).(func() string); ok {
+		r0 = returnFunc()
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+	return r0
+}
+
+// mockconfig_WALSnapshotDirName_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'WALSnapshotDirName'
+type mockconfig_WALSnapshotDirName_Call struct {
+	*mock.Call
+}
+
+// WALSnapshotDirName is a helper method to define mock.On call
+func (_e *mockconfig_Expecter) WALSnapshotDirName() *mockconfig_WALSnapshotDirName_Call {
+	return &mockconfig_WALSnapshotDirName_Call{Call: _e.mock.On("WALSnapshotDirName")}
+}
+
+func (_c *mockconfig_WALSnapshotDirName_Call) Run(run func()) *mockconfig_WALSnapshotDirName_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *mockconfig_WALSnapshotDirName_Call) Return(string1 string) *mockconfig_WALSnapshotDirName_Call {
+	_c.Call.Return(string1)
+	return _c
+}
+
+func (_c *mockconfig_WALSnapshotDirName_Call) RunAndReturn(run func() string) *mockconfig_WALSnapshotDirName_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // newMockinterpreter creates a new instance of mockinterpreter. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func newMockinterpreter(t interface {
@@ -424,24 +577,22 @@ func (_m *mockinterpreter) EXPECT() *mockinterpreter_Expecter {
 }
 
 // Execute provides a mock function for the type mockinterpreter
-func (_mock *mockinterpreter) Execute(ctx context.Context, raw string) (*domain.Entry, error) {
+func (_mock *mockinterpreter) Execute(ctx context.Context, raw string) (domain.Result, error) {
 	ret := _mock.Called(ctx, raw)
 
 	if len(ret) == 0 {
 		panic("no return value specified for Execute")
 	}
 
-	var r0 *domain.Entry
+	var r0 domain.Result
 	var r1 error
-	if returnFunc, ok := ret.Get(0).(func(context.Context, string) (*domain.Entry, error)); ok {
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) (domain.Result, error)); ok {
 		return returnFunc(ctx, raw)
 	}
-	if returnFunc, ok := ret.Get(0).(func(context.Context, string) *domain.Entry); ok {
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) domain.Result); ok {
 		r0 = returnFunc(ctx, raw)
 	} else {
-		if ret.Get(0) != nil {
-			r0 = ret.Get(0).(*domain.Entry)
-		}
+		r0 = ret.Get(0).(domain.Result)
 	}
 	if returnFunc, ok := ret.Get(1).(func(context.Context, string) error); ok {
 		r1 = returnFunc(ctx, raw)
@@ -470,12 +621,12 @@ func (_c *mockinterpreter_Execute_Call) Run(run func(ctx context.Context, raw st
 	return _c
 }
 
-func (_c *mockinterpreter_Execute_Call) Return(entry *domain.Entry, err error) *mockinterpreter_Execute_Call {
-	_c.Call.Return(entry, err)
+func (_c *mockinterpreter_Execute_Call) Return(result domain.Result, err error) *mockinterpreter_Execute_Call {
+	_c.Call.Return(result, err)
 	return _c
 }
 
-func (_c *mockinterpreter_Execute_Call) RunAndReturn(run func(ctx context.Context, raw string) (*domain.Entry, error)) *mockinterpreter_Execute_Call {
+func (_c *mockinterpreter_Execute_Call) RunAndReturn(run func(ctx context.Context, raw string) (domain.Result, error)) *mockinterpreter_Execute_Call {
 	_c.Call.Return(run)
 	return _c
 }
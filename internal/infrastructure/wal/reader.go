@@ -1,12 +1,17 @@
 package wal
 
 import (
-	"bufio"
+	"errors"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
-	"sort"
 )
 
+// maxRecordSize bounds a single record, which a transaction or a large
+// collection can make longer than a segment.
+const maxRecordSize = 64 << 20
+
 type reader struct {
 	dir string
 }
@@ -15,42 +20,74 @@ func NewReader(dir string) reader {
 	return reader{dir: dir}
 }
 
-func (r *reader) Read() ([]string, error) {
+// Read returns the commands of every segment whose name sorts at or after
+// from; empty from reads the whole log. Reading stops cleanly at the last
+// valid record, before a torn or damaged one or a gap in the sequence.
+func (r *reader) Read(from string) ([]string, error) {
 	var lines []string
 
-	entries, err := os.ReadDir(r.dir)
+	names, err := segmentNames(r.dir)
 	if err != nil {
 		return nil, err
 	}
 
-	// Sort entries by filename (assumes filenames are time-prefixed and sortable)
-	sort.Slice(entries, func(i, j int) bool {
-		return entries[i].Name() < entries[j].Name()
-	})
-
-	for _, entry := range entries {
-		if entry.IsDir() {
+	var nextLSN uint64
+	for _, name := range names {
+		if name < from {
 			continue
 		}
 
-		path := filepath.Join(r.dir, entry.Name())
-
-		file, err := os.Open(path)
-		if err != nil {
+		records, sr, err := readSegment(filepath.Join(r.dir, name))
+		if err != nil && !isDamage(err) {
 			return nil, err
 		}
+		if nextLSN != 0 && sr.firstLSN != nextLSN {
+			return lines, nil
+		}
 
-		scanner := bufio.NewScanner(file)
-		for scanner.Scan() {
-			lines = append(lines, scanner.Text())
+		lines = append(lines, records...)
+		if err != nil {
+			return lines, nil
 		}
+		nextLSN = sr.nextLSN
+	}
 
-		file.Close()
+	return lines, nil
+}
 
-		if err := scanner.Err(); err != nil {
-			return nil, err
+// readSegment returns the commands of the valid records of a segment, and
+// the reader that went through them. When the segment does not go on validly
+// after them, the error tells why, see isDamage.
+func readSegment(path string) ([]string, *segmentReader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	sr, err := newSegmentReader(file)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	var lines []string
+	for {
+		rec, err := sr.next()
+		if errors.Is(err, io.EOF) {
+			return lines, sr, nil
 		}
+		if isDamage(err) {
+			return lines, sr, err
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		lines = append(lines, rec.data)
 	}
+}
 
-	return lines, nil
+// isDamage tells errors of a segment that does not go on validly from
+// those of reading it.
+func isDamage(err error) bool {
+	return errors.Is(err, errTornRecord) || errors.Is(err, errBadRecord)
 }
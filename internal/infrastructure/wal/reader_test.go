@@ -3,42 +3,143 @@ package wal
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
+// writeSegment writes a segment of records numbered from firstLSN.
+func writeSegment(t *testing.T, path string, firstLSN uint64, commands ...string) {
+	t.Helper()
+	buf := appendSegmentHeader(nil, firstLSN)
+	for i, cmd := range commands {
+		buf = appendRecord(buf, newRecord(firstLSN+uint64(i), cmd))
+	}
+	if err := os.WriteFile(path, buf, 0o644); err != nil {
+		t.Fatalf("failed to write %s: %v", path, err)
+	}
+}
+
 func TestReader_Read_MultipleFiles(t *testing.T) {
 	dir := t.TempDir()
 
-	// create files with predictable timestamps in filenames
+	writeSegment(t, filepath.Join(dir, segmentName(1)), 1, "first1", "first2")
+	writeSegment(t, filepath.Join(dir, segmentName(3)), 3, "second1", "second2")
+	writeSegment(t, filepath.Join(dir, segmentName(5)), 5, "third1", "third2")
+
+	r := NewReader(dir)
+	lines, err := r.Read("")
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+
+	expected := []string{
+		"first1", "first2",
+		"second1", "second2",
+		"third1", "third2",
+	}
+	if len(lines) != len(expected) {
+		t.Fatalf("expected %d lines, got %d", len(expected), len(lines))
+	}
+	for i := range expected {
+		if lines[i] != expected[i] {
+			t.Errorf("at index %d: expected %q, got %q", i, expected[i], lines[i])
+		}
+	}
+}
+
+func TestReader_Read_StopsAtLastValidRecord(t *testing.T) {
+	tests := []struct {
+		name   string
+		damage func(data []byte) []byte
+	}{
+		{"torn header", func(data []byte) []byte { return data[:len(data)-len("second")-recordHeaderSize+3] }},
+		{"torn payload", func(data []byte) []byte { return data[:len(data)-2] }},
+		{"checksum mismatch", func(data []byte) []byte {
+			data[len(data)-1] ^= 0xff
+			return data
+		}},
+		{"garbage", func(data []byte) []byte { return append(data, make([]byte, 64)...) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			first := filepath.Join(dir, segmentName(1))
+			writeSegment(t, first, 1, "first", "second")
+			// later segments are not read past the damage
+			writeSegment(t, filepath.Join(dir, segmentName(3)), 3, "third")
+
+			data, err := os.ReadFile(first)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(first, tt.damage(data), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			r := NewReader(dir)
+			lines, err := r.Read("")
+			if err != nil {
+				t.Fatalf("Read failed: %v", err)
+			}
+			want := []string{"first", "second"}
+			if tt.name != "garbage" {
+				want = want[:1]
+			}
+			if strings.Join(lines, ",") != strings.Join(want, ",") {
+				t.Errorf("expected %q, got %q", want, lines)
+			}
+		})
+	}
+}
+
+func TestReader_Read_StopsAtSequenceGap(t *testing.T) {
+	dir := t.TempDir()
+	writeSegment(t, filepath.Join(dir, segmentName(1)), 1, "first")
+	// the segment holding record 2 is missing
+	writeSegment(t, filepath.Join(dir, segmentName(3)), 3, "third")
+
+	r := NewReader(dir)
+	lines, err := r.Read("")
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if len(lines) != 1 || lines[0] != "first" {
+		t.Errorf("expected only the first record, got %q", lines)
+	}
+}
+
+func TestMigrateTextSegments(t *testing.T) {
+	dir := t.TempDir()
 	files := []struct {
 		name    string
 		content string
 	}{
-		{"20240101T000000.wal", "first1\nfirst2\n"},
-		{"20240101T000001.wal", "second1\nsecond2\n"},
-		{"20240101T000002.wal", "third1\nthird2\n"},
+		{"20240101T000000.wal", "SET a 1\nDEL a\n"},
+		// the last line was torn by a crash
+		{"20240101T000001.wal", "SET b 2\nSET c"},
 	}
-
 	for _, f := range files {
-		err := os.WriteFile(filepath.Join(dir, f.name), []byte(f.content), 0o644)
-		if err != nil {
+		if err := os.WriteFile(filepath.Join(dir, f.name), []byte(f.content), 0o644); err != nil {
 			t.Fatalf("failed to write %s: %v", f.name, err)
 		}
 	}
 
+	if err := migrateTextSegments(dir); err != nil {
+		t.Fatalf("migrate failed: %v", err)
+	}
+	// migrating again leaves the binary segments as they are
+	if err := migrateTextSegments(dir); err != nil {
+		t.Fatalf("second migrate failed: %v", err)
+	}
+
 	r := NewReader(dir)
-	lines, err := r.Read()
+	lines, err := r.Read("")
 	if err != nil {
 		t.Fatalf("Read failed: %v", err)
 	}
-
-	expected := []string{
-		"first1", "first2",
-		"second1", "second2",
-		"third1", "third2",
-	}
+	expected := []string{"SET a 1", "DEL a", "SET b 2"}
 	if len(lines) != len(expected) {
-		t.Fatalf("expected %d lines, got %d", len(expected), len(lines))
+		t.Fatalf("expected %q, got %q", expected, lines)
 	}
 	for i := range expected {
 		if lines[i] != expected[i] {
@@ -46,3 +147,50 @@ func TestReader_Read_MultipleFiles(t *testing.T) {
 		}
 	}
 }
+
+func TestMigrateSegmentNames(t *testing.T) {
+	dir := t.TempDir()
+	snapshotDir := filepath.Join(dir, defaultSnapshotDir)
+	if err := os.Mkdir(snapshotDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	writeSegment(t, filepath.Join(dir, "20240101T000000.000000000.wal"), 1, "SET a 1", "SET b 2")
+	// a snapshot was taken, and another one with no write in between
+	writeSegment(t, filepath.Join(dir, "20240101T000001.000000000.wal"), 3)
+	writeSegment(t, filepath.Join(dir, "20240101T000002.000000000.wal"), 3, "SET c 3")
+	for _, p := range []string{"20240101T000001.000000000.wal", "20240101T000002.000000000.wal"} {
+		if err := os.WriteFile(filepath.Join(snapshotDir, p+snapshotExt), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := migrateSegmentNames(dir, snapshotDir); err != nil {
+		t.Fatalf("migrate failed: %v", err)
+	}
+
+	names, err := segmentNames(dir)
+	if err != nil {
+		t.Fatalf("failed to list segments: %v", err)
+	}
+	expected := []string{segmentName(1), segmentName(3)}
+	if strings.Join(names, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected segments %v, got %v", expected, names)
+	}
+	positions, err := listSnapshots(snapshotDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(positions) != 1 || positions[0] != segmentName(3) {
+		t.Errorf("expected the snapshots at %s, got %v", segmentName(3), positions)
+	}
+
+	r := NewReader(dir)
+	lines, err := r.Read("")
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if strings.Join(lines, ",") != "SET a 1,SET b 2,SET c 3" {
+		t.Errorf("expected every record, got %q", lines)
+	}
+}
@@ -0,0 +1,232 @@
+package wal
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// A segment starts with a header of the magic bytes, the format version,
+// three reserved bytes and the LSN of its first record. Records
+// follow, each of them laid out as
+//
+//	length   uint32  size of the payload
+//	checksum uint32  CRC32C of the type, the LSN and the payload
+//	type     uint8
+//	lsn      uint64  one more than the record before, across segments
+//	payload  [length]byte
+//
+// integers in little endian. A record cut short tells a write torn by a
+// crash, a checksum mismatch a damaged disk; what recovery makes of them
+// depends on its policy, see RecoveryPolicy.
+const (
+	segmentMagic      = "KVWL"
+	formatVersion     = 1
+	segmentHeaderSize = 16
+	recordHeaderSize  = 17
+)
+
+type recordType uint8
+
+const (
+	// recordCommand holds a single command.
+	recordCommand recordType = iota + 1
+	// recordTransaction holds the commands of a transaction, see txRecord.
+	recordTransaction
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+var (
+	errNotSegment         = errors.New("not a WAL segment")
+	errUnsupportedVersion = errors.New("unsupported WAL format version")
+	errTornRecord         = errors.New("record is cut short")
+	errBadRecord          = errors.New("record is damaged")
+)
+
+type record struct {
+	typ  recordType
+	lsn  uint64
+	data string
+	// offset is where the record starts in its segment.
+	offset int64
+}
+
+// newRecord types a logged command.
+func newRecord(lsn uint64, data string) record {
+	typ := recordCommand
+	if strings.HasPrefix(data, txTag+" ") {
+		typ = recordTransaction
+	}
+	return record{typ: typ, lsn: lsn, data: data}
+}
+
+func appendSegmentHeader(buf []byte, firstLSN uint64) []byte {
+	buf = append(buf, segmentMagic...)
+	buf = append(buf, formatVersion, 0, 0, 0)
+	return binary.LittleEndian.AppendUint64(buf, firstLSN)
+}
+
+func appendRecord(buf []byte, r record) []byte {
+	start := len(buf)
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(r.data)))
+	buf = binary.LittleEndian.AppendUint32(buf, 0)
+	buf = append(buf, byte(r.typ))
+	buf = binary.LittleEndian.AppendUint64(buf, r.lsn)
+	buf = append(buf, r.data...)
+	binary.LittleEndian.PutUint32(buf[start+4:], crc32.Checksum(buf[start+8:], castagnoli))
+	return buf
+}
+
+// segmentReader reads the records of a segment in order, checking each of
+// them.
+type segmentReader struct {
+	r        *bufio.Reader
+	firstLSN uint64
+	nextLSN  uint64
+	// offset is where the valid records read so far end, pos where reading
+	// goes on.
+	offset int64
+	pos    int64
+	// resumable tells whether reading may go on after the last damaged
+	// record, which is the case when its length could be trusted.
+	resumable bool
+	// checkLSN is unset after a damaged record, whose LSN is unknown, so
+	// the next one is taken as it is.
+	checkLSN bool
+}
+
+// newSegmentReader reads the header of a segment.
+func newSegmentReader(r io.Reader) (*segmentReader, error) {
+	br := bufio.NewReader(r)
+	header := make([]byte, segmentHeaderSize)
+	if _, err := io.ReadFull(br, header); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, errNotSegment
+		}
+		return nil, err
+	}
+	if string(header[:len(segmentMagic)]) != segmentMagic {
+		return nil, errNotSegment
+	}
+	if header[len(segmentMagic)] != formatVersion {
+		return nil, fmt.Errorf("%w: %d", errUnsupportedVersion, header[len(segmentMagic)])
+	}
+	firstLSN := binary.LittleEndian.Uint64(header[8:])
+	return &segmentReader{
+		r:        br,
+		firstLSN: firstLSN,
+		nextLSN:  firstLSN,
+		offset:   segmentHeaderSize,
+		pos:      segmentHeaderSize,
+		checkLSN: true,
+	}, nil
+}
+
+// next returns the next record, io.EOF at the end of the segment, or
+// errTornRecord or errBadRecord when the segment does not go on validly. The
+// record returned with them only tells where the damage starts.
+func (s *segmentReader) next() (record, error) {
+	start := s.pos
+	damaged := record{offset: start}
+	s.resumable = false
+
+	header := make([]byte, recordHeaderSize)
+	if _, err := io.ReadFull(s.r, header); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return damaged, errTornRecord
+		}
+		return damaged, err
+	}
+	length := binary.LittleEndian.Uint32(header)
+	if length > maxRecordSize {
+		return damaged, errBadRecord
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(s.r, payload); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return damaged, errTornRecord
+		}
+		return damaged, err
+	}
+	s.pos += int64(recordHeaderSize) + int64(length)
+	s.resumable = true
+
+	crc := crc32.Update(crc32.Checksum(header[8:], castagnoli), castagnoli, payload)
+	if crc != binary.LittleEndian.Uint32(header[4:]) {
+		s.checkLSN = false
+		return damaged, errBadRecord
+	}
+	r := record{
+		typ:    recordType(header[8]),
+		lsn:    binary.LittleEndian.Uint64(header[9:]),
+		data:   string(payload),
+		offset: start,
+	}
+	if r.typ != recordCommand && r.typ != recordTransaction {
+		return damaged, errBadRecord
+	}
+	if s.checkLSN && r.lsn != s.nextLSN {
+		return damaged, fmt.Errorf("%w: expected record %d, found %d", errBadRecord, s.nextLSN, r.lsn)
+	}
+
+	s.checkLSN = true
+	s.nextLSN = r.lsn + 1
+	s.offset = s.pos
+	return r, nil
+}
+
+// createSegment creates a segment holding only its header. The header is
+// written aside and renamed into place, so a segment never lacks one.
+func createSegment(path string, firstLSN uint64) error {
+	return writeFileAtomic(path, appendSegmentHeader(nil, firstLSN))
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return syncDir(filepath.Dir(path))
+}
+
+// syncDir makes the entries created in a directory durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	err = d.Sync()
+	if cerr := d.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// isSegment tells segments apart from the other files of the WAL directory,
+// such as leftovers of an interrupted rename.
+func isSegment(name string) bool {
+	return strings.HasSuffix(name, "."+baseFileName)
+}
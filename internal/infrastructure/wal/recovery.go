@@ -0,0 +1,255 @@
+package wal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/rdimidov/kvstore/internal/domain"
+)
+
+// RecoveryPolicy tells what recovery makes of a bad record: one that is
+// damaged, missing from the sequence, or that the interpreter rejects.
+// Whatever the policy, a record torn at the end of the last segment is cut
+// off, since the crash that tore it came before it was acknowledged.
+type RecoveryPolicy string
+
+const (
+	// RecoverStrict fails recovery, so the server does not start.
+	RecoverStrict RecoveryPolicy = "strict"
+	// RecoverTruncate cuts the log before the first bad record, dropping
+	// it and everything logged after it, and goes on.
+	RecoverTruncate RecoveryPolicy = "truncate"
+	// RecoverSkip leaves bad records out and replays the others. A damaged
+	// record whose length cannot be trusted takes the rest of its segment
+	// with it.
+	RecoverSkip RecoveryPolicy = "skip"
+)
+
+func ParseRecoveryPolicy(s string) (RecoveryPolicy, error) {
+	switch p := RecoveryPolicy(s); p {
+	case RecoverStrict, RecoverTruncate, RecoverSkip:
+		return p, nil
+	case "":
+		return RecoverStrict, nil
+	}
+	return "", fmt.Errorf("unknown recovery policy: %q", s)
+}
+
+var errMissingRecords = errors.New("records are missing")
+
+// RecoveryReport tells what recovery went through.
+type RecoveryReport struct {
+	Policy RecoveryPolicy
+	// Snapshot is the position of the snapshot recovery started from, empty
+	// when there was none.
+	Snapshot string
+	Segments []SegmentReport
+	// Applied counts the records replayed, the commands of the snapshot
+	// included.
+	Applied  int
+	Skipped  []SkippedRecord
+	Truncate *Truncation
+}
+
+// SegmentReport tells what was read from a segment.
+type SegmentReport struct {
+	Name string
+	// Records counts the records of the segment that were replayed, and
+	// Bytes is where the last valid one ends.
+	Records int
+	Bytes   int64
+}
+
+// LogPosition is a byte offset within a segment.
+type LogPosition struct {
+	Segment string
+	Offset  int64
+}
+
+type SkippedRecord struct {
+	LogPosition
+	Reason string
+}
+
+// Truncation tells where the log was cut and why.
+type Truncation struct {
+	LogPosition
+	Reason string
+	// Removed are the segments after the cut, deleted as a whole.
+	Removed []string
+}
+
+// Recover loads the newest valid snapshot and replays the segments written
+// after it, handling bad records as the recovery policy says. A command of
+// the snapshot being rejected fails recovery whatever the policy, since the
+// snapshot is checksummed as a whole. The report is kept for Report.
+func (w *WAL) Recover(ctx context.Context) error {
+	r := recovery{wal: w, ctx: ctx}
+	r.report.Policy = w.recovery
+	defer func() { w.report = r.report }()
+
+	from, commands, err := w.newestSnapshot()
+	if err != nil {
+		return err
+	}
+	r.report.Snapshot = from
+	for _, cmd := range commands {
+		if err := w.apply(ctx, cmd); err != nil {
+			return fmt.Errorf("replay snapshot %s: %w", from, err)
+		}
+		r.report.Applied++
+	}
+
+	names, err := segmentNames(w.reader.dir)
+	if err != nil {
+		return err
+	}
+	names = names[sort.SearchStrings(names, from):]
+
+	for i, name := range names {
+		cut, err := r.segment(name, i == len(names)-1)
+		if err != nil {
+			return err
+		}
+		if cut != nil {
+			return r.truncate(*cut, names[i+1:])
+		}
+	}
+	return nil
+}
+
+// Report returns what the last recovery went through.
+func (w *WAL) Report() RecoveryReport {
+	return w.report
+}
+
+// apply replays the commands of a record.
+func (w *WAL) apply(ctx context.Context, line string) error {
+	for _, record := range txRecords(line) {
+		ns, cmd := untagged(record)
+		_, err := w.interpreter.Execute(domain.WithSession(ctx, &domain.Session{Namespace: ns}), cmd)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type recovery struct {
+	wal    *WAL
+	ctx    context.Context
+	report RecoveryReport
+	// nextLSN is the LSN the next segment has to start at, zero
+	// when it is not known.
+	nextLSN uint64
+}
+
+// segment replays a segment. It returns where to cut the log when it has
+// to be, and an error when recovery has to fail.
+func (r *recovery) segment(name string, last bool) (*Truncation, error) {
+	path := filepath.Join(r.wal.reader.dir, name)
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r.report.Segments = append(r.report.Segments, SegmentReport{Name: name})
+	seg := &r.report.Segments[len(r.report.Segments)-1]
+	nextLSN := r.nextLSN
+	r.nextLSN = 0
+
+	sr, err := newSegmentReader(f)
+	if errors.Is(err, errNotSegment) || errors.Is(err, errUnsupportedVersion) {
+		return r.bad(LogPosition{Segment: name}, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	seg.Bytes = sr.offset
+	if nextLSN != 0 && sr.firstLSN != nextLSN {
+		err := fmt.Errorf("%w: expected record %d, segment starts at %d", errMissingRecords, nextLSN, sr.firstLSN)
+		if cut, err := r.bad(LogPosition{Segment: name}, err); cut != nil || err != nil {
+			return cut, err
+		}
+	}
+
+	for {
+		rec, err := sr.next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		pos := LogPosition{Segment: name, Offset: rec.offset}
+		if last && errors.Is(err, errTornRecord) {
+			return &Truncation{LogPosition: pos, Reason: err.Error()}, nil
+		}
+		if isDamage(err) {
+			if cut, err := r.bad(pos, err); cut != nil || err != nil {
+				return cut, err
+			}
+			if !sr.resumable {
+				return nil, nil
+			}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if err := r.wal.apply(r.ctx, rec.data); err != nil {
+			if cut, err := r.bad(pos, err); cut != nil || err != nil {
+				return cut, err
+			}
+			continue
+		}
+		r.report.Applied++
+		seg.Records++
+		seg.Bytes = sr.offset
+	}
+	r.nextLSN = sr.nextLSN
+	return nil, nil
+}
+
+// bad handles a bad record as the policy says.
+func (r *recovery) bad(pos LogPosition, err error) (*Truncation, error) {
+	switch r.report.Policy {
+	case RecoverTruncate:
+		return &Truncation{LogPosition: pos, Reason: err.Error()}, nil
+	case RecoverSkip:
+		r.report.Skipped = append(r.report.Skipped, SkippedRecord{LogPosition: pos, Reason: err.Error()})
+		return nil, nil
+	}
+	return nil, fmt.Errorf("recover %s at offset %d: %w", pos.Segment, pos.Offset, err)
+}
+
+// truncate cuts the log at cut and removes the segments after it. A segment
+// cut before its first record goes as a whole, unless its name tells the LSN
+// it starts at: it is then left empty, so the records written next keep
+// their numbers and the position of a snapshot taken there.
+func (r *recovery) truncate(cut Truncation, later []string) error {
+	dir := r.wal.reader.dir
+	path := filepath.Join(dir, cut.Segment)
+	var err error
+	if cut.Offset > segmentHeaderSize {
+		err = os.Truncate(path, cut.Offset)
+	} else if lsn, ok := segmentLSN(cut.Segment); ok {
+		err = createSegment(path, lsn)
+	} else {
+		later = append([]string{cut.Segment}, later...)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := removeSegments(dir, later); err != nil {
+		return err
+	}
+	cut.Removed = later
+	r.report.Truncate = &cut
+	return nil
+}
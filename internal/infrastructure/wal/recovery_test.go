@@ -0,0 +1,350 @@
+package wal
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rdimidov/kvstore/internal/application/services"
+	"github.com/rdimidov/kvstore/internal/domain"
+	"github.com/rdimidov/kvstore/internal/infrastructure/storage"
+	interp "github.com/rdimidov/kvstore/internal/presentation/interpreter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+var (
+	firstSegment  = segmentName(1)
+	secondSegment = segmentName(4)
+)
+
+const (
+	// secondRecord is the offset of the second record of firstSegment, its
+	// first one being "SET a 1".
+	secondRecord = segmentHeaderSize + recordHeaderSize + len("SET a 1")
+)
+
+// recoverLog replays the segments of dir under policy, rejecting the
+// command "BAD", and returns the commands applied.
+func recoverLog(t *testing.T, dir string, policy RecoveryPolicy) ([]string, RecoveryReport, error) {
+	t.Helper()
+	var applied []string
+	interp := newMockinterpreter(t)
+	interp.On("Execute", mock.Anything, mock.Anything).Return(func(_ context.Context, cmd string) (domain.Result, error) {
+		if cmd == "BAD" {
+			return domain.Result{}, errors.New("rejected")
+		}
+		applied = append(applied, cmd)
+		return domain.OKResult(), nil
+	}).Maybe()
+
+	w := WAL{reader: NewReader(dir), interpreter: interp, recovery: policy}
+	err := w.Recover(context.Background())
+	return applied, w.Report(), err
+}
+
+func TestRecover_DamagedRecord(t *testing.T) {
+	setup := func(t *testing.T) string {
+		dir := t.TempDir()
+		path := filepath.Join(dir, firstSegment)
+		writeSegment(t, path, 1, "SET a 1", "SET b 2", "SET c 3")
+		writeSegment(t, filepath.Join(dir, secondSegment), 4, "SET d 4")
+
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		data[secondRecord+recordHeaderSize] ^= 0xff
+		require.NoError(t, os.WriteFile(path, data, 0o644))
+		return dir
+	}
+
+	t.Run("strict", func(t *testing.T) {
+		applied, _, err := recoverLog(t, setup(t), RecoverStrict)
+		require.ErrorIs(t, err, errBadRecord)
+		assert.Contains(t, err.Error(), firstSegment)
+		assert.Equal(t, []string{"SET a 1"}, applied)
+	})
+
+	t.Run("truncate", func(t *testing.T) {
+		dir := setup(t)
+		applied, report, err := recoverLog(t, dir, RecoverTruncate)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"SET a 1"}, applied)
+		require.NotNil(t, report.Truncate)
+		assert.Equal(t, LogPosition{Segment: firstSegment, Offset: int64(secondRecord)}, report.Truncate.LogPosition)
+		assert.Equal(t, []string{secondSegment}, report.Truncate.Removed)
+
+		// the log now ends before the damage, and recovers as it is
+		names, err := segmentNames(dir)
+		require.NoError(t, err)
+		assert.Equal(t, []string{firstSegment}, names)
+		applied, report, err = recoverLog(t, dir, RecoverStrict)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"SET a 1"}, applied)
+		assert.Nil(t, report.Truncate)
+	})
+
+	t.Run("skip", func(t *testing.T) {
+		applied, report, err := recoverLog(t, setup(t), RecoverSkip)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"SET a 1", "SET c 3", "SET d 4"}, applied)
+		assert.Equal(t, 3, report.Applied)
+		require.Len(t, report.Skipped, 1)
+		assert.Equal(t, LogPosition{Segment: firstSegment, Offset: int64(secondRecord)}, report.Skipped[0].LogPosition)
+		require.Len(t, report.Segments, 2)
+		assert.Equal(t, SegmentReport{Name: firstSegment, Records: 2, Bytes: report.Segments[0].Bytes}, report.Segments[0])
+		assert.Equal(t, 1, report.Segments[1].Records)
+	})
+}
+
+func TestRecover_RejectedRecord(t *testing.T) {
+	setup := func(t *testing.T) string {
+		dir := t.TempDir()
+		writeSegment(t, filepath.Join(dir, firstSegment), 1, "SET a 1", "BAD", "SET c 3")
+		return dir
+	}
+
+	t.Run("strict", func(t *testing.T) {
+		_, _, err := recoverLog(t, setup(t), RecoverStrict)
+		assert.EqualError(t, err, "recover "+firstSegment+" at offset 40: rejected")
+	})
+
+	t.Run("truncate", func(t *testing.T) {
+		applied, report, err := recoverLog(t, setup(t), RecoverTruncate)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"SET a 1"}, applied)
+		require.NotNil(t, report.Truncate)
+		assert.Equal(t, "rejected", report.Truncate.Reason)
+	})
+
+	t.Run("skip", func(t *testing.T) {
+		applied, report, err := recoverLog(t, setup(t), RecoverSkip)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"SET a 1", "SET c 3"}, applied)
+		require.Len(t, report.Skipped, 1)
+		assert.Equal(t, int64(secondRecord), report.Skipped[0].Offset)
+	})
+}
+
+func TestRecover_TornTailIsCutWhateverThePolicy(t *testing.T) {
+	for _, policy := range []RecoveryPolicy{RecoverStrict, RecoverTruncate, RecoverSkip} {
+		t.Run(string(policy), func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, firstSegment)
+			writeSegment(t, path, 1, "SET a 1", "SET b 2")
+			info, err := os.Stat(path)
+			require.NoError(t, err)
+			require.NoError(t, os.Truncate(path, info.Size()-3))
+
+			applied, report, err := recoverLog(t, dir, policy)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"SET a 1"}, applied)
+			require.NotNil(t, report.Truncate)
+			assert.Equal(t, int64(secondRecord), report.Truncate.Offset)
+
+			info, err = os.Stat(path)
+			require.NoError(t, err)
+			assert.Equal(t, int64(secondRecord), info.Size())
+		})
+	}
+}
+
+func TestRecover_MissingSegment(t *testing.T) {
+	dir := t.TempDir()
+	writeSegment(t, filepath.Join(dir, firstSegment), 1, "SET a 1")
+	writeSegment(t, filepath.Join(dir, secondSegment), 4, "SET d 4")
+
+	_, _, err := recoverLog(t, dir, RecoverStrict)
+	require.ErrorIs(t, err, errMissingRecords)
+
+	applied, report, err := recoverLog(t, dir, RecoverSkip)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"SET a 1", "SET d 4"}, applied)
+	require.Len(t, report.Skipped, 1)
+	assert.Equal(t, LogPosition{Segment: secondSegment}, report.Skipped[0].LogPosition)
+}
+
+func TestRecover_TruncateKeepsNumbering(t *testing.T) {
+	dir := t.TempDir()
+	writeSegment(t, filepath.Join(dir, firstSegment), 1, "SET a 1", "SET b 2", "SET c 3")
+	path := filepath.Join(dir, secondSegment)
+	writeSegment(t, path, 4, "SET d 4")
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	data[segmentHeaderSize+recordHeaderSize] ^= 0xff
+	require.NoError(t, os.WriteFile(path, data, 0o644))
+
+	_, report, err := recoverLog(t, dir, RecoverTruncate)
+	require.NoError(t, err)
+	require.NotNil(t, report.Truncate)
+	assert.Empty(t, report.Truncate.Removed)
+
+	// the segment is left empty, so the next record is still numbered 4
+	names, err := segmentNames(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{firstSegment, secondSegment}, names)
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, int64(segmentHeaderSize), info.Size())
+}
+
+func TestParseRecoveryPolicy(t *testing.T) {
+	for _, s := range []string{"strict", "truncate", "skip"} {
+		p, err := ParseRecoveryPolicy(s)
+		require.NoError(t, err)
+		assert.Equal(t, RecoveryPolicy(s), p)
+	}
+	p, err := ParseRecoveryPolicy("")
+	require.NoError(t, err)
+	assert.Equal(t, RecoverStrict, p)
+	_, err = ParseRecoveryPolicy("lenient")
+	assert.Error(t, err)
+}
+
+// dirConfig is testConfig logging to a directory of its own.
+type dirConfig struct {
+	testConfig
+	dir string
+}
+
+func (c dirConfig) WALDirName() string { return c.dir }
+
+// openWAL replays the log of cfg into store the way the server does, and
+// returns the WAL and the application logging to it.
+func openWAL(t *testing.T, cfg config, store *storage.LSM) (*WAL, *services.Application) {
+	t.Helper()
+	ctx := context.Background()
+	logger := zap.NewNop().Sugar()
+	replay, err := services.NewApplication(ctx, store, logger, nil, services.WithoutEviction())
+	require.NoError(t, err)
+	handler, err := interp.New(replay, interp.WithReplay())
+	require.NoError(t, err)
+	w, err := New(cfg, handler)
+	require.NoError(t, err)
+	app, err := services.NewApplication(ctx, store, logger, w)
+	require.NoError(t, err)
+	return w, app
+}
+
+func TestRecover_ListOverStoredList(t *testing.T) {
+	ctx := context.Background()
+	cfg := dirConfig{dir: t.TempDir()}
+	storeDir := t.TempDir()
+
+	store, err := storage.OpenLSM(storeDir)
+	require.NoError(t, err)
+	w, app := openWAL(t, cfg, store)
+	_, err = app.Push(ctx, "l", []domain.Value{"a", "b", "c"}, false)
+	require.NoError(t, err)
+	_, err = app.Pop(ctx, "l", true)
+	require.NoError(t, err)
+	_, err = app.Push(ctx, "l", []domain.Value{"z"}, true)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	require.NoError(t, store.Close())
+
+	// the engine holds the list already when the pushes and the pop are
+	// replayed over it
+	store, err = storage.OpenLSM(storeDir)
+	require.NoError(t, err)
+	defer store.Close()
+	w, app = openWAL(t, cfg, store)
+	defer w.Close()
+	items, err := app.LRange(ctx, "l", 0, -1)
+	require.NoError(t, err)
+	assert.Equal(t, []domain.Value{"z", "b", "c"}, items)
+}
+
+func TestRecover_CollectionsOverLaterWrites(t *testing.T) {
+	ctx := context.Background()
+	cfg := dirConfig{dir: t.TempDir()}
+	store, err := storage.OpenLSM(t.TempDir())
+	require.NoError(t, err)
+	defer store.Close()
+
+	w, app := openWAL(t, cfg, store)
+	_, err = app.HSet(ctx, "h", []domain.HashField{{Field: "f", Value: "v"}})
+	require.NoError(t, err)
+	_, err = app.SAdd(ctx, "s", []domain.Value{"m"})
+	require.NoError(t, err)
+	require.NoError(t, app.Delete(ctx, "h"))
+	require.NoError(t, app.Delete(ctx, "s"))
+	require.NoError(t, app.Set(ctx, "h", "x"))
+	_, err = app.Push(ctx, "s", []domain.Value{"a"}, false)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	// the log is replayed over its own final state, where the keys of the
+	// hash and the set hold other types by now
+	w, app = openWAL(t, cfg, store)
+	defer w.Close()
+	entry, err := app.Get(ctx, "h")
+	require.NoError(t, err)
+	assert.Equal(t, domain.Value("x"), entry.Value)
+	items, err := app.LRange(ctx, "s", 0, -1)
+	require.NoError(t, err)
+	assert.Equal(t, []domain.Value{"a"}, items)
+}
+
+func TestRecover_ReopenLSMAfterTypeChange(t *testing.T) {
+	ctx := context.Background()
+	cfg := dirConfig{dir: t.TempDir()}
+	storeDir := t.TempDir()
+
+	store, err := storage.OpenLSM(storeDir)
+	require.NoError(t, err)
+	w, app := openWAL(t, cfg, store)
+	_, err = app.HSet(ctx, "h", []domain.HashField{{Field: "f", Value: "v"}})
+	require.NoError(t, err)
+	require.NoError(t, app.Delete(ctx, "h"))
+	require.NoError(t, app.Set(ctx, "h", "x"))
+	require.NoError(t, w.Close())
+	require.NoError(t, store.Close())
+
+	// the tables hold the string when the hash write is replayed
+	store, err = storage.OpenLSM(storeDir)
+	require.NoError(t, err)
+	defer store.Close()
+	w, app = openWAL(t, cfg, store)
+	defer w.Close()
+	entry, err := app.Get(ctx, "h")
+	require.NoError(t, err)
+	assert.Equal(t, domain.TypeString, entry.Type)
+	assert.Equal(t, domain.Value("x"), entry.Value)
+}
+
+func TestRecover_SnapshotHoldingLaterWrites(t *testing.T) {
+	ctx := context.Background()
+	cfg := dirConfig{dir: t.TempDir()}
+	store, err := storage.OpenLSM(t.TempDir())
+	require.NoError(t, err)
+	defer store.Close()
+
+	w, app := openWAL(t, cfg, store)
+	position, err := w.Rotate()
+	require.NoError(t, err)
+	_, err = app.HSet(ctx, "h", []domain.HashField{{Field: "f", Value: "v"}})
+	require.NoError(t, err)
+	require.NoError(t, app.Delete(ctx, "h"))
+	require.NoError(t, app.Set(ctx, "h", "x"))
+
+	// the snapshot taken at position is read after the writes that follow it,
+	// as Snapshot may do, and holds h as a string already
+	entries, err := store.Scan(ctx, "", "", 0)
+	require.NoError(t, err)
+	require.NoError(t, w.WriteSnapshot(position, pages(entries)))
+	require.NoError(t, w.Close())
+
+	empty, err := storage.OpenLSM(t.TempDir())
+	require.NoError(t, err)
+	defer empty.Close()
+	w, app = openWAL(t, cfg, empty)
+	defer w.Close()
+	assert.Equal(t, position, w.Report().Snapshot)
+	entry, err := app.Get(ctx, "h")
+	require.NoError(t, err)
+	assert.Equal(t, domain.Value("x"), entry.Value)
+}
@@ -0,0 +1,190 @@
+package wal
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rdimidov/kvstore/internal/domain"
+)
+
+// A snapshot is a file of SET commands that rebuild the dataset as it was
+// when the segment the snapshot is named after was started, so recovery
+// only has to replay that segment and the later ones. It is read while
+// writes go on and may hold some of theirs too, which replay sets again. The
+// last line is "END <commands> <crc32>", which tells a complete snapshot from
+// a damaged one.
+
+const (
+	snapshotExt     = ".snapshot"
+	snapshotTrailer = "END"
+	// snapshotsKept also keeps the segments of the previous snapshot, so
+	// recovery can fall back to it when the newest one turns out damaged.
+	snapshotsKept = 2
+)
+
+var errBadSnapshot = errors.New("snapshot is damaged")
+
+// setCommand formats a SET record. A deadline is logged as an absolute
+// unix-millisecond time and the version as is, so the record means the same
+// thing no matter when it is replayed.
+func setCommand(e domain.Entry) string {
+	args := []string{e.Value.String()}
+	if e.HasExpiry() {
+		args = append(args, "PXAT", strconv.FormatInt(e.ExpiresAt.UnixMilli(), 10))
+	}
+	if e.Version != 0 {
+		args = append(args, "VER", strconv.FormatUint(e.Version, 10))
+	}
+	return keyCommand("SET", e.Key, args...)
+}
+
+// entryCommands returns the records that rebuild the entry. A collection is
+// deleted first and rebuilt by adding all of its items at once, so it comes
+// out the same over a store that holds it already; its version is not
+// restored, since only string values expose versions to clients.
+func entryCommands(e domain.Entry) []string {
+	var cmd string
+	switch e.Type {
+	case domain.TypeString:
+		return []string{setCommand(e)}
+	case domain.TypeHash:
+		cmd = keyCommand("HSET", e.Key, values(e.Items)...)
+	case domain.TypeList:
+		cmd = keyCommand("RPUSH", e.Key, values(e.Items)...)
+	case domain.TypeSet:
+		cmd = keyCommand("SADD", e.Key, values(e.Items)...)
+	}
+	commands := []string{keyCommand("DEL", e.Key), cmd}
+	if e.HasExpiry() {
+		commands = append(commands, keyCommand("PEXPIREAT", e.Key, strconv.FormatInt(e.ExpiresAt.UnixMilli(), 10)))
+	}
+	return commands
+}
+
+func values(vs []domain.Value) []string {
+	s := make([]string, len(vs))
+	for i, v := range vs {
+		s[i] = v.String()
+	}
+	return s
+}
+
+func writeSnapshot(dir, position string, next func() ([]domain.Entry, error)) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	path := filepath.Join(dir, position+snapshotExt)
+	f, err := os.Create(path + ".tmp")
+	if err != nil {
+		return err
+	}
+
+	crc := crc32.NewIEEE()
+	w := bufio.NewWriter(f)
+	var commands int
+	for {
+		var entries []domain.Entry
+		entries, err = next()
+		if err != nil || len(entries) == 0 {
+			break
+		}
+		for _, e := range entries {
+			for _, cmd := range entryCommands(e) {
+				line := cmd + "\n"
+				_, _ = crc.Write([]byte(line))
+				_, _ = w.WriteString(line)
+				commands++
+			}
+		}
+	}
+	if err == nil {
+		_, _ = fmt.Fprintf(w, "%s %d %08x\n", snapshotTrailer, commands, crc.Sum32())
+		err = w.Flush()
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(path + ".tmp")
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// loadSnapshot returns the commands of a snapshot after checking them
+// against its trailer.
+func loadSnapshot(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	body, trailer, ok := cutLastLine(string(data))
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", path, errBadSnapshot)
+	}
+
+	fields := strings.Fields(trailer)
+	if len(fields) != 3 || fields[0] != snapshotTrailer {
+		return nil, fmt.Errorf("%s: %w", path, errBadSnapshot)
+	}
+	count, err := strconv.Atoi(fields[1])
+	if err != nil || fields[2] != fmt.Sprintf("%08x", crc32.ChecksumIEEE([]byte(body))) {
+		return nil, fmt.Errorf("%s: %w", path, errBadSnapshot)
+	}
+
+	commands := strings.Split(strings.TrimSuffix(body, "\n"), "\n")
+	if body == "" {
+		commands = nil
+	}
+	if len(commands) != count {
+		return nil, fmt.Errorf("%s: %w", path, errBadSnapshot)
+	}
+	return commands, nil
+}
+
+// cutLastLine splits newline-terminated text into everything before its last
+// line and the last line itself.
+func cutLastLine(s string) (string, string, bool) {
+	if !strings.HasSuffix(s, "\n") {
+		return "", "", false
+	}
+	s = strings.TrimSuffix(s, "\n")
+	i := strings.LastIndexByte(s, '\n')
+	return s[:i+1], s[i+1:], true
+}
+
+// listSnapshots returns the positions of the snapshots in dir, oldest first.
+func listSnapshots(dir string) ([]string, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	files, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var positions []string
+	for _, f := range files {
+		if !f.IsDir() && strings.HasSuffix(f.Name(), snapshotExt) {
+			positions = append(positions, strings.TrimSuffix(f.Name(), snapshotExt))
+		}
+	}
+	sort.Strings(positions)
+	return positions, nil
+}
@@ -0,0 +1,135 @@
+package wal
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rdimidov/kvstore/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// pages returns the entries a page of one entry at a time, the way the
+// application hands them to WriteSnapshot.
+func pages(entries []domain.Entry) func() ([]domain.Entry, error) {
+	return func() ([]domain.Entry, error) {
+		if len(entries) == 0 {
+			return nil, nil
+		}
+		page := entries[:1]
+		entries = entries[1:]
+		return page, nil
+	}
+}
+
+func TestSnapshot_RoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	entries := []domain.Entry{
+		{Key: "a", Value: "1"},
+		{Key: "b", Value: "2", ExpiresAt: time.UnixMilli(1700000000000)},
+	}
+
+	require.NoError(t, writeSnapshot(dir, "pos", pages(entries)))
+	commands, err := loadSnapshot(filepath.Join(dir, "pos"+snapshotExt))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"SET a 1", "SET b 2 PXAT 1700000000000"}, commands)
+
+	require.NoError(t, writeSnapshot(dir, "empty", pages(nil)))
+	commands, err = loadSnapshot(filepath.Join(dir, "empty"+snapshotExt))
+	require.NoError(t, err)
+	assert.Empty(t, commands)
+
+	path := filepath.Join(dir, "pos"+snapshotExt)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, append([]byte("SET c 3\n"), data...), 0o644))
+	_, err = loadSnapshot(path)
+	assert.ErrorIs(t, err, errBadSnapshot)
+
+	// a failed read leaves no snapshot behind
+	failure := errors.New("scan failed")
+	err = writeSnapshot(dir, "failed", func() ([]domain.Entry, error) { return nil, failure })
+	assert.ErrorIs(t, err, failure)
+	assert.NoFileExists(t, filepath.Join(dir, "failed"+snapshotExt))
+	assert.NoFileExists(t, filepath.Join(dir, "failed"+snapshotExt+".tmp"))
+}
+
+func TestSnapshot_Collections(t *testing.T) {
+	dir := t.TempDir()
+	entries := []domain.Entry{
+		{Key: "h", Type: domain.TypeHash, Items: []domain.Value{"f", "1", "g", "2"}},
+		{Key: "l", Type: domain.TypeList, Items: []domain.Value{"b", "a"}, ExpiresAt: time.UnixMilli(1700000000000)},
+		{Key: domain.Namespace("team").Key("s"), Type: domain.TypeSet, Items: []domain.Value{"x"}},
+	}
+
+	require.NoError(t, writeSnapshot(dir, "pos", pages(entries)))
+	commands, err := loadSnapshot(filepath.Join(dir, "pos"+snapshotExt))
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"DEL h",
+		"HSET h f 1 g 2",
+		"DEL l",
+		"RPUSH l b a",
+		"PEXPIREAT l 1700000000000",
+		"@team DEL s",
+		"@team SADD s x",
+	}, commands)
+}
+
+func TestRecoverFromSnapshotAndLaterSegments(t *testing.T) {
+	dir := t.TempDir()
+	snapshots := filepath.Join(dir, defaultSnapshotDir)
+
+	w, err := newRotatingWalWriter(dir, 1<<20)
+	require.NoError(t, err)
+	wal := WAL{writer: w, reader: NewReader(dir), dir: dir, snapshotDir: snapshots, timeout: defaultFlushTimeout}
+	wal.start()
+	defer wal.Close()
+
+	write := func(cmd string) {
+		e := newEntry(cmd)
+		w.Write([]entry{e})
+		_, err := wait(e.FutureResponse())
+		require.NoError(t, err)
+	}
+
+	// three snapshots, each covering the segments before it
+	write("SET old 1")
+	for i, key := range []domain.Key{"s1", "s2", "s3"} {
+		position, err := wal.Rotate()
+		require.NoError(t, err)
+		require.NoError(t, wal.WriteSnapshot(position, pages([]domain.Entry{{Key: key, Value: "v"}})))
+		write("SET after" + string(rune('1'+i)) + " v")
+	}
+
+	positions, err := listSnapshots(snapshots)
+	require.NoError(t, err)
+	require.Len(t, positions, snapshotsKept)
+
+	logged, err := wal.reader.Read("")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"SET after2 v", "SET after3 v"}, logged, "segments before the oldest kept snapshot are deleted")
+
+	ctx := context.Background()
+	replayed := func() []string {
+		var commands []string
+		interp := newMockinterpreter(t)
+		interp.On("Execute", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			commands = append(commands, args.String(1))
+		}).Return(domain.OKResult(), nil)
+		wal.interpreter = interp
+		require.NoError(t, wal.Recover(ctx))
+		return commands
+	}
+	assert.Equal(t, []string{"SET s3 v", "SET after3 v"}, replayed())
+
+	// a damaged newest snapshot falls back to the previous one
+	newest := filepath.Join(snapshots, positions[1]+snapshotExt)
+	require.NoError(t, os.WriteFile(newest, []byte("SET s3 v\n"), 0o644))
+	assert.Equal(t, []string{"SET s2 v", "SET after2 v", "SET after3 v"}, replayed())
+}
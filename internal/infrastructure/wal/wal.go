@@ -4,9 +4,16 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/rdimidov/kvstore/internal/domain"
 )
 
@@ -15,10 +22,17 @@ const (
 	defaultSegentSizeMB = 10
 	defaultBatchSize    = 10
 	defaultFlushTimeout = 10 * time.Millisecond
+	// defaultQueueDepth bounds the batches sealed but not written yet. Once
+	// it is reached, writes wait for the writer to catch up.
+	defaultQueueDepth  = 4
+	defaultSnapshotDir = "snapshots"
 )
 
 type writer interface {
 	Write([]entry)
+	Rotate() (string, error)
+	Sync() error
+	Close() error
 }
 
 type config interface {
@@ -26,26 +40,57 @@ type config interface {
 	WALBatchFlushTimeout() time.Duration
 	WALDirName() string
 	WALMaxSegmentSize() int
+	WALSnapshotDirName() string
+	WALRecoveryPolicy() string
+	WALFsyncPolicy() string
 }
 
 type interpreter interface {
-	Execute(ctx context.Context, raw string) (*domain.Entry, error)
+	Execute(ctx context.Context, raw string) (domain.Result, error)
 }
 
+// ErrClosed is returned by writes to a closed WAL.
+var ErrClosed = errors.New("WAL is closed")
+
 type WAL struct {
 	writer      writer
 	reader      reader
 	interpreter interpreter
+	dir         string
+	snapshotDir string
 
 	batchLimit int
 	timeout    time.Duration
-
-	readyCh chan []entry
-	mu      sync.Mutex
-	batch   []entry
+	recovery   RecoveryPolicy
+	report     RecoveryReport
+	fsync      FsyncPolicy
+
+	mu    sync.Mutex
+	batch []entry
+	// tx collects the records of a transaction between Begin and Commit;
+	// nil when there is none.
+	tx     []string
+	closed bool
+
+	// queue hands the sealed batches to the writer goroutine in the order
+	// they were sealed. Batches are sealed and queued under mu, so writes
+	// are logged in the order they were made.
+	queue chan batch
+	// busy is set while the writer has batches to write. Until it is done,
+	// writes are collected into the next batch; once it is idle, a write is
+	// sealed at once and idle tells the flusher to seal the batch collected
+	// meanwhile.
+	busy    atomic.Bool
+	idle    chan struct{}
+	done    chan struct{}
+	stopped chan struct{}
+	close   sync.Once
 }
 
-func New(ctx context.Context, config config, interpreter interpreter) (*WAL, error) {
+// New opens the WAL and starts flushing batches. The WAL is not bound to a
+// context: commands still running at shutdown have to get their records
+// logged, so it keeps going until Close.
+func New(config config, interpreter interpreter) (*WAL, error) {
 	if interpreter == nil {
 		return nil, errors.New("interpreter is nil")
 	}
@@ -55,21 +100,42 @@ func New(ctx context.Context, config config, interpreter interpreter) (*WAL, err
 		dirname = defaultWriteDir
 	}
 
+	snapshotDir := config.WALSnapshotDirName()
+	if snapshotDir == "" {
+		snapshotDir = filepath.Join(dirname, defaultSnapshotDir)
+	}
+
 	mssMB := config.WALMaxSegmentSize()
 	if mssMB == 0 {
 		mssMB = defaultSegentSizeMB
 	}
 
+	recovery, err := ParseRecoveryPolicy(config.WALRecoveryPolicy())
+	if err != nil {
+		return nil, err
+	}
+	fsync, err := ParseFsyncPolicy(config.WALFsyncPolicy())
+	if err != nil {
+		return nil, err
+	}
+
 	writer, err := newRotatingWalWriter(dirname, mssMB*1024*1024)
 	if err != nil {
 		return nil, err
 	}
+	writer.fsync = fsync
+	if err := migrateTextSegments(dirname); err != nil {
+		return nil, fmt.Errorf("migrate WAL segments: %w", err)
+	}
+	if err := migrateSegmentNames(dirname, snapshotDir); err != nil {
+		return nil, fmt.Errorf("migrate WAL segment names: %w", err)
+	}
 
 	reader := NewReader(dirname)
 
-	batch := config.WALBatchSize()
-	if batch == 0 {
-		batch = defaultBatchSize
+	batchLimit := config.WALBatchSize()
+	if batchLimit == 0 {
+		batchLimit = defaultBatchSize
 	}
 
 	timeout := config.WALBatchFlushTimeout()
@@ -78,94 +144,430 @@ func New(ctx context.Context, config config, interpreter interpreter) (*WAL, err
 	}
 
 	wal := &WAL{
-		batchLimit:  batch,
-		timeout:     defaultFlushTimeout,
-		readyCh:     make(chan []entry, 1),
+		batchLimit:  batchLimit,
+		timeout:     timeout,
+		recovery:    recovery,
+		fsync:       fsync,
 		writer:      writer,
 		reader:      reader,
 		interpreter: interpreter,
+		dir:         dirname,
+		snapshotDir: snapshotDir,
 	}
-	wal.start(ctx)
+	wal.start()
 	return wal, nil
 }
 
-func (w *WAL) start(ctx context.Context) {
-	go func() {
-		ticker := time.NewTicker(w.timeout)
-		defer ticker.Stop()
+// start runs the commit pipeline: a single writer goroutine writes and
+// syncs the queued batches one after the other, while the next batch is
+// collected. A batch is sealed once it is full, once the writer is idle, or
+// at the latest when the flush timeout passes. Under FsyncInterval, the
+// records written are synced in the background.
+func (w *WAL) start() {
+	w.queue = make(chan batch, defaultQueueDepth)
+	w.idle = make(chan struct{}, 1)
+	w.done = make(chan struct{})
+	w.stopped = make(chan struct{})
+	go w.write()
+	go w.flush()
+	if w.fsync.Mode == FsyncInterval {
+		go w.syncEvery(w.fsync.Interval)
+	}
+}
 
-		for {
+// batch is a run of entries sealed together. flushed, when set, is closed
+// once they are synced along with every entry queued before them.
+type batch struct {
+	entries []entry
+	flushed chan struct{}
+}
+
+// write writes the queued batches in order until the queue is closed.
+// Batches sealed while the previous one was being synced are written
+// together, so they share a single sync. Only those already queued are
+// taken along, so a write holds at most defaultQueueDepth+1 batches however
+// fast new ones come in.
+func (w *WAL) write() {
+	defer close(w.stopped)
+
+	for b := range w.queue {
+		entries := b.entries
+		flushed := []chan struct{}{b.flushed}
+		for n := len(w.queue); n > 0; n-- {
+			next := <-w.queue
+			entries = append(entries, next.entries...)
+			flushed = append(flushed, next.flushed)
+		}
+		if len(entries) > 0 {
+			w.writer.Write(entries)
+		}
+		for _, ch := range flushed {
+			if ch != nil {
+				close(ch)
+			}
+		}
+
+		if len(w.queue) == 0 {
+			w.busy.Store(false)
 			select {
-			case <-ctx.Done():
-				w.dumpBatch()
-				return
+			case w.idle <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
 
-			case <-ticker.C:
-				w.dumpBatch()
+func (w *WAL) flush() {
+	ticker := time.NewTicker(w.timeout)
+	defer ticker.Stop()
 
-			case batch := <-w.readyCh:
-				go w.writer.Write(batch)
-				ticker.Reset(w.timeout)
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+		case <-w.idle:
+		}
+		w.mu.Lock()
+		if !w.closed {
+			w.seal(nil)
+		}
+		w.mu.Unlock()
+	}
+}
 
-			}
+// syncEvery syncs the records written on every tick until the WAL is
+// closed. A failed sync is retried on the next tick.
+func (w *WAL) syncEvery(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+			_ = w.writer.Sync()
 		}
-	}()
+	}
 }
 
-func (w *WAL) WriteSet(key domain.Key, value domain.Value) error {
-	fut := w.processInput(fmt.Sprintf("SET %s %s", key, value))
-	return fut.Get()
+// seal queues the batch being collected, along with flushed to be closed
+// once it is synced. It blocks while the queue is full, holding back further
+// writes. Caller holds mu.
+func (w *WAL) seal(flushed chan struct{}) {
+	if len(w.batch) == 0 && flushed == nil {
+		return
+	}
+	w.busy.Store(true)
+	w.queue <- batch{entries: w.batch, flushed: flushed}
+	w.batch = nil
 }
 
-func (w *WAL) WriteDel(key domain.Key) error {
-	fut := w.processInput(fmt.Sprintf("DEL %s", key))
-	return fut.Get()
+// Flush writes out the pending batch and waits until every record logged
+// before the call is synced.
+func (w *WAL) Flush() {
+	flushed := make(chan struct{})
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return
+	}
+	w.seal(flushed)
+	w.mu.Unlock()
+	<-flushed
 }
 
-func (w *WAL) processInput(input string) FutureError {
-	entry := newEntry(input)
+// Sync makes every record logged so far durable, whatever the fsync policy.
+func (w *WAL) Sync() error {
+	return w.writer.Sync()
+}
+
+// Close writes out the pending batch, waits for the batches still queued to
+// be synced and closes the current segment. Every write logged before the
+// call is resolved once it returns, the later ones fail with ErrClosed.
+func (w *WAL) Close() error {
+	var err error
+	w.close.Do(func() {
+		w.mu.Lock()
+		w.closed = true
+		w.seal(nil)
+		close(w.queue)
+		w.mu.Unlock()
+
+		close(w.done)
+		<-w.stopped
+		err = w.writer.Close()
+	})
+	return err
+}
+
+// WriteSet logs the entry with its deadline and version, so replay restores
+// it exactly.
+func (w *WAL) WriteSet(entry domain.Entry) (domain.LSN, error) {
+	fut := w.processInput(setCommand(entry))
+	return wait(fut)
+}
+
+func (w *WAL) WriteDel(key domain.Key) (domain.LSN, error) {
+	fut := w.processInput(keyCommand("DEL", key))
+	return wait(fut)
+}
+
+func (w *WAL) WriteExpire(key domain.Key, deadline time.Time) (domain.LSN, error) {
+	fut := w.processInput(keyCommand("PEXPIREAT", key, strconv.FormatInt(deadline.UnixMilli(), 10)))
+	return wait(fut)
+}
+
+func (w *WAL) WritePersist(key domain.Key) (domain.LSN, error) {
+	fut := w.processInput(keyCommand("PERSIST", key))
+	return wait(fut)
+}
+
+// WriteCollection logs the collection an entry holds after a write as a
+// batch record deleting and rebuilding it. Logging the operation instead
+// would not do: replayed over a store holding later writes already, as the
+// LSM engine or a snapshot may, a push would be applied twice and a hash
+// write would fail on a key given another type since. An emptied collection
+// is logged as a delete.
+func (w *WAL) WriteCollection(entry domain.Entry) (domain.LSN, error) {
+	if len(entry.Items) == 0 {
+		return w.WriteDel(entry.Key)
+	}
+	return w.writeBatch(entryCommands(entry))
+}
+
+// WriteFlush logs the removal of every key of the namespace.
+func (w *WAL) WriteFlush(ns domain.Namespace) (domain.LSN, error) {
+	fut := w.processInput(tagged(ns, "FLUSHDB"))
+	return wait(fut)
+}
 
+// WriteMSet logs the entries as one batch record, so replay restores all
+// of them or none.
+func (w *WAL) WriteMSet(entries []domain.Entry) (domain.LSN, error) {
+	records := make([]string, 0, len(entries))
+	for _, e := range entries {
+		records = append(records, setCommand(e))
+	}
+	return w.writeBatch(records)
+}
+
+// WriteMDel logs the deletes of the keys as one batch record.
+func (w *WAL) WriteMDel(keys []domain.Key) (domain.LSN, error) {
+	records := make([]string, 0, len(keys))
+	for _, key := range keys {
+		records = append(records, keyCommand("DEL", key))
+	}
+	return w.writeBatch(records)
+}
+
+// writeBatch logs records the way a transaction of its own is logged. Inside
+// a transaction they join its records instead, since transaction records do
+// not nest.
+func (w *WAL) writeBatch(records []string) (domain.LSN, error) {
 	w.mu.Lock()
-	w.batch = append(w.batch, entry)
+	inTx := w.tx != nil
+	if inTx {
+		w.tx = append(w.tx, records...)
+	}
+	w.mu.Unlock()
 
-	if len(w.batch) == w.batchLimit {
-		w.readyCh <- w.batch
-		w.batch = nil
+	if inTx {
+		return 0, nil
 	}
+	fut := w.processInput(txRecord(uuid.NewString(), records))
+	return wait(fut)
+}
+
+// Begin starts collecting the records that follow into a single
+// transaction record instead of writing them. The caller has to make sure
+// no other records are written until Commit or Rollback.
+func (w *WAL) Begin() {
+	w.mu.Lock()
+	w.tx = []string{}
 	w.mu.Unlock()
+}
 
-	return entry.FutureResponse()
+// Commit writes the records collected since Begin as one record, so replay
+// sees either all of them or none.
+func (w *WAL) Commit(id string) (domain.LSN, error) {
+	w.mu.Lock()
+	records := w.tx
+	w.tx = nil
+	w.mu.Unlock()
+
+	if len(records) == 0 {
+		return 0, nil
+	}
+	fut := w.processInput(txRecord(id, records))
+	return wait(fut)
+}
+
+// Rollback drops the records collected since Begin.
+func (w *WAL) Rollback() {
+	w.mu.Lock()
+	w.tx = nil
+	w.mu.Unlock()
 }
 
-func (w *WAL) dumpBatch() {
-	var batch []entry
+func (w *WAL) processInput(input string) FutureCommit {
+	entry := newEntry(input)
 
 	w.mu.Lock()
-	batch = w.batch
-	w.batch = nil
+	if w.closed {
+		w.mu.Unlock()
+		entry.SetResponse(0, ErrClosed)
+		return entry.FutureResponse()
+	}
+	if w.tx != nil {
+		w.tx = append(w.tx, input)
+		w.mu.Unlock()
+		entry.SetResponse(0, nil)
+		return entry.FutureResponse()
+	}
+	w.batch = append(w.batch, entry)
+
+	if len(w.batch) >= w.batchLimit || !w.busy.Load() {
+		w.seal(nil)
+	}
 	w.mu.Unlock()
 
-	if len(batch) != 0 {
-		w.writer.Write(batch)
+	return entry.FutureResponse()
+}
+
+// Rotate writes out the pending batch and starts a new segment. The returned
+// segment name, that of the LSN of the next record, is the position a
+// snapshot of the current state covers up to.
+// The caller has to make sure no writes are in flight.
+func (w *WAL) Rotate() (string, error) {
+	w.Flush()
+	return w.writer.Rotate()
+}
+
+// WriteSnapshot stores the entries next returns, a page at a time until an
+// empty one, as the snapshot of every record before position, then deletes
+// the snapshots and segments that are no longer needed for recovery.
+func (w *WAL) WriteSnapshot(position string, next func() ([]domain.Entry, error)) error {
+	if err := writeSnapshot(w.snapshotDir, position, next); err != nil {
+		return err
 	}
+	return w.truncate()
 }
 
-func (w *WAL) Recover(ctx context.Context) error {
-	commands, err := w.reader.Read()
+// truncate keeps the last snapshotsKept snapshots and the segments that
+// have to be replayed on top of the oldest of them.
+func (w *WAL) truncate() error {
+	positions, err := listSnapshots(w.snapshotDir)
+	if err != nil || len(positions) <= snapshotsKept {
+		return err
+	}
+
+	keepFrom := positions[len(positions)-snapshotsKept]
+	for _, p := range positions[:len(positions)-snapshotsKept] {
+		if err := os.Remove(filepath.Join(w.snapshotDir, p+snapshotExt)); err != nil {
+			return err
+		}
+	}
+
+	segments, err := segmentNames(w.dir)
 	if err != nil {
 		return err
 	}
-	for _, l := range commands {
-		_, err := w.interpreter.Execute(ctx, l)
+	return removeSegments(w.dir, segments[:sort.SearchStrings(segments, keepFrom)])
+}
+
+// A transaction is logged as "TX <id> <record> ; <record> ...". Records
+// never contain the separator, since domain.FormatCommand quotes lone
+// semicolons and never leaves a plain space in a quoted argument.
+const (
+	txTag       = "TX"
+	txSeparator = " ; "
+)
+
+func txRecord(id string, records []string) string {
+	return txTag + " " + id + " " + strings.Join(records, txSeparator)
+}
+
+// txRecords returns the records a line holds: those of a transaction, or
+// the line itself.
+func txRecords(line string) []string {
+	rest, ok := strings.CutPrefix(line, txTag+" ")
+	if !ok {
+		return []string{line}
+	}
+	_, records, _ := strings.Cut(rest, " ")
+	return strings.Split(records, txSeparator)
+}
+
+// Records of keys outside the default namespace start with "@<namespace> ",
+// so records written before namespaces existed still replay as they are.
+const namespaceTag = "@"
+
+// keyCommand formats a record of a command on a single stored key. Keys
+// and values are quoted when needed, so a record always fits on one line.
+func keyCommand(name string, key domain.Key, args ...string) string {
+	ns, k := domain.SplitKey(key)
+	return tagged(ns, domain.FormatCommand(append([]string{name, k.String()}, args...)...))
+}
+
+func tagged(ns domain.Namespace, cmd string) string {
+	if ns == domain.DefaultNamespace {
+		return cmd
+	}
+	return namespaceTag + ns.String() + " " + cmd
+}
+
+// untagged splits a record into its namespace and the command to replay.
+func untagged(record string) (domain.Namespace, string) {
+	if !strings.HasPrefix(record, namespaceTag) {
+		return domain.DefaultNamespace, record
+	}
+	ns, cmd, _ := strings.Cut(strings.TrimPrefix(record, namespaceTag), " ")
+	return domain.Namespace(ns), cmd
+}
+
+// newestSnapshot returns the position and commands of the newest snapshot
+// that passes its checksum. Damaged snapshots are skipped; recovery only
+// gives up when none is usable, since the segments before them are gone.
+func (w *WAL) newestSnapshot() (string, []string, error) {
+	positions, err := listSnapshots(w.snapshotDir)
+	if err != nil {
+		return "", nil, err
+	}
+
+	for i := len(positions) - 1; i >= 0; i-- {
+		commands, err := loadSnapshot(filepath.Join(w.snapshotDir, positions[i]+snapshotExt))
+		if errors.Is(err, errBadSnapshot) {
+			continue
+		}
 		if err != nil {
-			return err
+			return "", nil, err
 		}
+		return positions[i], commands, nil
+	}
+
+	if len(positions) > 0 {
+		return "", nil, fmt.Errorf("no usable snapshot in %s: %w", w.snapshotDir, errBadSnapshot)
 	}
-	return nil
+	return "", nil, nil
 }
 
 type Noop struct{}
 
-func (w *Noop) WriteSet(domain.Key, domain.Value) error { return nil }
-func (w *Noop) WriteDel(domain.Key) error               { return nil }
-func (w *Noop) Recover(context.Context) error           { return nil }
+func (w *Noop) WriteSet(domain.Entry) (domain.LSN, error)                  { return 0, nil }
+func (w *Noop) WriteDel(domain.Key) (domain.LSN, error)                    { return 0, nil }
+func (w *Noop) WriteExpire(domain.Key, time.Time) (domain.LSN, error)      { return 0, nil }
+func (w *Noop) WritePersist(domain.Key) (domain.LSN, error)                { return 0, nil }
+func (w *Noop) WriteFlush(domain.Namespace) (domain.LSN, error)            { return 0, nil }
+func (w *Noop) WriteCollection(domain.Entry) (domain.LSN, error)           { return 0, nil }
+func (w *Noop) WriteMSet([]domain.Entry) (domain.LSN, error)               { return 0, nil }
+func (w *Noop) WriteMDel([]domain.Key) (domain.LSN, error)                 { return 0, nil }
+func (w *Noop) Recover(context.Context) error                              { return nil }
+func (w *Noop) Rotate() (string, error)                                    { return "", nil }
+func (w *Noop) Sync() error                                                { return nil }
+func (w *Noop) Begin()                                                     {}
+func (w *Noop) Commit(string) (domain.LSN, error)                          { return 0, nil }
+func (w *Noop) Rollback()                                                  {}
+func (w *Noop) Close() error                                               { return nil }
+func (w *Noop) WriteSnapshot(string, func() ([]domain.Entry, error)) error { return nil }
@@ -5,12 +5,15 @@ import (
 	"errors"
 	"os"
 	"path/filepath"
+	"strconv"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/rdimidov/kvstore/internal/domain"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 type testConfig struct{}
@@ -19,6 +22,24 @@ func (testConfig) WALBatchSize() int                   { return 2 }
 func (testConfig) WALBatchFlushTimeout() time.Duration { return 20 * time.Millisecond }
 func (testConfig) WALDirName() string                  { return "./test_wal" }
 func (testConfig) WALMaxSegmentSize() int              { return 1 } // MB
+func (testConfig) WALSnapshotDirName() string          { return "" }
+func (testConfig) WALRecoveryPolicy() string           { return "" }
+func (testConfig) WALFsyncPolicy() string              { return "" }
+
+// inNamespace matches a context whose session selected ns.
+func inNamespace(ns domain.Namespace) any {
+	return mock.MatchedBy(func(ctx context.Context) bool {
+		return domain.NamespaceFrom(ctx) == ns
+	})
+}
+
+// logged asserts that a write was logged.
+func logged(t *testing.T) func(domain.LSN, error) {
+	return func(_ domain.LSN, err error) {
+		t.Helper()
+		assert.NoError(t, err)
+	}
+}
 
 func cleanupTestDir(t *testing.T, path string) {
 	t.Helper()
@@ -29,65 +50,240 @@ func cleanupTestDir(t *testing.T, path string) {
 func TestWriteSetAndFlushOnBatchLimit(t *testing.T) {
 	cfg := testConfig{}
 	defer cleanupTestDir(t, cfg.WALDirName())
-	w, err := New(context.Background(), cfg, newMockinterpreter(t))
+	w, err := New(cfg, newMockinterpreter(t))
 	assert.NoError(t, err)
 
 	key1, _ := domain.NewKey("foo")
 	val1, _ := domain.NewValue("bar")
-	err = w.WriteSet(key1, val1)
+	_, err = w.WriteSet(domain.NewEntryFromKV(key1, val1))
 
 	assert.NoError(t, err)
 
-	err = w.WriteSet("key", "val")
+	_, err = w.WriteSet(domain.Entry{Key: "key", Value: "val", Version: 7})
 	assert.NoError(t, err)
 
 	time.Sleep(50 * time.Millisecond)
 
 	reader := NewReader(cfg.WALDirName())
-	lines, err := reader.Read()
+	lines, err := reader.Read("")
 	assert.NoError(t, err)
 	assert.Contains(t, lines, "SET foo bar")
-	assert.Contains(t, lines, "SET key val")
+	assert.Contains(t, lines, "SET key val VER 7")
 }
 
 func TestWriteDelAndFlushOnTimeout(t *testing.T) {
 	cfg := testConfig{}
 	defer cleanupTestDir(t, cfg.WALDirName())
-	w, err := New(context.Background(), cfg, newMockinterpreter(t))
+	w, err := New(cfg, newMockinterpreter(t))
 	assert.NoError(t, err)
 
-	err = w.WriteDel("somekey")
+	_, err = w.WriteDel("somekey")
 	assert.NoError(t, err)
 
 	time.Sleep(50 * time.Millisecond) // flush on timeout
 
 	reader := NewReader("./test_wal")
-	lines, err := reader.Read()
+	lines, err := reader.Read("")
 	assert.NoError(t, err)
 	assert.Contains(t, lines, "DEL somekey")
 }
 
+func TestWritesReturnLSNs(t *testing.T) {
+	cfg := testConfig{}
+	defer cleanupTestDir(t, cfg.WALDirName())
+	w, err := New(cfg, newMockinterpreter(t))
+	assert.NoError(t, err)
+
+	lsn, err := w.WriteSet(domain.Entry{Key: "a", Value: "1"})
+	assert.NoError(t, err)
+	assert.Equal(t, domain.LSN(1), lsn)
+	lsn, err = w.WriteDel("a")
+	assert.NoError(t, err)
+	assert.Equal(t, domain.LSN(2), lsn)
+
+	// a transaction is numbered as a whole
+	w.Begin()
+	logged(t)(w.WriteSet(domain.Entry{Key: "b", Value: "2"}))
+	lsn, err = w.Commit("tx")
+	assert.NoError(t, err)
+	assert.Equal(t, domain.LSN(3), lsn)
+	assert.NoError(t, w.Close())
+
+	// numbering goes on after a restart
+	w, err = New(cfg, newMockinterpreter(t))
+	assert.NoError(t, err)
+	lsn, err = w.WriteDel("b")
+	assert.NoError(t, err)
+	assert.Equal(t, domain.LSN(4), lsn)
+	assert.NoError(t, w.Close())
+}
+
+func TestWritesAreLoggedInOrder(t *testing.T) {
+	cfg := testConfig{}
+	defer cleanupTestDir(t, cfg.WALDirName())
+	w, err := New(cfg, newMockinterpreter(t))
+	assert.NoError(t, err)
+
+	// many small batches, queued faster than they are synced
+	var expected []string
+	var futures []FutureCommit
+	for i := range 200 {
+		cmd := "DEL k" + strconv.Itoa(i)
+		expected = append(expected, cmd)
+		futures = append(futures, w.processInput(cmd))
+	}
+	for i, fut := range futures {
+		lsn, err := wait(fut)
+		assert.NoError(t, err)
+		assert.Equal(t, domain.LSN(i+1), lsn)
+	}
+	assert.NoError(t, w.Close())
+
+	reader := NewReader(cfg.WALDirName())
+	lines, err := reader.Read("")
+	assert.NoError(t, err)
+	assert.Equal(t, expected, lines)
+}
+
+func TestWriteExpirationsAsDeadlines(t *testing.T) {
+	cfg := testConfig{}
+	defer cleanupTestDir(t, cfg.WALDirName())
+	w, err := New(cfg, newMockinterpreter(t))
+	assert.NoError(t, err)
+
+	deadline := time.UnixMilli(1700000000000)
+	logged(t)(w.WriteSet(domain.Entry{Key: "foo", Value: "bar", ExpiresAt: deadline}))
+	logged(t)(w.WriteExpire("foo", deadline))
+	logged(t)(w.WritePersist("foo"))
+
+	reader := NewReader(cfg.WALDirName())
+	lines, err := reader.Read("")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"SET foo bar PXAT 1700000000000",
+		"PEXPIREAT foo 1700000000000",
+		"PERSIST foo",
+	}, lines)
+}
+
+func TestWriteTagsNamespaces(t *testing.T) {
+	cfg := testConfig{}
+	defer cleanupTestDir(t, cfg.WALDirName())
+	w, err := New(cfg, newMockinterpreter(t))
+	assert.NoError(t, err)
+
+	team := domain.Namespace("team")
+	logged(t)(w.WriteSet(domain.Entry{Key: team.Key("foo"), Value: "bar"}))
+	logged(t)(w.WriteDel(team.Key("foo")))
+	logged(t)(w.WriteFlush(team))
+	logged(t)(w.WriteFlush(domain.DefaultNamespace))
+	logged(t)(w.WriteSet(domain.Entry{Key: "a b", Value: "x ; y\n"}))
+
+	time.Sleep(50 * time.Millisecond) // flush on timeout
+
+	reader := NewReader(cfg.WALDirName())
+	lines, err := reader.Read("")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"@team SET foo bar",
+		"@team DEL foo",
+		"@team FLUSHDB",
+		"FLUSHDB",
+		`SET "a\x20b" "x\x20;\x20y\n"`,
+	}, lines)
+}
+
+func TestWriteCollections(t *testing.T) {
+	cfg := testConfig{}
+	defer cleanupTestDir(t, cfg.WALDirName())
+	w, err := New(cfg, newMockinterpreter(t))
+	assert.NoError(t, err)
+
+	expiry := time.UnixMilli(1700000000000)
+	logged(t)(w.WriteCollection(domain.Entry{Key: "h", Type: domain.TypeHash, Items: []domain.Value{"f", "1"}}))
+	logged(t)(w.WriteCollection(domain.Entry{Key: "l", Type: domain.TypeList, Items: []domain.Value{"b", "a"}, ExpiresAt: expiry}))
+	logged(t)(w.WriteCollection(domain.Entry{Key: domain.Namespace("team").Key("s"), Type: domain.TypeSet, Items: []domain.Value{"x"}}))
+	logged(t)(w.WriteCollection(domain.Entry{Key: "l", Type: domain.TypeList}))
+
+	reader := NewReader(cfg.WALDirName())
+	lines, err := reader.Read("")
+	assert.NoError(t, err)
+	assert.Len(t, lines, 4)
+	// a collection is logged as a batch rebuilding it, and as a delete once
+	// empty
+	assert.Equal(t, []string{"DEL h", "HSET h f 1"}, txRecords(lines[0]))
+	assert.Equal(t, []string{"DEL l", "RPUSH l b a", "PEXPIREAT l 1700000000000"}, txRecords(lines[1]))
+	assert.Equal(t, []string{"@team DEL s", "@team SADD s x"}, txRecords(lines[2]))
+	assert.Equal(t, "DEL l", lines[3])
+}
+
+func TestWriteTransactionAsOneRecord(t *testing.T) {
+	cfg := testConfig{}
+	defer cleanupTestDir(t, cfg.WALDirName())
+	w, err := New(cfg, newMockinterpreter(t))
+	assert.NoError(t, err)
+
+	w.Begin()
+	logged(t)(w.WriteSet(domain.Entry{Key: "foo", Value: "bar"}))
+	logged(t)(w.WriteDel(domain.Namespace("team").Key("baz")))
+	logged(t)(w.Commit("id"))
+
+	w.Begin()
+	logged(t)(w.WriteDel("foo"))
+	w.Rollback()
+
+	time.Sleep(50 * time.Millisecond) // flush on timeout
+
+	reader := NewReader(cfg.WALDirName())
+	lines, err := reader.Read("")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"TX id SET foo bar ; @team DEL baz"}, lines)
+}
+
+func TestWriteBatches(t *testing.T) {
+	cfg := testConfig{}
+	defer cleanupTestDir(t, cfg.WALDirName())
+	w, err := New(cfg, newMockinterpreter(t))
+	assert.NoError(t, err)
+
+	logged(t)(w.WriteMSet([]domain.Entry{{Key: "a", Value: "1", Version: 1}, {Key: "b", Value: "2", Version: 2}}))
+	w.Begin()
+	logged(t)(w.WriteMDel([]domain.Key{"a", "b"}))
+	logged(t)(w.Commit("id"))
+
+	time.Sleep(50 * time.Millisecond) // flush on timeout
+
+	reader := NewReader(cfg.WALDirName())
+	lines, err := reader.Read("")
+	assert.NoError(t, err)
+	assert.Len(t, lines, 2)
+	assert.Equal(t, []string{"SET a 1 VER 1", "SET b 2 VER 2"}, txRecords(lines[0]))
+	// a batch inside a transaction joins its record
+	assert.Equal(t, "TX id DEL a ; DEL b", lines[1])
+}
+
 func TestRecoverExecutesCommands(t *testing.T) {
 	cfg := testConfig{}
 	defer cleanupTestDir(t, cfg.WALDirName())
 
 	_ = os.MkdirAll(cfg.WALDirName(), 0o755)
-	f, err := os.Create(filepath.Join(cfg.WALDirName(), "manual.wal"))
-	assert.NoError(t, err)
-	_, _ = f.WriteString("SET foo bar\nDEL foo\n")
-	f.Close()
+	writeSegment(t, filepath.Join(cfg.WALDirName(), "manual.wal"), 1,
+		"SET foo bar", "DEL foo", "@team SET foo baz", "TX id SET a b ; @team DEL a")
 
 	ctx := context.Background()
 
 	mockInterpreter := newMockinterpreter(t)
-	mockInterpreter.On("Execute", ctx, "SET foo bar").Return(&domain.Entry{}, nil).Once()
-	mockInterpreter.On("Execute", ctx, "DEL foo").Return(&domain.Entry{}, nil).Once()
+	mockInterpreter.On("Execute", inNamespace(domain.DefaultNamespace), "SET foo bar").Return(domain.OKResult(), nil).Once()
+	mockInterpreter.On("Execute", inNamespace(domain.DefaultNamespace), "DEL foo").Return(domain.OKResult(), nil).Once()
+	mockInterpreter.On("Execute", inNamespace("team"), "SET foo baz").Return(domain.OKResult(), nil).Once()
+	mockInterpreter.On("Execute", inNamespace(domain.DefaultNamespace), "SET a b").Return(domain.OKResult(), nil).Once()
+	mockInterpreter.On("Execute", inNamespace("team"), "DEL a").Return(domain.OKResult(), nil).Once()
 
 	w := WAL{
 		reader:      NewReader(cfg.WALDirName()),
 		interpreter: mockInterpreter,
 	}
-	err = w.Recover(ctx)
+	err := w.Recover(ctx)
 	assert.NoError(t, err)
 	mockInterpreter.AssertExpectations(t)
 }
@@ -97,26 +293,103 @@ func TestRecoverFailsIfInterpreterFails(t *testing.T) {
 	defer cleanupTestDir(t, cfg.WALDirName())
 
 	_ = os.MkdirAll(cfg.WALDirName(), 0o755)
-	f, err := os.Create(filepath.Join(cfg.WALDirName(), "bad.wal"))
-	assert.NoError(t, err)
-	_, _ = f.WriteString("SET foo bar\n")
-	f.Close()
+	writeSegment(t, filepath.Join(cfg.WALDirName(), "bad.wal"), 1, "SET foo bar")
 
 	ctx := context.Background()
 
 	mockInterpreter := newMockinterpreter(t)
-	mockInterpreter.On("Execute", ctx, "SET foo bar").Return(nil, errors.New("fail")).Once()
+	mockInterpreter.On("Execute", inNamespace(domain.DefaultNamespace), "SET foo bar").Return(domain.Result{}, errors.New("fail")).Once()
 
 	w := WAL{
 		reader:      NewReader(cfg.WALDirName()),
 		interpreter: mockInterpreter,
 	}
-	err = w.Recover(ctx)
-	assert.EqualError(t, err, "fail")
+	err := w.Recover(ctx)
+	assert.EqualError(t, err, "recover bad.wal at offset 16: fail")
 }
 
 func TestNewFailsOnNilInterpreter(t *testing.T) {
-	ctx := context.Background()
-	_, err := New(ctx, testConfig{}, nil)
+	_, err := New(testConfig{}, nil)
 	assert.Error(t, err)
 }
+
+func TestCloseFlushesPendingWrites(t *testing.T) {
+	cfg := testConfig{}
+	defer cleanupTestDir(t, cfg.WALDirName())
+	w, err := New(cfg, newMockinterpreter(t))
+	assert.NoError(t, err)
+
+	// records still queued or being collected when closing
+	futures := []FutureCommit{
+		w.processInput("DEL a"),
+		w.processInput("DEL b"),
+		w.processInput("DEL c"),
+	}
+	assert.NoError(t, w.Close())
+	for _, fut := range futures {
+		_, err := wait(fut)
+		assert.NoError(t, err)
+	}
+
+	reader := NewReader(cfg.WALDirName())
+	lines, err := reader.Read("")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"DEL a", "DEL b", "DEL c"}, lines)
+
+	_, err = w.WriteDel("d")
+	assert.ErrorIs(t, err, ErrClosed)
+	assert.NoError(t, w.Close())
+}
+
+// stallingWriter holds the first write until release is closed and records
+// the size of every write.
+type stallingWriter struct {
+	release chan struct{}
+	mu      sync.Mutex
+	sizes   []int
+	lsn     domain.LSN
+}
+
+func (s *stallingWriter) Write(batch []entry) {
+	<-s.release
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sizes = append(s.sizes, len(batch))
+	for _, e := range batch {
+		s.lsn++
+		e.SetResponse(s.lsn, nil)
+	}
+}
+
+func (s *stallingWriter) Rotate() (string, error) { return "", nil }
+func (s *stallingWriter) Sync() error             { return nil }
+func (s *stallingWriter) Close() error            { return nil }
+
+func TestWriteCoalescesBoundedBatches(t *testing.T) {
+	const batchLimit = 2
+	sw := &stallingWriter{release: make(chan struct{})}
+	w := &WAL{writer: sw, batchLimit: batchLimit, timeout: time.Hour}
+	w.start()
+
+	// writers keep the queue full while the first write is held up
+	var wg sync.WaitGroup
+	for i := range 100 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := wait(w.processInput("DEL k" + strconv.Itoa(i)))
+			assert.NoError(t, err)
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(sw.release)
+	wg.Wait()
+	assert.NoError(t, w.Close())
+
+	total := 0
+	for _, n := range sw.sizes {
+		assert.LessOrEqual(t, n, (defaultQueueDepth+1)*batchLimit)
+		total += n
+	}
+	assert.Equal(t, 100, total)
+}
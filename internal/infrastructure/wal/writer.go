@@ -1,28 +1,40 @@
 package wal
 
 import (
-	"bytes"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
-	"sort"
 	"sync"
-	"time"
+
+	"github.com/rdimidov/kvstore/internal/domain"
 )
 
 const baseFileName = "wal"
 
+// segmentFile is the open segment records are appended to.
+type segmentFile interface {
+	io.Writer
+	Sync() error
+	Truncate(size int64) error
+	Close() error
+}
+
 // rotatingWalWriter implements walWriter and can "fold" logs into segments:
 // as soon as one file grows to maxBytes, it is closed and a new one is started.
 type rotatingWalWriter struct {
-	dir      string // directory where to put segments
-	baseName string // segment file  prefix
-	maxBytes int    // max segment size
+	dir      string      // directory where to put segments
+	maxBytes int         // max segment size
+	fsync    FsyncPolicy // when records are synced
 
 	mu      sync.Mutex
-	curFile *os.File
-	curSize int // curr segment size
+	curFile segmentFile
+	curName string
+	curSize int    // curr segment size
+	lsn     uint64 // LSN of the last record written
+	synced  uint64 // LSN of the last record synced
+	closed  bool
 }
 
 func newRotatingWalWriter(dir string, maxBytes int) (*rotatingWalWriter, error) {
@@ -30,121 +42,244 @@ func newRotatingWalWriter(dir string, maxBytes int) (*rotatingWalWriter, error)
 		return nil, err
 	}
 
-	w := &rotatingWalWriter{
+	return &rotatingWalWriter{
 		dir:      dir,
-		baseName: baseFileName,
 		maxBytes: maxBytes,
-	}
-	w.openLastCreatedFile()
+	}, nil
+}
 
-	if w.curFile == nil {
-		if err := w.rotate(); err != nil {
-			return nil, err
-		}
+// open picks the segment to write to on first use, so that recovery has
+// repaired the log by then.
+func (w *rotatingWalWriter) open() error {
+	if w.closed {
+		return ErrClosed
 	}
-
-	return w, nil
+	if w.curFile != nil {
+		return nil
+	}
+	return w.openLastSegment()
 }
 
-// rotate closes the current file (if it is open) and creates a new segment.
+// rotate closes the current file (if it is open) and creates a new segment,
+// named by the LSN of the next record. An empty current segment is kept, as
+// it already starts there.
 func (w *rotatingWalWriter) rotate() error {
+	filename := segmentName(w.lsn + 1)
 	if w.curFile != nil {
+		if filename == w.curName {
+			return nil
+		}
+		// a full segment is synced whatever the policy, so that Sync has
+		// only the current one to care about
+		if err := w.sync(); err != nil {
+			return err
+		}
 		if err := w.curFile.Close(); err != nil {
 			return err
 		}
 		w.curFile = nil
 	}
 
-	timestamp := time.Now().Format("20060102T150405")
-	filename := fmt.Sprintf("%s.%s", timestamp, w.baseName)
 	fullpath := filepath.Join(w.dir, filename)
-
-	f, err := os.OpenFile(fullpath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err := createSegment(fullpath, w.lsn+1); err != nil {
+		return err
+	}
+	if err := addSegment(w.dir, filename); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(fullpath, os.O_APPEND|os.O_WRONLY, 0o644)
 	if err != nil {
 		return err
 	}
 
 	w.curFile = f
-	w.curSize = 0
+	w.curName = filename
+	w.curSize = segmentHeaderSize
 	return nil
 }
 
-// Write writes a batch of entries to the current WAL segment.
-func (w *rotatingWalWriter) Write(batch []entry) {
-	var buf bytes.Buffer
-	for _, e := range batch {
-		buf.WriteString(e.data)
-		buf.WriteByte('\n')
+// Rotate starts a new segment, unless the current one is still empty, and
+// returns its name. Everything written before the call is in older segments.
+func (w *rotatingWalWriter) Rotate() (string, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.open(); err != nil {
+		return "", err
+	}
+	if err := w.rotate(); err != nil {
+		return "", err
 	}
+	return w.curName, nil
+}
 
+// Write writes a batch of entries to the current WAL segment, numbering
+// them after the records before. They are synced as the fsync policy says:
+// each on its own, together, or not before they are acknowledged. Records
+// that could not be synced are acknowledged with their LSNs all the same,
+// along with domain.ErrDurabilityUnknown.
+func (w *rotatingWalWriter) Write(batch []entry) {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
-	if w.curSize+buf.Len() > w.maxBytes {
+	if err := w.open(); err != nil {
+		fail(batch, err)
+		return
+	}
+
+	if w.fsync.Mode == FsyncAlways {
+		for i := range batch {
+			w.write(batch[i : i+1])
+		}
+		return
+	}
+	w.write(batch)
+}
+
+func (w *rotatingWalWriter) write(batch []entry) {
+	first := w.lsn + 1
+	var buf []byte
+	for i, e := range batch {
+		buf = appendRecord(buf, newRecord(first+uint64(i), e.data))
+	}
+
+	// a batch bigger than a whole segment still goes to an empty one
+	if w.curSize > segmentHeaderSize && w.curSize+len(buf) > w.maxBytes {
 		if err := w.rotate(); err != nil {
-			for _, e := range batch {
-				e.SetResponse(err)
-			}
+			fail(batch, err)
 			return
 		}
 	}
 
-	n, err := w.curFile.Write(buf.Bytes())
-	if err == nil && n < buf.Len() {
+	n, err := w.curFile.Write(buf)
+	if err == nil && n < len(buf) {
 		err = errors.New("short write to WAL")
 	}
-	if err == nil {
-		w.curSize += n
-		err = w.curFile.Sync()
+	if err != nil {
+		// cut the partial batch off, so later records follow valid ones
+		_ = w.curFile.Truncate(int64(w.curSize))
+		fail(batch, err)
+		return
 	}
+	w.curSize += n
+	w.lsn += uint64(len(batch))
 
-	for _, e := range batch {
-		e.SetResponse(err)
+	// the records are in the segment now and replay will apply them if they
+	// reach the disk, so a failed sync leaves them logged, durability unknown
+	if w.fsync.syncsWrites() {
+		if err = w.sync(); err != nil {
+			err = fmt.Errorf("%w: %w", domain.ErrDurabilityUnknown, err)
+		}
+	}
+	for i, e := range batch {
+		e.SetResponse(domain.LSN(first+uint64(i)), err)
 	}
 }
 
-func (w *rotatingWalWriter) openLastCreatedFile() {
-	files, err := os.ReadDir(w.dir)
-	if err != nil || len(files) == 0 {
-		return
+// Sync makes the records written so far durable.
+func (w *rotatingWalWriter) Sync() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.curFile == nil {
+		return nil
 	}
+	return w.sync()
+}
 
-	type namedTime struct {
-		name string
-		t    int64
+func (w *rotatingWalWriter) sync() error {
+	if w.synced == w.lsn {
+		return nil
 	}
+	if err := w.curFile.Sync(); err != nil {
+		return err
+	}
+	w.synced = w.lsn
+	return nil
+}
 
-	var candidates []namedTime
+func fail(batch []entry, err error) {
+	for _, e := range batch {
+		e.SetResponse(0, err)
+	}
+}
 
-	for _, file := range files {
-		if file.IsDir() {
-			continue
-		}
-		info, err := file.Info()
-		if err != nil {
-			continue
-		}
-		candidates = append(candidates, namedTime{file.Name(), info.ModTime().UnixNano()})
+// Close syncs and closes the current segment.
+func (w *rotatingWalWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.closed = true
+	if w.curFile == nil {
+		return nil
 	}
-	if len(candidates) == 0 {
-		return
+	err := w.curFile.Sync()
+	if cerr := w.curFile.Close(); err == nil {
+		err = cerr
 	}
+	w.curFile = nil
+	return err
+}
 
-	sort.Slice(candidates, func(i, j int) bool {
-		return candidates[i].t > candidates[j].t
-	})
-
-	f, err := os.OpenFile(filepath.Join(w.dir, candidates[0].name), os.O_APPEND|os.O_WRONLY, 0o644)
+// openLastSegment resumes writing to the last segment, or starts a new one
+// when there is none or the last one does not go on validly, since records
+// appended after a damaged one could not be read.
+func (w *rotatingWalWriter) openLastSegment() error {
+	names, err := segmentNames(w.dir)
 	if err != nil {
-		return
+		return err
+	}
+	if len(names) == 0 {
+		return w.rotate()
 	}
 
-	info, err := f.Stat()
+	name := names[len(names)-1]
+	path := filepath.Join(w.dir, name)
+	sr, damaged, err := scanSegment(path)
 	if err != nil {
-		f.Close()
-		return
+		return err
+	}
+	w.curName = name
+	w.lsn = sr.nextLSN - 1
+	if damaged {
+		return w.rotate()
 	}
 
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
 	w.curFile = f
-	w.curSize = int(info.Size())
+	w.curSize = int(sr.offset)
+	return nil
+}
+
+// scanSegment reads a segment through, past the damaged records it can, and
+// tells whether it met any.
+func scanSegment(path string) (*segmentReader, bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, false, err
+	}
+	defer f.Close()
+
+	sr, err := newSegmentReader(f)
+	if err != nil {
+		return nil, false, fmt.Errorf("%s: %w", path, err)
+	}
+	damaged := false
+	for {
+		_, err := sr.next()
+		switch {
+		case errors.Is(err, io.EOF):
+			return sr, damaged, nil
+		case isDamage(err):
+			damaged = true
+			if !sr.resumable {
+				return sr, damaged, nil
+			}
+		case err != nil:
+			return nil, false, err
+		}
+	}
 }
@@ -5,7 +5,6 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
-	"time"
 )
 
 func TestRotatingWalWriter_WriteAndRotate(t *testing.T) {
@@ -23,26 +22,26 @@ func TestRotatingWalWriter_WriteAndRotate(t *testing.T) {
 	}
 
 	writer.Write(batch)
-	time.Sleep(time.Second)
 	writer.Write(batch)
 
-	files, err := os.ReadDir(dir)
+	names, err := segmentNames(dir)
 	if err != nil {
-		t.Fatalf("failed to read WAL directory: %v", err)
+		t.Fatalf("failed to list segments: %v", err)
 	}
 
-	if len(files) < 2 {
-		t.Errorf("expected at least 2 WAL files due to rotation, got %d", len(files))
+	// segments are named by the LSN of their first record
+	expected := []string{segmentName(1), segmentName(3)}
+	if strings.Join(names, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected segments %v due to rotation, got %v", expected, names)
 	}
 
 	var totalLines int
-	for _, file := range files {
-		data, err := os.ReadFile(filepath.Join(dir, file.Name()))
+	for _, name := range names {
+		lines, _, err := readSegment(filepath.Join(dir, name))
 		if err != nil {
-			t.Errorf("failed to read file %s: %v", file.Name(), err)
+			t.Errorf("failed to read file %s: %v", name, err)
 			continue
 		}
-		lines := strings.Split(strings.TrimSpace(string(data)), "\n")
 		totalLines += len(lines)
 	}
 
@@ -51,3 +50,74 @@ func TestRotatingWalWriter_WriteAndRotate(t *testing.T) {
 		t.Errorf("expected %d log lines, found %d", allEntries, totalLines)
 	}
 }
+
+func TestRotatingWalWriter_StartsNewSegmentAfterDamage(t *testing.T) {
+	dir := t.TempDir()
+
+	writer, err := newRotatingWalWriter(dir, 1<<20)
+	if err != nil {
+		t.Fatalf("failed to create writer: %v", err)
+	}
+	writer.Write([]entry{newEntry("SET a 1"), newEntry("SET b 2")})
+	if err := writer.Close(); err != nil {
+		t.Fatalf("failed to close writer: %v", err)
+	}
+
+	// a crash tore the last record
+	damaged := filepath.Join(dir, writer.curName)
+	info, err := os.Stat(damaged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(damaged, info.Size()-3); err != nil {
+		t.Fatal(err)
+	}
+
+	writer, err = newRotatingWalWriter(dir, 1<<20)
+	if err != nil {
+		t.Fatalf("failed to reopen writer: %v", err)
+	}
+	writer.Write([]entry{newEntry("SET c 3")})
+	if writer.curName == filepath.Base(damaged) {
+		t.Fatal("appended to the damaged segment")
+	}
+
+	lines, sr, err := readSegment(filepath.Join(dir, writer.curName))
+	if err != nil {
+		t.Fatalf("failed to read the new segment: %v", err)
+	}
+	// numbered after the last valid record
+	if sr.firstLSN != 2 || strings.Join(lines, ",") != "SET c 3" {
+		t.Errorf("expected SET c 3 from record 2, got %q from %d", lines, sr.firstLSN)
+	}
+}
+
+func TestRotatingWalWriter_RotateKeepsEmptySegment(t *testing.T) {
+	dir := t.TempDir()
+
+	writer, err := newRotatingWalWriter(dir, 1<<20)
+	if err != nil {
+		t.Fatalf("failed to create writer: %v", err)
+	}
+	writer.Write([]entry{newEntry("SET a 1")})
+
+	first, err := writer.Rotate()
+	if err != nil {
+		t.Fatalf("failed to rotate: %v", err)
+	}
+	second, err := writer.Rotate()
+	if err != nil {
+		t.Fatalf("failed to rotate: %v", err)
+	}
+	if first != segmentName(2) || second != first {
+		t.Errorf("expected both rotations to return %s, got %s and %s", segmentName(2), first, second)
+	}
+
+	names, err := segmentNames(dir)
+	if err != nil {
+		t.Fatalf("failed to list segments: %v", err)
+	}
+	if len(names) != 2 {
+		t.Errorf("expected 2 segments, got %v", names)
+	}
+}
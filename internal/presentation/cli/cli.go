@@ -6,31 +6,53 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"time"
 
-	"github.com/google/uuid"
 	"github.com/rdimidov/kvstore/internal/domain"
 	"github.com/rdimidov/kvstore/internal/presentation/interpreter"
 )
 
-// contextKey is used for context values
-// txIDKey stores a transaction ID
-type contextKey string
-
-const (
-	txIDKey   contextKey = "tx"
-	inputMark string     = "> "
-)
+const inputMark string = "> "
 
 // app abstracts business logic methods used by CLI
 type app interface {
 	Get(ctx context.Context, key domain.Key) (*domain.Entry, error)
 	Set(ctx context.Context, key domain.Key, value domain.Value) error
+	SetEx(ctx context.Context, key domain.Key, value domain.Value, deadline time.Time) error
 	Delete(ctx context.Context, key domain.Key) error
+	Expire(ctx context.Context, key domain.Key, deadline time.Time) error
+	Persist(ctx context.Context, key domain.Key) error
+	Scan(ctx context.Context, start, end domain.Key, limit int) ([]domain.Entry, error)
+	Snapshot(ctx context.Context) error
+	Restore(ctx context.Context, entry domain.Entry) error
+	CompareAndSet(ctx context.Context, key domain.Key, expected uint64, value domain.Value) error
+	SetNX(ctx context.Context, key domain.Key, value domain.Value) error
+	SetXX(ctx context.Context, key domain.Key, value domain.Value) error
+	IncrBy(ctx context.Context, key domain.Key, delta int64) (int64, error)
+	FlushDB(ctx context.Context) error
+	DBSize(ctx context.Context) (int, error)
+	HSet(ctx context.Context, key domain.Key, fields []domain.HashField) (int, error)
+	HGet(ctx context.Context, key domain.Key, field domain.Value) (domain.Value, error)
+	HDel(ctx context.Context, key domain.Key, fields []domain.Value) (int, error)
+	HGetAll(ctx context.Context, key domain.Key) ([]domain.HashField, error)
+	Push(ctx context.Context, key domain.Key, values []domain.Value, left bool) (int, error)
+	Pop(ctx context.Context, key domain.Key, left bool) (domain.Value, error)
+	LRange(ctx context.Context, key domain.Key, start, stop int) ([]domain.Value, error)
+	SAdd(ctx context.Context, key domain.Key, members []domain.Value) (int, error)
+	SRem(ctx context.Context, key domain.Key, members []domain.Value) (int, error)
+	SMembers(ctx context.Context, key domain.Key) ([]domain.Value, error)
+	SIsMember(ctx context.Context, key domain.Key, member domain.Value) (bool, error)
+	Watch(ctx context.Context, keys []domain.Key) error
+	Unwatch(ctx context.Context)
+	Exec(ctx context.Context, fn func(context.Context) error) error
+	MGet(ctx context.Context, keys []domain.Key) ([]*domain.Entry, error)
+	MSet(ctx context.Context, entries []domain.Entry) error
+	MDel(ctx context.Context, keys []domain.Key) (int, error)
 }
 
 // interpr processes raw input and executes commands
 type interpr interface {
-	Execute(ctx context.Context, raw string) (*domain.Entry, error)
+	Execute(ctx context.Context, raw string) (domain.Result, error)
 }
 
 // Cli runs the command loop
@@ -53,6 +75,7 @@ func NewCli(app app) (*Cli, error) {
 // Run reads stdin, executes commands, and prints results
 func (c *Cli) Run(ctx context.Context) error {
 	scanner := bufio.NewScanner(os.Stdin)
+	ctx = domain.WithSession(ctx, &domain.Session{})
 	c.prompt()
 
 	for scanner.Scan() {
@@ -62,14 +85,13 @@ func (c *Cli) Run(ctx context.Context) error {
 			continue
 		}
 
-		ctx = context.WithValue(ctx, txIDKey, uuid.NewString())
-		entry, err := c.interpreter.Execute(ctx, input)
+		result, err := c.interpreter.Execute(ctx, input)
 
 		switch {
 		case err != nil:
 			fmt.Println("Error:", err)
-		case entry != nil:
-			fmt.Println(entry.Value)
+		case result.Kind != domain.ResultOK:
+			fmt.Println(result)
 		}
 		c.prompt()
 	}
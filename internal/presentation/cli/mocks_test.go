@@ -6,6 +6,7 @@ package cli
 
 import (
 	"context"
+	"time"
 
 	"github.com/rdimidov/kvstore/internal/domain"
 	mock "github.com/stretchr/testify/mock"
@@ -38,105 +39,1332 @@ func (_m *mockapp) EXPECT() *mockapp_Expecter {
 	return &mockapp_Expecter{mock: &_m.Mock}
 }
 
+// CompareAndSet provides a mock function for the type mockapp
+func (_mock *mockapp) CompareAndSet(ctx context.Context, key domain.Key, expected uint64, value domain.Value) error {
+	ret := _mock.Called(ctx, key, expected, value)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CompareAndSet")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key, uint64, domain.Value) error); ok {
+		r0 = returnFunc(ctx, key, expected, value)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// mockapp_CompareAndSet_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'CompareAndSet'
+type mockapp_CompareAndSet_Call struct {
+	*mock.Call
+}
+
+// CompareAndSet is a helper method to define mock.On call
+//   - ctx
+//   - key
+//   - expected
+//   - value
+func (_e *mockapp_Expecter) CompareAndSet(ctx interface{}, key interface{}, expected interface{}, value interface{}) *mockapp_CompareAndSet_Call {
+	return &mockapp_CompareAndSet_Call{Call: _e.mock.On("CompareAndSet", ctx, key, expected, value)}
+}
+
+func (_c *mockapp_CompareAndSet_Call) Run(run func(ctx context.Context, key domain.Key, expected uint64, value domain.Value)) *mockapp_CompareAndSet_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.Key), args[2].(uint64), args[3].(domain.Value))
+	})
+	return _c
+}
+
+func (_c *mockapp_CompareAndSet_Call) Return(err error) *mockapp_CompareAndSet_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *mockapp_CompareAndSet_Call) RunAndReturn(run func(ctx context.Context, key domain.Key, expected uint64, value domain.Value) error) *mockapp_CompareAndSet_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// DBSize provides a mock function for the type mockapp
+func (_mock *mockapp) DBSize(ctx context.Context) (int, error) {
+	ret := _mock.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DBSize")
+	}
+
+	var r0 int
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context) (int, error)); ok {
+		return returnFunc(ctx)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context) int); ok {
+		r0 = returnFunc(ctx)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = returnFunc(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// mockapp_DBSize_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'DBSize'
+type mockapp_DBSize_Call struct {
+	*mock.Call
+}
+
+// DBSize is a helper method to define mock.On call
+//   - ctx
+func (_e *mockapp_Expecter) DBSize(ctx interface{}) *mockapp_DBSize_Call {
+	return &mockapp_DBSize_Call{Call: _e.mock.On("DBSize", ctx)}
+}
+
+func (_c *mockapp_DBSize_Call) Run(run func(ctx context.Context)) *mockapp_DBSize_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context))
+	})
+	return _c
+}
+
+func (_c *mockapp_DBSize_Call) Return(int1 int, err error) *mockapp_DBSize_Call {
+	_c.Call.Return(int1, err)
+	return _c
+}
+
+func (_c *mockapp_DBSize_Call) RunAndReturn(run func(ctx context.Context) (int, error)) *mockapp_DBSize_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // Delete provides a mock function for the type mockapp
 func (_mock *mockapp) Delete(ctx context.Context, key domain.Key) error {
 	ret := _mock.Called(ctx, key)
 
 	if len(ret) == 0 {
-		panic("no return value specified for Delete")
+		panic("no return value specified for Delete")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key) error); ok {
+		r0 = returnFunc(ctx, key)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// mockapp_Delete_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Delete'
+type mockapp_Delete_Call struct {
+	*mock.Call
+}
+
+// Delete is a helper method to define mock.On call
+//   - ctx
+//   - key
+func (_e *mockapp_Expecter) Delete(ctx interface{}, key interface{}) *mockapp_Delete_Call {
+	return &mockapp_Delete_Call{Call: _e.mock.On("Delete", ctx, key)}
+}
+
+func (_c *mockapp_Delete_Call) Run(run func(ctx context.Context, key domain.Key)) *mockapp_Delete_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.Key))
+	})
+	return _c
+}
+
+func (_c *mockapp_Delete_Call) Return(err error) *mockapp_Delete_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *mockapp_Delete_Call) RunAndReturn(run func(ctx context.Context, key domain.Key) error) *mockapp_Delete_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Exec provides a mock function for the type mockapp
+func (_mock *mockapp) Exec(ctx context.Context, fn func(context.Context) error) error {
+	ret := _mock.Called(ctx, fn)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Exec")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, func(context.Context) error) error); ok {
+		r0 = returnFunc(ctx, fn)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// mockapp_Exec_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Exec'
+type mockapp_Exec_Call struct {
+	*mock.Call
+}
+
+// Exec is a helper method to define mock.On call
+//   - ctx
+//   - fn
+func (_e *mockapp_Expecter) Exec(ctx interface{}, fn interface{}) *mockapp_Exec_Call {
+	return &mockapp_Exec_Call{Call: _e.mock.On("Exec", ctx, fn)}
+}
+
+func (_c *mockapp_Exec_Call) Run(run func(ctx context.Context, fn func(context.Context) error)) *mockapp_Exec_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(func(context.Context) error))
+	})
+	return _c
+}
+
+func (_c *mockapp_Exec_Call) Return(err error) *mockapp_Exec_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *mockapp_Exec_Call) RunAndReturn(run func(ctx context.Context, fn func(context.Context) error) error) *mockapp_Exec_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Expire provides a mock function for the type mockapp
+func (_mock *mockapp) Expire(ctx context.Context, key domain.Key, deadline time.Time) error {
+	ret := _mock.Called(ctx, key, deadline)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Expire")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key, time.Time) error); ok {
+		r0 = returnFunc(ctx, key, deadline)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// mockapp_Expire_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Expire'
+type mockapp_Expire_Call struct {
+	*mock.Call
+}
+
+// Expire is a helper method to define mock.On call
+//   - ctx
+//   - key
+//   - deadline
+func (_e *mockapp_Expecter) Expire(ctx interface{}, key interface{}, deadline interface{}) *mockapp_Expire_Call {
+	return &mockapp_Expire_Call{Call: _e.mock.On("Expire", ctx, key, deadline)}
+}
+
+func (_c *mockapp_Expire_Call) Run(run func(ctx context.Context, key domain.Key, deadline time.Time)) *mockapp_Expire_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.Key), args[2].(time.Time))
+	})
+	return _c
+}
+
+func (_c *mockapp_Expire_Call) Return(err error) *mockapp_Expire_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *mockapp_Expire_Call) RunAndReturn(run func(ctx context.Context, key domain.Key, deadline time.Time) error) *mockapp_Expire_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// FlushDB provides a mock function for the type mockapp
+func (_mock *mockapp) FlushDB(ctx context.Context) error {
+	ret := _mock.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for FlushDB")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context) error); ok {
+		r0 = returnFunc(ctx)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// mockapp_FlushDB_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'FlushDB'
+type mockapp_FlushDB_Call struct {
+	*mock.Call
+}
+
+// FlushDB is a helper method to define mock.On call
+//   - ctx
+func (_e *mockapp_Expecter) FlushDB(ctx interface{}) *mockapp_FlushDB_Call {
+	return &mockapp_FlushDB_Call{Call: _e.mock.On("FlushDB", ctx)}
+}
+
+func (_c *mockapp_FlushDB_Call) Run(run func(ctx context.Context)) *mockapp_FlushDB_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context))
+	})
+	return _c
+}
+
+func (_c *mockapp_FlushDB_Call) Return(err error) *mockapp_FlushDB_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *mockapp_FlushDB_Call) RunAndReturn(run func(ctx context.Context) error) *mockapp_FlushDB_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Get provides a mock function for the type mockapp
+func (_mock *mockapp) Get(ctx context.Context, key domain.Key) (*domain.Entry, error) {
+	ret := _mock.Called(ctx, key)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Get")
+	}
+
+	var r0 *domain.Entry
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key) (*domain.Entry, error)); ok {
+		return returnFunc(ctx, key)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key) *domain.Entry); ok {
+		r0 = returnFunc(ctx, key)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*domain.Entry)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, domain.Key) error); ok {
+		r1 = returnFunc(ctx, key)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// mockapp_Get_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Get'
+type mockapp_Get_Call struct {
+	*mock.Call
+}
+
+// Get is a helper method to define mock.On call
+//   - ctx
+//   - key
+func (_e *mockapp_Expecter) Get(ctx interface{}, key interface{}) *mockapp_Get_Call {
+	return &mockapp_Get_Call{Call: _e.mock.On("Get", ctx, key)}
+}
+
+func (_c *mockapp_Get_Call) Run(run func(ctx context.Context, key domain.Key)) *mockapp_Get_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.Key))
+	})
+	return _c
+}
+
+func (_c *mockapp_Get_Call) Return(entry *domain.Entry, err error) *mockapp_Get_Call {
+	_c.Call.Return(entry, err)
+	return _c
+}
+
+func (_c *mockapp_Get_Call) RunAndReturn(run func(ctx context.Context, key domain.Key) (*domain.Entry, error)) *mockapp_Get_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// HDel provides a mock function for the type mockapp
+func (_mock *mockapp) HDel(ctx context.Context, key domain.Key, fields []domain.Value) (int, error) {
+	ret := _mock.Called(ctx, key, fields)
+
+	if len(ret) == 0 {
+		panic("no return value specified for HDel")
+	}
+
+	var r0 int
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key, []domain.Value) (int, error)); ok {
+		return returnFunc(ctx, key, fields)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key, []domain.Value) int); ok {
+		r0 = returnFunc(ctx, key, fields)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, domain.Key, []domain.Value) error); ok {
+		r1 = returnFunc(ctx, key, fields)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// mockapp_HDel_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'HDel'
+type mockapp_HDel_Call struct {
+	*mock.Call
+}
+
+// HDel is a helper method to define mock.On call
+//   - ctx
+//   - key
+//   - fields
+func (_e *mockapp_Expecter) HDel(ctx interface{}, key interface{}, fields interface{}) *mockapp_HDel_Call {
+	return &mockapp_HDel_Call{Call: _e.mock.On("HDel", ctx, key, fields)}
+}
+
+func (_c *mockapp_HDel_Call) Run(run func(ctx context.Context, key domain.Key, fields []domain.Value)) *mockapp_HDel_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.Key), args[2].([]domain.Value))
+	})
+	return _c
+}
+
+func (_c *mockapp_HDel_Call) Return(int1 int, err error) *mockapp_HDel_Call {
+	_c.Call.Return(int1, err)
+	return _c
+}
+
+func (_c *mockapp_HDel_Call) RunAndReturn(run func(ctx context.Context, key domain.Key, fields []domain.Value) (int, error)) *mockapp_HDel_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// HGet provides a mock function for the type mockapp
+func (_mock *mockapp) HGet(ctx context.Context, key domain.Key, field domain.Value) (domain.Value, error) {
+	ret := _mock.Called(ctx, key, field)
+
+	if len(ret) == 0 {
+		panic("no return value specified for HGet")
+	}
+
+	var r0 domain.Value
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key, domain.Value) (domain.Value, error)); ok {
+		return returnFunc(ctx, key, field)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key, domain.Value) domain.Value); ok {
+		r0 = returnFunc(ctx, key, field)
+	} else {
+		r0 = ret.Get(0).(domain.Value)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, domain.Key, domain.Value) error); ok {
+		r1 = returnFunc(ctx, key, field)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// mockapp_HGet_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'HGet'
+type mockapp_HGet_Call struct {
+	*mock.Call
+}
+
+// HGet is a helper method to define mock.On call
+//   - ctx
+//   - key
+//   - field
+func (_e *mockapp_Expecter) HGet(ctx interface{}, key interface{}, field interface{}) *mockapp_HGet_Call {
+	return &mockapp_HGet_Call{Call: _e.mock.On("HGet", ctx, key, field)}
+}
+
+func (_c *mockapp_HGet_Call) Run(run func(ctx context.Context, key domain.Key, field domain.Value)) *mockapp_HGet_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.Key), args[2].(domain.Value))
+	})
+	return _c
+}
+
+func (_c *mockapp_HGet_Call) Return(value domain.Value, err error) *mockapp_HGet_Call {
+	_c.Call.Return(value, err)
+	return _c
+}
+
+func (_c *mockapp_HGet_Call) RunAndReturn(run func(ctx context.Context, key domain.Key, field domain.Value) (domain.Value, error)) *mockapp_HGet_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// HGetAll provides a mock function for the type mockapp
+func (_mock *mockapp) HGetAll(ctx context.Context, key domain.Key) ([]domain.HashField, error) {
+	ret := _mock.Called(ctx, key)
+
+	if len(ret) == 0 {
+		panic("no return value specified for HGetAll")
+	}
+
+	var r0 []domain.HashField
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key) ([]domain.HashField, error)); ok {
+		return returnFunc(ctx, key)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key) []domain.HashField); ok {
+		r0 = returnFunc(ctx, key)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]domain.HashField)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, domain.Key) error); ok {
+		r1 = returnFunc(ctx, key)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// mockapp_HGetAll_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'HGetAll'
+type mockapp_HGetAll_Call struct {
+	*mock.Call
+}
+
+// HGetAll is a helper method to define mock.On call
+//   - ctx
+//   - key
+func (_e *mockapp_Expecter) HGetAll(ctx interface{}, key interface{}) *mockapp_HGetAll_Call {
+	return &mockapp_HGetAll_Call{Call: _e.mock.On("HGetAll", ctx, key)}
+}
+
+func (_c *mockapp_HGetAll_Call) Run(run func(ctx context.Context, key domain.Key)) *mockapp_HGetAll_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.Key))
+	})
+	return _c
+}
+
+func (_c *mockapp_HGetAll_Call) Return(hashFieldMoqParams []domain.HashField, err error) *mockapp_HGetAll_Call {
+	_c.Call.Return(hashFieldMoqParams, err)
+	return _c
+}
+
+func (_c *mockapp_HGetAll_Call) RunAndReturn(run func(ctx context.Context, key domain.Key) ([]domain.HashField, error)) *mockapp_HGetAll_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// HSet provides a mock function for the type mockapp
+func (_mock *mockapp) HSet(ctx context.Context, key domain.Key, fields []domain.HashField) (int, error) {
+	ret := _mock.Called(ctx, key, fields)
+
+	if len(ret) == 0 {
+		panic("no return value specified for HSet")
+	}
+
+	var r0 int
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key, []domain.HashField) (int, error)); ok {
+		return returnFunc(ctx, key, fields)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key, []domain.HashField) int); ok {
+		r0 = returnFunc(ctx, key, fields)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, domain.Key, []domain.HashField) error); ok {
+		r1 = returnFunc(ctx, key, fields)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// mockapp_HSet_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'HSet'
+type mockapp_HSet_Call struct {
+	*mock.Call
+}
+
+// HSet is a helper method to define mock.On call
+//   - ctx
+//   - key
+//   - fields
+func (_e *mockapp_Expecter) HSet(ctx interface{}, key interface{}, fields interface{}) *mockapp_HSet_Call {
+	return &mockapp_HSet_Call{Call: _e.mock.On("HSet", ctx, key, fields)}
+}
+
+func (_c *mockapp_HSet_Call) Run(run func(ctx context.Context, key domain.Key, fields []domain.HashField)) *mockapp_HSet_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.Key), args[2].([]domain.HashField))
+	})
+	return _c
+}
+
+func (_c *mockapp_HSet_Call) Return(int1 int, err error) *mockapp_HSet_Call {
+	_c.Call.Return(int1, err)
+	return _c
+}
+
+func (_c *mockapp_HSet_Call) RunAndReturn(run func(ctx context.Context, key domain.Key, fields []domain.HashField) (int, error)) *mockapp_HSet_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// IncrBy provides a mock function for the type mockapp
+func (_mock *mockapp) IncrBy(ctx context.Context, key domain.Key, delta int64) (int64, error) {
+	ret := _mock.Called(ctx, key, delta)
+
+	if len(ret) == 0 {
+		panic("no return value specified for IncrBy")
+	}
+
+	var r0 int64
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key, int64) (int64, error)); ok {
+		return returnFunc(ctx, key, delta)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key, int64) int64); ok {
+		r0 = returnFunc(ctx, key, delta)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, domain.Key, int64) error); ok {
+		r1 = returnFunc(ctx, key, delta)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// mockapp_IncrBy_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'IncrBy'
+type mockapp_IncrBy_Call struct {
+	*mock.Call
+}
+
+// IncrBy is a helper method to define mock.On call
+//   - ctx
+//   - key
+//   - delta
+func (_e *mockapp_Expecter) IncrBy(ctx interface{}, key interface{}, delta interface{}) *mockapp_IncrBy_Call {
+	return &mockapp_IncrBy_Call{Call: _e.mock.On("IncrBy", ctx, key, delta)}
+}
+
+func (_c *mockapp_IncrBy_Call) Run(run func(ctx context.Context, key domain.Key, delta int64)) *mockapp_IncrBy_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.Key), args[2].(int64))
+	})
+	return _c
+}
+
+func (_c *mockapp_IncrBy_Call) Return(int641 int64, err error) *mockapp_IncrBy_Call {
+	_c.Call.Return(int641, err)
+	return _c
+}
+
+func (_c *mockapp_IncrBy_Call) RunAndReturn(run func(ctx context.Context, key domain.Key, delta int64) (int64, error)) *mockapp_IncrBy_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// LRange provides a mock function for the type mockapp
+func (_mock *mockapp) LRange(ctx context.Context, key domain.Key, start int, stop int) ([]domain.Value, error) {
+	ret := _mock.Called(ctx, key, start, stop)
+
+	if len(ret) == 0 {
+		panic("no return value specified for LRange")
+	}
+
+	var r0 []domain.Value
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key, int, int) ([]domain.Value, error)); ok {
+		return returnFunc(ctx, key, start, stop)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key, int, int) []domain.Value); ok {
+		r0 = returnFunc(ctx, key, start, stop)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]domain.Value)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, domain.Key, int, int) error); ok {
+		r1 = returnFunc(ctx, key, start, stop)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// mockapp_LRange_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'LRange'
+type mockapp_LRange_Call struct {
+	*mock.Call
+}
+
+// LRange is a helper method to define mock.On call
+//   - ctx
+//   - key
+//   - start
+//   - stop
+func (_e *mockapp_Expecter) LRange(ctx interface{}, key interface{}, start interface{}, stop interface{}) *mockapp_LRange_Call {
+	return &mockapp_LRange_Call{Call: _e.mock.On("LRange", ctx, key, start, stop)}
+}
+
+func (_c *mockapp_LRange_Call) Run(run func(ctx context.Context, key domain.Key, start int, stop int)) *mockapp_LRange_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.Key), args[2].(int), args[3].(int))
+	})
+	return _c
+}
+
+func (_c *mockapp_LRange_Call) Return(valueMoqParams []domain.Value, err error) *mockapp_LRange_Call {
+	_c.Call.Return(valueMoqParams, err)
+	return _c
+}
+
+func (_c *mockapp_LRange_Call) RunAndReturn(run func(ctx context.Context, key domain.Key, start int, stop int) ([]domain.Value, error)) *mockapp_LRange_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// MDel provides a mock function for the type mockapp
+func (_mock *mockapp) MDel(ctx context.Context, keys []domain.Key) (int, error) {
+	ret := _mock.Called(ctx, keys)
+
+	if len(ret) == 0 {
+		panic("no return value specified for MDel")
+	}
+
+	var r0 int
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, []domain.Key) (int, error)); ok {
+		return returnFunc(ctx, keys)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, []domain.Key) int); ok {
+		r0 = returnFunc(ctx, keys)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, []domain.Key) error); ok {
+		r1 = returnFunc(ctx, keys)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// mockapp_MDel_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'MDel'
+type mockapp_MDel_Call struct {
+	*mock.Call
+}
+
+// MDel is a helper method to define mock.On call
+//   - ctx
+//   - keys
+func (_e *mockapp_Expecter) MDel(ctx interface{}, keys interface{}) *mockapp_MDel_Call {
+	return &mockapp_MDel_Call{Call: _e.mock.On("MDel", ctx, keys)}
+}
+
+func (_c *mockapp_MDel_Call) Run(run func(ctx context.Context, keys []domain.Key)) *mockapp_MDel_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].([]domain.Key))
+	})
+	return _c
+}
+
+func (_c *mockapp_MDel_Call) Return(int1 int, err error) *mockapp_MDel_Call {
+	_c.Call.Return(int1, err)
+	return _c
+}
+
+func (_c *mockapp_MDel_Call) RunAndReturn(run func(ctx context.Context, keys []domain.Key) (int, error)) *mockapp_MDel_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// MGet provides a mock function for the type mockapp
+func (_mock *mockapp) MGet(ctx context.Context, keys []domain.Key) ([]*domain.Entry, error) {
+	ret := _mock.Called(ctx, keys)
+
+	if len(ret) == 0 {
+		panic("no return value specified for MGet")
+	}
+
+	var r0 []*domain.Entry
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, []domain.Key) ([]*domain.Entry, error)); ok {
+		return returnFunc(ctx, keys)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, []domain.Key) []*domain.Entry); ok {
+		r0 = returnFunc(ctx, keys)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*domain.Entry)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, []domain.Key) error); ok {
+		r1 = returnFunc(ctx, keys)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// mockapp_MGet_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'MGet'
+type mockapp_MGet_Call struct {
+	*mock.Call
+}
+
+// MGet is a helper method to define mock.On call
+//   - ctx
+//   - keys
+func (_e *mockapp_Expecter) MGet(ctx interface{}, keys interface{}) *mockapp_MGet_Call {
+	return &mockapp_MGet_Call{Call: _e.mock.On("MGet", ctx, keys)}
+}
+
+func (_c *mockapp_MGet_Call) Run(run func(ctx context.Context, keys []domain.Key)) *mockapp_MGet_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].([]domain.Key))
+	})
+	return _c
+}
+
+func (_c *mockapp_MGet_Call) Return(entryMoqParams []*domain.Entry, err error) *mockapp_MGet_Call {
+	_c.Call.Return(entryMoqParams, err)
+	return _c
+}
+
+func (_c *mockapp_MGet_Call) RunAndReturn(run func(ctx context.Context, keys []domain.Key) ([]*domain.Entry, error)) *mockapp_MGet_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// MSet provides a mock function for the type mockapp
+func (_mock *mockapp) MSet(ctx context.Context, entries []domain.Entry) error {
+	ret := _mock.Called(ctx, entries)
+
+	if len(ret) == 0 {
+		panic("no return value specified for MSet")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, []domain.Entry) error); ok {
+		r0 = returnFunc(ctx, entries)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// mockapp_MSet_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'MSet'
+type mockapp_MSet_Call struct {
+	*mock.Call
+}
+
+// MSet is a helper method to define mock.On call
+//   - ctx
+//   - entries
+func (_e *mockapp_Expecter) MSet(ctx interface{}, entries interface{}) *mockapp_MSet_Call {
+	return &mockapp_MSet_Call{Call: _e.mock.On("MSet", ctx, entries)}
+}
+
+func (_c *mockapp_MSet_Call) Run(run func(ctx context.Context, entries []domain.Entry)) *mockapp_MSet_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].([]domain.Entry))
+	})
+	return _c
+}
+
+func (_c *mockapp_MSet_Call) Return(err error) *mockapp_MSet_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *mockapp_MSet_Call) RunAndReturn(run func(ctx context.Context, entries []domain.Entry) error) *mockapp_MSet_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Persist provides a mock function for the type mockapp
+func (_mock *mockapp) Persist(ctx context.Context, key domain.Key) error {
+	ret := _mock.Called(ctx, key)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Persist")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key) error); ok {
+		r0 = returnFunc(ctx, key)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// mockapp_Persist_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Persist'
+type mockapp_Persist_Call struct {
+	*mock.Call
+}
+
+// Persist is a helper method to define mock.On call
+//   - ctx
+//   - key
+func (_e *mockapp_Expecter) Persist(ctx interface{}, key interface{}) *mockapp_Persist_Call {
+	return &mockapp_Persist_Call{Call: _e.mock.On("Persist", ctx, key)}
+}
+
+func (_c *mockapp_Persist_Call) Run(run func(ctx context.Context, key domain.Key)) *mockapp_Persist_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.Key))
+	})
+	return _c
+}
+
+func (_c *mockapp_Persist_Call) Return(err error) *mockapp_Persist_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *mockapp_Persist_Call) RunAndReturn(run func(ctx context.Context, key domain.Key) error) *mockapp_Persist_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Pop provides a mock function for the type mockapp
+func (_mock *mockapp) Pop(ctx context.Context, key domain.Key, left bool) (domain.Value, error) {
+	ret := _mock.Called(ctx, key, left)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Pop")
+	}
+
+	var r0 domain.Value
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key, bool) (domain.Value, error)); ok {
+		return returnFunc(ctx, key, left)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key, bool) domain.Value); ok {
+		r0 = returnFunc(ctx, key, left)
+	} else {
+		r0 = ret.Get(0).(domain.Value)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, domain.Key, bool) error); ok {
+		r1 = returnFunc(ctx, key, left)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// mockapp_Pop_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Pop'
+type mockapp_Pop_Call struct {
+	*mock.Call
+}
+
+// Pop is a helper method to define mock.On call
+//   - ctx
+//   - key
+//   - left
+func (_e *mockapp_Expecter) Pop(ctx interface{}, key interface{}, left interface{}) *mockapp_Pop_Call {
+	return &mockapp_Pop_Call{Call: _e.mock.On("Pop", ctx, key, left)}
+}
+
+func (_c *mockapp_Pop_Call) Run(run func(ctx context.Context, key domain.Key, left bool)) *mockapp_Pop_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.Key), args[2].(bool))
+	})
+	return _c
+}
+
+func (_c *mockapp_Pop_Call) Return(value domain.Value, err error) *mockapp_Pop_Call {
+	_c.Call.Return(value, err)
+	return _c
+}
+
+func (_c *mockapp_Pop_Call) RunAndReturn(run func(ctx context.Context, key domain.Key, left bool) (domain.Value, error)) *mockapp_Pop_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Push provides a mock function for the type mockapp
+func (_mock *mockapp) Push(ctx context.Context, key domain.Key, values []domain.Value, left bool) (int, error) {
+	ret := _mock.Called(ctx, key, values, left)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Push")
+	}
+
+	var r0 int
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key, []domain.Value, bool) (int, error)); ok {
+		return returnFunc(ctx, key, values, left)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key, []domain.Value, bool) int); ok {
+		r0 = returnFunc(ctx, key, values, left)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, domain.Key, []domain.Value, bool) error); ok {
+		r1 = returnFunc(ctx, key, values, left)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// mockapp_Push_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Push'
+type mockapp_Push_Call struct {
+	*mock.Call
+}
+
+// Push is a helper method to define mock.On call
+//   - ctx
+//   - key
+//   - values
+//   - left
+func (_e *mockapp_Expecter) Push(ctx interface{}, key interface{}, values interface{}, left interface{}) *mockapp_Push_Call {
+	return &mockapp_Push_Call{Call: _e.mock.On("Push", ctx, key, values, left)}
+}
+
+func (_c *mockapp_Push_Call) Run(run func(ctx context.Context, key domain.Key, values []domain.Value, left bool)) *mockapp_Push_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.Key), args[2].([]domain.Value), args[3].(bool))
+	})
+	return _c
+}
+
+func (_c *mockapp_Push_Call) Return(int1 int, err error) *mockapp_Push_Call {
+	_c.Call.Return(int1, err)
+	return _c
+}
+
+func (_c *mockapp_Push_Call) RunAndReturn(run func(ctx context.Context, key domain.Key, values []domain.Value, left bool) (int, error)) *mockapp_Push_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Restore provides a mock function for the type mockapp
+func (_mock *mockapp) Restore(ctx context.Context, entry domain.Entry) error {
+	ret := _mock.Called(ctx, entry)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Restore")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Entry) error); ok {
+		r0 = returnFunc(ctx, entry)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// mockapp_Restore_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Restore'
+type mockapp_Restore_Call struct {
+	*mock.Call
+}
+
+// Restore is a helper method to define mock.On call
+//   - ctx
+//   - entry
+func (_e *mockapp_Expecter) Restore(ctx interface{}, entry interface{}) *mockapp_Restore_Call {
+	return &mockapp_Restore_Call{Call: _e.mock.On("Restore", ctx, entry)}
+}
+
+func (_c *mockapp_Restore_Call) Run(run func(ctx context.Context, entry domain.Entry)) *mockapp_Restore_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.Entry))
+	})
+	return _c
+}
+
+func (_c *mockapp_Restore_Call) Return(err error) *mockapp_Restore_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *mockapp_Restore_Call) RunAndReturn(run func(ctx context.Context, entry domain.Entry) error) *mockapp_Restore_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// SAdd provides a mock function for the type mockapp
+func (_mock *mockapp) SAdd(ctx context.Context, key domain.Key, members []domain.Value) (int, error) {
+	ret := _mock.Called(ctx, key, members)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SAdd")
+	}
+
+	var r0 int
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key, []domain.Value) (int, error)); ok {
+		return returnFunc(ctx, key, members)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key, []domain.Value) int); ok {
+		r0 = returnFunc(ctx, key, members)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, domain.Key, []domain.Value) error); ok {
+		r1 = returnFunc(ctx, key, members)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// mockapp_SAdd_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SAdd'
+type mockapp_SAdd_Call struct {
+	*mock.Call
+}
+
+// SAdd is a helper method to define mock.On call
+//   - ctx
+//   - key
+//   - members
+func (_e *mockapp_Expecter) SAdd(ctx interface{}, key interface{}, members interface{}) *mockapp_SAdd_Call {
+	return &mockapp_SAdd_Call{Call: _e.mock.On("SAdd", ctx, key, members)}
+}
+
+func (_c *mockapp_SAdd_Call) Run(run func(ctx context.Context, key domain.Key, members []domain.Value)) *mockapp_SAdd_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.Key), args[2].([]domain.Value))
+	})
+	return _c
+}
+
+func (_c *mockapp_SAdd_Call) Return(int1 int, err error) *mockapp_SAdd_Call {
+	_c.Call.Return(int1, err)
+	return _c
+}
+
+func (_c *mockapp_SAdd_Call) RunAndReturn(run func(ctx context.Context, key domain.Key, members []domain.Value) (int, error)) *mockapp_SAdd_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// SIsMember provides a mock function for the type mockapp
+func (_mock *mockapp) SIsMember(ctx context.Context, key domain.Key, member domain.Value) (bool, error) {
+	ret := _mock.Called(ctx, key, member)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SIsMember")
+	}
+
+	var r0 bool
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key, domain.Value) (bool, error)); ok {
+		return returnFunc(ctx, key, member)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key, domain.Value) bool); ok {
+		r0 = returnFunc(ctx, key, member)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, domain.Key, domain.Value) error); ok {
+		r1 = returnFunc(ctx, key, member)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// mockapp_SIsMember_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SIsMember'
+type mockapp_SIsMember_Call struct {
+	*mock.Call
+}
+
+// SIsMember is a helper method to define mock.On call
+//   - ctx
+//   - key
+//   - member
+func (_e *mockapp_Expecter) SIsMember(ctx interface{}, key interface{}, member interface{}) *mockapp_SIsMember_Call {
+	return &mockapp_SIsMember_Call{Call: _e.mock.On("SIsMember", ctx, key, member)}
+}
+
+func (_c *mockapp_SIsMember_Call) Run(run func(ctx context.Context, key domain.Key, member domain.Value)) *mockapp_SIsMember_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.Key), args[2].(domain.Value))
+	})
+	return _c
+}
+
+func (_c *mockapp_SIsMember_Call) Return(bool1 bool, err error) *mockapp_SIsMember_Call {
+	_c.Call.Return(bool1, err)
+	return _c
+}
+
+func (_c *mockapp_SIsMember_Call) RunAndReturn(run func(ctx context.Context, key domain.Key, member domain.Value) (bool, error)) *mockapp_SIsMember_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// SMembers provides a mock function for the type mockapp
+func (_mock *mockapp) SMembers(ctx context.Context, key domain.Key) ([]domain.Value, error) {
+	ret := _mock.Called(ctx, key)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SMembers")
+	}
+
+	var r0 []domain.Value
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key) ([]domain.Value, error)); ok {
+		return returnFunc(ctx, key)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key) []domain.Value); ok {
+		r0 = returnFunc(ctx, key)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]domain.Value)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, domain.Key) error); ok {
+		r1 = returnFunc(ctx, key)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// mockapp_SMembers_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SMembers'
+type mockapp_SMembers_Call struct {
+	*mock.Call
+}
+
+// SMembers is a helper method to define mock.On call
+//   - ctx
+//   - key
+func (_e *mockapp_Expecter) SMembers(ctx interface{}, key interface{}) *mockapp_SMembers_Call {
+	return &mockapp_SMembers_Call{Call: _e.mock.On("SMembers", ctx, key)}
+}
+
+func (_c *mockapp_SMembers_Call) Run(run func(ctx context.Context, key domain.Key)) *mockapp_SMembers_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.Key))
+	})
+	return _c
+}
+
+func (_c *mockapp_SMembers_Call) Return(valueMoqParams []domain.Value, err error) *mockapp_SMembers_Call {
+	_c.Call.Return(valueMoqParams, err)
+	return _c
+}
+
+func (_c *mockapp_SMembers_Call) RunAndReturn(run func(ctx context.Context, key domain.Key) ([]domain.Value, error)) *mockapp_SMembers_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// SRem provides a mock function for the type mockapp
+func (_mock *mockapp) SRem(ctx context.Context, key domain.Key, members []domain.Value) (int, error) {
+	ret := _mock.Called(ctx, key, members)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SRem")
 	}
 
-	var r0 error
-	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key) error); ok {
-		r0 = returnFunc(ctx, key)
+	var r0 int
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key, []domain.Value) (int, error)); ok {
+		return returnFunc(ctx, key, members)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key, []domain.Value) int); ok {
+		r0 = returnFunc(ctx, key, members)
 	} else {
-		r0 = ret.Error(0)
+		r0 = ret.Get(0).(int)
 	}
-	return r0
+	if returnFunc, ok := ret.Get(1).(func(context.Context, domain.Key, []domain.Value) error); ok {
+		r1 = returnFunc(ctx, key, members)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
 }
 
-// mockapp_Delete_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Delete'
-type mockapp_Delete_Call struct {
+// mockapp_SRem_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SRem'
+type mockapp_SRem_Call struct {
 	*mock.Call
 }
 
-// Delete is a helper method to define mock.On call
+// SRem is a helper method to define mock.On call
 //   - ctx
 //   - key
-func (_e *mockapp_Expecter) Delete(ctx interface{}, key interface{}) *mockapp_Delete_Call {
-	return &mockapp_Delete_Call{Call: _e.mock.On("Delete", ctx, key)}
+//   - members
+func (_e *mockapp_Expecter) SRem(ctx interface{}, key interface{}, members interface{}) *mockapp_SRem_Call {
+	return &mockapp_SRem_Call{Call: _e.mock.On("SRem", ctx, key, members)}
 }
 
-func (_c *mockapp_Delete_Call) Run(run func(ctx context.Context, key domain.Key)) *mockapp_Delete_Call {
+func (_c *mockapp_SRem_Call) Run(run func(ctx context.Context, key domain.Key, members []domain.Value)) *mockapp_SRem_Call {
 	_c.Call.Run(func(args mock.Arguments) {
-		run(args[0].(context.Context), args[1].(domain.Key))
+		run(args[0].(context.Context), args[1].(domain.Key), args[2].([]domain.Value))
 	})
 	return _c
 }
 
-func (_c *mockapp_Delete_Call) Return(err error) *mockapp_Delete_Call {
-	_c.Call.Return(err)
+func (_c *mockapp_SRem_Call) Return(int1 int, err error) *mockapp_SRem_Call {
+	_c.Call.Return(int1, err)
 	return _c
 }
 
-func (_c *mockapp_Delete_Call) RunAndReturn(run func(ctx context.Context, key domain.Key) error) *mockapp_Delete_Call {
+func (_c *mockapp_SRem_Call) RunAndReturn(run func(ctx context.Context, key domain.Key, members []domain.Value) (int, error)) *mockapp_SRem_Call {
 	_c.Call.Return(run)
 	return _c
 }
 
-// Get provides a mock function for the type mockapp
-func (_mock *mockapp) Get(ctx context.Context, key domain.Key) (*domain.Entry, error) {
-	ret := _mock.Called(ctx, key)
+// Scan provides a mock function for the type mockapp
+func (_mock *mockapp) Scan(ctx context.Context, start domain.Key, end domain.Key, limit int) ([]domain.Entry, error) {
+	ret := _mock.Called(ctx, start, end, limit)
 
 	if len(ret) == 0 {
-		panic("no return value specified for Get")
+		panic("no return value specified for Scan")
 	}
 
-	var r0 *domain.Entry
+	var r0 []domain.Entry
 	var r1 error
-	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key) (*domain.Entry, error)); ok {
-		return returnFunc(ctx, key)
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key, domain.Key, int) ([]domain.Entry, error)); ok {
+		return returnFunc(ctx, start, end, limit)
 	}
-	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key) *domain.Entry); ok {
-		r0 = returnFunc(ctx, key)
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key, domain.Key, int) []domain.Entry); ok {
+		r0 = returnFunc(ctx, start, end, limit)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).(*domain.Entry)
+			r0 = ret.Get(0).([]domain.Entry)
 		}
 	}
-	if returnFunc, ok := ret.Get(1).(func(context.Context, domain.Key) error); ok {
-		r1 = returnFunc(ctx, key)
+	if returnFunc, ok := ret.Get(1).(func(context.Context, domain.Key, domain.Key, int) error); ok {
+		r1 = returnFunc(ctx, start, end, limit)
 	} else {
 		r1 = ret.Error(1)
 	}
 	return r0, r1
 }
 
-// mockapp_Get_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Get'
-type mockapp_Get_Call struct {
+// mockapp_Scan_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Scan'
+type mockapp_Scan_Call struct {
 	*mock.Call
 }
 
-// Get is a helper method to define mock.On call
+// Scan is a helper method to define mock.On call
 //   - ctx
-//   - key
-func (_e *mockapp_Expecter) Get(ctx interface{}, key interface{}) *mockapp_Get_Call {
-	return &mockapp_Get_Call{Call: _e.mock.On("Get", ctx, key)}
+//   - start
+//   - end
+//   - limit
+func (_e *mockapp_Expecter) Scan(ctx interface{}, start interface{}, end interface{}, limit interface{}) *mockapp_Scan_Call {
+	return &mockapp_Scan_Call{Call: _e.mock.On("Scan", ctx, start, end, limit)}
 }
 
-func (_c *mockapp_Get_Call) Run(run func(ctx context.Context, key domain.Key)) *mockapp_Get_Call {
+func (_c *mockapp_Scan_Call) Run(run func(ctx context.Context, start domain.Key, end domain.Key, limit int)) *mockapp_Scan_Call {
 	_c.Call.Run(func(args mock.Arguments) {
-		run(args[0].(context.Context), args[1].(domain.Key))
+		run(args[0].(context.Context), args[1].(domain.Key), args[2].(domain.Key), args[3].(int))
 	})
 	return _c
 }
 
-func (_c *mockapp_Get_Call) Return(entry *domain.Entry, err error) *mockapp_Get_Call {
-	_c.Call.Return(entry, err)
+func (_c *mockapp_Scan_Call) Return(entryMoqParams []domain.Entry, err error) *mockapp_Scan_Call {
+	_c.Call.Return(entryMoqParams, err)
 	return _c
 }
 
-func (_c *mockapp_Get_Call) RunAndReturn(run func(ctx context.Context, key domain.Key) (*domain.Entry, error)) *mockapp_Get_Call {
+func (_c *mockapp_Scan_Call) RunAndReturn(run func(ctx context.Context, start domain.Key, end domain.Key, limit int) ([]domain.Entry, error)) *mockapp_Scan_Call {
 	_c.Call.Return(run)
 	return _c
 }
@@ -188,6 +1416,273 @@ func (_c *mockapp_Set_Call) RunAndReturn(run func(ctx context.Context, key domai
 	return _c
 }
 
+// SetEx provides a mock function for the type mockapp
+func (_mock *mockapp) SetEx(ctx context.Context, key domain.Key, value domain.Value, deadline time.Time) error {
+	ret := _mock.Called(ctx, key, value, deadline)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetEx")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key, domain.Value, time.Time) error); ok {
+		r0 = returnFunc(ctx, key, value, deadline)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// mockapp_SetEx_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SetEx'
+type mockapp_SetEx_Call struct {
+	*mock.Call
+}
+
+// SetEx is a helper method to define mock.On call
+//   - ctx
+//   - key
+//   - value
+//   - deadline
+func (_e *mockapp_Expecter) SetEx(ctx interface{}, key interface{}, value interface{}, deadline interface{}) *mockapp_SetEx_Call {
+	return &mockapp_SetEx_Call{Call: _e.mock.On("SetEx", ctx, key, value, deadline)}
+}
+
+func (_c *mockapp_SetEx_Call) Run(run func(ctx context.Context, key domain.Key, value domain.Value, deadline time.Time)) *mockapp_SetEx_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.Key), args[2].(domain.Value), args[3].(time.Time))
+	})
+	return _c
+}
+
+func (_c *mockapp_SetEx_Call) Return(err error) *mockapp_SetEx_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *mockapp_SetEx_Call) RunAndReturn(run func(ctx context.Context, key domain.Key, value domain.Value, deadline time.Time) error) *mockapp_SetEx_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// SetNX provides a mock function for the type mockapp
+func (_mock *mockapp) SetNX(ctx context.Context, key domain.Key, value domain.Value) error {
+	ret := _mock.Called(ctx, key, value)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetNX")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key, domain.Value) error); ok {
+		r0 = returnFunc(ctx, key, value)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// mockapp_SetNX_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SetNX'
+type mockapp_SetNX_Call struct {
+	*mock.Call
+}
+
+// SetNX is a helper method to define mock.On call
+//   - ctx
+//   - key
+//   - value
+func (_e *mockapp_Expecter) SetNX(ctx interface{}, key interface{}, value interface{}) *mockapp_SetNX_Call {
+	return &mockapp_SetNX_Call{Call: _e.mock.On("SetNX", ctx, key, value)}
+}
+
+func (_c *mockapp_SetNX_Call) Run(run func(ctx context.Context, key domain.Key, value domain.Value)) *mockapp_SetNX_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.Key), args[2].(domain.Value))
+	})
+	return _c
+}
+
+func (_c *mockapp_SetNX_Call) Return(err error) *mockapp_SetNX_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *mockapp_SetNX_Call) RunAndReturn(run func(ctx context.Context, key domain.Key, value domain.Value) error) *mockapp_SetNX_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// SetXX provides a mock function for the type mockapp
+func (_mock *mockapp) SetXX(ctx context.Context, key domain.Key, value domain.Value) error {
+	ret := _mock.Called(ctx, key, value)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetXX")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key, domain.Value) error); ok {
+		r0 = returnFunc(ctx, key, value)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// mockapp_SetXX_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SetXX'
+type mockapp_SetXX_Call struct {
+	*mock.Call
+}
+
+// SetXX is a helper method to define mock.On call
+//   - ctx
+//   - key
+//   - value
+func (_e *mockapp_Expecter) SetXX(ctx interface{}, key interface{}, value interface{}) *mockapp_SetXX_Call {
+	return &mockapp_SetXX_Call{Call: _e.mock.On("SetXX", ctx, key, value)}
+}
+
+func (_c *mockapp_SetXX_Call) Run(run func(ctx context.Context, key domain.Key, value domain.Value)) *mockapp_SetXX_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.Key), args[2].(domain.Value))
+	})
+	return _c
+}
+
+func (_c *mockapp_SetXX_Call) Return(err error) *mockapp_SetXX_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *mockapp_SetXX_Call) RunAndReturn(run func(ctx context.Context, key domain.Key, value domain.Value) error) *mockapp_SetXX_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Snapshot provides a mock function for the type mockapp
+func (_mock *mockapp) Snapshot(ctx context.Context) error {
+	ret := _mock.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Snapshot")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context) error); ok {
+		r0 = returnFunc(ctx)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// mockapp_Snapshot_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Snapshot'
+type mockapp_Snapshot_Call struct {
+	*mock.Call
+}
+
+// Snapshot is a helper method to define mock.On call
+//   - ctx
+func (_e *mockapp_Expecter) Snapshot(ctx interface{}) *mockapp_Snapshot_Call {
+	return &mockapp_Snapshot_Call{Call: _e.mock.On("Snapshot", ctx)}
+}
+
+func (_c *mockapp_Snapshot_Call) Run(run func(ctx context.Context)) *mockapp_Snapshot_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context))
+	})
+	return _c
+}
+
+func (_c *mockapp_Snapshot_Call) Return(err error) *mockapp_Snapshot_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *mockapp_Snapshot_Call) RunAndReturn(run func(ctx context.Context) error) *mockapp_Snapshot_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Unwatch provides a mock function for the type mockapp
+func (_mock *mockapp) Unwatch(ctx context.Context) {
+	_mock.Called(ctx)
+	return
+}
+
+// mockapp_Unwatch_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Unwatch'
+type mockapp_Unwatch_Call struct {
+	*mock.Call
+}
+
+// Unwatch is a helper method to define mock.On call
+//   - ctx
+func (_e *mockapp_Expecter) Unwatch(ctx interface{}) *mockapp_Unwatch_Call {
+	return &mockapp_Unwatch_Call{Call: _e.mock.On("Unwatch", ctx)}
+}
+
+func (_c *mockapp_Unwatch_Call) Run(run func(ctx context.Context)) *mockapp_Unwatch_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context))
+	})
+	return _c
+}
+
+func (_c *mockapp_Unwatch_Call) Return() *mockapp_Unwatch_Call {
+	_c.Call.Return()
+	return _c
+}
+
+func (_c *mockapp_Unwatch_Call) RunAndReturn(run func(ctx context.Context)) *mockapp_Unwatch_Call {
+	_c.Run(run)
+	return _c
+}
+
+// Watch provides a mock function for the type mockapp
+func (_mock *mockapp) Watch(ctx context.Context, keys []domain.Key) error {
+	ret := _mock.Called(ctx, keys)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Watch")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, []domain.Key) error); ok {
+		r0 = returnFunc(ctx, keys)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// mockapp_Watch_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Watch'
+type mockapp_Watch_Call struct {
+	*mock.Call
+}
+
+// Watch is a helper method to define mock.On call
+//   - ctx
+//   - keys
+func (_e *mockapp_Expecter) Watch(ctx interface{}, keys interface{}) *mockapp_Watch_Call {
+	return &mockapp_Watch_Call{Call: _e.mock.On("Watch", ctx, keys)}
+}
+
+func (_c *mockapp_Watch_Call) Run(run func(ctx context.Context, keys []domain.Key)) *mockapp_Watch_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].([]domain.Key))
+	})
+	return _c
+}
+
+func (_c *mockapp_Watch_Call) Return(err error) *mockapp_Watch_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *mockapp_Watch_Call) RunAndReturn(run func(ctx context.Context, keys []domain.Key) error) *mockapp_Watch_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // newMockinterpr creates a new instance of mockinterpr. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func newMockinterpr(t interface {
@@ -216,24 +1711,22 @@ func (_m *mockinterpr) EXPECT() *mockinterpr_Expecter {
 }
 
 // Execute provides a mock function for the type mockinterpr
-func (_mock *mockinterpr) Execute(ctx context.Context, raw string) (*domain.Entry, error) {
+func (_mock *mockinterpr) Execute(ctx context.Context, raw string) (domain.Result, error) {
 	ret := _mock.Called(ctx, raw)
 
 	if len(ret) == 0 {
 		panic("no return value specified for Execute")
 	}
 
-	var r0 *domain.Entry
+	var r0 domain.Result
 	var r1 error
-	if returnFunc, ok := ret.Get(0).(func(context.Context, string) (*domain.Entry, error)); ok {
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) (domain.Result, error)); ok {
 		return returnFunc(ctx, raw)
 	}
-	if returnFunc, ok := ret.Get(0).(func(context.Context, string) *domain.Entry); ok {
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) domain.Result); ok {
 		r0 = returnFunc(ctx, raw)
 	} else {
-		if ret.Get(0) != nil {
-			r0 = ret.Get(0).(*domain.Entry)
-		}
+		r0 = ret.Get(0).(domain.Result)
 	}
 	if returnFunc, ok := ret.Get(1).(func(context.Context, string) error); ok {
 		r1 = returnFunc(ctx, raw)
@@ -262,12 +1755,12 @@ func (_c *mockinterpr_Execute_Call) Run(run func(ctx context.Context, raw string
 	return _c
 }
 
-func (_c *mockinterpr_Execute_Call) Return(entry *domain.Entry, err error) *mockinterpr_Execute_Call {
-	_c.Call.Return(entry, err)
+func (_c *mockinterpr_Execute_Call) Return(result domain.Result, err error) *mockinterpr_Execute_Call {
+	_c.Call.Return(result, err)
 	return _c
 }
 
-func (_c *mockinterpr_Execute_Call) RunAndReturn(run func(ctx context.Context, raw string) (*domain.Entry, error)) *mockinterpr_Execute_Call {
+func (_c *mockinterpr_Execute_Call) RunAndReturn(run func(ctx context.Context, raw string) (domain.Result, error)) *mockinterpr_Execute_Call {
 	_c.Call.Return(run)
 	return _c
 }
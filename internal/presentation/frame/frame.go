@@ -0,0 +1,249 @@
+// Package frame implements the binary protocol. A request is a frame holding
+// the arguments of a command and a response is a frame holding its result.
+// Every length is given up front, so nothing needs escaping and a frame can
+// take as many reads as it needs.
+//
+//	request  = count:uint32 { length:uint32 bytes }
+//	response = '+'                              OK
+//	         | '$' length:uint32 bytes          value
+//	         | ':' int64                        integer
+//	         | '_'                              nil
+//	         | '*' count:uint32 { response }    list
+//	         | '-' length:uint32 message        error
+//
+// Integers are big-endian.
+package frame
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/rdimidov/kvstore/internal/domain"
+)
+
+const (
+	okType      = '+'
+	valueType   = '$'
+	integerType = ':'
+	nilType     = '_'
+	listType    = '*'
+	errorType   = '-'
+)
+
+// maxDepth bounds the nesting of lists in a response.
+const maxDepth = 32
+
+var (
+	// ErrTooLarge is returned when a frame exceeds the size limit of the reader.
+	ErrTooLarge = errors.New("frame is too large")
+	// ErrMalformed is returned when a frame does not follow the protocol.
+	ErrMalformed = errors.New("malformed frame")
+)
+
+// Error is an error reply of the server.
+type Error string
+
+func (e Error) Error() string {
+	return string(e)
+}
+
+func AppendRequest(buf []byte, args []string) []byte {
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(args)))
+	for _, arg := range args {
+		buf = appendString(buf, arg)
+	}
+	return buf
+}
+
+// ReadRequest reads a request frame and returns its arguments. A frame of
+// more than limit bytes fails with ErrTooLarge before it is read in; zero
+// means no limit.
+func ReadRequest(r io.Reader, limit int) ([]string, error) {
+	d := newDecoder(r, limit)
+	count, err := d.uint32()
+	if err != nil {
+		return nil, err
+	}
+	// every argument takes at least its length
+	if d.budget >= 0 && 4*int(count) > d.budget {
+		return nil, ErrTooLarge
+	}
+
+	args := make([]string, 0, min(count, 1024))
+	for range count {
+		arg, err := d.string()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	return args, nil
+}
+
+func AppendResult(buf []byte, r domain.Result) []byte {
+	switch r.Kind {
+	case domain.ResultValue:
+		return appendString(append(buf, valueType), r.Value.String())
+	case domain.ResultInteger:
+		return binary.BigEndian.AppendUint64(append(buf, integerType), uint64(r.Integer))
+	case domain.ResultNil:
+		return append(buf, nilType)
+	case domain.ResultList:
+		buf = binary.BigEndian.AppendUint32(append(buf, listType), uint32(len(r.List)))
+		for _, item := range r.List {
+			buf = AppendResult(buf, item)
+		}
+		return buf
+	}
+	return append(buf, okType)
+}
+
+func AppendError(buf []byte, message string) []byte {
+	return appendString(append(buf, errorType), message)
+}
+
+// ReadResponse reads a response frame. An error reply is returned as an
+// Error. A frame of more than limit bytes is still read to its end, so the
+// stream can go on, but it is dropped and fails with ErrTooLarge; zero means
+// no limit.
+func ReadResponse(r io.Reader, limit int) (domain.Result, error) {
+	d := newDecoder(r, limit)
+	d.drain = true
+	result, err := d.result(0)
+	var reply Error
+	if d.over && (err == nil || errors.As(err, &reply)) {
+		return domain.Result{}, ErrTooLarge
+	}
+	return result, err
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(s)))
+	return append(buf, s...)
+}
+
+type decoder struct {
+	r io.Reader
+	// budget is how many more bytes the frame may take, or negative when
+	// there is no limit.
+	budget int
+	// drain has a frame over the budget read to its end rather than failed
+	// right away, and over tells that it was.
+	drain bool
+	over  bool
+	buf   [8]byte
+}
+
+func newDecoder(r io.Reader, limit int) *decoder {
+	if limit <= 0 {
+		limit = -1
+	}
+	return &decoder{r: r, budget: limit}
+}
+
+// reserve takes n bytes from the budget.
+func (d *decoder) reserve(n int) error {
+	if d.budget < 0 {
+		return nil
+	}
+	if n > d.budget {
+		if !d.drain {
+			return ErrTooLarge
+		}
+		d.over, d.budget = true, -1
+		return nil
+	}
+	d.budget -= n
+	return nil
+}
+
+func (d *decoder) read(n int) ([]byte, error) {
+	if err := d.reserve(n); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(d.r, d.buf[:n]); err != nil {
+		return nil, err
+	}
+	return d.buf[:n], nil
+}
+
+func (d *decoder) uint32() (uint32, error) {
+	b, err := d.read(4)
+	if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint32(b), nil
+}
+
+func (d *decoder) string() (string, error) {
+	n, err := d.uint32()
+	if err != nil {
+		return "", err
+	}
+	if err := d.reserve(int(n)); err != nil {
+		return "", err
+	}
+	if d.over {
+		_, err := io.CopyN(io.Discard, d.r, int64(n))
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return "", err
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(d.r, b); err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func (d *decoder) result(depth int) (domain.Result, error) {
+	b, err := d.read(1)
+	if err != nil {
+		return domain.Result{}, err
+	}
+
+	switch b[0] {
+	case okType:
+		return domain.OKResult(), nil
+	case nilType:
+		return domain.NilResult(), nil
+	case valueType:
+		s, err := d.string()
+		return domain.ValueResult(domain.Value(s)), err
+	case errorType:
+		s, err := d.string()
+		if err != nil {
+			return domain.Result{}, err
+		}
+		return domain.Result{}, Error(s)
+	case integerType:
+		b, err := d.read(8)
+		if err != nil {
+			return domain.Result{}, err
+		}
+		return domain.IntegerResult(int64(binary.BigEndian.Uint64(b))), nil
+	case listType:
+		if depth == maxDepth {
+			return domain.Result{}, fmt.Errorf("%w: lists nested too deep", ErrMalformed)
+		}
+		count, err := d.uint32()
+		if err != nil {
+			return domain.Result{}, err
+		}
+		items := make([]domain.Result, 0, min(count, 1024))
+		for range count {
+			item, err := d.result(depth + 1)
+			if err != nil {
+				return domain.Result{}, err
+			}
+			if !d.over {
+				items = append(items, item)
+			}
+		}
+		return domain.ListResult(items...), nil
+	}
+	return domain.Result{}, fmt.Errorf("%w: unknown type %q", ErrMalformed, b[0])
+}
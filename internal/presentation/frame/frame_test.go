@@ -0,0 +1,88 @@
+package frame
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"testing/iotest"
+
+	"github.com/rdimidov/kvstore/internal/domain"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequest_RoundTrip(t *testing.T) {
+	args := []string{"SET", "key with spaces", "\x00\xff\r\n", ""}
+	buf := AppendRequest(nil, args)
+
+	// a frame may arrive one byte at a time
+	got, err := ReadRequest(iotest.OneByteReader(bytes.NewReader(buf)), 0)
+	require.NoError(t, err)
+	require.Equal(t, args, got)
+
+	got, err = ReadRequest(bytes.NewReader(buf), len(buf))
+	require.NoError(t, err)
+	require.Equal(t, args, got)
+}
+
+func TestReadRequest_Limit(t *testing.T) {
+	buf := AppendRequest(nil, []string{"SET", "foo", string(make([]byte, 1000))})
+	_, err := ReadRequest(bytes.NewReader(buf), 100)
+	require.ErrorIs(t, err, ErrTooLarge)
+
+	// the argument count alone may give a frame away as too large
+	_, err = ReadRequest(bytes.NewReader([]byte{0xff, 0xff, 0xff, 0xff}), 100)
+	require.ErrorIs(t, err, ErrTooLarge)
+}
+
+func TestReadRequest_Truncated(t *testing.T) {
+	buf := AppendRequest(nil, []string{"GET", "foo"})
+	_, err := ReadRequest(bytes.NewReader(buf[:len(buf)-1]), 0)
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+func TestResponse_RoundTrip(t *testing.T) {
+	results := []domain.Result{
+		domain.OKResult(),
+		domain.ValueResult("a b\x00"),
+		domain.IntegerResult(-42),
+		domain.NilResult(),
+		domain.ListResult(
+			domain.ValueResult("x"),
+			domain.NilResult(),
+			domain.ListResult(domain.IntegerResult(1)),
+		),
+		domain.ListResult(),
+	}
+	for _, want := range results {
+		got, err := ReadResponse(bytes.NewReader(AppendResult(nil, want)), 0)
+		require.NoError(t, err)
+		if want.Kind == domain.ResultList && len(want.List) == 0 {
+			require.Empty(t, got.List)
+			continue
+		}
+		require.Equal(t, want, got)
+	}
+}
+
+func TestReadResponse_LimitDrainsFrame(t *testing.T) {
+	large := domain.ListResult(domain.ValueResult(domain.Value(make([]byte, 1000))), domain.IntegerResult(1))
+	buf := AppendResult(nil, large)
+	buf = AppendResult(buf, domain.ValueResult("next"))
+	r := bytes.NewReader(buf)
+
+	_, err := ReadResponse(r, 100)
+	require.ErrorIs(t, err, ErrTooLarge)
+
+	// the stream goes on with the next frame
+	result, err := ReadResponse(r, 100)
+	require.NoError(t, err)
+	require.Equal(t, domain.ValueResult("next"), result)
+}
+
+func TestReadResponse_Error(t *testing.T) {
+	_, err := ReadResponse(bytes.NewReader(AppendError(nil, "ERR key not found")), 0)
+	require.Equal(t, Error("ERR key not found"), err)
+
+	_, err = ReadResponse(bytes.NewReader([]byte{'?'}), 0)
+	require.ErrorIs(t, err, ErrMalformed)
+}
@@ -0,0 +1,342 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/rdimidov/kvstore/internal/domain"
+)
+
+// namespaceParam selects the namespace of a request, the default one when
+// it is not given.
+const namespaceParam = "namespace"
+
+// authRealm is the realm of the basic authentication challenge.
+const authRealm = `Basic realm="kvstore"`
+
+// errBadRequest marks request bodies that could not be decoded.
+var errBadRequest = errors.New("bad request")
+
+// entryBody describes a key. Value is null for a missing key, ExpiresAt is
+// in unix milliseconds.
+type entryBody struct {
+	Key       string  `json:"key"`
+	Value     *string `json:"value"`
+	Version   uint64  `json:"version,omitempty"`
+	ExpiresAt int64   `json:"expires_at,omitempty"`
+}
+
+type putBody struct {
+	Value *string `json:"value"`
+	// TTL is the time to live in milliseconds, zero keeps the key forever.
+	TTL int64 `json:"ttl_ms"`
+}
+
+type keysBody struct {
+	Keys []string `json:"keys"`
+}
+
+type entriesBody struct {
+	Entries []entryBody `json:"entries"`
+}
+
+type deletedBody struct {
+	Deleted int `json:"deleted"`
+}
+
+type errorBody struct {
+	Error string `json:"error"`
+}
+
+func (s *Server) getKey(w http.ResponseWriter, r *http.Request) {
+	ctx, key, ok := s.keyRequest(w, r, domain.PermissionRead)
+	if !ok {
+		return
+	}
+
+	entry, err := s.app.Get(ctx, key)
+	if err == nil && entry.Type != domain.TypeString {
+		err = domain.ErrWrongType
+	}
+	if err != nil {
+		s.fail(w, err)
+		return
+	}
+	s.reply(w, http.StatusOK, newEntryBody(key, entry))
+}
+
+func (s *Server) putKey(w http.ResponseWriter, r *http.Request) {
+	ctx, key, ok := s.keyRequest(w, r, domain.PermissionWrite)
+	if !ok {
+		return
+	}
+
+	var body putBody
+	if !s.decode(w, r, &body) {
+		return
+	}
+	if body.Value == nil || body.TTL < 0 {
+		s.fail(w, domain.ErrValueIsNotValid)
+		return
+	}
+	value, err := domain.NewValue(*body.Value)
+	if err != nil {
+		s.fail(w, err)
+		return
+	}
+
+	if body.TTL > 0 {
+		err = s.app.SetEx(ctx, key, value, time.Now().Add(time.Duration(body.TTL)*time.Millisecond))
+	} else {
+		err = s.app.Set(ctx, key, value)
+	}
+	if err != nil {
+		s.fail(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) deleteKey(w http.ResponseWriter, r *http.Request) {
+	ctx, key, ok := s.keyRequest(w, r, domain.PermissionWrite)
+	if !ok {
+		return
+	}
+
+	if err := s.app.Delete(ctx, key); err != nil && !errors.Is(err, domain.ErrKeyNotFound) {
+		s.fail(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// batchGet replies with an entry for each of the keys in order; a key that
+// is missing or does not hold a string has a null value.
+func (s *Server) batchGet(w http.ResponseWriter, r *http.Request) {
+	ctx, keys, ok := s.keysRequest(w, r, domain.PermissionRead)
+	if !ok {
+		return
+	}
+
+	entries, err := s.app.MGet(ctx, keys)
+	if err != nil {
+		s.fail(w, err)
+		return
+	}
+	body := entriesBody{Entries: make([]entryBody, len(keys))}
+	for i, e := range entries {
+		if e != nil && e.Type != domain.TypeString {
+			e = nil
+		}
+		body.Entries[i] = newEntryBody(keys[i], e)
+	}
+	s.reply(w, http.StatusOK, body)
+}
+
+// batchSet stores all the entries at once, see services.Application.MSet.
+func (s *Server) batchSet(w http.ResponseWriter, r *http.Request) {
+	ctx, ok := s.request(w, r)
+	if !ok {
+		return
+	}
+
+	var body entriesBody
+	if !s.decode(w, r, &body) {
+		return
+	}
+	entries := make([]domain.Entry, 0, len(body.Entries))
+	keys := make([]domain.Key, 0, len(body.Entries))
+	for _, e := range body.Entries {
+		key, err := domain.NewKey(e.Key)
+		if err != nil {
+			s.fail(w, err)
+			return
+		}
+		keys = append(keys, key)
+		if e.Value == nil {
+			s.fail(w, domain.ErrValueIsNotValid)
+			return
+		}
+		value, err := domain.NewValue(*e.Value)
+		if err != nil {
+			s.fail(w, err)
+			return
+		}
+		entries = append(entries, domain.NewEntryFromKV(key, value))
+	}
+	if !s.authorize(ctx, w, domain.PermissionWrite, keys...) {
+		return
+	}
+
+	if err := s.app.MSet(ctx, entries); err != nil {
+		s.fail(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) batchDelete(w http.ResponseWriter, r *http.Request) {
+	ctx, keys, ok := s.keysRequest(w, r, domain.PermissionWrite)
+	if !ok {
+		return
+	}
+
+	n, err := s.app.MDel(ctx, keys)
+	if err != nil {
+		s.fail(w, err)
+		return
+	}
+	s.reply(w, http.StatusOK, deletedBody{Deleted: n})
+}
+
+// request returns the context of a request, carrying a session in the
+// namespace the request selected, authenticated as the user it names.
+func (s *Server) request(w http.ResponseWriter, r *http.Request) (context.Context, bool) {
+	session := &domain.Session{}
+	if s.users != nil {
+		name, password, ok := r.BasicAuth()
+		if !ok {
+			s.fail(w, domain.ErrAuthRequired)
+			return nil, false
+		}
+		user, err := s.users.Authenticate(name, password)
+		if err != nil {
+			s.fail(w, err)
+			return nil, false
+		}
+		session.User = user
+	}
+	if name := r.URL.Query().Get(namespaceParam); name != "" {
+		ns, err := domain.NewNamespace(name)
+		if err != nil {
+			s.fail(w, err)
+			return nil, false
+		}
+		session.Namespace = ns
+	}
+	return domain.WithSession(r.Context(), session), true
+}
+
+func (s *Server) keyRequest(w http.ResponseWriter, r *http.Request, permission domain.Permission) (context.Context, domain.Key, bool) {
+	ctx, ok := s.request(w, r)
+	if !ok {
+		return nil, "", false
+	}
+	key, err := domain.NewKey(r.PathValue("key"))
+	if err != nil {
+		s.fail(w, err)
+		return nil, "", false
+	}
+	if !s.authorize(ctx, w, permission, key) {
+		return nil, "", false
+	}
+	return ctx, key, true
+}
+
+func (s *Server) keysRequest(w http.ResponseWriter, r *http.Request, permission domain.Permission) (context.Context, []domain.Key, bool) {
+	ctx, ok := s.request(w, r)
+	if !ok {
+		return nil, nil, false
+	}
+
+	var body keysBody
+	if !s.decode(w, r, &body) {
+		return nil, nil, false
+	}
+	keys := make([]domain.Key, 0, len(body.Keys))
+	for _, k := range body.Keys {
+		key, err := domain.NewKey(k)
+		if err != nil {
+			s.fail(w, err)
+			return nil, nil, false
+		}
+		keys = append(keys, key)
+	}
+	if !s.authorize(ctx, w, permission, keys...) {
+		return nil, nil, false
+	}
+	return ctx, keys, true
+}
+
+// authorize checks that the user of the request may access keys with
+// permission, replying with an error when it may not.
+func (s *Server) authorize(ctx context.Context, w http.ResponseWriter, permission domain.Permission, keys ...domain.Key) bool {
+	user := domain.SessionFrom(ctx).User
+	if user == nil {
+		return true
+	}
+	if err := user.Authorize(permission, keys...); err != nil {
+		s.fail(w, err)
+		return false
+	}
+	return true
+}
+
+// decode reads the JSON body of the request into v, replying with an error
+// when it cannot.
+func (s *Server) decode(w http.ResponseWriter, r *http.Request, v any) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, s.maxBodySize))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			s.reply(w, http.StatusRequestEntityTooLarge, errorBody{Error: err.Error()})
+			return false
+		}
+		s.fail(w, fmt.Errorf("%w: %w", errBadRequest, err))
+		return false
+	}
+	return true
+}
+
+func (s *Server) reply(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		s.logger.Infow("failed to write response", "error", err)
+	}
+}
+
+// fail replies with the error and the status it maps to.
+func (s *Server) fail(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, domain.ErrKeyNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, errBadRequest),
+		errors.Is(err, domain.ErrKeyIsNotValid),
+		errors.Is(err, domain.ErrValueIsNotValid),
+		errors.Is(err, domain.ErrNamespaceIsNotValid):
+		status = http.StatusBadRequest
+	case errors.Is(err, domain.ErrAuthRequired), errors.Is(err, domain.ErrInvalidCredentials):
+		status = http.StatusUnauthorized
+		w.Header().Set("WWW-Authenticate", authRealm)
+	case errors.Is(err, domain.ErrPermissionDenied):
+		status = http.StatusForbidden
+	case errors.Is(err, domain.ErrWrongType):
+		status = http.StatusConflict
+	case errors.Is(err, domain.ErrOutOfMemory):
+		status = http.StatusInsufficientStorage
+	default:
+		s.logger.Errorw("request failed", "error", err)
+	}
+	s.reply(w, status, errorBody{Error: err.Error()})
+}
+
+func newEntryBody(key domain.Key, e *domain.Entry) entryBody {
+	body := entryBody{Key: key.String()}
+	if e == nil {
+		return body
+	}
+	value := e.Value.String()
+	body.Value = &value
+	body.Version = e.Version
+	if e.HasExpiry() {
+		body.ExpiresAt = e.ExpiresAt.UnixMilli()
+	}
+	return body
+}
@@ -0,0 +1,38 @@
+package httpserver
+
+import (
+	"time"
+
+	"github.com/rdimidov/kvstore/internal/domain"
+)
+
+type Option func(*Server)
+
+// WithMaxBodySize bounds the size of a request body.
+func WithMaxBodySize(size int64) Option {
+	return func(s *Server) {
+		s.maxBodySize = size
+	}
+}
+
+func WithTimeouts(read, write time.Duration) Option {
+	return func(s *Server) {
+		s.readTimeout = read
+		s.writeTimeout = write
+	}
+}
+
+// WithShutdownTimeout bounds how long shutdown waits for requests in progress.
+func WithShutdownTimeout(timeout time.Duration) Option {
+	return func(s *Server) {
+		s.shutdownTimeout = timeout
+	}
+}
+
+// WithUsers requires each request to authenticate as one of users with HTTP
+// basic authentication, and limits it to what the ACL of the user allows.
+func WithUsers(users *domain.Users) Option {
+	return func(s *Server) {
+		s.users = users
+	}
+}
@@ -0,0 +1,113 @@
+package httpserver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/rdimidov/kvstore/internal/domain"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultMaxBodySize     = 1 << 20
+	defaultShutdownTimeout = 5 * time.Second
+)
+
+// app defines the operations the gateway exposes.
+type app interface {
+	Get(ctx context.Context, key domain.Key) (*domain.Entry, error)
+	Set(ctx context.Context, key domain.Key, value domain.Value) error
+	SetEx(ctx context.Context, key domain.Key, value domain.Value, deadline time.Time) error
+	Delete(ctx context.Context, key domain.Key) error
+	MGet(ctx context.Context, keys []domain.Key) ([]*domain.Entry, error)
+	MSet(ctx context.Context, entries []domain.Entry) error
+	MDel(ctx context.Context, keys []domain.Key) (int, error)
+}
+
+// Server is an HTTP gateway serving a JSON API over the application, for
+// clients that cannot open raw TCP connections.
+type Server struct {
+	listener        net.Listener
+	server          *http.Server
+	app             app
+	logger          *zap.SugaredLogger
+	maxBodySize     int64
+	readTimeout     time.Duration
+	writeTimeout    time.Duration
+	shutdownTimeout time.Duration
+	// users is nil when requests need not authenticate.
+	users *domain.Users
+	// handlers counts the requests being handled, which may outlive the
+	// shutdown timeout.
+	handlers sync.WaitGroup
+}
+
+func New(address string, app app, logger *zap.SugaredLogger, options ...Option) (*Server, error) {
+	if app == nil {
+		return nil, errors.New("app is required")
+	}
+
+	ln, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		listener:        ln,
+		app:             app,
+		logger:          logger,
+		maxBodySize:     defaultMaxBodySize,
+		shutdownTimeout: defaultShutdownTimeout,
+	}
+	for _, opt := range options {
+		opt(s)
+	}
+
+	s.server = &http.Server{
+		Handler:      s.routes(),
+		ReadTimeout:  s.readTimeout,
+		WriteTimeout: s.writeTimeout,
+		ErrorLog:     zap.NewStdLog(logger.Desugar()),
+	}
+	return s, nil
+}
+
+// Start serves requests until ctx is done, then waits for the requests in
+// progress to finish. Connections still busy after the shutdown timeout are
+// closed, and Start returns once no request is handled anymore.
+func (s *Server) Start(ctx context.Context) {
+	go func() {
+		if err := s.server.Serve(s.listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Errorw("http server failed", "error", err)
+		}
+	}()
+	s.logger.Infof("http server listening on %v", s.listener.Addr())
+	<-ctx.Done()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+	defer cancel()
+	if err := s.server.Shutdown(shutdownCtx); err != nil {
+		s.logger.Infow("could not shut down http server correctly", "error", err)
+		_ = s.server.Close()
+	}
+	s.handlers.Wait()
+}
+
+func (s *Server) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/keys/{key...}", s.getKey)
+	mux.HandleFunc("PUT /v1/keys/{key...}", s.putKey)
+	mux.HandleFunc("DELETE /v1/keys/{key...}", s.deleteKey)
+	mux.HandleFunc("POST /v1/batch/get", s.batchGet)
+	mux.HandleFunc("POST /v1/batch/set", s.batchSet)
+	mux.HandleFunc("POST /v1/batch/delete", s.batchDelete)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.handlers.Add(1)
+		defer s.handlers.Done()
+		mux.ServeHTTP(w, r)
+	})
+}
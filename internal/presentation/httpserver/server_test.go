@@ -0,0 +1,313 @@
+package httpserver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rdimidov/kvstore/internal/domain"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func newTestServer(app app) *Server {
+	return &Server{app: app, logger: zap.NewNop().Sugar(), maxBodySize: 64}
+}
+
+func TestServer_Routes(t *testing.T) {
+	entry := &domain.Entry{Key: "foo", Value: "bar", Version: 7}
+	set := &domain.Entry{Key: "s", Type: domain.TypeSet, Items: []domain.Value{"a"}}
+
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		body       string
+		setup      func(app *mockapp)
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:   "get",
+			method: http.MethodGet,
+			target: "/v1/keys/foo",
+			setup: func(app *mockapp) {
+				app.On("Get", mock.Anything, domain.Key("foo")).Return(entry, nil)
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   `{"key":"foo","value":"bar","version":7}`,
+		},
+		{
+			name:   "get an escaped key",
+			method: http.MethodGet,
+			target: "/v1/keys/a%20b/c",
+			setup: func(app *mockapp) {
+				app.On("Get", mock.Anything, domain.Key("a b/c")).Return(&domain.Entry{Key: "a b/c", Value: "1"}, nil)
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   `{"key":"a b/c","value":"1"}`,
+		},
+		{
+			name:   "get a missing key",
+			method: http.MethodGet,
+			target: "/v1/keys/foo",
+			setup: func(app *mockapp) {
+				app.On("Get", mock.Anything, domain.Key("foo")).Return(nil, domain.ErrKeyNotFound)
+			},
+			wantStatus: http.StatusNotFound,
+			wantBody:   `{"error":"key not found"}`,
+		},
+		{
+			name:   "get a collection",
+			method: http.MethodGet,
+			target: "/v1/keys/s",
+			setup: func(app *mockapp) {
+				app.On("Get", mock.Anything, domain.Key("s")).Return(set, nil)
+			},
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name:       "get in an invalid namespace",
+			method:     http.MethodGet,
+			target:     "/v1/keys/foo?namespace=a-b",
+			setup:      func(app *mockapp) {},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "put",
+			method: http.MethodPut,
+			target: "/v1/keys/foo",
+			body:   `{"value":"bar"}`,
+			setup: func(app *mockapp) {
+				app.On("Set", mock.Anything, domain.Key("foo"), domain.Value("bar")).Return(nil)
+			},
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name:   "put with a ttl",
+			method: http.MethodPut,
+			target: "/v1/keys/foo",
+			body:   `{"value":"bar","ttl_ms":60000}`,
+			setup: func(app *mockapp) {
+				app.On("SetEx", mock.Anything, domain.Key("foo"), domain.Value("bar"), mock.MatchedBy(func(d time.Time) bool {
+					return d.After(time.Now().Add(50 * time.Second))
+				})).Return(nil)
+			},
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name:       "put without a value",
+			method:     http.MethodPut,
+			target:     "/v1/keys/foo",
+			body:       `{"ttl_ms":1}`,
+			setup:      func(app *mockapp) {},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "put malformed json",
+			method:     http.MethodPut,
+			target:     "/v1/keys/foo",
+			body:       `{"value":`,
+			setup:      func(app *mockapp) {},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "put a body over the limit",
+			method:     http.MethodPut,
+			target:     "/v1/keys/foo",
+			body:       `{"value":"` + strings.Repeat("a", 100) + `"}`,
+			setup:      func(app *mockapp) {},
+			wantStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:   "put when memory is full",
+			method: http.MethodPut,
+			target: "/v1/keys/foo",
+			body:   `{"value":"bar"}`,
+			setup: func(app *mockapp) {
+				app.On("Set", mock.Anything, domain.Key("foo"), domain.Value("bar")).Return(domain.ErrOutOfMemory)
+			},
+			wantStatus: http.StatusInsufficientStorage,
+		},
+		{
+			name:   "delete",
+			method: http.MethodDelete,
+			target: "/v1/keys/foo",
+			setup: func(app *mockapp) {
+				app.On("Delete", mock.Anything, domain.Key("foo")).Return(nil)
+			},
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name:   "batch get marks missing keys",
+			method: http.MethodPost,
+			target: "/v1/batch/get",
+			body:   `{"keys":["foo","zz","s"]}`,
+			setup: func(app *mockapp) {
+				app.On("MGet", mock.Anything, []domain.Key{"foo", "zz", "s"}).Return([]*domain.Entry{entry, nil, set}, nil)
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   `{"entries":[{"key":"foo","value":"bar","version":7},{"key":"zz","value":null},{"key":"s","value":null}]}`,
+		},
+		{
+			name:   "batch set",
+			method: http.MethodPost,
+			target: "/v1/batch/set",
+			body:   `{"entries":[{"key":"a","value":"1"}]}`,
+			setup: func(app *mockapp) {
+				app.On("MSet", mock.Anything, []domain.Entry{domain.NewEntryFromKV("a", "1")}).Return(nil)
+			},
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name:       "batch set with an invalid key",
+			method:     http.MethodPost,
+			target:     "/v1/batch/set",
+			body:       `{"entries":[{"key":"","value":"1"}]}`,
+			setup:      func(app *mockapp) {},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "batch delete",
+			method: http.MethodPost,
+			target: "/v1/batch/delete",
+			body:   `{"keys":["a","b"]}`,
+			setup: func(app *mockapp) {
+				app.On("MDel", mock.Anything, []domain.Key{"a", "b"}).Return(1, nil)
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   `{"deleted":1}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newMockapp(t)
+			tt.setup(app)
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			newTestServer(app).routes().ServeHTTP(rec, req)
+
+			require.Equal(t, tt.wantStatus, rec.Code, rec.Body.String())
+			if tt.wantBody != "" {
+				require.JSONEq(t, tt.wantBody, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestServer_Namespace(t *testing.T) {
+	app := newMockapp(t)
+	app.On("Get", mock.MatchedBy(func(ctx context.Context) bool {
+		return domain.NamespaceFrom(ctx) == "team"
+	}), domain.Key("foo")).Return(&domain.Entry{Key: "foo", Value: "bar"}, nil)
+
+	rec := httptest.NewRecorder()
+	newTestServer(app).routes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/keys/foo?namespace=team", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestServer_GracefulShutdown(t *testing.T) {
+	server, err := New("127.0.0.1:0", newMockapp(t), zap.NewNop().Sugar())
+	require.NoError(t, err)
+	addr := server.listener.Addr().String()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		server.Start(ctx)
+		close(done)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("server did not shut down")
+	}
+	_, err = http.Get("http://" + addr + "/v1/keys/foo")
+	require.Error(t, err)
+}
+
+func TestServer_ShutdownWaitsForRequests(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	app := newMockapp(t)
+	app.On("Get", mock.Anything, domain.Key("foo")).
+		Run(func(mock.Arguments) {
+			close(started)
+			<-release
+		}).
+		Return(nil, domain.ErrKeyNotFound).Once()
+
+	server, err := New("127.0.0.1:0", app, zap.NewNop().Sugar(), WithShutdownTimeout(50*time.Millisecond))
+	require.NoError(t, err)
+	addr := server.listener.Addr().String()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		server.Start(ctx)
+		close(done)
+	}()
+	go func() {
+		resp, err := http.Get("http://" + addr + "/v1/keys/foo")
+		if err == nil {
+			_ = resp.Body.Close()
+		}
+	}()
+	<-started
+	cancel()
+
+	// the request outlives the shutdown timeout, and Start waits for it
+	select {
+	case <-done:
+		t.Fatal("server stopped while a request was still handled")
+	case <-time.After(150 * time.Millisecond):
+	}
+	close(release)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("server did not shut down once the request was handled")
+	}
+}
+
+func TestServer_Auth(t *testing.T) {
+	hash, err := domain.HashPassword("secret")
+	require.NoError(t, err)
+	users, err := domain.NewUsers([]domain.User{
+		{Name: "reader", PasswordHash: hash, Permission: domain.PermissionRead, KeyPatterns: []string{"billing/*"}},
+	})
+	require.NoError(t, err)
+
+	app := newMockapp(t)
+	app.On("Get", mock.Anything, domain.Key("billing/a")).Return(&domain.Entry{Key: "billing/a", Value: "1"}, nil).Once()
+	server := newTestServer(app)
+	server.users = users
+
+	do := func(method, target, user, password string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(`{"value":"1"}`))
+		if user != "" {
+			req.SetBasicAuth(user, password)
+		}
+		rec := httptest.NewRecorder()
+		server.routes().ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do(http.MethodGet, "/v1/keys/billing/a", "", "")
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+	require.Equal(t, authRealm, rec.Header().Get("WWW-Authenticate"))
+	require.Equal(t, http.StatusUnauthorized, do(http.MethodGet, "/v1/keys/billing/a", "reader", "wrong").Code)
+
+	require.Equal(t, http.StatusOK, do(http.MethodGet, "/v1/keys/billing/a", "reader", "secret").Code)
+	require.Equal(t, http.StatusForbidden, do(http.MethodGet, "/v1/keys/ops/a", "reader", "secret").Code)
+	require.Equal(t, http.StatusForbidden, do(http.MethodPut, "/v1/keys/billing/a", "reader", "secret").Code)
+}
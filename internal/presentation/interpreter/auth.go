@@ -0,0 +1,138 @@
+package interpreter
+
+import (
+	"context"
+	"errors"
+
+	"github.com/rdimidov/kvstore/internal/domain"
+)
+
+const authCommand = "AUTH"
+
+const (
+	authArgsLen     = 3
+	authUserIdx     = 1
+	authPasswordIdx = 2
+)
+
+// ErrAuthDisabled is returned by AUTH when the server knows no users.
+var ErrAuthDisabled = errors.New("AUTH called without any user configured")
+
+// commandPermissions hold what each command needs. Commands missing from
+// it, such as PING or MULTI, touch no data and are left to any user.
+var commandPermissions = map[string]domain.Permission{
+	getCommand:       domain.PermissionRead,
+	getvCommand:      domain.PermissionRead,
+	ttlCommand:       domain.PermissionRead,
+	scanCommand:      domain.PermissionRead,
+	keysCommand:      domain.PermissionRead,
+	dbsizeCommand:    domain.PermissionRead,
+	hgetCommand:      domain.PermissionRead,
+	hgetallCommand:   domain.PermissionRead,
+	lrangeCommand:    domain.PermissionRead,
+	smembersCommand:  domain.PermissionRead,
+	sismemberCommand: domain.PermissionRead,
+	mgetCommand:      domain.PermissionRead,
+	watchCommand:     domain.PermissionRead,
+	setCommand:       domain.PermissionWrite,
+	casCommand:       domain.PermissionWrite,
+	setnxCommand:     domain.PermissionWrite,
+	setxxCommand:     domain.PermissionWrite,
+	incrCommand:      domain.PermissionWrite,
+	decrCommand:      domain.PermissionWrite,
+	incrbyCommand:    domain.PermissionWrite,
+	decrbyCommand:    domain.PermissionWrite,
+	delCommand:       domain.PermissionWrite,
+	expireCommand:    domain.PermissionWrite,
+	pexpireatCommand: domain.PermissionWrite,
+	persistCommand:   domain.PermissionWrite,
+	hsetCommand:      domain.PermissionWrite,
+	hdelCommand:      domain.PermissionWrite,
+	lpushCommand:     domain.PermissionWrite,
+	rpushCommand:     domain.PermissionWrite,
+	lpopCommand:      domain.PermissionWrite,
+	rpopCommand:      domain.PermissionWrite,
+	saddCommand:      domain.PermissionWrite,
+	sremCommand:      domain.PermissionWrite,
+	msetCommand:      domain.PermissionWrite,
+	mdelCommand:      domain.PermissionWrite,
+	snapshotCommand:  domain.PermissionAdmin,
+	flushdbCommand:   domain.PermissionAdmin,
+}
+
+// auth authenticates the session as the user of a name and password. A
+// failed attempt leaves the session as it was.
+func (i *Interpreter) auth(ctx context.Context, tokens []string) (domain.Result, error) {
+	if len(tokens) != authArgsLen {
+		return domain.Result{}, ErrInvalidCmd
+	}
+	if i.users == nil {
+		return domain.Result{}, ErrAuthDisabled
+	}
+	session := domain.SessionFrom(ctx)
+	if session == nil {
+		return domain.Result{}, domain.ErrNoSession
+	}
+
+	user, err := i.users.Authenticate(tokens[authUserIdx], tokens[authPasswordIdx])
+	if err != nil {
+		return domain.Result{}, err
+	}
+	session.User = user
+	return domain.OKResult(), nil
+}
+
+// authorize checks that the user of the session may run a command. A
+// session that presented the certificate of a user is authenticated as it
+// on its first command.
+func (i *Interpreter) authorize(ctx context.Context, tokens []string) error {
+	if i.users == nil {
+		return nil
+	}
+	session := domain.SessionFrom(ctx)
+	if session == nil {
+		return domain.ErrNoSession
+	}
+	if session.User == nil {
+		session.User = i.users.BySubject(session.Subject)
+	}
+	if session.User == nil {
+		return domain.ErrAuthRequired
+	}
+	return session.User.Authorize(commandPermissions[tokens[commandNameIdx]], commandKeys(tokens)...)
+}
+
+// commandKeys returns the keys a command accesses. SCAN and KEYS list
+// only the keys the user may access instead.
+func commandKeys(tokens []string) []domain.Key {
+	name := tokens[commandNameIdx]
+	args := tokens[commandKeyIdx:]
+	switch {
+	case name == mgetCommand || name == mdelCommand || name == watchCommand:
+		keys := make([]domain.Key, 0, len(args))
+		for _, arg := range args {
+			keys = append(keys, domain.Key(arg))
+		}
+		return keys
+	case name == msetCommand:
+		keys := make([]domain.Key, 0, (len(args)+1)/2)
+		for j := 0; j < len(args); j += 2 {
+			keys = append(keys, domain.Key(args[j]))
+		}
+		return keys
+	case name == scanCommand || name == keysCommand:
+		return nil
+	case commandPermissions[name] != domain.PermissionNone && len(args) > 0:
+		return []domain.Key{domain.Key(args[0])}
+	}
+	return nil
+}
+
+// canList reports whether SCAN and KEYS may list a key to the session.
+func (i *Interpreter) canList(ctx context.Context, key domain.Key) bool {
+	if i.users == nil {
+		return true
+	}
+	session := domain.SessionFrom(ctx)
+	return session != nil && session.User != nil && session.User.CanAccess(key)
+}
@@ -0,0 +1,73 @@
+package interpreter
+
+import (
+	"context"
+
+	"github.com/rdimidov/kvstore/internal/domain"
+)
+
+// executeBatch runs the commands working on several keys in one round trip.
+// MGET replies with a list holding the value of each key in order, or nil
+// for a key that is missing or does not hold a string.
+func (i *Interpreter) executeBatch(ctx context.Context, tokens []string) (domain.Result, error) {
+	args := tokens[commandKeyIdx:]
+
+	switch tokens[commandNameIdx] {
+	case mgetCommand:
+		keys, err := parseKeys(args)
+		if err != nil {
+			return domain.Result{}, err
+		}
+		entries, err := i.handler.MGet(ctx, keys)
+		if err != nil {
+			return domain.Result{}, err
+		}
+		list := make([]domain.Result, 0, len(entries))
+		for _, e := range entries {
+			if e == nil || e.Type != domain.TypeString {
+				list = append(list, domain.NilResult())
+				continue
+			}
+			list = append(list, domain.ValueResult(e.Value))
+		}
+		return domain.ListResult(list...), nil
+
+	case msetCommand:
+		if len(args)%2 != 0 {
+			return domain.Result{}, ErrInvalidCmd
+		}
+		entries := make([]domain.Entry, 0, len(args)/2)
+		for j := 0; j < len(args); j += 2 {
+			key, err := domain.NewKey(args[j])
+			if err != nil {
+				return domain.Result{}, err
+			}
+			value, err := domain.NewValue(args[j+1])
+			if err != nil {
+				return domain.Result{}, err
+			}
+			entries = append(entries, domain.NewEntryFromKV(key, value))
+		}
+		return domain.OKResult(), i.handler.MSet(ctx, entries)
+
+	case mdelCommand:
+		keys, err := parseKeys(args)
+		if err != nil {
+			return domain.Result{}, err
+		}
+		return integerResult(i.handler.MDel(ctx, keys))
+	}
+	return domain.Result{}, ErrInvalidCmd
+}
+
+func parseKeys(tokens []string) ([]domain.Key, error) {
+	keys := make([]domain.Key, 0, len(tokens))
+	for _, t := range tokens {
+		key, err := domain.NewKey(t)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
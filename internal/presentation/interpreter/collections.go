@@ -0,0 +1,192 @@
+package interpreter
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/rdimidov/kvstore/internal/domain"
+)
+
+// Expected number of arguments for collection commands
+const (
+	collectionArgsLen = 2
+	memberArgsLen     = 3
+	minItemsArgsLen   = 3
+	hsetMinArgsLen    = 4
+	lrangeArgsLen     = 4
+	itemsIdx          = 2
+	lrangeStartIdx    = 2
+	lrangeStopIdx     = 3
+)
+
+// executeHash runs the hash commands.
+func (i *Interpreter) executeHash(ctx context.Context, key domain.Key, tokens []string) (domain.Result, error) {
+	switch tokens[commandNameIdx] {
+	case hsetCommand:
+		if len(tokens) < hsetMinArgsLen || len(tokens)%2 != 0 {
+			return domain.Result{}, ErrInvalidCmd
+		}
+		pairs, err := parseValues(tokens[itemsIdx:])
+		if err != nil {
+			return domain.Result{}, err
+		}
+		fields := make([]domain.HashField, 0, len(pairs)/2)
+		for j := 0; j < len(pairs); j += 2 {
+			fields = append(fields, domain.HashField{Field: pairs[j], Value: pairs[j+1]})
+		}
+		return integerResult(i.handler.HSet(ctx, key, fields))
+
+	case hgetCommand:
+		if len(tokens) != memberArgsLen {
+			return domain.Result{}, ErrInvalidCmd
+		}
+		field, err := domain.NewValue(tokens[itemsIdx])
+		if err != nil {
+			return domain.Result{}, err
+		}
+		value, err := i.handler.HGet(ctx, key, field)
+		return valueResult(value, err)
+
+	case hdelCommand:
+		if len(tokens) < minItemsArgsLen {
+			return domain.Result{}, ErrInvalidCmd
+		}
+		fields, err := parseValues(tokens[itemsIdx:])
+		if err != nil {
+			return domain.Result{}, err
+		}
+		return integerResult(i.handler.HDel(ctx, key, fields))
+
+	default:
+		if len(tokens) != collectionArgsLen {
+			return domain.Result{}, ErrInvalidCmd
+		}
+		fields, err := i.handler.HGetAll(ctx, key)
+		if err != nil {
+			return domain.Result{}, err
+		}
+		flat := make([]domain.Result, 0, 2*len(fields))
+		for _, f := range fields {
+			flat = append(flat, domain.ValueResult(f.Field), domain.ValueResult(f.Value))
+		}
+		return domain.ListResult(flat...), nil
+	}
+}
+
+// executeList runs the list commands.
+func (i *Interpreter) executeList(ctx context.Context, key domain.Key, tokens []string) (domain.Result, error) {
+	switch name := tokens[commandNameIdx]; name {
+	case lpushCommand, rpushCommand:
+		if len(tokens) < minItemsArgsLen {
+			return domain.Result{}, ErrInvalidCmd
+		}
+		values, err := parseValues(tokens[itemsIdx:])
+		if err != nil {
+			return domain.Result{}, err
+		}
+		return integerResult(i.handler.Push(ctx, key, values, name == lpushCommand))
+
+	case lpopCommand, rpopCommand:
+		if len(tokens) != collectionArgsLen {
+			return domain.Result{}, ErrInvalidCmd
+		}
+		return valueResult(i.handler.Pop(ctx, key, name == lpopCommand))
+
+	default:
+		if len(tokens) != lrangeArgsLen {
+			return domain.Result{}, ErrInvalidCmd
+		}
+		start, err := strconv.Atoi(tokens[lrangeStartIdx])
+		if err != nil {
+			return domain.Result{}, ErrInvalidIndex
+		}
+		stop, err := strconv.Atoi(tokens[lrangeStopIdx])
+		if err != nil {
+			return domain.Result{}, ErrInvalidIndex
+		}
+		return valuesResult(i.handler.LRange(ctx, key, start, stop))
+	}
+}
+
+// executeMembers runs the set commands.
+func (i *Interpreter) executeMembers(ctx context.Context, key domain.Key, tokens []string) (domain.Result, error) {
+	switch name := tokens[commandNameIdx]; name {
+	case saddCommand, sremCommand:
+		if len(tokens) < minItemsArgsLen {
+			return domain.Result{}, ErrInvalidCmd
+		}
+		members, err := parseValues(tokens[itemsIdx:])
+		if err != nil {
+			return domain.Result{}, err
+		}
+		if name == saddCommand {
+			return integerResult(i.handler.SAdd(ctx, key, members))
+		}
+		return integerResult(i.handler.SRem(ctx, key, members))
+
+	case sismemberCommand:
+		if len(tokens) != memberArgsLen {
+			return domain.Result{}, ErrInvalidCmd
+		}
+		member, err := domain.NewValue(tokens[itemsIdx])
+		if err != nil {
+			return domain.Result{}, err
+		}
+		ok, err := i.handler.SIsMember(ctx, key, member)
+		if err != nil {
+			return domain.Result{}, err
+		}
+		if ok {
+			return domain.IntegerResult(1), nil
+		}
+		return domain.IntegerResult(0), nil
+
+	default:
+		if len(tokens) != collectionArgsLen {
+			return domain.Result{}, ErrInvalidCmd
+		}
+		return valuesResult(i.handler.SMembers(ctx, key))
+	}
+}
+
+func parseValues(tokens []string) ([]domain.Value, error) {
+	values := make([]domain.Value, 0, len(tokens))
+	for _, t := range tokens {
+		v, err := domain.NewValue(t)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+func integerResult(n int, err error) (domain.Result, error) {
+	if err != nil {
+		return domain.Result{}, err
+	}
+	return domain.IntegerResult(int64(n)), nil
+}
+
+// valueResult replies with the value, or with nil when there is none.
+func valueResult(v domain.Value, err error) (domain.Result, error) {
+	if errors.Is(err, domain.ErrKeyNotFound) {
+		return domain.NilResult(), nil
+	}
+	if err != nil {
+		return domain.Result{}, err
+	}
+	return domain.ValueResult(v), nil
+}
+
+func valuesResult(values []domain.Value, err error) (domain.Result, error) {
+	if err != nil {
+		return domain.Result{}, err
+	}
+	list := make([]domain.Result, 0, len(values))
+	for _, v := range values {
+		list = append(list, domain.ValueResult(v))
+	}
+	return domain.ListResult(list...), nil
+}
@@ -3,51 +3,210 @@ package interpreter
 import (
 	"context"
 	"errors"
+	"fmt"
+	"math"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/rdimidov/kvstore/internal/domain"
+	"github.com/rdimidov/kvstore/internal/presentation/frame"
 )
 
 // List of supported command names
 const (
-	getCommand = "GET"
-	setCommand = "SET"
-	delCommand = "DEL"
+	getCommand       = "GET"
+	setCommand       = "SET"
+	delCommand       = "DEL"
+	expireCommand    = "EXPIRE"
+	pexpireatCommand = "PEXPIREAT"
+	ttlCommand       = "TTL"
+	persistCommand   = "PERSIST"
+	scanCommand      = "SCAN"
+	keysCommand      = "KEYS"
+	snapshotCommand  = "SNAPSHOT"
+	getvCommand      = "GETV"
+	casCommand       = "CAS"
+	setnxCommand     = "SETNX"
+	setxxCommand     = "SETXX"
+	incrCommand      = "INCR"
+	decrCommand      = "DECR"
+	incrbyCommand    = "INCRBY"
+	decrbyCommand    = "DECRBY"
+	selectCommand    = "SELECT"
+	useCommand       = "USE"
+	flushdbCommand   = "FLUSHDB"
+	dbsizeCommand    = "DBSIZE"
+	hsetCommand      = "HSET"
+	hgetCommand      = "HGET"
+	hdelCommand      = "HDEL"
+	hgetallCommand   = "HGETALL"
+	lpushCommand     = "LPUSH"
+	rpushCommand     = "RPUSH"
+	lpopCommand      = "LPOP"
+	rpopCommand      = "RPOP"
+	lrangeCommand    = "LRANGE"
+	saddCommand      = "SADD"
+	sremCommand      = "SREM"
+	smembersCommand  = "SMEMBERS"
+	sismemberCommand = "SISMEMBER"
+	multiCommand     = "MULTI"
+	execCommand      = "EXEC"
+	discardCommand   = "DISCARD"
+	watchCommand     = "WATCH"
+	unwatchCommand   = "UNWATCH"
+	mgetCommand      = "MGET"
+	msetCommand      = "MSET"
+	mdelCommand      = "MDEL"
+	pingCommand      = "PING"
+	echoCommand      = "ECHO"
+)
+
+// queuedReply answers a command queued after MULTI.
+const queuedReply = "QUEUED"
+
+// pongReply answers PING without a message.
+const pongReply = "PONG"
+
+// Options accepted by the SET command
+const (
+	exOption   = "EX"
+	pxOption   = "PX"
+	pxatOption = "PXAT"
+	// verOption restores a version; the WAL and snapshots use it on replay,
+	// clients can not.
+	verOption = "VER"
+	// syncOption has the write synced to disk before it is acknowledged,
+	// whatever the fsync policy of the WAL. Only SET takes it; other writes
+	// get the same guarantee inside a transaction holding a SET ... SYNC, as
+	// the whole transaction is synced on EXEC.
+	syncOption = "SYNC"
+)
+
+// Options accepted by the SCAN command
+const (
+	limitOption      = "LIMIT"
+	defaultScanLimit = 100
 )
 
 // Expected number of arguments for each command
 const (
-	minArgsLen      = 2
-	getArgsLen      = 2
-	delArgsLen      = 2
-	setArgsLen      = 3
-	commandNameIdx  = 0
-	commandKeyIdx   = 1
-	commandValueIdx = 2
+	minArgsLen       = 2
+	serverArgsLen    = 1
+	selectArgsLen    = 2
+	echoArgsLen      = 2
+	getArgsLen       = 2
+	delArgsLen       = 2
+	setArgsLen       = 3
+	casArgsLen       = 4
+	incrArgsLen      = 2
+	incrbyArgsLen    = 3
+	expireArgsLen    = 3
+	ttlArgsLen       = 2
+	persistArgsLen   = 2
+	scanArgsLen      = 3
+	scanLimitArgsLen = 5
+	keysArgsLen      = 2
+	commandNameIdx   = 0
+	commandKeyIdx    = 1
+	namespaceIdx     = 1
+	commandValueIdx  = 2
+	setOptionIdx     = 3
+	casVersionIdx    = 2
+	casValueIdx      = 3
+	incrbyDeltaIdx   = 2
+	expireSecondsIdx = 2
+	scanEndIdx       = 2
+	scanOptionIdx    = 3
+	scanLimitIdx     = 4
+)
+
+// Replies of TTL for keys without a remaining time to live, as in Redis.
+const (
+	ttlNoKey    = -2
+	ttlNoExpiry = -1
 )
 
-// ErrInvalidCmd is returned when the input does not match any supported command format.
-var ErrInvalidCmd = errors.New("invalid command")
+var (
+	// ErrInvalidCmd is returned when the input does not match any supported command format.
+	ErrInvalidCmd = errors.New("invalid command")
+	// ErrInvalidExpire is returned when an expiration argument is not a valid number.
+	ErrInvalidExpire = errors.New("invalid expire time")
+	// ErrInvalidLimit is returned when a LIMIT argument is not a positive number.
+	ErrInvalidLimit = errors.New("invalid limit")
+	// ErrInvalidVersion is returned when a version argument is not a valid number.
+	ErrInvalidVersion = errors.New("invalid version")
+	// ErrInvalidIncrement is returned when an INCRBY or DECRBY argument is not a 64-bit integer.
+	ErrInvalidIncrement = errors.New("invalid increment")
+	// ErrNestedMulti is returned by MULTI inside a transaction.
+	ErrNestedMulti = errors.New("MULTI calls can not be nested")
+	// ErrNoMulti is returned by EXEC and DISCARD outside of a transaction.
+	ErrNoMulti = errors.New("EXEC and DISCARD require MULTI")
+	// ErrWatchInMulti is returned by WATCH inside a transaction.
+	ErrWatchInMulti = errors.New("WATCH inside MULTI is not allowed")
+	// ErrExecAborted wraps the failure of a queued command, after which none
+	// of the transaction is applied.
+	ErrExecAborted = errors.New("transaction discarded")
+	// ErrInvalidIndex is returned when a list index is not an integer.
+	ErrInvalidIndex = errors.New("invalid index")
+)
 
 // application defines the set of operations supported by the business logic layer.
 type handler interface {
 	Get(ctx context.Context, key domain.Key) (*domain.Entry, error)
 	Set(ctx context.Context, key domain.Key, value domain.Value) error
+	SetEx(ctx context.Context, key domain.Key, value domain.Value, deadline time.Time) error
 	Delete(ctx context.Context, key domain.Key) error
+	Expire(ctx context.Context, key domain.Key, deadline time.Time) error
+	Persist(ctx context.Context, key domain.Key) error
+	Scan(ctx context.Context, start, end domain.Key, limit int) ([]domain.Entry, error)
+	Snapshot(ctx context.Context) error
+	Restore(ctx context.Context, entry domain.Entry) error
+	CompareAndSet(ctx context.Context, key domain.Key, expected uint64, value domain.Value) error
+	SetNX(ctx context.Context, key domain.Key, value domain.Value) error
+	SetXX(ctx context.Context, key domain.Key, value domain.Value) error
+	IncrBy(ctx context.Context, key domain.Key, delta int64) (int64, error)
+	FlushDB(ctx context.Context) error
+	DBSize(ctx context.Context) (int, error)
+	HSet(ctx context.Context, key domain.Key, fields []domain.HashField) (int, error)
+	HGet(ctx context.Context, key domain.Key, field domain.Value) (domain.Value, error)
+	HDel(ctx context.Context, key domain.Key, fields []domain.Value) (int, error)
+	HGetAll(ctx context.Context, key domain.Key) ([]domain.HashField, error)
+	Push(ctx context.Context, key domain.Key, values []domain.Value, left bool) (int, error)
+	Pop(ctx context.Context, key domain.Key, left bool) (domain.Value, error)
+	LRange(ctx context.Context, key domain.Key, start, stop int) ([]domain.Value, error)
+	SAdd(ctx context.Context, key domain.Key, members []domain.Value) (int, error)
+	SRem(ctx context.Context, key domain.Key, members []domain.Value) (int, error)
+	SMembers(ctx context.Context, key domain.Key) ([]domain.Value, error)
+	SIsMember(ctx context.Context, key domain.Key, member domain.Value) (bool, error)
+	Watch(ctx context.Context, keys []domain.Key) error
+	Unwatch(ctx context.Context)
+	Exec(ctx context.Context, fn func(context.Context) error) error
+	MGet(ctx context.Context, keys []domain.Key) ([]*domain.Entry, error)
+	MSet(ctx context.Context, entries []domain.Entry) error
+	MDel(ctx context.Context, keys []domain.Key) (int, error)
 }
 
 // Interpreter handles parsing raw input strings and executing corresponding application commands.
 type Interpreter struct {
 	handler handler
+	// users is nil when clients need not authenticate.
+	users *domain.Users
+	// replay is set for the interpreter applying the WAL, see WithReplay.
+	replay bool
 }
 
 // New creates a new Interpreter with the given application implementation.
 // Returns an error if the application is nil.
-func New(handler handler) (*Interpreter, error) {
+func New(handler handler, options ...Option) (*Interpreter, error) {
 	if handler == nil {
 		return nil, errors.New("handler is nil")
 	}
-	return &Interpreter{handler: handler}, nil
+	i := &Interpreter{handler: handler}
+	for _, opt := range options {
+		opt(i)
+	}
+	return i, nil
 }
 
 // Execute parses the raw input string and invokes the matching application method.
@@ -55,68 +214,652 @@ func New(handler handler) (*Interpreter, error) {
 //
 //	GET <key>
 //	DEL <key>
-//	SET <key> <value>
-func (i *Interpreter) Execute(ctx context.Context, raw string) (*domain.Entry, error) {
-	tokens := strings.Fields(raw)
+//	SET <key> <value> [EX <seconds> | PX <milliseconds> | PXAT <unix-milliseconds>] [SYNC]
+//	GETV <key>
+//	CAS <key> <expected-version> <value>
+//	SETNX <key> <value>
+//	SETXX <key> <value>
+//	INCR <key>
+//	DECR <key>
+//	INCRBY <key> <increment>
+//	DECRBY <key> <decrement>
+//	EXPIRE <key> <seconds>
+//	PEXPIREAT <key> <unix-milliseconds>
+//	TTL <key>
+//	PERSIST <key>
+//	SCAN <start> <end> [LIMIT <n>]
+//	KEYS <prefix>
+//	SNAPSHOT
+//	SELECT <index>
+//	USE <namespace>
+//	FLUSHDB
+//	DBSIZE
+//	HSET <key> <field> <value> [<field> <value> ...]
+//	HGET <key> <field>
+//	HDEL <key> <field> [<field> ...]
+//	HGETALL <key>
+//	LPUSH <key> <value> [<value> ...]
+//	RPUSH <key> <value> [<value> ...]
+//	LPOP <key>
+//	RPOP <key>
+//	LRANGE <key> <start> <stop>
+//	SADD <key> <member> [<member> ...]
+//	SREM <key> <member> [<member> ...]
+//	SMEMBERS <key>
+//	SISMEMBER <key> <member>
+//	MULTI
+//	EXEC
+//	DISCARD
+//	WATCH <key> [<key> ...]
+//	UNWATCH
+//	MGET <key> [<key> ...]
+//	MSET <key> <value> [<key> <value> ...]
+//	MDEL <key> [<key> ...]
+//	PING [<message>]
+//	ECHO <message>
+//	AUTH <user> <password>
+//
+// Command and option names are case-insensitive.
+// Arguments holding spaces or other bytes are given as Go string literals,
+// see domain.ParseCommand. Keys are relative to the namespace selected in the
+// session carried by ctx. Once users are configured, a session runs no other
+// command before AUTH, and then only the ones the ACL of its user allows.
+func (i *Interpreter) Execute(ctx context.Context, raw string) (domain.Result, error) {
+	tokens, err := domain.ParseCommand(raw)
+	if err != nil {
+		return domain.Result{}, err
+	}
+	return i.ExecuteArgs(ctx, tokens)
+}
+
+// ExecuteArgs runs a command already split into its arguments, which may
+// hold any bytes. It supports the commands Execute does.
+func (i *Interpreter) ExecuteArgs(ctx context.Context, tokens []string) (domain.Result, error) {
+	if len(tokens) > 0 {
+		tokens = append([]string{strings.ToUpper(tokens[commandNameIdx])}, tokens[commandNameIdx+1:]...)
+
+		if tokens[commandNameIdx] == authCommand {
+			return i.auth(ctx, tokens)
+		}
+		if err := i.authorize(ctx, tokens); err != nil {
+			return domain.Result{}, err
+		}
+
+		switch tokens[commandNameIdx] {
+		case multiCommand, execCommand, discardCommand, watchCommand, unwatchCommand:
+			return i.executeTransaction(ctx, tokens)
+		}
+		if session := domain.SessionFrom(ctx); session != nil && session.InTransaction() {
+			session.Queued = append(session.Queued, domain.FormatCommand(tokens...))
+			return domain.ValueResult(queuedReply), nil
+		}
+
+		switch tokens[commandNameIdx] {
+		case snapshotCommand, selectCommand, useCommand, flushdbCommand, dbsizeCommand, pingCommand, echoCommand:
+			return i.executeServer(ctx, tokens)
+		}
+	}
 	if len(tokens) < minArgsLen {
-		return nil, ErrInvalidCmd
+		return domain.Result{}, ErrInvalidCmd
+	}
+
+	switch tokens[commandNameIdx] {
+	case mgetCommand, msetCommand, mdelCommand:
+		return i.executeBatch(ctx, tokens)
 	}
 
 	key, err := domain.NewKey(tokens[commandKeyIdx])
 	if err != nil {
-		return nil, err
+		return domain.Result{}, err
 	}
 
 	switch tokens[commandNameIdx] {
 	case getCommand:
 		if len(tokens) != getArgsLen {
-			return nil, ErrInvalidCmd
+			return domain.Result{}, ErrInvalidCmd
+		}
+		entry, err := i.getString(ctx, key)
+		if err != nil {
+			return domain.Result{}, err
+		}
+		return domain.ValueResult(entry.Value), nil
+
+	case getvCommand:
+		if len(tokens) != getArgsLen {
+			return domain.Result{}, ErrInvalidCmd
+		}
+		entry, err := i.getString(ctx, key)
+		if err != nil {
+			return domain.Result{}, err
+		}
+		return domain.ListResult(domain.ValueResult(entry.Value), domain.IntegerResult(int64(entry.Version))), nil
+
+	case casCommand:
+		if len(tokens) != casArgsLen {
+			return domain.Result{}, ErrInvalidCmd
+		}
+		expected, err := strconv.ParseUint(tokens[casVersionIdx], 10, 64)
+		if err != nil {
+			return domain.Result{}, ErrInvalidVersion
+		}
+		value, err := domain.NewValue(tokens[casValueIdx])
+		if err != nil {
+			return domain.Result{}, err
+		}
+		return conditionResult(i.handler.CompareAndSet(ctx, key, expected, value), domain.ErrVersionMismatch)
+
+	case setnxCommand, setxxCommand:
+		if len(tokens) != setArgsLen {
+			return domain.Result{}, ErrInvalidCmd
+		}
+		value, err := domain.NewValue(tokens[commandValueIdx])
+		if err != nil {
+			return domain.Result{}, err
 		}
-		return i.handler.Get(ctx, key)
+		if tokens[commandNameIdx] == setnxCommand {
+			return conditionResult(i.handler.SetNX(ctx, key, value), domain.ErrKeyExists)
+		}
+		return conditionResult(i.handler.SetXX(ctx, key, value), domain.ErrKeyNotFound)
+
+	case incrCommand, decrCommand, incrbyCommand, decrbyCommand:
+		return i.executeIncr(ctx, key, tokens)
 
 	case delCommand:
 		if len(tokens) != delArgsLen {
-			return nil, ErrInvalidCmd
+			return domain.Result{}, ErrInvalidCmd
 		}
-		return nil, i.handler.Delete(ctx, key)
+		return domain.OKResult(), i.handler.Delete(ctx, key)
 
 	case setCommand:
-		if len(tokens) != setArgsLen {
-			return nil, ErrInvalidCmd
+		return i.executeSet(ctx, key, tokens)
+
+	case expireCommand, pexpireatCommand:
+		if len(tokens) != expireArgsLen {
+			return domain.Result{}, ErrInvalidCmd
 		}
-		value, err := domain.NewValue(tokens[commandValueIdx])
+		deadline, err := parseDeadline(tokens[commandNameIdx], tokens[expireSecondsIdx], time.Now())
+		if err != nil {
+			return domain.Result{}, err
+		}
+		return existenceResult(i.handler.Expire(ctx, key, deadline))
+
+	case ttlCommand:
+		if len(tokens) != ttlArgsLen {
+			return domain.Result{}, ErrInvalidCmd
+		}
+		return i.executeTTL(ctx, key)
+
+	case persistCommand:
+		if len(tokens) != persistArgsLen {
+			return domain.Result{}, ErrInvalidCmd
+		}
+		entry, err := i.handler.Get(ctx, key)
+		if errors.Is(err, domain.ErrKeyNotFound) || (err == nil && !entry.HasExpiry()) {
+			return domain.IntegerResult(0), nil
+		}
+		if err != nil {
+			return domain.Result{}, err
+		}
+		return existenceResult(i.handler.Persist(ctx, key))
+
+	case scanCommand:
+		return i.executeScan(ctx, key, tokens)
+
+	case hsetCommand, hgetCommand, hdelCommand, hgetallCommand:
+		return i.executeHash(ctx, key, tokens)
+
+	case lpushCommand, rpushCommand, lpopCommand, rpopCommand, lrangeCommand:
+		return i.executeList(ctx, key, tokens)
+
+	case saddCommand, sremCommand, smembersCommand, sismemberCommand:
+		return i.executeMembers(ctx, key, tokens)
+
+	case keysCommand:
+		if len(tokens) != keysArgsLen {
+			return domain.Result{}, ErrInvalidCmd
+		}
+		entries, err := i.handler.Scan(ctx, key, prefixEnd(key), 0)
+		if err != nil {
+			return domain.Result{}, err
+		}
+		keys := make([]domain.Result, 0, len(entries))
+		for _, e := range entries {
+			if i.canList(ctx, e.Key) {
+				keys = append(keys, domain.ValueResult(domain.Value(e.Key)))
+			}
+		}
+		return domain.ListResult(keys...), nil
+	}
+
+	return domain.Result{}, ErrInvalidCmd
+}
+
+// executeServer runs the commands that do not take a key.
+func (i *Interpreter) executeServer(ctx context.Context, tokens []string) (domain.Result, error) {
+	name := tokens[commandNameIdx]
+	switch {
+	case len(tokens) == serverArgsLen && name == snapshotCommand:
+		return domain.OKResult(), i.handler.Snapshot(ctx)
+
+	case len(tokens) == serverArgsLen && name == flushdbCommand:
+		return domain.OKResult(), i.handler.FlushDB(ctx)
+
+	case len(tokens) == serverArgsLen && name == dbsizeCommand:
+		n, err := i.handler.DBSize(ctx)
 		if err != nil {
-			return nil, err
+			return domain.Result{}, err
 		}
-		return nil, i.handler.Set(ctx, key, value)
+		return domain.IntegerResult(int64(n)), nil
+
+	case len(tokens) == selectArgsLen && (name == selectCommand || name == useCommand):
+		return selectNamespace(ctx, name, tokens[namespaceIdx])
+
+	case len(tokens) == serverArgsLen && name == pingCommand:
+		return domain.ValueResult(pongReply), nil
+
+	case len(tokens) == echoArgsLen && (name == pingCommand || name == echoCommand):
+		return domain.ValueResult(domain.Value(tokens[commandKeyIdx])), nil
+	}
+	return domain.Result{}, ErrInvalidCmd
+}
+
+// executeTransaction runs the commands that start, end and guard the
+// transaction of the session. EXEC replies with the replies of the queued
+// commands, or with nil when a watched key changed. A missing key does not
+// abort the transaction; the command that met it replies with nil, or with 0
+// when it counts what it changed.
+func (i *Interpreter) executeTransaction(ctx context.Context, tokens []string) (domain.Result, error) {
+	session := domain.SessionFrom(ctx)
+	if session == nil {
+		return domain.Result{}, domain.ErrNoSession
 	}
 
-	return nil, ErrInvalidCmd
+	name := tokens[commandNameIdx]
+	switch {
+	case len(tokens) == serverArgsLen && name == multiCommand:
+		if session.InTransaction() {
+			return domain.Result{}, ErrNestedMulti
+		}
+		session.Queued = []string{}
+		return domain.OKResult(), nil
+
+	case len(tokens) == serverArgsLen && name == discardCommand:
+		if !session.InTransaction() {
+			return domain.Result{}, ErrNoMulti
+		}
+		session.Queued = nil
+		i.handler.Unwatch(ctx)
+		return domain.OKResult(), nil
+
+	case len(tokens) == serverArgsLen && name == execCommand:
+		if !session.InTransaction() {
+			return domain.Result{}, ErrNoMulti
+		}
+		queued := session.Queued
+		session.Queued = nil
+
+		results := make([]domain.Result, 0, len(queued))
+		err := i.handler.Exec(ctx, func(ctx context.Context) error {
+			for _, cmd := range queued {
+				result, err := i.Execute(ctx, cmd)
+				if errors.Is(err, domain.ErrKeyNotFound) {
+					name, _, _ := strings.Cut(cmd, " ")
+					result, err = missingResult(strings.ToUpper(name)), nil
+				}
+				if err != nil {
+					return fmt.Errorf("%w: %s: %w", ErrExecAborted, cmd, err)
+				}
+				results = append(results, result)
+			}
+			return nil
+		})
+		if errors.Is(err, domain.ErrWatchedKeyChanged) {
+			return domain.NilResult(), nil
+		}
+		if err != nil {
+			return domain.Result{}, err
+		}
+		return domain.ListResult(results...), nil
+
+	case len(tokens) >= minArgsLen && name == watchCommand:
+		if session.InTransaction() {
+			return domain.Result{}, ErrWatchInMulti
+		}
+		keys, err := parseKeys(tokens[commandKeyIdx:])
+		if err != nil {
+			return domain.Result{}, err
+		}
+		return domain.OKResult(), i.handler.Watch(ctx, keys)
+
+	case len(tokens) == serverArgsLen && name == unwatchCommand:
+		i.handler.Unwatch(ctx)
+		return domain.OKResult(), nil
+	}
+	return domain.Result{}, ErrInvalidCmd
+}
+
+// selectNamespace switches the session to a namespace: SELECT takes the
+// index of a numbered one, USE the name of any.
+func selectNamespace(ctx context.Context, command, arg string) (domain.Result, error) {
+	session := domain.SessionFrom(ctx)
+	if session == nil {
+		return domain.Result{}, domain.ErrNoSession
+	}
+
+	var ns domain.Namespace
+	if command == selectCommand {
+		index, err := strconv.ParseUint(arg, 10, 64)
+		if err != nil {
+			return domain.Result{}, domain.ErrNamespaceIsNotValid
+		}
+		ns = domain.Namespace(strconv.FormatUint(index, 10))
+	} else {
+		var err error
+		if ns, err = domain.NewNamespace(arg); err != nil {
+			return domain.Result{}, err
+		}
+	}
+
+	session.Namespace = ns
+	return domain.OKResult(), nil
+}
+
+// executeScan replies with a two element list: the cursor to pass as <start>
+// of the next page (nil when the range is exhausted) and the flattened
+// key/value pairs of the current page.
+func (i *Interpreter) executeScan(ctx context.Context, start domain.Key, tokens []string) (domain.Result, error) {
+	if len(tokens) != scanArgsLen && len(tokens) != scanLimitArgsLen {
+		return domain.Result{}, ErrInvalidCmd
+	}
+
+	end, err := domain.NewKey(tokens[scanEndIdx])
+	if err != nil {
+		return domain.Result{}, err
+	}
+
+	limit := defaultScanLimit
+	if len(tokens) == scanLimitArgsLen {
+		if !strings.EqualFold(tokens[scanOptionIdx], limitOption) {
+			return domain.Result{}, ErrInvalidCmd
+		}
+		limit, err = strconv.Atoi(tokens[scanLimitIdx])
+		if err != nil || limit <= 0 {
+			return domain.Result{}, ErrInvalidLimit
+		}
+	}
+
+	// one extra entry tells whether there is a next page and where it starts
+	entries, err := i.handler.Scan(ctx, start, end, limit+1)
+	if err != nil {
+		return domain.Result{}, err
+	}
+
+	cursor := domain.NilResult()
+	if len(entries) > limit {
+		cursor = domain.ValueResult(domain.Value(entries[limit].Key))
+		entries = entries[:limit]
+	}
+
+	page := make([]domain.Result, 0, 2*len(entries))
+	for _, e := range entries {
+		if !i.canList(ctx, e.Key) {
+			continue
+		}
+		// collections are read with their own commands
+		value := domain.NilResult()
+		if e.Type == domain.TypeString {
+			value = domain.ValueResult(e.Value)
+		}
+		page = append(page, domain.ValueResult(domain.Value(e.Key)), value)
+	}
+	return domain.ListResult(cursor, domain.ListResult(page...)), nil
+}
+
+// executeSet accepts at most one expiration option, an optional VER on
+// replay and an optional SYNC, in any order.
+func (i *Interpreter) executeSet(ctx context.Context, key domain.Key, tokens []string) (domain.Result, error) {
+	if len(tokens) < setArgsLen {
+		return domain.Result{}, ErrInvalidCmd
+	}
+
+	value, err := domain.NewValue(tokens[commandValueIdx])
+	if err != nil {
+		return domain.Result{}, err
+	}
+
+	entry := domain.NewEntryFromKV(key, value)
+	now := time.Now()
+	var hasDeadline, hasVersion, hasSync bool
+	for j := setOptionIdx; j < len(tokens); j++ {
+		option := strings.ToUpper(tokens[j])
+		if option == syncOption && !hasSync {
+			hasSync = true
+			continue
+		}
+		if j+1 == len(tokens) {
+			return domain.Result{}, ErrInvalidCmd
+		}
+		j++
+		arg := tokens[j]
+		switch {
+		case option == verOption && !hasVersion && i.replay:
+			entry.Version, err = strconv.ParseUint(arg, 10, 64)
+			if err != nil || entry.Version == 0 {
+				return domain.Result{}, ErrInvalidVersion
+			}
+			hasVersion = true
+		case (option == exOption || option == pxOption || option == pxatOption) && !hasDeadline:
+			entry.ExpiresAt, err = parseDeadline(option, arg, now)
+			if err != nil {
+				return domain.Result{}, err
+			}
+			hasDeadline = true
+		default:
+			return domain.Result{}, ErrInvalidCmd
+		}
+	}
+	if hasSync {
+		ctx = domain.WithSync(ctx)
+	}
+
+	switch {
+	case hasVersion:
+		return domain.OKResult(), i.handler.Restore(ctx, entry)
+	case hasDeadline:
+		return domain.OKResult(), i.handler.SetEx(ctx, key, value, entry.ExpiresAt)
+	}
+	return domain.OKResult(), i.handler.Set(ctx, key, value)
+}
+
+func (i *Interpreter) executeIncr(ctx context.Context, key domain.Key, tokens []string) (domain.Result, error) {
+	var delta int64 = 1
+	switch tokens[commandNameIdx] {
+	case incrCommand, decrCommand:
+		if len(tokens) != incrArgsLen {
+			return domain.Result{}, ErrInvalidCmd
+		}
+	default:
+		if len(tokens) != incrbyArgsLen {
+			return domain.Result{}, ErrInvalidCmd
+		}
+		var err error
+		if delta, err = strconv.ParseInt(tokens[incrbyDeltaIdx], 10, 64); err != nil {
+			return domain.Result{}, ErrInvalidIncrement
+		}
+	}
+
+	if name := tokens[commandNameIdx]; name == decrCommand || name == decrbyCommand {
+		if delta == math.MinInt64 {
+			return domain.Result{}, domain.ErrOverflow
+		}
+		delta = -delta
+	}
+
+	n, err := i.handler.IncrBy(ctx, key, delta)
+	if err != nil {
+		return domain.Result{}, err
+	}
+	return domain.IntegerResult(n), nil
+}
+
+// getString returns the entry of a key that holds a string.
+func (i *Interpreter) getString(ctx context.Context, key domain.Key) (*domain.Entry, error) {
+	entry, err := i.handler.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if entry.Type != domain.TypeString {
+		return nil, domain.ErrWrongType
+	}
+	return entry, nil
+}
+
+func (i *Interpreter) executeTTL(ctx context.Context, key domain.Key) (domain.Result, error) {
+	entry, err := i.handler.Get(ctx, key)
+	if errors.Is(err, domain.ErrKeyNotFound) {
+		return domain.IntegerResult(ttlNoKey), nil
+	}
+	if err != nil {
+		return domain.Result{}, err
+	}
+	if !entry.HasExpiry() {
+		return domain.IntegerResult(ttlNoExpiry), nil
+	}
+
+	left := time.Until(entry.ExpiresAt)
+	return domain.IntegerResult(int64(left.Round(time.Second) / time.Second)), nil
+}
+
+// parseDeadline turns an expiration option and its argument into an absolute deadline.
+func parseDeadline(option, arg string, now time.Time) (time.Time, error) {
+	n, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil {
+		return time.Time{}, ErrInvalidExpire
+	}
+
+	switch option {
+	case expireCommand:
+		return now.Add(time.Duration(n) * time.Second), nil
+	case exOption, pxOption:
+		// unlike EXPIRE, SET refuses a TTL that is already over
+		if n <= 0 {
+			return time.Time{}, ErrInvalidExpire
+		}
+		unit := time.Second
+		if option == pxOption {
+			unit = time.Millisecond
+		}
+		return now.Add(time.Duration(n) * unit), nil
+	case pxatOption, pexpireatCommand:
+		return time.UnixMilli(n), nil
+	}
+	return time.Time{}, ErrInvalidCmd
+}
+
+// prefixEnd returns the smallest key greater than every key starting with
+// prefix, or an empty key when there is no such bound.
+func prefixEnd(prefix domain.Key) domain.Key {
+	end := []byte(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return domain.Key(end[:i+1])
+		}
+	}
+	return ""
+}
+
+// countingCommands reply with how many keys or items they changed, which is
+// none when the key is missing.
+var countingCommands = map[string]bool{
+	delCommand:       true,
+	mdelCommand:      true,
+	expireCommand:    true,
+	pexpireatCommand: true,
+	persistCommand:   true,
+	setxxCommand:     true,
+	hdelCommand:      true,
+	sremCommand:      true,
+	sismemberCommand: true,
+}
+
+// missingResult is the reply of a command that met a missing key where
+// clients expect a reply rather than an error: 0 for a command counting what
+// it changed, as in Redis, and nil for one reading a value.
+func missingResult(name string) domain.Result {
+	if countingCommands[name] {
+		return domain.IntegerResult(0)
+	}
+	return domain.NilResult()
+}
+
+// existenceResult maps the outcome of a command on a possibly missing key to 1 or 0.
+func existenceResult(err error) (domain.Result, error) {
+	return conditionResult(err, domain.ErrKeyNotFound)
+}
+
+// conditionResult maps the outcome of a conditional command to 1, or to 0
+// when it failed with unmet.
+func conditionResult(err, unmet error) (domain.Result, error) {
+	if errors.Is(err, unmet) {
+		return domain.IntegerResult(0), nil
+	}
+	if err != nil {
+		return domain.Result{}, err
+	}
+	return domain.IntegerResult(1), nil
 }
 
 type RawInterpreter struct {
 	Interpreter
 }
 
-func NewRaw(handler handler) (*RawInterpreter, error) {
+func NewRaw(handler handler, options ...Option) (*RawInterpreter, error) {
 	if handler == nil {
 		return nil, errors.New("application is nil")
 	}
-	return &RawInterpreter{
+	r := &RawInterpreter{
 		Interpreter: Interpreter{handler: handler},
-	}, nil
+	}
+	for _, opt := range options {
+		opt(&r.Interpreter)
+	}
+	return r, nil
 }
 
+// Execute runs a command line of the text protocol and returns its reply, a
+// single line: the result written by domain.FormatResult, or the error.
 func (r *RawInterpreter) Execute(ctx context.Context, data []byte) []byte {
 	raw := strings.TrimSpace(string(data))
 	result, err := r.Interpreter.Execute(ctx, raw)
 	if err != nil {
-		return []byte("ERR " + err.Error() + "\n")
+		return []byte(strings.ReplaceAll(errorReply(err), "\n", " ") + "\n")
 	}
 
-	if result == nil {
-		return []byte("OK\n")
+	return []byte(domain.FormatResult(result) + "\n")
+}
+
+// ExecuteArgs runs a command of the binary protocol and returns its
+// response frame.
+func (r *RawInterpreter) ExecuteArgs(ctx context.Context, args []string) []byte {
+	result, err := r.Interpreter.ExecuteArgs(ctx, args)
+	if err != nil {
+		return frame.AppendError(nil, errorReply(err))
 	}
+	return frame.AppendResult(nil, result)
+}
 
-	return []byte(result.Value.String() + "\n")
+// errorReply prefixes the error with its class, as Redis does.
+func errorReply(err error) string {
+	switch {
+	case errors.Is(err, domain.ErrWrongType):
+		return "WRONGTYPE " + err.Error()
+	case errors.Is(err, domain.ErrAuthRequired):
+		return "NOAUTH " + err.Error()
+	case errors.Is(err, domain.ErrInvalidCredentials):
+		return "WRONGPASS " + err.Error()
+	case errors.Is(err, domain.ErrPermissionDenied):
+		return "NOPERM " + err.Error()
+	}
+	return "ERR " + err.Error()
 }
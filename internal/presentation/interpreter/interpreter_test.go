@@ -1,10 +1,14 @@
 package interpreter
 
 import (
+	"bytes"
 	"context"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/rdimidov/kvstore/internal/domain"
+	"github.com/rdimidov/kvstore/internal/presentation/frame"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -14,13 +18,16 @@ func TestInterpreter_Execute(t *testing.T) {
 	key, _ := domain.NewKey("foo")
 	val, _ := domain.NewValue("bar")
 	entry := &domain.Entry{Key: key, Value: val}
+	expiring := &domain.Entry{Key: key, Value: val, ExpiresAt: time.Now().Add(time.Minute)}
+	deadline := time.UnixMilli(1700000000000)
 
 	tests := []struct {
-		name      string
-		input     string
-		setup     func(app *mockhandler)
-		wantEntry *domain.Entry
-		wantErr   error
+		name       string
+		input      string
+		setup      func(app *mockhandler)
+		replay     bool
+		wantResult domain.Result
+		wantErr    error
 	}{
 		{
 			name:  "GET success",
@@ -28,8 +35,8 @@ func TestInterpreter_Execute(t *testing.T) {
 			setup: func(app *mockhandler) {
 				app.On("Get", mock.Anything, key).Return(entry, nil)
 			},
-			wantEntry: entry,
-			wantErr:   nil,
+			wantResult: domain.ValueResult(val),
+			wantErr:    nil,
 		},
 		{
 			name:    "GET invalid args",
@@ -49,8 +56,8 @@ func TestInterpreter_Execute(t *testing.T) {
 			setup: func(app *mockhandler) {
 				app.On("Delete", mock.Anything, key).Return(nil)
 			},
-			wantEntry: nil,
-			wantErr:   nil,
+			wantResult: domain.OKResult(),
+			wantErr:    nil,
 		},
 		{
 			name:    "DEL invalid args",
@@ -64,8 +71,8 @@ func TestInterpreter_Execute(t *testing.T) {
 			setup: func(app *mockhandler) {
 				app.On("Set", mock.Anything, key, val).Return(nil)
 			},
-			wantEntry: nil,
-			wantErr:   nil,
+			wantResult: domain.OKResult(),
+			wantErr:    nil,
 		},
 		{
 			name:    "SET invalid args",
@@ -73,6 +80,499 @@ func TestInterpreter_Execute(t *testing.T) {
 			setup:   func(app *mockhandler) {},
 			wantErr: ErrInvalidCmd,
 		},
+		{
+			name:  "SET with EX",
+			input: "SET foo bar EX 30",
+			setup: func(app *mockhandler) {
+				app.On("SetEx", mock.Anything, key, val, mock.MatchedBy(func(d time.Time) bool {
+					left := time.Until(d)
+					return left > 29*time.Second && left <= 30*time.Second
+				})).Return(nil)
+			},
+			wantResult: domain.OKResult(),
+		},
+		{
+			name:  "SET with PXAT",
+			input: "SET foo bar PXAT 1700000000000",
+			setup: func(app *mockhandler) {
+				app.On("SetEx", mock.Anything, key, val, deadline).Return(nil)
+			},
+			wantResult: domain.OKResult(),
+		},
+		{
+			name:    "SET with non-positive EX",
+			input:   "SET foo bar EX 0",
+			setup:   func(app *mockhandler) {},
+			wantErr: ErrInvalidExpire,
+		},
+		{
+			name:    "SET with non-numeric PX",
+			input:   "SET foo bar PX soon",
+			setup:   func(app *mockhandler) {},
+			wantErr: ErrInvalidExpire,
+		},
+		{
+			name:    "SET with unknown option",
+			input:   "SET foo bar KEEP 1",
+			setup:   func(app *mockhandler) {},
+			wantErr: ErrInvalidCmd,
+		},
+		{
+			name:  "SET with PXAT and VER restores the entry on replay",
+			input: "SET foo bar PXAT 1700000000000 VER 42",
+			setup: func(app *mockhandler) {
+				app.On("Restore", mock.Anything, domain.Entry{Key: key, Value: val, ExpiresAt: deadline, Version: 42}).Return(nil)
+			},
+			replay:     true,
+			wantResult: domain.OKResult(),
+		},
+		{
+			name:    "SET with zero VER",
+			input:   "SET foo bar VER 0",
+			setup:   func(app *mockhandler) {},
+			replay:  true,
+			wantErr: ErrInvalidVersion,
+		},
+		{
+			name:    "SET with VER from a client",
+			input:   "SET foo bar VER 1",
+			setup:   func(app *mockhandler) {},
+			wantErr: ErrInvalidCmd,
+		},
+		{
+			name:    "SET with two expirations",
+			input:   "SET foo bar EX 10 PX 100",
+			setup:   func(app *mockhandler) {},
+			wantErr: ErrInvalidCmd,
+		},
+		{
+			name:  "SET with SYNC asks for a synced write",
+			input: "SET foo bar SYNC",
+			setup: func(app *mockhandler) {
+				app.On("Set", mock.MatchedBy(domain.SyncRequested), key, val).Return(nil)
+			},
+			wantResult: domain.OKResult(),
+		},
+		{
+			name:  "SET with SYNC among options",
+			input: "SET foo bar sync PXAT 1700000000000",
+			setup: func(app *mockhandler) {
+				app.On("SetEx", mock.MatchedBy(domain.SyncRequested), key, val, deadline).Return(nil)
+			},
+			wantResult: domain.OKResult(),
+		},
+		{
+			name:    "SET with SYNC twice",
+			input:   "SET foo bar SYNC SYNC",
+			setup:   func(app *mockhandler) {},
+			wantErr: ErrInvalidCmd,
+		},
+		{
+			name:    "SET with an option missing its argument",
+			input:   "SET foo bar SYNC EX",
+			setup:   func(app *mockhandler) {},
+			wantErr: ErrInvalidCmd,
+		},
+		{
+			name:  "GETV success",
+			input: "GETV foo",
+			setup: func(app *mockhandler) {
+				app.On("Get", mock.Anything, key).Return(&domain.Entry{Key: key, Value: val, Version: 7}, nil)
+			},
+			wantResult: domain.ListResult(domain.ValueResult(val), domain.IntegerResult(7)),
+		},
+		{
+			name:  "CAS success",
+			input: "CAS foo 7 bar",
+			setup: func(app *mockhandler) {
+				app.On("CompareAndSet", mock.Anything, key, uint64(7), val).Return(nil)
+			},
+			wantResult: domain.IntegerResult(1),
+		},
+		{
+			name:  "CAS version mismatch",
+			input: "CAS foo 6 bar",
+			setup: func(app *mockhandler) {
+				app.On("CompareAndSet", mock.Anything, key, uint64(6), val).Return(domain.ErrVersionMismatch)
+			},
+			wantResult: domain.IntegerResult(0),
+		},
+		{
+			name:    "CAS invalid version",
+			input:   "CAS foo -1 bar",
+			setup:   func(app *mockhandler) {},
+			wantErr: ErrInvalidVersion,
+		},
+		{
+			name:  "SETNX on existing key",
+			input: "SETNX foo bar",
+			setup: func(app *mockhandler) {
+				app.On("SetNX", mock.Anything, key, val).Return(domain.ErrKeyExists)
+			},
+			wantResult: domain.IntegerResult(0),
+		},
+		{
+			name:  "SETXX success",
+			input: "SETXX foo bar",
+			setup: func(app *mockhandler) {
+				app.On("SetXX", mock.Anything, key, val).Return(nil)
+			},
+			wantResult: domain.IntegerResult(1),
+		},
+		{
+			name:  "INCR",
+			input: "INCR foo",
+			setup: func(app *mockhandler) {
+				app.On("IncrBy", mock.Anything, key, int64(1)).Return(int64(3), nil)
+			},
+			wantResult: domain.IntegerResult(3),
+		},
+		{
+			name:  "DECRBY",
+			input: "DECRBY foo 5",
+			setup: func(app *mockhandler) {
+				app.On("IncrBy", mock.Anything, key, int64(-5)).Return(int64(-2), nil)
+			},
+			wantResult: domain.IntegerResult(-2),
+		},
+		{
+			name:  "INCRBY on non-integer value",
+			input: "INCRBY foo 2",
+			setup: func(app *mockhandler) {
+				app.On("IncrBy", mock.Anything, key, int64(2)).Return(int64(0), domain.ErrNotInteger)
+			},
+			wantErr: domain.ErrNotInteger,
+		},
+		{
+			name:    "INCRBY with invalid increment",
+			input:   "INCRBY foo 1.5",
+			setup:   func(app *mockhandler) {},
+			wantErr: ErrInvalidIncrement,
+		},
+		{
+			name:    "DECRBY of the smallest integer",
+			input:   "DECRBY foo -9223372036854775808",
+			setup:   func(app *mockhandler) {},
+			wantErr: domain.ErrOverflow,
+		},
+		{
+			name:  "EXPIRE success",
+			input: "EXPIRE foo 30",
+			setup: func(app *mockhandler) {
+				app.On("Expire", mock.Anything, key, mock.AnythingOfType("time.Time")).Return(nil)
+			},
+			wantResult: domain.IntegerResult(1),
+		},
+		{
+			name:  "EXPIRE missing key",
+			input: "EXPIRE foo 30",
+			setup: func(app *mockhandler) {
+				app.On("Expire", mock.Anything, key, mock.AnythingOfType("time.Time")).Return(domain.ErrKeyNotFound)
+			},
+			wantResult: domain.IntegerResult(0),
+		},
+		{
+			name:  "PEXPIREAT success",
+			input: "PEXPIREAT foo 1700000000000",
+			setup: func(app *mockhandler) {
+				app.On("Expire", mock.Anything, key, deadline).Return(nil)
+			},
+			wantResult: domain.IntegerResult(1),
+		},
+		{
+			name:  "TTL missing key",
+			input: "TTL foo",
+			setup: func(app *mockhandler) {
+				app.On("Get", mock.Anything, key).Return(nil, domain.ErrKeyNotFound)
+			},
+			wantResult: domain.IntegerResult(-2),
+		},
+		{
+			name:  "TTL without expiry",
+			input: "TTL foo",
+			setup: func(app *mockhandler) {
+				app.On("Get", mock.Anything, key).Return(entry, nil)
+			},
+			wantResult: domain.IntegerResult(-1),
+		},
+		{
+			name:  "TTL with expiry",
+			input: "TTL foo",
+			setup: func(app *mockhandler) {
+				app.On("Get", mock.Anything, key).Return(expiring, nil)
+			},
+			wantResult: domain.IntegerResult(60),
+		},
+		{
+			name:  "PERSIST success",
+			input: "PERSIST foo",
+			setup: func(app *mockhandler) {
+				app.On("Get", mock.Anything, key).Return(expiring, nil)
+				app.On("Persist", mock.Anything, key).Return(nil)
+			},
+			wantResult: domain.IntegerResult(1),
+		},
+		{
+			name:  "PERSIST without expiry",
+			input: "PERSIST foo",
+			setup: func(app *mockhandler) {
+				app.On("Get", mock.Anything, key).Return(entry, nil)
+			},
+			wantResult: domain.IntegerResult(0),
+		},
+		{
+			name:  "SCAN last page",
+			input: "SCAN a z",
+			setup: func(app *mockhandler) {
+				app.On("Scan", mock.Anything, domain.Key("a"), domain.Key("z"), 101).
+					Return([]domain.Entry{{Key: "b", Value: "1"}, {Key: "c", Value: "2"}}, nil)
+			},
+			wantResult: domain.ListResult(
+				domain.NilResult(),
+				domain.ListResult(
+					domain.ValueResult("b"), domain.ValueResult("1"),
+					domain.ValueResult("c"), domain.ValueResult("2"),
+				),
+			),
+		},
+		{
+			name:  "SCAN with LIMIT returns cursor",
+			input: "SCAN a z LIMIT 1",
+			setup: func(app *mockhandler) {
+				app.On("Scan", mock.Anything, domain.Key("a"), domain.Key("z"), 2).
+					Return([]domain.Entry{{Key: "b", Value: "1"}, {Key: "c", Value: "2"}}, nil)
+			},
+			wantResult: domain.ListResult(
+				domain.ValueResult("c"),
+				domain.ListResult(domain.ValueResult("b"), domain.ValueResult("1")),
+			),
+		},
+		{
+			name:    "SCAN invalid limit",
+			input:   "SCAN a z LIMIT 0",
+			setup:   func(app *mockhandler) {},
+			wantErr: ErrInvalidLimit,
+		},
+		{
+			name:    "SCAN unknown option",
+			input:   "SCAN a z COUNT 1",
+			setup:   func(app *mockhandler) {},
+			wantErr: ErrInvalidCmd,
+		},
+		{
+			name:  "KEYS by prefix",
+			input: "KEYS users/",
+			setup: func(app *mockhandler) {
+				app.On("Scan", mock.Anything, domain.Key("users/"), domain.Key("users0"), 0).
+					Return([]domain.Entry{{Key: "users/1"}, {Key: "users/2"}}, nil)
+			},
+			wantResult: domain.ListResult(domain.ValueResult("users/1"), domain.ValueResult("users/2")),
+		},
+		{
+			name:  "SNAPSHOT",
+			input: "SNAPSHOT",
+			setup: func(app *mockhandler) {
+				app.On("Snapshot", mock.Anything).Return(nil)
+			},
+			wantResult: domain.OKResult(),
+		},
+		{
+			name:    "SNAPSHOT with arguments",
+			input:   "SNAPSHOT now",
+			setup:   func(app *mockhandler) {},
+			wantErr: ErrInvalidCmd,
+		},
+		{
+			name:  "FLUSHDB",
+			input: "FLUSHDB",
+			setup: func(app *mockhandler) {
+				app.On("FlushDB", mock.Anything).Return(nil)
+			},
+			wantResult: domain.OKResult(),
+		},
+		{
+			name:  "DBSIZE",
+			input: "DBSIZE",
+			setup: func(app *mockhandler) {
+				app.On("DBSize", mock.Anything).Return(3, nil)
+			},
+			wantResult: domain.IntegerResult(3),
+		},
+		{
+			name:    "SELECT without a session",
+			input:   "SELECT 1",
+			setup:   func(app *mockhandler) {},
+			wantErr: domain.ErrNoSession,
+		},
+		{
+			name:  "GET on a hash",
+			input: "GET foo",
+			setup: func(app *mockhandler) {
+				app.On("Get", mock.Anything, key).Return(&domain.Entry{Key: key, Type: domain.TypeHash}, nil)
+			},
+			wantErr: domain.ErrWrongType,
+		},
+		{
+			name:  "HSET",
+			input: "HSET foo a 1 b 2",
+			setup: func(app *mockhandler) {
+				app.On("HSet", mock.Anything, key, []domain.HashField{{Field: "a", Value: "1"}, {Field: "b", Value: "2"}}).Return(1, nil)
+			},
+			wantResult: domain.IntegerResult(1),
+		},
+		{
+			name:    "HSET without a value",
+			input:   "HSET foo a 1 b",
+			setup:   func(app *mockhandler) {},
+			wantErr: ErrInvalidCmd,
+		},
+		{
+			name:  "HGET missing field",
+			input: "HGET foo a",
+			setup: func(app *mockhandler) {
+				app.On("HGet", mock.Anything, key, domain.Value("a")).Return(domain.Value(""), domain.ErrKeyNotFound)
+			},
+			wantResult: domain.NilResult(),
+		},
+		{
+			name:  "HGETALL",
+			input: "HGETALL foo",
+			setup: func(app *mockhandler) {
+				app.On("HGetAll", mock.Anything, key).Return([]domain.HashField{{Field: "a", Value: "1"}}, nil)
+			},
+			wantResult: domain.ListResult(domain.ValueResult("a"), domain.ValueResult("1")),
+		},
+		{
+			name:  "LPUSH",
+			input: "LPUSH foo a b",
+			setup: func(app *mockhandler) {
+				app.On("Push", mock.Anything, key, []domain.Value{"a", "b"}, true).Return(2, nil)
+			},
+			wantResult: domain.IntegerResult(2),
+		},
+		{
+			name:  "RPOP",
+			input: "RPOP foo",
+			setup: func(app *mockhandler) {
+				app.On("Pop", mock.Anything, key, false).Return(domain.Value("b"), nil)
+			},
+			wantResult: domain.ValueResult("b"),
+		},
+		{
+			name:  "LRANGE",
+			input: "LRANGE foo 0 -1",
+			setup: func(app *mockhandler) {
+				app.On("LRange", mock.Anything, key, 0, -1).Return([]domain.Value{"a", "b"}, nil)
+			},
+			wantResult: domain.ListResult(domain.ValueResult("a"), domain.ValueResult("b")),
+		},
+		{
+			name:    "LRANGE invalid index",
+			input:   "LRANGE foo 0 end",
+			setup:   func(app *mockhandler) {},
+			wantErr: ErrInvalidIndex,
+		},
+		{
+			name:  "SADD",
+			input: "SADD foo a b",
+			setup: func(app *mockhandler) {
+				app.On("SAdd", mock.Anything, key, []domain.Value{"a", "b"}).Return(1, nil)
+			},
+			wantResult: domain.IntegerResult(1),
+		},
+		{
+			name:  "SISMEMBER",
+			input: "SISMEMBER foo a",
+			setup: func(app *mockhandler) {
+				app.On("SIsMember", mock.Anything, key, domain.Value("a")).Return(true, nil)
+			},
+			wantResult: domain.IntegerResult(1),
+		},
+		{
+			name:  "SMEMBERS of a string",
+			input: "SMEMBERS foo",
+			setup: func(app *mockhandler) {
+				app.On("SMembers", mock.Anything, key).Return(nil, domain.ErrWrongType)
+			},
+			wantErr: domain.ErrWrongType,
+		},
+		{
+			name:  "MGET marks missing keys",
+			input: "MGET foo bar baz",
+			setup: func(app *mockhandler) {
+				app.On("MGet", mock.Anything, []domain.Key{"foo", "bar", "baz"}).Return([]*domain.Entry{
+					{Key: "foo", Value: "1"},
+					nil,
+					{Key: "baz", Type: domain.TypeSet, Items: []domain.Value{"a"}},
+				}, nil)
+			},
+			wantResult: domain.ListResult(domain.ValueResult("1"), domain.NilResult(), domain.NilResult()),
+		},
+		{
+			name:  "MSET",
+			input: "MSET foo 1 bar 2",
+			setup: func(app *mockhandler) {
+				app.On("MSet", mock.Anything, []domain.Entry{
+					domain.NewEntryFromKV("foo", "1"),
+					domain.NewEntryFromKV("bar", "2"),
+				}).Return(nil)
+			},
+			wantResult: domain.OKResult(),
+		},
+		{
+			name:    "MSET without a value",
+			input:   "MSET foo 1 bar",
+			setup:   func(app *mockhandler) {},
+			wantErr: ErrInvalidCmd,
+		},
+		{
+			name:  "MDEL",
+			input: "MDEL foo bar",
+			setup: func(app *mockhandler) {
+				app.On("MDel", mock.Anything, []domain.Key{"foo", "bar"}).Return(1, nil)
+			},
+			wantResult: domain.IntegerResult(1),
+		},
+		{
+			name:  "SET quoted arguments",
+			input: `SET "hello world" "a\nb"`,
+			setup: func(app *mockhandler) {
+				app.On("Set", mock.Anything, domain.Key("hello world"), domain.Value("a\nb")).Return(nil)
+			},
+			wantResult: domain.OKResult(),
+		},
+		{
+			name:    "SET unterminated quote",
+			input:   `SET foo "bar`,
+			setup:   func(app *mockhandler) {},
+			wantErr: domain.ErrInvalidQuoting,
+		},
+		{
+			name:    "Reserved key",
+			input:   `GET "\x00team\x00foo"`,
+			setup:   func(app *mockhandler) {},
+			wantErr: domain.ErrKeyIsNotValid,
+		},
+		{
+			name:  "lowercase command and option names",
+			input: "set foo bar px 1000",
+			setup: func(app *mockhandler) {
+				app.On("SetEx", mock.Anything, key, val, mock.Anything).Return(nil)
+			},
+			wantResult: domain.OKResult(),
+		},
+		{
+			name:       "PING",
+			input:      "PING",
+			setup:      func(app *mockhandler) {},
+			wantResult: domain.ValueResult("PONG"),
+		},
+		{
+			name:       "ECHO",
+			input:      `ECHO "hello world"`,
+			setup:      func(app *mockhandler) {},
+			wantResult: domain.ValueResult("hello world"),
+		},
 		{
 			name:    "Unknown command",
 			input:   "FOO foo",
@@ -87,17 +587,299 @@ func TestInterpreter_Execute(t *testing.T) {
 			appMock := newMockhandler(t)
 			tt.setup(appMock)
 
-			interp, err := New(appMock)
+			var options []Option
+			if tt.replay {
+				options = append(options, WithReplay())
+			}
+			interp, err := New(appMock, options...)
 			assert.NoError(t, err)
 
-			gotEntry, err := interp.Execute(context.Background(), tt.input)
+			gotResult, err := interp.Execute(context.Background(), tt.input)
 			if tt.wantErr != nil {
 				assert.ErrorIs(t, err, tt.wantErr)
 			} else {
 				assert.NoError(t, err)
-				assert.Equal(t, tt.wantEntry, gotEntry)
+				assert.Equal(t, tt.wantResult, gotResult)
 			}
 			appMock.AssertExpectations(t)
 		})
 	}
 }
+
+func TestRawInterpreter_WrongType(t *testing.T) {
+	appMock := newMockhandler(t)
+	appMock.On("SMembers", mock.Anything, domain.Key("foo")).Return(nil, domain.ErrWrongType)
+
+	raw, err := NewRaw(appMock)
+	assert.NoError(t, err)
+	assert.Equal(t, "WRONGTYPE "+domain.ErrWrongType.Error()+"\n", string(raw.Execute(context.Background(), []byte("SMEMBERS foo"))))
+}
+
+func TestRawInterpreter_ExecuteRepliesOnOneLine(t *testing.T) {
+	appMock := newMockhandler(t)
+	appMock.On("LRange", mock.Anything, domain.Key("foo"), 0, -1).Return([]domain.Value{"a", "b\nc"}, nil)
+
+	raw, err := NewRaw(appMock)
+	assert.NoError(t, err)
+	assert.Equal(t, "[a \"b\\nc\"]\n", string(raw.Execute(context.Background(), []byte("LRANGE foo 0 -1"))))
+}
+
+func TestRawInterpreter_ExecuteArgs(t *testing.T) {
+	appMock := newMockhandler(t)
+	appMock.On("Get", mock.Anything, domain.Key("a b")).Return(&domain.Entry{Key: "a b", Value: "\x00\xff"}, nil)
+	appMock.On("SMembers", mock.Anything, domain.Key("foo")).Return(nil, domain.ErrWrongType)
+
+	raw, err := NewRaw(appMock)
+	assert.NoError(t, err)
+
+	result, err := frame.ReadResponse(bytes.NewReader(raw.ExecuteArgs(context.Background(), []string{"GET", "a b"})), 0)
+	assert.NoError(t, err)
+	assert.Equal(t, domain.ValueResult("\x00\xff"), result)
+
+	_, err = frame.ReadResponse(bytes.NewReader(raw.ExecuteArgs(context.Background(), []string{"SMEMBERS", "foo"})), 0)
+	assert.Equal(t, frame.Error("WRONGTYPE "+domain.ErrWrongType.Error()), err)
+}
+
+func TestRawInterpreter_ExecuteRESP(t *testing.T) {
+	appMock := newMockhandler(t)
+	appMock.On("Get", mock.Anything, domain.Key("foo")).Return(nil, domain.ErrKeyNotFound)
+	appMock.On("SMembers", mock.Anything, domain.Key("foo")).Return(nil, domain.ErrWrongType)
+	appMock.On("MDel", mock.Anything, []domain.Key{"foo"}).Return(0, nil).Once()
+	appMock.On("MDel", mock.Anything, []domain.Key{"bar"}).Return(1, nil).Once()
+	appMock.On("HGetAll", mock.Anything, domain.Key("h")).Return([]domain.HashField{{Field: "f", Value: "v"}}, nil).Twice()
+
+	raw, err := NewRaw(appMock)
+	assert.NoError(t, err)
+	ctx := domain.WithSession(context.Background(), &domain.Session{})
+	run := func(args ...string) string {
+		return string(raw.ExecuteRESP(ctx, args))
+	}
+
+	assert.Equal(t, "$-1\r\n", run("get", "foo"))
+	// DEL replies with how many keys it removed
+	assert.Equal(t, ":0\r\n", run("DEL", "foo"))
+	assert.Equal(t, ":1\r\n", run("del", "bar"))
+	assert.Equal(t, "*2\r\n$1\r\nf\r\n$1\r\nv\r\n", run("HGETALL", "h"))
+	assert.Equal(t, "*0\r\n", run("COMMAND", "DOCS"))
+	assert.Equal(t, "-WRONGTYPE "+domain.ErrWrongType.Error()+"\r\n", run("SMEMBERS", "foo"))
+	assert.Equal(t, "-NOPROTO unsupported protocol version\r\n", run("HELLO", "4"))
+
+	hello := run("hello", "3")
+	assert.True(t, strings.HasPrefix(hello, "%3\r\n"), hello)
+	assert.Contains(t, hello, "$5\r\nproto\r\n:3\r\n")
+	// replies switch to RESP3
+	assert.Equal(t, "_\r\n", run("GET", "foo"))
+	assert.Equal(t, "%1\r\n$1\r\nf\r\n$1\r\nv\r\n", run("HGETALL", "h"))
+}
+
+func TestInterpreter_SelectNamespace(t *testing.T) {
+	interp, err := New(newMockhandler(t))
+	assert.NoError(t, err)
+
+	session := &domain.Session{}
+	ctx := domain.WithSession(context.Background(), session)
+
+	tests := []struct {
+		input   string
+		want    domain.Namespace
+		wantErr error
+	}{
+		{input: "SELECT 3", want: "3"},
+		{input: "SELECT 007", want: "7"},
+		{input: "USE team", want: "team"},
+		{input: "SELECT 0", want: domain.DefaultNamespace},
+		{input: "SELECT team", wantErr: domain.ErrNamespaceIsNotValid},
+		{input: "USE te~am", wantErr: domain.ErrNamespaceIsNotValid},
+		{input: "USE", wantErr: ErrInvalidCmd},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			session.Namespace = "before"
+			result, err := interp.Execute(ctx, tt.input)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Equal(t, domain.Namespace("before"), session.Namespace)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, domain.OKResult(), result)
+			assert.Equal(t, tt.want, session.Namespace)
+		})
+	}
+}
+
+func TestInterpreter_Transaction(t *testing.T) {
+	key, _ := domain.NewKey("foo")
+
+	appMock := newMockhandler(t)
+	appMock.On("Exec", mock.Anything, mock.Anything).
+		Return(func(ctx context.Context, fn func(context.Context) error) error { return fn(ctx) }).
+		Twice()
+	appMock.On("Set", mock.Anything, key, domain.Value("bar")).Return(nil).Once()
+	appMock.On("Get", mock.Anything, key).Return(nil, domain.ErrKeyNotFound).Once()
+	appMock.On("Delete", mock.Anything, key).Return(domain.ErrKeyNotFound).Once()
+	appMock.On("IncrBy", mock.Anything, key, int64(1)).Return(int64(0), domain.ErrNotInteger).Once()
+	appMock.On("Unwatch", mock.Anything).Return().Once()
+
+	interp, err := New(appMock)
+	assert.NoError(t, err)
+
+	session := &domain.Session{}
+	ctx := domain.WithSession(context.Background(), session)
+	run := func(input string) (domain.Result, error) {
+		return interp.Execute(ctx, input)
+	}
+
+	_, err = run("EXEC")
+	assert.ErrorIs(t, err, ErrNoMulti)
+
+	result, err := run("MULTI")
+	assert.NoError(t, err)
+	assert.Equal(t, domain.OKResult(), result)
+	_, err = run("MULTI")
+	assert.ErrorIs(t, err, ErrNestedMulti)
+	_, err = run("WATCH foo")
+	assert.ErrorIs(t, err, ErrWatchInMulti)
+
+	for _, cmd := range []string{"SET foo bar", "GET foo", "DEL foo"} {
+		result, err = run(cmd)
+		assert.NoError(t, err)
+		assert.Equal(t, domain.ValueResult(queuedReply), result)
+	}
+	result, err = run("EXEC")
+	assert.NoError(t, err)
+	assert.Equal(t, domain.ListResult(domain.OKResult(), domain.NilResult(), domain.IntegerResult(0)), result)
+	assert.False(t, session.InTransaction())
+
+	// a failing command fails the whole transaction
+	_, _ = run("MULTI")
+	_, _ = run("INCR foo")
+	_, err = run("EXEC")
+	assert.ErrorIs(t, err, ErrExecAborted)
+	assert.ErrorIs(t, err, domain.ErrNotInteger)
+
+	_, _ = run("MULTI")
+	_, _ = run("DEL foo")
+	result, err = run("DISCARD")
+	assert.NoError(t, err)
+	assert.Equal(t, domain.OKResult(), result)
+	assert.False(t, session.InTransaction())
+}
+
+func TestInterpreter_ExecWatchedKeyChanged(t *testing.T) {
+	appMock := newMockhandler(t)
+	appMock.On("Watch", mock.Anything, []domain.Key{"foo", "bar"}).Return(nil).Once()
+	appMock.On("Exec", mock.Anything, mock.Anything).Return(domain.ErrWatchedKeyChanged).Once()
+
+	interp, err := New(appMock)
+	assert.NoError(t, err)
+	ctx := domain.WithSession(context.Background(), &domain.Session{})
+
+	_, err = interp.Execute(ctx, "WATCH foo bar")
+	assert.NoError(t, err)
+	_, _ = interp.Execute(ctx, "MULTI")
+	_, _ = interp.Execute(ctx, "SET foo baz")
+	result, err := interp.Execute(ctx, "EXEC")
+	assert.NoError(t, err)
+	assert.Equal(t, domain.NilResult(), result)
+}
+
+func TestInterpreter_Auth(t *testing.T) {
+	hash, err := domain.HashPassword("secret")
+	assert.NoError(t, err)
+	users, err := domain.NewUsers([]domain.User{
+		{Name: "reader", PasswordHash: hash, Permission: domain.PermissionRead, KeyPatterns: []string{"billing/*"}},
+		{Name: "admin", PasswordHash: hash, Permission: domain.PermissionAdmin, KeyPatterns: []string{"*"}, Subject: "CN=admin"},
+	})
+	assert.NoError(t, err)
+
+	appMock := newMockhandler(t)
+	appMock.On("Get", mock.Anything, domain.Key("billing/a")).Return(&domain.Entry{Value: "1"}, nil).Once()
+	appMock.On("Scan", mock.Anything, domain.Key("billing/"), domain.Key("billing0"), 0).
+		Return([]domain.Entry{{Key: "billing/a"}}, nil).Once()
+	appMock.On("Scan", mock.Anything, domain.Key("b"), domain.Key("c"), 0).
+		Return([]domain.Entry{{Key: "b"}, {Key: "billing/a"}}, nil).Once()
+	appMock.On("FlushDB", mock.Anything).Return(nil).Once()
+	appMock.On("Unwatch", mock.Anything).Return().Once()
+
+	interp, err := New(appMock, WithUsers(users))
+	assert.NoError(t, err)
+
+	session := &domain.Session{}
+	ctx := domain.WithSession(context.Background(), session)
+	run := func(input string) (domain.Result, error) {
+		return interp.Execute(ctx, input)
+	}
+
+	_, err = run("GET billing/a")
+	assert.ErrorIs(t, err, domain.ErrAuthRequired)
+	_, err = run("PING")
+	assert.ErrorIs(t, err, domain.ErrAuthRequired)
+	_, err = run("AUTH reader wrong")
+	assert.ErrorIs(t, err, domain.ErrInvalidCredentials)
+	_, err = run("AUTH nobody secret")
+	assert.ErrorIs(t, err, domain.ErrInvalidCredentials)
+
+	result, err := run("auth reader secret")
+	assert.NoError(t, err)
+	assert.Equal(t, domain.OKResult(), result)
+
+	result, err = run("GET billing/a")
+	assert.NoError(t, err)
+	assert.Equal(t, domain.ValueResult("1"), result)
+	result, err = run("PING")
+	assert.NoError(t, err)
+	assert.Equal(t, domain.ValueResult(pongReply), result)
+
+	// the role and the key patterns both limit the user
+	for _, cmd := range []string{"SET billing/a 2", "GET ops/a", "MGET billing/a ops/a", "FLUSHDB"} {
+		_, err = run(cmd)
+		assert.ErrorIs(t, err, domain.ErrPermissionDenied, cmd)
+	}
+	// and are checked before a command is queued
+	_, err = run("MULTI")
+	assert.NoError(t, err)
+	_, err = run("GET ops/a")
+	assert.ErrorIs(t, err, domain.ErrPermissionDenied)
+	_, err = run("DISCARD")
+	assert.NoError(t, err)
+
+	// KEYS lists only the keys the user may access
+	result, err = run("KEYS billing/")
+	assert.NoError(t, err)
+	assert.Equal(t, domain.ListResult(domain.ValueResult("billing/a")), result)
+	result, err = run("KEYS b")
+	assert.NoError(t, err)
+	assert.Equal(t, domain.ListResult(domain.ValueResult("billing/a")), result)
+
+	// a client certificate of a user authenticates without AUTH
+	adminCtx := domain.WithSession(context.Background(), &domain.Session{Subject: "CN=admin"})
+	result, err = interp.Execute(adminCtx, "FLUSHDB")
+	assert.NoError(t, err)
+	assert.Equal(t, domain.OKResult(), result)
+
+	_, err = (&Interpreter{handler: appMock}).Execute(ctx, "AUTH reader secret")
+	assert.ErrorIs(t, err, ErrAuthDisabled)
+}
+
+func TestRawInterpreter_AuthErrors(t *testing.T) {
+	hash, err := domain.HashPassword("secret")
+	assert.NoError(t, err)
+	users, err := domain.NewUsers([]domain.User{
+		{Name: "reader", PasswordHash: hash, Permission: domain.PermissionRead, KeyPatterns: []string{"*"}},
+	})
+	assert.NoError(t, err)
+
+	interp, err := NewRaw(newMockhandler(t), WithUsers(users))
+	assert.NoError(t, err)
+	ctx := domain.WithSession(context.Background(), &domain.Session{})
+
+	assert.Equal(t, "NOAUTH authentication required\n", string(interp.Execute(ctx, []byte("GET a"))))
+	assert.Equal(t, "WRONGPASS invalid username-password pair\n", string(interp.Execute(ctx, []byte("AUTH reader wrong"))))
+
+	// HELLO authenticates RESP clients along with choosing the version
+	reply := string(interp.ExecuteRESP(ctx, []string{"HELLO", "3", "AUTH", "reader", "secret"}))
+	assert.True(t, strings.HasPrefix(reply, "%3\r\n"), reply)
+	assert.Equal(t, "-NOPERM permission denied: user reader may not run the command\r\n",
+		string(interp.ExecuteRESP(ctx, []string{"DEL", "a"})))
+}
@@ -6,6 +6,7 @@ package interpreter
 
 import (
 	"context"
+	"time"
 
 	"github.com/rdimidov/kvstore/internal/domain"
 	mock "github.com/stretchr/testify/mock"
@@ -38,6 +39,108 @@ func (_m *mockhandler) EXPECT() *mockhandler_Expecter {
 	return &mockhandler_Expecter{mock: &_m.Mock}
 }
 
+// CompareAndSet provides a mock function for the type mockhandler
+func (_mock *mockhandler) CompareAndSet(ctx context.Context, key domain.Key, expected uint64, value domain.Value) error {
+	ret := _mock.Called(ctx, key, expected, value)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CompareAndSet")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key, uint64, domain.Value) error); ok {
+		r0 = returnFunc(ctx, key, expected, value)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// mockhandler_CompareAndSet_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'CompareAndSet'
+type mockhandler_CompareAndSet_Call struct {
+	*mock.Call
+}
+
+// CompareAndSet is a helper method to define mock.On call
+//   - ctx
+//   - key
+//   - expected
+//   - value
+func (_e *mockhandler_Expecter) CompareAndSet(ctx interface{}, key interface{}, expected interface{}, value interface{}) *mockhandler_CompareAndSet_Call {
+	return &mockhandler_CompareAndSet_Call{Call: _e.mock.On("CompareAndSet", ctx, key, expected, value)}
+}
+
+func (_c *mockhandler_CompareAndSet_Call) Run(run func(ctx context.Context, key domain.Key, expected uint64, value domain.Value)) *mockhandler_CompareAndSet_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.Key), args[2].(uint64), args[3].(domain.Value))
+	})
+	return _c
+}
+
+func (_c *mockhandler_CompareAndSet_Call) Return(err error) *mockhandler_CompareAndSet_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *mockhandler_CompareAndSet_Call) RunAndReturn(run func(ctx context.Context, key domain.Key, expected uint64, value domain.Value) error) *mockhandler_CompareAndSet_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// DBSize provides a mock function for the type mockhandler
+func (_mock *mockhandler) DBSize(ctx context.Context) (int, error) {
+	ret := _mock.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DBSize")
+	}
+
+	var r0 int
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context) (int, error)); ok {
+		return returnFunc(ctx)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context) int); ok {
+		r0 = returnFunc(ctx)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = returnFunc(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// mockhandler_DBSize_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'DBSize'
+type mockhandler_DBSize_Call struct {
+	*mock.Call
+}
+
+// DBSize is a helper method to define mock.On call
+//   - ctx
+func (_e *mockhandler_Expecter) DBSize(ctx interface{}) *mockhandler_DBSize_Call {
+	return &mockhandler_DBSize_Call{Call: _e.mock.On("DBSize", ctx)}
+}
+
+func (_c *mockhandler_DBSize_Call) Run(run func(ctx context.Context)) *mockhandler_DBSize_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context))
+	})
+	return _c
+}
+
+func (_c *mockhandler_DBSize_Call) Return(int1 int, err error) *mockhandler_DBSize_Call {
+	_c.Call.Return(int1, err)
+	return _c
+}
+
+func (_c *mockhandler_DBSize_Call) RunAndReturn(run func(ctx context.Context) (int, error)) *mockhandler_DBSize_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // Delete provides a mock function for the type mockhandler
 func (_mock *mockhandler) Delete(ctx context.Context, key domain.Key) error {
 	ret := _mock.Called(ctx, key)
@@ -84,6 +187,144 @@ func (_c *mockhandler_Delete_Call) RunAndReturn(run func(ctx context.Context, ke
 	return _c
 }
 
+// Exec provides a mock function for the type mockhandler
+func (_mock *mockhandler) Exec(ctx context.Context, fn func(context.Context) error) error {
+	ret := _mock.Called(ctx, fn)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Exec")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, func(context.Context) error) error); ok {
+		r0 = returnFunc(ctx, fn)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// mockhandler_Exec_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Exec'
+type mockhandler_Exec_Call struct {
+	*mock.Call
+}
+
+// Exec is a helper method to define mock.On call
+//   - ctx
+//   - fn
+func (_e *mockhandler_Expecter) Exec(ctx interface{}, fn interface{}) *mockhandler_Exec_Call {
+	return &mockhandler_Exec_Call{Call: _e.mock.On("Exec", ctx, fn)}
+}
+
+func (_c *mockhandler_Exec_Call) Run(run func(ctx context.Context, fn func(context.Context) error)) *mockhandler_Exec_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(func(context.Context) error))
+	})
+	return _c
+}
+
+func (_c *mockhandler_Exec_Call) Return(err error) *mockhandler_Exec_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *mockhandler_Exec_Call) RunAndReturn(run func(ctx context.Context, fn func(context.Context) error) error) *mockhandler_Exec_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Expire provides a mock function for the type mockhandler
+func (_mock *mockhandler) Expire(ctx context.Context, key domain.Key, deadline time.Time) error {
+	ret := _mock.Called(ctx, key, deadline)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Expire")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key, time.Time) error); ok {
+		r0 = returnFunc(ctx, key, deadline)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// mockhandler_Expire_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Expire'
+type mockhandler_Expire_Call struct {
+	*mock.Call
+}
+
+// Expire is a helper method to define mock.On call
+//   - ctx
+//   - key
+//   - deadline
+func (_e *mockhandler_Expecter) Expire(ctx interface{}, key interface{}, deadline interface{}) *mockhandler_Expire_Call {
+	return &mockhandler_Expire_Call{Call: _e.mock.On("Expire", ctx, key, deadline)}
+}
+
+func (_c *mockhandler_Expire_Call) Run(run func(ctx context.Context, key domain.Key, deadline time.Time)) *mockhandler_Expire_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.Key), args[2].(time.Time))
+	})
+	return _c
+}
+
+func (_c *mockhandler_Expire_Call) Return(err error) *mockhandler_Expire_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *mockhandler_Expire_Call) RunAndReturn(run func(ctx context.Context, key domain.Key, deadline time.Time) error) *mockhandler_Expire_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// FlushDB provides a mock function for the type mockhandler
+func (_mock *mockhandler) FlushDB(ctx context.Context) error {
+	ret := _mock.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for FlushDB")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context) error); ok {
+		r0 = returnFunc(ctx)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// mockhandler_FlushDB_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'FlushDB'
+type mockhandler_FlushDB_Call struct {
+	*mock.Call
+}
+
+// FlushDB is a helper method to define mock.On call
+//   - ctx
+func (_e *mockhandler_Expecter) FlushDB(ctx interface{}) *mockhandler_FlushDB_Call {
+	return &mockhandler_FlushDB_Call{Call: _e.mock.On("FlushDB", ctx)}
+}
+
+func (_c *mockhandler_FlushDB_Call) Run(run func(ctx context.Context)) *mockhandler_FlushDB_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context))
+	})
+	return _c
+}
+
+func (_c *mockhandler_FlushDB_Call) Return(err error) *mockhandler_FlushDB_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *mockhandler_FlushDB_Call) RunAndReturn(run func(ctx context.Context) error) *mockhandler_FlushDB_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // Get provides a mock function for the type mockhandler
 func (_mock *mockhandler) Get(ctx context.Context, key domain.Key) (*domain.Entry, error) {
 	ret := _mock.Called(ctx, key)
@@ -141,49 +382,1303 @@ func (_c *mockhandler_Get_Call) RunAndReturn(run func(ctx context.Context, key d
 	return _c
 }
 
-// Set provides a mock function for the type mockhandler
-func (_mock *mockhandler) Set(ctx context.Context, key domain.Key, value domain.Value) error {
-	ret := _mock.Called(ctx, key, value)
+// HDel provides a mock function for the type mockhandler
+func (_mock *mockhandler) HDel(ctx context.Context, key domain.Key, fields []domain.Value) (int, error) {
+	ret := _mock.Called(ctx, key, fields)
 
 	if len(ret) == 0 {
-		panic("no return value specified for Set")
+		panic("no return value specified for HDel")
 	}
 
-	var r0 error
-	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key, domain.Value) error); ok {
-		r0 = returnFunc(ctx, key, value)
+	var r0 int
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key, []domain.Value) (int, error)); ok {
+		return returnFunc(ctx, key, fields)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key, []domain.Value) int); ok {
+		r0 = returnFunc(ctx, key, fields)
 	} else {
-		r0 = ret.Error(0)
+		r0 = ret.Get(0).(int)
 	}
-	return r0
+	if returnFunc, ok := ret.Get(1).(func(context.Context, domain.Key, []domain.Value) error); ok {
+		r1 = returnFunc(ctx, key, fields)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
 }
 
-// mockhandler_Set_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Set'
-type mockhandler_Set_Call struct {
+// mockhandler_HDel_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'HDel'
+type mockhandler_HDel_Call struct {
 	*mock.Call
 }
 
-// Set is a helper method to define mock.On call
+// HDel is a helper method to define mock.On call
 //   - ctx
 //   - key
-//   - value
-func (_e *mockhandler_Expecter) Set(ctx interface{}, key interface{}, value interface{}) *mockhandler_Set_Call {
-	return &mockhandler_Set_Call{Call: _e.mock.On("Set", ctx, key, value)}
+//   - fields
+func (_e *mockhandler_Expecter) HDel(ctx interface{}, key interface{}, fields interface{}) *mockhandler_HDel_Call {
+	return &mockhandler_HDel_Call{Call: _e.mock.On("HDel", ctx, key, fields)}
 }
 
-func (_c *mockhandler_Set_Call) Run(run func(ctx context.Context, key domain.Key, value domain.Value)) *mockhandler_Set_Call {
+func (_c *mockhandler_HDel_Call) Run(run func(ctx context.Context, key domain.Key, fields []domain.Value)) *mockhandler_HDel_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.Key), args[2].([]domain.Value))
+	})
+	return _c
+}
+
+func (_c *mockhandler_HDel_Call) Return(int1 int, err error) *mockhandler_HDel_Call {
+	_c.Call.Return(int1, err)
+	return _c
+}
+
+func (_c *mockhandler_HDel_Call) RunAndReturn(run func(ctx context.Context, key domain.Key, fields []domain.Value) (int, error)) *mockhandler_HDel_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// HGet provides a mock function for the type mockhandler
+func (_mock *mockhandler) HGet(ctx context.Context, key domain.Key, field domain.Value) (domain.Value, error) {
+	ret := _mock.Called(ctx, key, field)
+
+	if len(ret) == 0 {
+		panic("no return value specified for HGet")
+	}
+
+	var r0 domain.Value
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key, domain.Value) (domain.Value, error)); ok {
+		return returnFunc(ctx, key, field)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key, domain.Value) domain.Value); ok {
+		r0 = returnFunc(ctx, key, field)
+	} else {
+		r0 = ret.Get(0).(domain.Value)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, domain.Key, domain.Value) error); ok {
+		r1 = returnFunc(ctx, key, field)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// mockhandler_HGet_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'HGet'
+type mockhandler_HGet_Call struct {
+	*mock.Call
+}
+
+// HGet is a helper method to define mock.On call
+//   - ctx
+//   - key
+//   - field
+func (_e *mockhandler_Expecter) HGet(ctx interface{}, key interface{}, field interface{}) *mockhandler_HGet_Call {
+	return &mockhandler_HGet_Call{Call: _e.mock.On("HGet", ctx, key, field)}
+}
+
+func (_c *mockhandler_HGet_Call) Run(run func(ctx context.Context, key domain.Key, field domain.Value)) *mockhandler_HGet_Call {
 	_c.Call.Run(func(args mock.Arguments) {
 		run(args[0].(context.Context), args[1].(domain.Key), args[2].(domain.Value))
 	})
 	return _c
 }
 
-func (_c *mockhandler_Set_Call) Return(err error) *mockhandler_Set_Call {
-	_c.Call.Return(err)
+func (_c *mockhandler_HGet_Call) Return(value domain.Value, err error) *mockhandler_HGet_Call {
+	_c.Call.Return(value, err)
 	return _c
 }
 
-func (_c *mockhandler_Set_Call) RunAndReturn(run func(ctx context.Context, key domain.Key, value domain.Value) error) *mockhandler_Set_Call {
+func (_c *mockhandler_HGet_Call) RunAndReturn(run func(ctx context.Context, key domain.Key, field domain.Value) (domain.Value, error)) *mockhandler_HGet_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// HGetAll provides a mock function for the type mockhandler
+func (_mock *mockhandler) HGetAll(ctx context.Context, key domain.Key) ([]domain.HashField, error) {
+	ret := _mock.Called(ctx, key)
+
+	if len(ret) == 0 {
+		panic("no return value specified for HGetAll")
+	}
+
+	var r0 []domain.HashField
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key) ([]domain.HashField, error)); ok {
+		return returnFunc(ctx, key)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key) []domain.HashField); ok {
+		r0 = returnFunc(ctx, key)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]domain.HashField)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, domain.Key) error); ok {
+		r1 = returnFunc(ctx, key)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// mockhandler_HGetAll_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'HGetAll'
+type mockhandler_HGetAll_Call struct {
+	*mock.Call
+}
+
+// HGetAll is a helper method to define mock.On call
+//   - ctx
+//   - key
+func (_e *mockhandler_Expecter) HGetAll(ctx interface{}, key interface{}) *mockhandler_HGetAll_Call {
+	return &mockhandler_HGetAll_Call{Call: _e.mock.On("HGetAll", ctx, key)}
+}
+
+func (_c *mockhandler_HGetAll_Call) Run(run func(ctx context.Context, key domain.Key)) *mockhandler_HGetAll_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.Key))
+	})
+	return _c
+}
+
+func (_c *mockhandler_HGetAll_Call) Return(hashFieldMoqParams []domain.HashField, err error) *mockhandler_HGetAll_Call {
+	_c.Call.Return(hashFieldMoqParams, err)
+	return _c
+}
+
+func (_c *mockhandler_HGetAll_Call) RunAndReturn(run func(ctx context.Context, key domain.Key) ([]domain.HashField, error)) *mockhandler_HGetAll_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// HSet provides a mock function for the type mockhandler
+func (_mock *mockhandler) HSet(ctx context.Context, key domain.Key, fields []domain.HashField) (int, error) {
+	ret := _mock.Called(ctx, key, fields)
+
+	if len(ret) == 0 {
+		panic("no return value specified for HSet")
+	}
+
+	var r0 int
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key, []domain.HashField) (int, error)); ok {
+		return returnFunc(ctx, key, fields)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key, []domain.HashField) int); ok {
+		r0 = returnFunc(ctx, key, fields)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, domain.Key, []domain.HashField) error); ok {
+		r1 = returnFunc(ctx, key, fields)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// mockhandler_HSet_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'HSet'
+type mockhandler_HSet_Call struct {
+	*mock.Call
+}
+
+// HSet is a helper method to define mock.On call
+//   - ctx
+//   - key
+//   - fields
+func (_e *mockhandler_Expecter) HSet(ctx interface{}, key interface{}, fields interface{}) *mockhandler_HSet_Call {
+	return &mockhandler_HSet_Call{Call: _e.mock.On("HSet", ctx, key, fields)}
+}
+
+func (_c *mockhandler_HSet_Call) Run(run func(ctx context.Context, key domain.Key, fields []domain.HashField)) *mockhandler_HSet_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.Key), args[2].([]domain.HashField))
+	})
+	return _c
+}
+
+func (_c *mockhandler_HSet_Call) Return(int1 int, err error) *mockhandler_HSet_Call {
+	_c.Call.Return(int1, err)
+	return _c
+}
+
+func (_c *mockhandler_HSet_Call) RunAndReturn(run func(ctx context.Context, key domain.Key, fields []domain.HashField) (int, error)) *mockhandler_HSet_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// IncrBy provides a mock function for the type mockhandler
+func (_mock *mockhandler) IncrBy(ctx context.Context, key domain.Key, delta int64) (int64, error) {
+	ret := _mock.Called(ctx, key, delta)
+
+	if len(ret) == 0 {
+		panic("no return value specified for IncrBy")
+	}
+
+	var r0 int64
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key, int64) (int64, error)); ok {
+		return returnFunc(ctx, key, delta)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key, int64) int64); ok {
+		r0 = returnFunc(ctx, key, delta)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, domain.Key, int64) error); ok {
+		r1 = returnFunc(ctx, key, delta)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// mockhandler_IncrBy_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'IncrBy'
+type mockhandler_IncrBy_Call struct {
+	*mock.Call
+}
+
+// IncrBy is a helper method to define mock.On call
+//   - ctx
+//   - key
+//   - delta
+func (_e *mockhandler_Expecter) IncrBy(ctx interface{}, key interface{}, delta interface{}) *mockhandler_IncrBy_Call {
+	return &mockhandler_IncrBy_Call{Call: _e.mock.On("IncrBy", ctx, key, delta)}
+}
+
+func (_c *mockhandler_IncrBy_Call) Run(run func(ctx context.Context, key domain.Key, delta int64)) *mockhandler_IncrBy_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.Key), args[2].(int64))
+	})
+	return _c
+}
+
+func (_c *mockhandler_IncrBy_Call) Return(int641 int64, err error) *mockhandler_IncrBy_Call {
+	_c.Call.Return(int641, err)
+	return _c
+}
+
+func (_c *mockhandler_IncrBy_Call) RunAndReturn(run func(ctx context.Context, key domain.Key, delta int64) (int64, error)) *mockhandler_IncrBy_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// LRange provides a mock function for the type mockhandler
+func (_mock *mockhandler) LRange(ctx context.Context, key domain.Key, start int, stop int) ([]domain.Value, error) {
+	ret := _mock.Called(ctx, key, start, stop)
+
+	if len(ret) == 0 {
+		panic("no return value specified for LRange")
+	}
+
+	var r0 []domain.Value
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key, int, int) ([]domain.Value, error)); ok {
+		return returnFunc(ctx, key, start, stop)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key, int, int) []domain.Value); ok {
+		r0 = returnFunc(ctx, key, start, stop)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]domain.Value)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, domain.Key, int, int) error); ok {
+		r1 = returnFunc(ctx, key, start, stop)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// mockhandler_LRange_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'LRange'
+type mockhandler_LRange_Call struct {
+	*mock.Call
+}
+
+// LRange is a helper method to define mock.On call
+//   - ctx
+//   - key
+//   - start
+//   - stop
+func (_e *mockhandler_Expecter) LRange(ctx interface{}, key interface{}, start interface{}, stop interface{}) *mockhandler_LRange_Call {
+	return &mockhandler_LRange_Call{Call: _e.mock.On("LRange", ctx, key, start, stop)}
+}
+
+func (_c *mockhandler_LRange_Call) Run(run func(ctx context.Context, key domain.Key, start int, stop int)) *mockhandler_LRange_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.Key), args[2].(int), args[3].(int))
+	})
+	return _c
+}
+
+func (_c *mockhandler_LRange_Call) Return(valueMoqParams []domain.Value, err error) *mockhandler_LRange_Call {
+	_c.Call.Return(valueMoqParams, err)
+	return _c
+}
+
+func (_c *mockhandler_LRange_Call) RunAndReturn(run func(ctx context.Context, key domain.Key, start int, stop int) ([]domain.Value, error)) *mockhandler_LRange_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// MDel provides a mock function for the type mockhandler
+func (_mock *mockhandler) MDel(ctx context.Context, keys []domain.Key) (int, error) {
+	ret := _mock.Called(ctx, keys)
+
+	if len(ret) == 0 {
+		panic("no return value specified for MDel")
+	}
+
+	var r0 int
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, []domain.Key) (int, error)); ok {
+		return returnFunc(ctx, keys)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, []domain.Key) int); ok {
+		r0 = returnFunc(ctx, keys)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, []domain.Key) error); ok {
+		r1 = returnFunc(ctx, keys)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// mockhandler_MDel_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'MDel'
+type mockhandler_MDel_Call struct {
+	*mock.Call
+}
+
+// MDel is a helper method to define mock.On call
+//   - ctx
+//   - keys
+func (_e *mockhandler_Expecter) MDel(ctx interface{}, keys interface{}) *mockhandler_MDel_Call {
+	return &mockhandler_MDel_Call{Call: _e.mock.On("MDel", ctx, keys)}
+}
+
+func (_c *mockhandler_MDel_Call) Run(run func(ctx context.Context, keys []domain.Key)) *mockhandler_MDel_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].([]domain.Key))
+	})
+	return _c
+}
+
+func (_c *mockhandler_MDel_Call) Return(int1 int, err error) *mockhandler_MDel_Call {
+	_c.Call.Return(int1, err)
+	return _c
+}
+
+func (_c *mockhandler_MDel_Call) RunAndReturn(run func(ctx context.Context, keys []domain.Key) (int, error)) *mockhandler_MDel_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// MGet provides a mock function for the type mockhandler
+func (_mock *mockhandler) MGet(ctx context.Context, keys []domain.Key) ([]*domain.Entry, error) {
+	ret := _mock.Called(ctx, keys)
+
+	if len(ret) == 0 {
+		panic("no return value specified for MGet")
+	}
+
+	var r0 []*domain.Entry
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, []domain.Key) ([]*domain.Entry, error)); ok {
+		return returnFunc(ctx, keys)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, []domain.Key) []*domain.Entry); ok {
+		r0 = returnFunc(ctx, keys)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*domain.Entry)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, []domain.Key) error); ok {
+		r1 = returnFunc(ctx, keys)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// mockhandler_MGet_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'MGet'
+type mockhandler_MGet_Call struct {
+	*mock.Call
+}
+
+// MGet is a helper method to define mock.On call
+//   - ctx
+//   - keys
+func (_e *mockhandler_Expecter) MGet(ctx interface{}, keys interface{}) *mockhandler_MGet_Call {
+	return &mockhandler_MGet_Call{Call: _e.mock.On("MGet", ctx, keys)}
+}
+
+func (_c *mockhandler_MGet_Call) Run(run func(ctx context.Context, keys []domain.Key)) *mockhandler_MGet_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].([]domain.Key))
+	})
+	return _c
+}
+
+func (_c *mockhandler_MGet_Call) Return(entryMoqParams []*domain.Entry, err error) *mockhandler_MGet_Call {
+	_c.Call.Return(entryMoqParams, err)
+	return _c
+}
+
+func (_c *mockhandler_MGet_Call) RunAndReturn(run func(ctx context.Context, keys []domain.Key) ([]*domain.Entry, error)) *mockhandler_MGet_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// MSet provides a mock function for the type mockhandler
+func (_mock *mockhandler) MSet(ctx context.Context, entries []domain.Entry) error {
+	ret := _mock.Called(ctx, entries)
+
+	if len(ret) == 0 {
+		panic("no return value specified for MSet")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, []domain.Entry) error); ok {
+		r0 = returnFunc(ctx, entries)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// mockhandler_MSet_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'MSet'
+type mockhandler_MSet_Call struct {
+	*mock.Call
+}
+
+// MSet is a helper method to define mock.On call
+//   - ctx
+//   - entries
+func (_e *mockhandler_Expecter) MSet(ctx interface{}, entries interface{}) *mockhandler_MSet_Call {
+	return &mockhandler_MSet_Call{Call: _e.mock.On("MSet", ctx, entries)}
+}
+
+func (_c *mockhandler_MSet_Call) Run(run func(ctx context.Context, entries []domain.Entry)) *mockhandler_MSet_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].([]domain.Entry))
+	})
+	return _c
+}
+
+func (_c *mockhandler_MSet_Call) Return(err error) *mockhandler_MSet_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *mockhandler_MSet_Call) RunAndReturn(run func(ctx context.Context, entries []domain.Entry) error) *mockhandler_MSet_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Persist provides a mock function for the type mockhandler
+func (_mock *mockhandler) Persist(ctx context.Context, key domain.Key) error {
+	ret := _mock.Called(ctx, key)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Persist")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key) error); ok {
+		r0 = returnFunc(ctx, key)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// mockhandler_Persist_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Persist'
+type mockhandler_Persist_Call struct {
+	*mock.Call
+}
+
+// Persist is a helper method to define mock.On call
+//   - ctx
+//   - key
+func (_e *mockhandler_Expecter) Persist(ctx interface{}, key interface{}) *mockhandler_Persist_Call {
+	return &mockhandler_Persist_Call{Call: _e.mock.On("Persist", ctx, key)}
+}
+
+func (_c *mockhandler_Persist_Call) Run(run func(ctx context.Context, key domain.Key)) *mockhandler_Persist_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.Key))
+	})
+	return _c
+}
+
+func (_c *mockhandler_Persist_Call) Return(err error) *mockhandler_Persist_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *mockhandler_Persist_Call) RunAndReturn(run func(ctx context.Context, key domain.Key) error) *mockhandler_Persist_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Pop provides a mock function for the type mockhandler
+func (_mock *mockhandler) Pop(ctx context.Context, key domain.Key, left bool) (domain.Value, error) {
+	ret := _mock.Called(ctx, key, left)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Pop")
+	}
+
+	var r0 domain.Value
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key, bool) (domain.Value, error)); ok {
+		return returnFunc(ctx, key, left)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key, bool) domain.Value); ok {
+		r0 = returnFunc(ctx, key, left)
+	} else {
+		r0 = ret.Get(0).(domain.Value)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, domain.Key, bool) error); ok {
+		r1 = returnFunc(ctx, key, left)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// mockhandler_Pop_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Pop'
+type mockhandler_Pop_Call struct {
+	*mock.Call
+}
+
+// Pop is a helper method to define mock.On call
+//   - ctx
+//   - key
+//   - left
+func (_e *mockhandler_Expecter) Pop(ctx interface{}, key interface{}, left interface{}) *mockhandler_Pop_Call {
+	return &mockhandler_Pop_Call{Call: _e.mock.On("Pop", ctx, key, left)}
+}
+
+func (_c *mockhandler_Pop_Call) Run(run func(ctx context.Context, key domain.Key, left bool)) *mockhandler_Pop_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.Key), args[2].(bool))
+	})
+	return _c
+}
+
+func (_c *mockhandler_Pop_Call) Return(value domain.Value, err error) *mockhandler_Pop_Call {
+	_c.Call.Return(value, err)
+	return _c
+}
+
+func (_c *mockhandler_Pop_Call) RunAndReturn(run func(ctx context.Context, key domain.Key, left bool) (domain.Value, error)) *mockhandler_Pop_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Push provides a mock function for the type mockhandler
+func (_mock *mockhandler) Push(ctx context.Context, key domain.Key, values []domain.Value, left bool) (int, error) {
+	ret := _mock.Called(ctx, key, values, left)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Push")
+	}
+
+	var r0 int
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key, []domain.Value, bool) (int, error)); ok {
+		return returnFunc(ctx, key, values, left)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key, []domain.Value, bool) int); ok {
+		r0 = returnFunc(ctx, key, values, left)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, domain.Key, []domain.Value, bool) error); ok {
+		r1 = returnFunc(ctx, key, values, left)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// mockhandler_Push_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Push'
+type mockhandler_Push_Call struct {
+	*mock.Call
+}
+
+// Push is a helper method to define mock.On call
+//   - ctx
+//   - key
+//   - values
+//   - left
+func (_e *mockhandler_Expecter) Push(ctx interface{}, key interface{}, values interface{}, left interface{}) *mockhandler_Push_Call {
+	return &mockhandler_Push_Call{Call: _e.mock.On("Push", ctx, key, values, left)}
+}
+
+func (_c *mockhandler_Push_Call) Run(run func(ctx context.Context, key domain.Key, values []domain.Value, left bool)) *mockhandler_Push_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.Key), args[2].([]domain.Value), args[3].(bool))
+	})
+	return _c
+}
+
+func (_c *mockhandler_Push_Call) Return(int1 int, err error) *mockhandler_Push_Call {
+	_c.Call.Return(int1, err)
+	return _c
+}
+
+func (_c *mockhandler_Push_Call) RunAndReturn(run func(ctx context.Context, key domain.Key, values []domain.Value, left bool) (int, error)) *mockhandler_Push_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Restore provides a mock function for the type mockhandler
+func (_mock *mockhandler) Restore(ctx context.Context, entry domain.Entry) error {
+	ret := _mock.Called(ctx, entry)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Restore")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Entry) error); ok {
+		r0 = returnFunc(ctx, entry)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// mockhandler_Restore_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Restore'
+type mockhandler_Restore_Call struct {
+	*mock.Call
+}
+
+// Restore is a helper method to define mock.On call
+//   - ctx
+//   - entry
+func (_e *mockhandler_Expecter) Restore(ctx interface{}, entry interface{}) *mockhandler_Restore_Call {
+	return &mockhandler_Restore_Call{Call: _e.mock.On("Restore", ctx, entry)}
+}
+
+func (_c *mockhandler_Restore_Call) Run(run func(ctx context.Context, entry domain.Entry)) *mockhandler_Restore_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.Entry))
+	})
+	return _c
+}
+
+func (_c *mockhandler_Restore_Call) Return(err error) *mockhandler_Restore_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *mockhandler_Restore_Call) RunAndReturn(run func(ctx context.Context, entry domain.Entry) error) *mockhandler_Restore_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// SAdd provides a mock function for the type mockhandler
+func (_mock *mockhandler) SAdd(ctx context.Context, key domain.Key, members []domain.Value) (int, error) {
+	ret := _mock.Called(ctx, key, members)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SAdd")
+	}
+
+	var r0 int
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key, []domain.Value) (int, error)); ok {
+		return returnFunc(ctx, key, members)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key, []domain.Value) int); ok {
+		r0 = returnFunc(ctx, key, members)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, domain.Key, []domain.Value) error); ok {
+		r1 = returnFunc(ctx, key, members)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// mockhandler_SAdd_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SAdd'
+type mockhandler_SAdd_Call struct {
+	*mock.Call
+}
+
+// SAdd is a helper method to define mock.On call
+//   - ctx
+//   - key
+//   - members
+func (_e *mockhandler_Expecter) SAdd(ctx interface{}, key interface{}, members interface{}) *mockhandler_SAdd_Call {
+	return &mockhandler_SAdd_Call{Call: _e.mock.On("SAdd", ctx, key, members)}
+}
+
+func (_c *mockhandler_SAdd_Call) Run(run func(ctx context.Context, key domain.Key, members []domain.Value)) *mockhandler_SAdd_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.Key), args[2].([]domain.Value))
+	})
+	return _c
+}
+
+func (_c *mockhandler_SAdd_Call) Return(int1 int, err error) *mockhandler_SAdd_Call {
+	_c.Call.Return(int1, err)
+	return _c
+}
+
+func (_c *mockhandler_SAdd_Call) RunAndReturn(run func(ctx context.Context, key domain.Key, members []domain.Value) (int, error)) *mockhandler_SAdd_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// SIsMember provides a mock function for the type mockhandler
+func (_mock *mockhandler) SIsMember(ctx context.Context, key domain.Key, member domain.Value) (bool, error) {
+	ret := _mock.Called(ctx, key, member)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SIsMember")
+	}
+
+	var r0 bool
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key, domain.Value) (bool, error)); ok {
+		return returnFunc(ctx, key, member)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key, domain.Value) bool); ok {
+		r0 = returnFunc(ctx, key, member)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, domain.Key, domain.Value) error); ok {
+		r1 = returnFunc(ctx, key, member)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// mockhandler_SIsMember_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SIsMember'
+type mockhandler_SIsMember_Call struct {
+	*mock.Call
+}
+
+// SIsMember is a helper method to define mock.On call
+//   - ctx
+//   - key
+//   - member
+func (_e *mockhandler_Expecter) SIsMember(ctx interface{}, key interface{}, member interface{}) *mockhandler_SIsMember_Call {
+	return &mockhandler_SIsMember_Call{Call: _e.mock.On("SIsMember", ctx, key, member)}
+}
+
+func (_c *mockhandler_SIsMember_Call) Run(run func(ctx context.Context, key domain.Key, member domain.Value)) *mockhandler_SIsMember_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.Key), args[2].(domain.Value))
+	})
+	return _c
+}
+
+func (_c *mockhandler_SIsMember_Call) Return(bool1 bool, err error) *mockhandler_SIsMember_Call {
+	_c.Call.Return(bool1, err)
+	return _c
+}
+
+func (_c *mockhandler_SIsMember_Call) RunAndReturn(run func(ctx context.Context, key domain.Key, member domain.Value) (bool, error)) *mockhandler_SIsMember_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// SMembers provides a mock function for the type mockhandler
+func (_mock *mockhandler) SMembers(ctx context.Context, key domain.Key) ([]domain.Value, error) {
+	ret := _mock.Called(ctx, key)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SMembers")
+	}
+
+	var r0 []domain.Value
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key) ([]domain.Value, error)); ok {
+		return returnFunc(ctx, key)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key) []domain.Value); ok {
+		r0 = returnFunc(ctx, key)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]domain.Value)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, domain.Key) error); ok {
+		r1 = returnFunc(ctx, key)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// mockhandler_SMembers_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SMembers'
+type mockhandler_SMembers_Call struct {
+	*mock.Call
+}
+
+// SMembers is a helper method to define mock.On call
+//   - ctx
+//   - key
+func (_e *mockhandler_Expecter) SMembers(ctx interface{}, key interface{}) *mockhandler_SMembers_Call {
+	return &mockhandler_SMembers_Call{Call: _e.mock.On("SMembers", ctx, key)}
+}
+
+func (_c *mockhandler_SMembers_Call) Run(run func(ctx context.Context, key domain.Key)) *mockhandler_SMembers_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.Key))
+	})
+	return _c
+}
+
+func (_c *mockhandler_SMembers_Call) Return(valueMoqParams []domain.Value, err error) *mockhandler_SMembers_Call {
+	_c.Call.Return(valueMoqParams, err)
+	return _c
+}
+
+func (_c *mockhandler_SMembers_Call) RunAndReturn(run func(ctx context.Context, key domain.Key) ([]domain.Value, error)) *mockhandler_SMembers_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// SRem provides a mock function for the type mockhandler
+func (_mock *mockhandler) SRem(ctx context.Context, key domain.Key, members []domain.Value) (int, error) {
+	ret := _mock.Called(ctx, key, members)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SRem")
+	}
+
+	var r0 int
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key, []domain.Value) (int, error)); ok {
+		return returnFunc(ctx, key, members)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key, []domain.Value) int); ok {
+		r0 = returnFunc(ctx, key, members)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, domain.Key, []domain.Value) error); ok {
+		r1 = returnFunc(ctx, key, members)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// mockhandler_SRem_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SRem'
+type mockhandler_SRem_Call struct {
+	*mock.Call
+}
+
+// SRem is a helper method to define mock.On call
+//   - ctx
+//   - key
+//   - members
+func (_e *mockhandler_Expecter) SRem(ctx interface{}, key interface{}, members interface{}) *mockhandler_SRem_Call {
+	return &mockhandler_SRem_Call{Call: _e.mock.On("SRem", ctx, key, members)}
+}
+
+func (_c *mockhandler_SRem_Call) Run(run func(ctx context.Context, key domain.Key, members []domain.Value)) *mockhandler_SRem_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.Key), args[2].([]domain.Value))
+	})
+	return _c
+}
+
+func (_c *mockhandler_SRem_Call) Return(int1 int, err error) *mockhandler_SRem_Call {
+	_c.Call.Return(int1, err)
+	return _c
+}
+
+func (_c *mockhandler_SRem_Call) RunAndReturn(run func(ctx context.Context, key domain.Key, members []domain.Value) (int, error)) *mockhandler_SRem_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Scan provides a mock function for the type mockhandler
+func (_mock *mockhandler) Scan(ctx context.Context, start domain.Key, end domain.Key, limit int) ([]domain.Entry, error) {
+	ret := _mock.Called(ctx, start, end, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Scan")
+	}
+
+	var r0 []domain.Entry
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key, domain.Key, int) ([]domain.Entry, error)); ok {
+		return returnFunc(ctx, start, end, limit)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key, domain.Key, int) []domain.Entry); ok {
+		r0 = returnFunc(ctx, start, end, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]domain.Entry)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, domain.Key, domain.Key, int) error); ok {
+		r1 = returnFunc(ctx, start, end, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// mockhandler_Scan_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Scan'
+type mockhandler_Scan_Call struct {
+	*mock.Call
+}
+
+// Scan is a helper method to define mock.On call
+//   - ctx
+//   - start
+//   - end
+//   - limit
+func (_e *mockhandler_Expecter) Scan(ctx interface{}, start interface{}, end interface{}, limit interface{}) *mockhandler_Scan_Call {
+	return &mockhandler_Scan_Call{Call: _e.mock.On("Scan", ctx, start, end, limit)}
+}
+
+func (_c *mockhandler_Scan_Call) Run(run func(ctx context.Context, start domain.Key, end domain.Key, limit int)) *mockhandler_Scan_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.Key), args[2].(domain.Key), args[3].(int))
+	})
+	return _c
+}
+
+func (_c *mockhandler_Scan_Call) Return(entryMoqParams []domain.Entry, err error) *mockhandler_Scan_Call {
+	_c.Call.Return(entryMoqParams, err)
+	return _c
+}
+
+func (_c *mockhandler_Scan_Call) RunAndReturn(run func(ctx context.Context, start domain.Key, end domain.Key, limit int) ([]domain.Entry, error)) *mockhandler_Scan_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Set provides a mock function for the type mockhandler
+func (_mock *mockhandler) Set(ctx context.Context, key domain.Key, value domain.Value) error {
+	ret := _mock.Called(ctx, key, value)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Set")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key, domain.Value) error); ok {
+		r0 = returnFunc(ctx, key, value)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// mockhandler_Set_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Set'
+type mockhandler_Set_Call struct {
+	*mock.Call
+}
+
+// Set is a helper method to define mock.On call
+//   - ctx
+//   - key
+//   - value
+func (_e *mockhandler_Expecter) Set(ctx interface{}, key interface{}, value interface{}) *mockhandler_Set_Call {
+	return &mockhandler_Set_Call{Call: _e.mock.On("Set", ctx, key, value)}
+}
+
+func (_c *mockhandler_Set_Call) Run(run func(ctx context.Context, key domain.Key, value domain.Value)) *mockhandler_Set_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.Key), args[2].(domain.Value))
+	})
+	return _c
+}
+
+func (_c *mockhandler_Set_Call) Return(err error) *mockhandler_Set_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *mockhandler_Set_Call) RunAndReturn(run func(ctx context.Context, key domain.Key, value domain.Value) error) *mockhandler_Set_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// SetEx provides a mock function for the type mockhandler
+func (_mock *mockhandler) SetEx(ctx context.Context, key domain.Key, value domain.Value, deadline time.Time) error {
+	ret := _mock.Called(ctx, key, value, deadline)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetEx")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key, domain.Value, time.Time) error); ok {
+		r0 = returnFunc(ctx, key, value, deadline)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// mockhandler_SetEx_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SetEx'
+type mockhandler_SetEx_Call struct {
+	*mock.Call
+}
+
+// SetEx is a helper method to define mock.On call
+//   - ctx
+//   - key
+//   - value
+//   - deadline
+func (_e *mockhandler_Expecter) SetEx(ctx interface{}, key interface{}, value interface{}, deadline interface{}) *mockhandler_SetEx_Call {
+	return &mockhandler_SetEx_Call{Call: _e.mock.On("SetEx", ctx, key, value, deadline)}
+}
+
+func (_c *mockhandler_SetEx_Call) Run(run func(ctx context.Context, key domain.Key, value domain.Value, deadline time.Time)) *mockhandler_SetEx_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.Key), args[2].(domain.Value), args[3].(time.Time))
+	})
+	return _c
+}
+
+func (_c *mockhandler_SetEx_Call) Return(err error) *mockhandler_SetEx_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *mockhandler_SetEx_Call) RunAndReturn(run func(ctx context.Context, key domain.Key, value domain.Value, deadline time.Time) error) *mockhandler_SetEx_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// SetNX provides a mock function for the type mockhandler
+func (_mock *mockhandler) SetNX(ctx context.Context, key domain.Key, value domain.Value) error {
+	ret := _mock.Called(ctx, key, value)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetNX")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key, domain.Value) error); ok {
+		r0 = returnFunc(ctx, key, value)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// mockhandler_SetNX_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SetNX'
+type mockhandler_SetNX_Call struct {
+	*mock.Call
+}
+
+// SetNX is a helper method to define mock.On call
+//   - ctx
+//   - key
+//   - value
+func (_e *mockhandler_Expecter) SetNX(ctx interface{}, key interface{}, value interface{}) *mockhandler_SetNX_Call {
+	return &mockhandler_SetNX_Call{Call: _e.mock.On("SetNX", ctx, key, value)}
+}
+
+func (_c *mockhandler_SetNX_Call) Run(run func(ctx context.Context, key domain.Key, value domain.Value)) *mockhandler_SetNX_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.Key), args[2].(domain.Value))
+	})
+	return _c
+}
+
+func (_c *mockhandler_SetNX_Call) Return(err error) *mockhandler_SetNX_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *mockhandler_SetNX_Call) RunAndReturn(run func(ctx context.Context, key domain.Key, value domain.Value) error) *mockhandler_SetNX_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// SetXX provides a mock function for the type mockhandler
+func (_mock *mockhandler) SetXX(ctx context.Context, key domain.Key, value domain.Value) error {
+	ret := _mock.Called(ctx, key, value)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetXX")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, domain.Key, domain.Value) error); ok {
+		r0 = returnFunc(ctx, key, value)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// mockhandler_SetXX_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SetXX'
+type mockhandler_SetXX_Call struct {
+	*mock.Call
+}
+
+// SetXX is a helper method to define mock.On call
+//   - ctx
+//   - key
+//   - value
+func (_e *mockhandler_Expecter) SetXX(ctx interface{}, key interface{}, value interface{}) *mockhandler_SetXX_Call {
+	return &mockhandler_SetXX_Call{Call: _e.mock.On("SetXX", ctx, key, value)}
+}
+
+func (_c *mockhandler_SetXX_Call) Run(run func(ctx context.Context, key domain.Key, value domain.Value)) *mockhandler_SetXX_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.Key), args[2].(domain.Value))
+	})
+	return _c
+}
+
+func (_c *mockhandler_SetXX_Call) Return(err error) *mockhandler_SetXX_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *mockhandler_SetXX_Call) RunAndReturn(run func(ctx context.Context, key domain.Key, value domain.Value) error) *mockhandler_SetXX_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Snapshot provides a mock function for the type mockhandler
+func (_mock *mockhandler) Snapshot(ctx context.Context) error {
+	ret := _mock.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Snapshot")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context) error); ok {
+		r0 = returnFunc(ctx)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// mockhandler_Snapshot_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Snapshot'
+type mockhandler_Snapshot_Call struct {
+	*mock.Call
+}
+
+// Snapshot is a helper method to define mock.On call
+//   - ctx
+func (_e *mockhandler_Expecter) Snapshot(ctx interface{}) *mockhandler_Snapshot_Call {
+	return &mockhandler_Snapshot_Call{Call: _e.mock.On("Snapshot", ctx)}
+}
+
+func (_c *mockhandler_Snapshot_Call) Run(run func(ctx context.Context)) *mockhandler_Snapshot_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context))
+	})
+	return _c
+}
+
+func (_c *mockhandler_Snapshot_Call) Return(err error) *mockhandler_Snapshot_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *mockhandler_Snapshot_Call) RunAndReturn(run func(ctx context.Context) error) *mockhandler_Snapshot_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Unwatch provides a mock function for the type mockhandler
+func (_mock *mockhandler) Unwatch(ctx context.Context) {
+	_mock.Called(ctx)
+	return
+}
+
+// mockhandler_Unwatch_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Unwatch'
+type mockhandler_Unwatch_Call struct {
+	*mock.Call
+}
+
+// Unwatch is a helper method to define mock.On call
+//   - ctx
+func (_e *mockhandler_Expecter) Unwatch(ctx interface{}) *mockhandler_Unwatch_Call {
+	return &mockhandler_Unwatch_Call{Call: _e.mock.On("Unwatch", ctx)}
+}
+
+func (_c *mockhandler_Unwatch_Call) Run(run func(ctx context.Context)) *mockhandler_Unwatch_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context))
+	})
+	return _c
+}
+
+func (_c *mockhandler_Unwatch_Call) Return() *mockhandler_Unwatch_Call {
+	_c.Call.Return()
+	return _c
+}
+
+func (_c *mockhandler_Unwatch_Call) RunAndReturn(run func(ctx context.Context)) *mockhandler_Unwatch_Call {
+	_c.Run(run)
+	return _c
+}
+
+// Watch provides a mock function for the type mockhandler
+func (_mock *mockhandler) Watch(ctx context.Context, keys []domain.Key) error {
+	ret := _mock.Called(ctx, keys)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Watch")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, []domain.Key) error); ok {
+		r0 = returnFunc(ctx, keys)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// mockhandler_Watch_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Watch'
+type mockhandler_Watch_Call struct {
+	*mock.Call
+}
+
+// Watch is a helper method to define mock.On call
+//   - ctx
+//   - keys
+func (_e *mockhandler_Expecter) Watch(ctx interface{}, keys interface{}) *mockhandler_Watch_Call {
+	return &mockhandler_Watch_Call{Call: _e.mock.On("Watch", ctx, keys)}
+}
+
+func (_c *mockhandler_Watch_Call) Run(run func(ctx context.Context, keys []domain.Key)) *mockhandler_Watch_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].([]domain.Key))
+	})
+	return _c
+}
+
+func (_c *mockhandler_Watch_Call) Return(err error) *mockhandler_Watch_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *mockhandler_Watch_Call) RunAndReturn(run func(ctx context.Context, keys []domain.Key) error) *mockhandler_Watch_Call {
 	_c.Call.Return(run)
 	return _c
 }
@@ -0,0 +1,22 @@
+package interpreter
+
+import "github.com/rdimidov/kvstore/internal/domain"
+
+type Option func(*Interpreter)
+
+// WithUsers requires clients to authenticate as one of users, and limits
+// each of them to the commands and keys its ACL allows.
+func WithUsers(users *domain.Users) Option {
+	return func(i *Interpreter) {
+		i.users = users
+	}
+}
+
+// WithReplay builds the interpreter replaying the WAL and snapshots. Only it
+// takes SET ... VER, which restores an entry with the version it was logged
+// with; from a client, VER could take versions back and fool CAS and WATCH.
+func WithReplay() Option {
+	return func(i *Interpreter) {
+		i.replay = true
+	}
+}
@@ -0,0 +1,113 @@
+package interpreter
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/rdimidov/kvstore/internal/domain"
+	"github.com/rdimidov/kvstore/internal/presentation/resp"
+)
+
+// Commands only RESP clients need. HELLO chooses the version of the replies
+// and COMMAND, which redis-cli asks for hints, gets an empty list.
+const (
+	helloCommand   = "HELLO"
+	commandCommand = "COMMAND"
+)
+
+// mapCommands reply with fields followed by their values, which RESP3 sends
+// as a map. Inside EXEC their replies stay flat arrays.
+var mapCommands = map[string]bool{
+	hgetallCommand: true,
+}
+
+const (
+	helloProtoIdx    = 1
+	helloAuthIdx     = 2
+	helloAuthArgsLen = 5
+)
+
+// ExecuteRESP runs a command of RESP and returns its reply in the version
+// the session chose with HELLO, RESP2 until then. As in Redis, reading a
+// missing key replies with nil rather than an error, and DEL replies with
+// the number of keys it deleted.
+func (r *RawInterpreter) ExecuteRESP(ctx context.Context, args []string) []byte {
+	session := domain.SessionFrom(ctx)
+	version := resp.Version2
+	if session != nil && session.RESPVersion != 0 {
+		version = session.RESPVersion
+	}
+
+	var name string
+	if len(args) > 0 {
+		name = strings.ToUpper(args[commandNameIdx])
+	}
+	if name != "" && !(session != nil && session.InTransaction()) {
+		switch name {
+		case helloCommand:
+			return r.hello(ctx, session, version, args)
+		case commandCommand:
+			return resp.AppendResult(nil, domain.ListResult(), version)
+		}
+	}
+
+	// DEL runs as MDEL, which counts the keys it removed where Delete cannot
+	if name == delCommand && len(args) == delArgsLen {
+		args = []string{mdelCommand, args[commandKeyIdx]}
+	}
+
+	result, err := r.Interpreter.ExecuteArgs(ctx, args)
+	if errors.Is(err, domain.ErrKeyNotFound) {
+		result, err = missingResult(name), nil
+	}
+	if err != nil {
+		return resp.AppendError(nil, errorReply(err))
+	}
+	if mapCommands[name] && result.Kind == domain.ResultList {
+		return resp.AppendMap(nil, result.List, version)
+	}
+	return resp.AppendResult(nil, result, version)
+}
+
+// hello switches the session to the requested version of RESP, after
+// authenticating it when given AUTH <user> <password>, and replies with a
+// description of the server.
+func (r *RawInterpreter) hello(ctx context.Context, session *domain.Session, version int, args []string) []byte {
+	var auth []string
+	if len(args) == helloAuthArgsLen && strings.EqualFold(args[helloAuthIdx], authCommand) {
+		auth = append([]string{authCommand}, args[helloAuthIdx+1:]...)
+		args = args[:helloAuthIdx]
+	}
+	if len(args) > helloProtoIdx+1 {
+		return resp.AppendError(nil, errorReply(ErrInvalidCmd))
+	}
+
+	requested := version
+	if len(args) > helloProtoIdx {
+		var err error
+		requested, err = strconv.Atoi(args[helloProtoIdx])
+		if err != nil || (requested != resp.Version2 && requested != resp.Version3) {
+			return resp.AppendError(nil, "NOPROTO unsupported protocol version")
+		}
+		if session == nil {
+			return resp.AppendError(nil, errorReply(domain.ErrNoSession))
+		}
+	}
+	if auth != nil {
+		if _, err := r.auth(ctx, auth); err != nil {
+			return resp.AppendError(nil, errorReply(err))
+		}
+	}
+	if requested != version {
+		version = requested
+		session.RESPVersion = version
+	}
+
+	return resp.AppendMap(nil, []domain.Result{
+		domain.ValueResult("server"), domain.ValueResult("kvstore"),
+		domain.ValueResult("proto"), domain.IntegerResult(int64(version)),
+		domain.ValueResult("mode"), domain.ValueResult("standalone"),
+	}, version)
+}
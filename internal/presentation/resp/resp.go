@@ -0,0 +1,195 @@
+// Package resp implements enough of RESP, the protocol of Redis, for Redis
+// clients and tools to talk to the server. Requests are arrays of bulk
+// strings or inline command lines; replies are written in RESP2 or RESP3.
+package resp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/rdimidov/kvstore/internal/domain"
+)
+
+// Versions of the protocol a client can choose with HELLO.
+const (
+	Version2 = 2
+	Version3 = 3
+)
+
+const crlf = "\r\n"
+
+var (
+	// ErrTooLarge is returned when a request exceeds the size limit of the reader.
+	ErrTooLarge = errors.New("request is too large")
+	// ErrProtocol is returned when a request does not follow the protocol.
+	ErrProtocol = errors.New("protocol error")
+)
+
+// ReadCommand reads a request and returns the arguments of its command.
+// Empty requests are skipped. A request of more than limit bytes fails with
+// ErrTooLarge; zero means no limit.
+func ReadCommand(r *bufio.Reader, limit int) ([]string, error) {
+	d := &decoder{r: r, budget: limit}
+	if limit <= 0 {
+		d.budget = -1
+	}
+
+	for {
+		line, err := d.line()
+		if err != nil {
+			return nil, err
+		}
+
+		if !strings.HasPrefix(line, "*") {
+			args, err := domain.ParseCommand(line)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %w", ErrProtocol, err)
+			}
+			if len(args) > 0 {
+				return args, nil
+			}
+			continue
+		}
+
+		count, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid multibulk length", ErrProtocol)
+		}
+		if count <= 0 {
+			continue
+		}
+		// every argument takes at least its "$0\r\n\r\n"
+		if d.budget >= 0 && 6*count > d.budget {
+			return nil, ErrTooLarge
+		}
+
+		args := make([]string, 0, min(count, 1024))
+		for range count {
+			arg, err := d.bulk()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+		}
+		return args, nil
+	}
+}
+
+// AppendResult appends the reply holding the result.
+func AppendResult(buf []byte, r domain.Result, version int) []byte {
+	switch r.Kind {
+	case domain.ResultValue:
+		return appendBulk(buf, r.Value.String())
+	case domain.ResultInteger:
+		return append(strconv.AppendInt(append(buf, ':'), r.Integer, 10), crlf...)
+	case domain.ResultNil:
+		if version == Version3 {
+			return append(buf, "_"+crlf...)
+		}
+		return append(buf, "$-1"+crlf...)
+	case domain.ResultList:
+		buf = appendHeader(buf, '*', len(r.List))
+		for _, item := range r.List {
+			buf = AppendResult(buf, item, version)
+		}
+		return buf
+	}
+	return append(buf, "+OK"+crlf...)
+}
+
+// AppendMap appends a reply holding the fields, given as keys followed by
+// their values. RESP2 has no maps, so there it is a flat array.
+func AppendMap(buf []byte, fields []domain.Result, version int) []byte {
+	if version == Version3 {
+		buf = appendHeader(buf, '%', len(fields)/2)
+	} else {
+		buf = appendHeader(buf, '*', len(fields))
+	}
+	for _, f := range fields {
+		buf = AppendResult(buf, f, version)
+	}
+	return buf
+}
+
+// AppendError appends an error reply. The message starts with the class of
+// the error, such as ERR.
+func AppendError(buf []byte, message string) []byte {
+	message = strings.NewReplacer("\r", " ", "\n", " ").Replace(message)
+	return append(append(append(buf, '-'), message...), crlf...)
+}
+
+func appendBulk(buf []byte, s string) []byte {
+	buf = appendHeader(buf, '$', len(s))
+	return append(append(buf, s...), crlf...)
+}
+
+func appendHeader(buf []byte, kind byte, n int) []byte {
+	return append(strconv.AppendInt(append(buf, kind), int64(n), 10), crlf...)
+}
+
+type decoder struct {
+	r *bufio.Reader
+	// budget is how many more bytes the request may take, or negative when
+	// there is no limit.
+	budget int
+}
+
+func (d *decoder) reserve(n int) error {
+	if d.budget < 0 {
+		return nil
+	}
+	if n > d.budget {
+		return ErrTooLarge
+	}
+	d.budget -= n
+	return nil
+}
+
+// line reads a line without its line ending.
+func (d *decoder) line() (string, error) {
+	var line []byte
+	for {
+		chunk, err := d.r.ReadSlice('\n')
+		if err := d.reserve(len(chunk)); err != nil {
+			return "", err
+		}
+		line = append(line, chunk...)
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSuffix(strings.TrimSuffix(string(line), "\n"), "\r"), nil
+	}
+}
+
+func (d *decoder) bulk() (string, error) {
+	header, err := d.line()
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(header, "$") {
+		return "", fmt.Errorf("%w: expected '$', got '%.1s'", ErrProtocol, header)
+	}
+	n, err := strconv.Atoi(header[1:])
+	if err != nil || n < 0 {
+		return "", fmt.Errorf("%w: invalid bulk length", ErrProtocol)
+	}
+	if err := d.reserve(n + len(crlf)); err != nil {
+		return "", err
+	}
+
+	b := make([]byte, n+len(crlf))
+	if _, err := io.ReadFull(d.r, b); err != nil {
+		return "", err
+	}
+	if string(b[n:]) != crlf {
+		return "", fmt.Errorf("%w: bulk string not terminated", ErrProtocol)
+	}
+	return string(b[:n]), nil
+}
@@ -0,0 +1,95 @@
+package resp
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+
+	"github.com/rdimidov/kvstore/internal/domain"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		limit   int
+		want    []string
+		wantErr error
+	}{
+		{
+			name:  "array of bulk strings",
+			input: "*3\r\n$3\r\nSET\r\n$7\r\nfoo\r\nba\r\n$0\r\n\r\n",
+			want:  []string{"SET", "foo\r\nba", ""},
+		},
+		{
+			name:  "inline command",
+			input: "GET \"a b\"\r\n",
+			want:  []string{"GET", "a b"},
+		},
+		{
+			name:  "empty requests are skipped",
+			input: "\r\n*0\r\nPING\n",
+			want:  []string{"PING"},
+		},
+		{
+			name:    "not a bulk string",
+			input:   "*1\r\n:1\r\n",
+			wantErr: ErrProtocol,
+		},
+		{
+			name:    "invalid multibulk length",
+			input:   "*x\r\n",
+			wantErr: ErrProtocol,
+		},
+		{
+			name:    "unterminated bulk string",
+			input:   "*1\r\n$3\r\nGETX\r\n",
+			wantErr: ErrProtocol,
+		},
+		{
+			name:    "bulk string over the limit",
+			input:   "*1\r\n$100\r\n",
+			limit:   50,
+			wantErr: ErrTooLarge,
+		},
+		{
+			name:    "inline command over the limit",
+			input:   strings.Repeat("a", 100) + "\r\n",
+			limit:   50,
+			wantErr: ErrTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// a small buffer makes long lines take several reads
+			r := bufio.NewReaderSize(strings.NewReader(tt.input), 16)
+			got, err := ReadCommand(r, tt.limit)
+			require.ErrorIs(t, err, tt.wantErr)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestAppendResult(t *testing.T) {
+	result := domain.ListResult(
+		domain.OKResult(),
+		domain.ValueResult("bar"),
+		domain.IntegerResult(-1),
+		domain.NilResult(),
+	)
+	require.Equal(t, "*4\r\n+OK\r\n$3\r\nbar\r\n:-1\r\n$-1\r\n", string(AppendResult(nil, result, Version2)))
+	require.Equal(t, "*4\r\n+OK\r\n$3\r\nbar\r\n:-1\r\n_\r\n", string(AppendResult(nil, result, Version3)))
+}
+
+func TestAppendMap(t *testing.T) {
+	fields := []domain.Result{domain.ValueResult("proto"), domain.IntegerResult(3)}
+	require.Equal(t, "*2\r\n$5\r\nproto\r\n:3\r\n", string(AppendMap(nil, fields, Version2)))
+	require.Equal(t, "%1\r\n$5\r\nproto\r\n:3\r\n", string(AppendMap(nil, fields, Version3)))
+}
+
+func TestAppendError(t *testing.T) {
+	require.Equal(t, "-ERR bad\r\n", string(AppendError(nil, "ERR bad")))
+	require.Equal(t, "-ERR a b\r\n", string(AppendError(nil, "ERR a\nb")))
+}
@@ -1,28 +1,43 @@
 package tcpclient
 
 import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
 	"errors"
+	"io"
 	"net"
 	"time"
+
+	"github.com/rdimidov/kvstore/internal/domain"
+	"github.com/rdimidov/kvstore/internal/presentation/frame"
 )
 
 type Client struct {
 	conn       net.Conn
+	reader     *bufio.Reader
 	timeout    time.Duration
 	bufferSize int
+	tlsConfig  *tls.Config
 }
 
 func New(serverAddr string, options ...Option) (*Client, error) {
-	conn, err := net.Dial("tcp", serverAddr)
-	if err != nil {
-		return nil, err
-	}
-
-	c := &Client{conn: conn}
+	c := &Client{}
 	for _, opt := range options {
 		opt(c)
 	}
 
+	var err error
+	if c.tlsConfig != nil {
+		dialer := &net.Dialer{Timeout: c.timeout}
+		c.conn, err = tls.DialWithDialer(dialer, "tcp", serverAddr, c.tlsConfig)
+	} else {
+		c.conn, err = net.Dial("tcp", serverAddr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
 	if c.timeout != 0 {
 		if err := c.conn.SetDeadline(time.Now().Add(c.timeout)); err != nil {
 			return nil, err
@@ -32,20 +47,132 @@ func New(serverAddr string, options ...Option) (*Client, error) {
 	return c, nil
 }
 
+// ErrResponseTooLarge is returned when a reply of the text protocol exceeds
+// the buffer size. The reply is read to its end and dropped, so the
+// connection can go on.
+var ErrResponseTooLarge = errors.New("received response is too big")
+
+// Send sends a command line to a server speaking the text protocol and
+// returns its reply, a line without its line ending, see
+// domain.ParseResult.
 func (c *Client) Send(message []byte) ([]byte, error) {
-	if _, err := c.conn.Write(message); err != nil {
+	replies, err := c.SendPipeline(message)
+	if err != nil {
 		return nil, err
 	}
-	resp := make([]byte, c.bufferSize)
-	count, err := c.conn.Read(resp)
-	if err != nil {
+	return replies[0], nil
+}
+
+// SendPipeline sends command lines to a server speaking the text protocol
+// all at once, without waiting for their replies, and returns the replies in
+// the order of the commands. A reply too large fails the whole pipeline, but
+// the replies after it are still read off the connection.
+func (c *Client) SendPipeline(messages ...[]byte) ([][]byte, error) {
+	var buf []byte
+	for _, message := range messages {
+		buf = append(buf, message...)
+		if !bytes.HasSuffix(message, []byte("\n")) {
+			buf = append(buf, '\n')
+		}
+	}
+	if _, err := c.conn.Write(buf); err != nil {
 		return nil, err
 	}
-	if count == c.bufferSize {
-		return nil, errors.New("received response is too big")
+
+	replies := make([][]byte, 0, len(messages))
+	var tooLarge bool
+	for range messages {
+		line, err := c.readLine()
+		if errors.Is(err, ErrResponseTooLarge) {
+			tooLarge = true
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		replies = append(replies, line)
 	}
+	if tooLarge {
+		return nil, ErrResponseTooLarge
+	}
+	return replies, nil
+}
 
-	return resp[:count], nil
+// readLine reads a reply of the text protocol, however many reads it takes.
+// The last reply of the stream needs no line ending.
+func (c *Client) readLine() ([]byte, error) {
+	r := c.bufferedReader()
+	var line []byte
+	var tooLarge bool
+	for {
+		chunk, err := r.ReadSlice('\n')
+		if !tooLarge {
+			line = append(line, chunk...)
+			if c.bufferSize > 0 && len(line) > c.bufferSize {
+				line, tooLarge = nil, true
+			}
+		}
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+		if err != nil && !(errors.Is(err, io.EOF) && (len(line) > 0 || tooLarge)) {
+			return nil, err
+		}
+		if tooLarge {
+			return nil, ErrResponseTooLarge
+		}
+		return bytes.TrimRight(line, "\r\n"), nil
+	}
+}
+
+// Do sends a command to a server speaking the binary protocol and returns
+// its result. Arguments may hold any bytes. An error reply of the server is
+// returned as a frame.Error.
+func (c *Client) Do(args ...string) (domain.Result, error) {
+	if _, err := c.conn.Write(frame.AppendRequest(nil, args)); err != nil {
+		return domain.Result{}, err
+	}
+	return frame.ReadResponse(c.bufferedReader(), c.bufferSize)
+}
+
+// Reply is the response to a command of a pipeline. Err holds the error
+// reply of the server as a frame.Error, if it gave one, or frame.ErrTooLarge
+// when the response was over the buffer size and skipped.
+type Reply struct {
+	Result domain.Result
+	Err    error
+}
+
+// Pipeline sends commands to a server speaking the binary protocol all at
+// once, without waiting for their replies, and returns the replies in the
+// order of the commands. The error is that of the connection, after which
+// the state of the commands not replied to is unknown.
+func (c *Client) Pipeline(commands ...[]string) ([]Reply, error) {
+	var buf []byte
+	for _, args := range commands {
+		buf = frame.AppendRequest(buf, args)
+	}
+	if _, err := c.conn.Write(buf); err != nil {
+		return nil, err
+	}
+
+	replies := make([]Reply, 0, len(commands))
+	for range commands {
+		result, err := frame.ReadResponse(c.bufferedReader(), c.bufferSize)
+		var reply frame.Error
+		if err != nil && !errors.As(err, &reply) && !errors.Is(err, frame.ErrTooLarge) {
+			return replies, err
+		}
+		replies = append(replies, Reply{Result: result, Err: err})
+	}
+	return replies, nil
+}
+
+func (c *Client) bufferedReader() *bufio.Reader {
+	if c.reader == nil {
+		c.reader = bufio.NewReader(c.conn)
+	}
+	return c.reader
 }
 
 func (c *Client) Close() {
@@ -1,9 +1,16 @@
 package tcpclient
 
 import (
+	"bufio"
+	"errors"
 	"net"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/rdimidov/kvstore/internal/domain"
+	"github.com/rdimidov/kvstore/internal/presentation/frame"
 )
 
 func startTestTCPServer(t *testing.T, response string) (addr string, stop func()) {
@@ -105,3 +112,258 @@ func TestClient_Timeout(t *testing.T) {
 		t.Error("expected timeout error, got nil")
 	}
 }
+
+func TestClient_Do(t *testing.T) {
+	t.Parallel()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to start server: %v", err)
+	}
+	defer ln.Close()
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		args, err := frame.ReadRequest(conn, 0)
+		if err != nil {
+			return
+		}
+		_, _ = conn.Write(frame.AppendResult(nil, domain.ListResult(domain.ValueResult(domain.Value(args[1])), domain.NilResult())))
+
+		_, _ = frame.ReadRequest(conn, 0)
+		_, _ = conn.Write(frame.AppendError(nil, "ERR boom"))
+	}()
+
+	client, err := New(ln.Addr().String(), WithTimeout(2*time.Second))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	defer client.Close()
+
+	result, err := client.Do("MGET", "a b\x00", "c")
+	if err != nil {
+		t.Fatalf("failed to send command: %v", err)
+	}
+	want := domain.ListResult(domain.ValueResult("a b\x00"), domain.NilResult())
+	if result.String() != want.String() {
+		t.Errorf("expected %q, got %q", want, result)
+	}
+
+	_, err = client.Do("GET", "a")
+	var reply frame.Error
+	if !errors.As(err, &reply) || reply != "ERR boom" {
+		t.Errorf("expected error reply, got %v", err)
+	}
+}
+
+func TestClient_Pipeline(t *testing.T) {
+	t.Parallel()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to start server: %v", err)
+	}
+	defer ln.Close()
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+
+		// the replies to the first pipeline come in one write, those to the
+		// second one byte at a time
+		for _, fragmented := range []bool{false, true} {
+			var replies []byte
+			for range 3 {
+				args, err := frame.ReadRequest(reader, 0)
+				if err != nil {
+					return
+				}
+				if args[0] == "FAIL" {
+					replies = frame.AppendError(replies, "ERR boom")
+					continue
+				}
+				replies = frame.AppendResult(replies, domain.ValueResult(domain.Value(args[1])))
+			}
+
+			if !fragmented {
+				_, _ = conn.Write(replies)
+				continue
+			}
+			for i := range replies {
+				_, _ = conn.Write(replies[i : i+1])
+				time.Sleep(time.Millisecond)
+			}
+		}
+	}()
+
+	client, err := New(ln.Addr().String(), WithTimeout(5*time.Second))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	defer client.Close()
+
+	for range 2 {
+		replies, err := client.Pipeline([]string{"GET", "a"}, []string{"FAIL"}, []string{"GET", "c\nd"})
+		if err != nil {
+			t.Fatalf("failed to send pipeline: %v", err)
+		}
+		if len(replies) != 3 {
+			t.Fatalf("expected 3 replies, got %d", len(replies))
+		}
+		if replies[0].Err != nil || replies[0].Result.String() != "a" {
+			t.Errorf("unexpected first reply %+v", replies[0])
+		}
+		var reply frame.Error
+		if !errors.As(replies[1].Err, &reply) || reply != "ERR boom" {
+			t.Errorf("expected error reply, got %+v", replies[1])
+		}
+		if replies[2].Err != nil || replies[2].Result.String() != "c\nd" {
+			t.Errorf("unexpected third reply %+v", replies[2])
+		}
+	}
+}
+
+func TestClient_SendEndsTheLine(t *testing.T) {
+	t.Parallel()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to start server: %v", err)
+	}
+	defer ln.Close()
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		line, err := bufio.NewReader(conn).ReadString('\n')
+		if err != nil {
+			return
+		}
+		_, _ = conn.Write([]byte(strconv.Quote(line)))
+	}()
+
+	client, err := New(ln.Addr().String(), WithTimeout(2*time.Second), WithBufferSize(1024))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	defer client.Close()
+
+	resp, err := client.Send([]byte("GET a"))
+	if err != nil {
+		t.Fatalf("failed to send message: %v", err)
+	}
+	if string(resp) != `"GET a\n"` {
+		t.Errorf("expected the line to end with a newline, got %s", resp)
+	}
+}
+
+func TestClient_SendPipeline(t *testing.T) {
+	t.Parallel()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to start server: %v", err)
+	}
+	defer ln.Close()
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+
+		// the replies to the first pipeline come in one write, those to the
+		// second one byte at a time
+		for _, fragmented := range []bool{false, true} {
+			var replies []byte
+			for range 3 {
+				line, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				replies = append(replies, "["+strings.TrimSpace(line)+" \"a\\nb\"]\n"...)
+			}
+
+			if !fragmented {
+				_, _ = conn.Write(replies)
+				continue
+			}
+			for i := range replies {
+				_, _ = conn.Write(replies[i : i+1])
+				time.Sleep(time.Millisecond)
+			}
+		}
+	}()
+
+	client, err := New(ln.Addr().String(), WithTimeout(5*time.Second), WithBufferSize(1024))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	defer client.Close()
+
+	for range 2 {
+		replies, err := client.SendPipeline([]byte("a"), []byte("b\n"), []byte("c"))
+		if err != nil {
+			t.Fatalf("failed to send pipeline: %v", err)
+		}
+		if len(replies) != 3 {
+			t.Fatalf("expected 3 replies, got %d", len(replies))
+		}
+		for i, name := range []string{"a", "b", "c"} {
+			if want := "[" + name + ` "a\nb"]`; string(replies[i]) != want {
+				t.Errorf("expected reply %q, got %q", want, replies[i])
+			}
+		}
+	}
+}
+
+func TestClient_SendSkipsReplyTooBig(t *testing.T) {
+	t.Parallel()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to start server: %v", err)
+	}
+	defer ln.Close()
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		for _, reply := range []string{strings.Repeat("x", 100) + "\n", "OK\n"} {
+			if _, err := reader.ReadString('\n'); err != nil {
+				return
+			}
+			_, _ = conn.Write([]byte(reply))
+		}
+	}()
+
+	client, err := New(ln.Addr().String(), WithTimeout(2*time.Second), WithBufferSize(16))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	defer client.Close()
+
+	if _, err := client.Send([]byte("KEYS a")); !errors.Is(err, ErrResponseTooLarge) {
+		t.Fatalf("expected ErrResponseTooLarge, got %v", err)
+	}
+	resp, err := client.Send([]byte("SET a b"))
+	if err != nil {
+		t.Fatalf("failed to send message: %v", err)
+	}
+	if string(resp) != "OK" {
+		t.Errorf("expected the reply to the next command, got %q", resp)
+	}
+}
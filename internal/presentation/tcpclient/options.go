@@ -1,6 +1,7 @@
 package tcpclient
 
 import (
+	"crypto/tls"
 	"time"
 )
 
@@ -12,8 +13,19 @@ func WithTimeout(timeout time.Duration) Option {
 	}
 }
 
+// WithBufferSize bounds the size of a response: of a line for Send, of a
+// whole frame for Do, where zero means no limit. A larger response is read
+// off the connection and dropped.
 func WithBufferSize(size int) Option {
 	return func(s *Client) {
 		s.bufferSize = size
 	}
 }
+
+// WithTLS connects over TLS, the config holds the authorities trusted to
+// sign the server certificate and the client certificate, if any.
+func WithTLS(config *tls.Config) Option {
+	return func(s *Client) {
+		s.tlsConfig = config
+	}
+}
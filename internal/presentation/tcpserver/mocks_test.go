@@ -84,3 +84,99 @@ func (_c *mockhandler_Execute_Call) RunAndReturn(run func(context1 context.Conte
 	_c.Call.Return(run)
 	return _c
 }
+
+// ExecuteArgs provides a mock function for the type mockhandler
+func (_mock *mockhandler) ExecuteArgs(context1 context.Context, strings []string) []byte {
+	ret := _mock.Called(context1, strings)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ExecuteArgs")
+	}
+
+	var r0 []byte
+	if returnFunc, ok := ret.Get(0).(func(context.Context, []string) []byte); ok {
+		r0 = returnFunc(context1, strings)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]byte)
+		}
+	}
+	return r0
+}
+
+// mockhandler_ExecuteArgs_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ExecuteArgs'
+type mockhandler_ExecuteArgs_Call struct {
+	*mock.Call
+}
+
+// ExecuteArgs is a helper method to define mock.On call
+//   - context1
+//   - strings
+func (_e *mockhandler_Expecter) ExecuteArgs(context1 interface{}, strings interface{}) *mockhandler_ExecuteArgs_Call {
+	return &mockhandler_ExecuteArgs_Call{Call: _e.mock.On("ExecuteArgs", context1, strings)}
+}
+
+func (_c *mockhandler_ExecuteArgs_Call) Run(run func(context1 context.Context, strings []string)) *mockhandler_ExecuteArgs_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].([]string))
+	})
+	return _c
+}
+
+func (_c *mockhandler_ExecuteArgs_Call) Return(bytes []byte) *mockhandler_ExecuteArgs_Call {
+	_c.Call.Return(bytes)
+	return _c
+}
+
+func (_c *mockhandler_ExecuteArgs_Call) RunAndReturn(run func(context1 context.Context, strings []string) []byte) *mockhandler_ExecuteArgs_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ExecuteRESP provides a mock function for the type mockhandler
+func (_mock *mockhandler) ExecuteRESP(context1 context.Context, strings []string) []byte {
+	ret := _mock.Called(context1, strings)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ExecuteRESP")
+	}
+
+	var r0 []byte
+	if returnFunc, ok := ret.Get(0).(func(context.Context, []string) []byte); ok {
+		r0 = returnFunc(context1, strings)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]byte)
+		}
+	}
+	return r0
+}
+
+// mockhandler_ExecuteRESP_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ExecuteRESP'
+type mockhandler_ExecuteRESP_Call struct {
+	*mock.Call
+}
+
+// ExecuteRESP is a helper method to define mock.On call
+//   - context1
+//   - strings
+func (_e *mockhandler_Expecter) ExecuteRESP(context1 interface{}, strings interface{}) *mockhandler_ExecuteRESP_Call {
+	return &mockhandler_ExecuteRESP_Call{Call: _e.mock.On("ExecuteRESP", context1, strings)}
+}
+
+func (_c *mockhandler_ExecuteRESP_Call) Run(run func(context1 context.Context, strings []string)) *mockhandler_ExecuteRESP_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].([]string))
+	})
+	return _c
+}
+
+func (_c *mockhandler_ExecuteRESP_Call) Return(bytes []byte) *mockhandler_ExecuteRESP_Call {
+	_c.Call.Return(bytes)
+	return _c
+}
+
+func (_c *mockhandler_ExecuteRESP_Call) RunAndReturn(run func(context1 context.Context, strings []string) []byte) *mockhandler_ExecuteRESP_Call {
+	_c.Call.Return(run)
+	return _c
+}
@@ -1,20 +1,76 @@
 package tcpserver
 
 import (
+	"crypto/tls"
 	"time"
+
+	"github.com/rdimidov/kvstore/pkg/concurrency"
 )
 
 type Option func(*Server)
 
+// WithBufferSize bounds the size of a request: of a line with the text
+// protocol, of a whole frame or command with the others.
 func WithBufferSize(size int) Option {
 	return func(s *Server) {
 		s.bufferSize = size
 	}
 }
 
+// WithProtocol selects the wire protocol, TextProtocol by default.
+func WithProtocol(p Protocol) Option {
+	return func(s *Server) {
+		s.protocol = p
+	}
+}
+
 func WithTimeouts(read, write time.Duration) Option {
 	return func(s *Server) {
 		s.readTimeout = read
 		s.writeTimeout = write
 	}
 }
+
+// WithShutdownTimeout bounds how long shutdown waits for connections to
+// finish the command they are running before closing them.
+func WithShutdownTimeout(timeout time.Duration) Option {
+	return func(s *Server) {
+		s.shutdownTimeout = timeout
+	}
+}
+
+// WithTLS serves connections over TLS. When the config verifies client
+// certificates, the subject of the one a client presented is kept in its
+// session.
+func WithTLS(config *tls.Config) Option {
+	return func(s *Server) {
+		s.tlsConfig = config
+	}
+}
+
+// WithMaxConnections bounds the connections served at once, zero meaning no
+// limit. The policy tells what becomes of the ones over it; queued ones wait
+// at most queueTimeout, or until shutdown when it is zero.
+func WithMaxConnections(max int, policy AdmissionPolicy, queueTimeout time.Duration) Option {
+	return func(s *Server) {
+		if max <= 0 {
+			return
+		}
+		connections := concurrency.NewSemaphore(max)
+		s.connections = &connections
+		s.admission = policy
+		s.queueTimeout = queueTimeout
+	}
+}
+
+// WithMaxInFlight bounds the commands executed at once over all the
+// connections, zero meaning no limit. The others wait for a free slot.
+func WithMaxInFlight(max int) Option {
+	return func(s *Server) {
+		if max <= 0 {
+			return
+		}
+		inFlight := concurrency.NewSemaphore(max)
+		s.inFlight = &inFlight
+	}
+}
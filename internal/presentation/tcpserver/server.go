@@ -1,27 +1,133 @@
 package tcpserver
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"crypto/tls"
 	"errors"
+	"fmt"
+	"io"
 	"net"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/rdimidov/kvstore/internal/domain"
+	"github.com/rdimidov/kvstore/internal/presentation/frame"
+	"github.com/rdimidov/kvstore/internal/presentation/resp"
+	"github.com/rdimidov/kvstore/pkg/concurrency"
 	"go.uber.org/zap"
 )
 
-const defaultBufferSize int = 4096
+const (
+	defaultBufferSize      int = 4096
+	defaultShutdownTimeout     = 5 * time.Second
+)
+
+// Protocol is the wire protocol spoken by the clients of a server.
+type Protocol string
+
+const (
+	// TextProtocol takes a command per line, ended by "\n" or "\r\n", and
+	// answers each with a line ended by "\n", see domain.FormatResult.
+	TextProtocol Protocol = "text"
+	// BinaryProtocol exchanges length-prefixed frames, see package frame.
+	BinaryProtocol Protocol = "binary"
+	// RESPProtocol speaks the protocol of Redis, so Redis clients and tools
+	// can connect, see package resp.
+	RESPProtocol Protocol = "resp"
+)
+
+func ParseProtocol(s string) (Protocol, error) {
+	switch p := Protocol(s); p {
+	case TextProtocol, BinaryProtocol, RESPProtocol:
+		return p, nil
+	case "":
+		return TextProtocol, nil
+	}
+	return "", fmt.Errorf("unknown protocol: %q", s)
+}
+
+// AdmissionPolicy tells what becomes of a connection over the limit.
+type AdmissionPolicy string
+
+const (
+	// RejectPolicy answers it with an error and closes it.
+	RejectPolicy AdmissionPolicy = "reject"
+	// QueuePolicy has it wait for another connection to close, and rejects
+	// it only when the queue timeout passes first.
+	QueuePolicy AdmissionPolicy = "queue"
+)
+
+func ParseAdmissionPolicy(s string) (AdmissionPolicy, error) {
+	switch p := AdmissionPolicy(s); p {
+	case RejectPolicy, QueuePolicy:
+		return p, nil
+	case "":
+		return RejectPolicy, nil
+	}
+	return "", fmt.Errorf("unknown admission policy: %q", s)
+}
+
+// ErrLineTooLong is returned when a line of the text protocol exceeds the
+// buffer size.
+var ErrLineTooLong = errors.New("line is too long")
+
+// maxClientsReply answers a rejected connection, as Redis does.
+const maxClientsReply = "ERR max number of clients reached"
+
+// Stats count what became of the connections of a server.
+type Stats struct {
+	// Active connections are being served.
+	Active int64
+	// Rejected connections were over the limit and closed unserved.
+	Rejected int64
+	// Queued connections had to wait for a free slot, whether they got one
+	// or were rejected.
+	Queued int64
+}
 
 type handler interface {
 	Execute(context.Context, []byte) []byte
+	ExecuteArgs(context.Context, []string) []byte
+	ExecuteRESP(context.Context, []string) []byte
+}
+
+// streamProtocol reads requests that may take any number of reads, and
+// replies to each of them.
+type streamProtocol struct {
+	read    func(r *bufio.Reader, limit int) ([]string, error)
+	execute func(ctx context.Context, args []string) []byte
 }
 
 type Server struct {
 	listener     net.Listener
 	handler      handler
 	bufferSize   int
+	protocol     Protocol
 	readTimeout  time.Duration
 	writeTimeout time.Duration
+	tlsConfig    *tls.Config
 	logger       *zap.SugaredLogger
+
+	// connections and inFlight are nil when there is no limit.
+	connections  *concurrency.Semaphore
+	admission    AdmissionPolicy
+	queueTimeout time.Duration
+	inFlight     *concurrency.Semaphore
+
+	active   atomic.Int64
+	rejected atomic.Int64
+	queued   atomic.Int64
+
+	// shutdownTimeout is the grace period connections get to finish the
+	// command they are running once the server stops.
+	shutdownTimeout time.Duration
+	mu              sync.Mutex
+	conns           map[net.Conn]struct{}
+	draining        bool
+	handlers        sync.WaitGroup
 }
 
 func New(address string, handler handler, logger *zap.SugaredLogger, options ...Option) (*Server, error) {
@@ -39,15 +145,29 @@ func New(address string, handler handler, logger *zap.SugaredLogger, options ...
 		handler:    handler,
 		logger:     logger,
 		bufferSize: defaultBufferSize,
+		protocol:   TextProtocol,
+		admission:  RejectPolicy,
+
+		shutdownTimeout: defaultShutdownTimeout,
+		conns:           make(map[net.Conn]struct{}),
 	}
 
 	for _, opt := range options {
 		opt(s)
 	}
+	if s.tlsConfig != nil {
+		s.listener = tls.NewListener(ln, s.tlsConfig)
+	}
 	return s, nil
 }
 
+// Start serves connections until ctx is done, then shuts down: it stops
+// accepting, lets the connections finish the command they are running
+// within the shutdown timeout, and closes those still busy after it. It
+// returns once no command runs anymore.
 func (s *Server) Start(ctx context.Context) {
+	// commands must not be cut short by the shutdown they are drained for
+	connCtx := context.WithoutCancel(ctx)
 	go func() {
 		for {
 			conn, err := s.listener.Accept()
@@ -59,7 +179,7 @@ func (s *Server) Start(ctx context.Context) {
 				s.logger.Infow("failed to accept connection", "error", err)
 				continue
 			}
-			go s.handleConnection(ctx, conn)
+			go s.admit(ctx, connCtx, conn)
 		}
 	}()
 	s.logger.Infof("server listening on %v", s.listener.Addr())
@@ -67,10 +187,133 @@ func (s *Server) Start(ctx context.Context) {
 	s.shutdown()
 }
 
+// Stats returns the connection counters of the server.
+func (s *Server) Stats() Stats {
+	return Stats{
+		Active:   s.active.Load(),
+		Rejected: s.rejected.Load(),
+		Queued:   s.queued.Load(),
+	}
+}
+
 func (s *Server) shutdown() {
 	if err := s.listener.Close(); err != nil {
 		s.logger.Infow("could not close listener correctly", "error", err)
 	}
+	stats := s.Stats()
+	s.logger.Infow("server stopped accepting connections",
+		"active", stats.Active, "rejected", stats.Rejected, "queued", stats.Queued)
+
+	// connections waiting for a request give up reading right away, the
+	// others once they have replied, see serveStream
+	s.mu.Lock()
+	s.draining = true
+	for conn := range s.conns {
+		_ = conn.SetReadDeadline(time.Now())
+	}
+	s.mu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		s.handlers.Wait()
+		close(drained)
+	}()
+
+	timer := time.NewTimer(s.shutdownTimeout)
+	defer timer.Stop()
+	select {
+	case <-drained:
+		s.logger.Infow("all connections closed")
+	case <-timer.C:
+		s.mu.Lock()
+		s.logger.Infow("closing connections still busy after the shutdown timeout", "count", len(s.conns))
+		for conn := range s.conns {
+			_ = conn.Close()
+		}
+		s.mu.Unlock()
+
+		// the commands still running get no reply, but they are not cut
+		// short: what they write has to reach the WAL and the storage before
+		// those are closed
+		<-drained
+		s.logger.Infow("all connections closed")
+	}
+}
+
+// track registers a connection to be drained at shutdown, and reports false
+// when the server is already shutting down.
+func (s *Server) track(conn net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.draining {
+		return false
+	}
+	s.conns[conn] = struct{}{}
+	s.handlers.Add(1)
+	return true
+}
+
+func (s *Server) untrack(conn net.Conn) {
+	s.mu.Lock()
+	delete(s.conns, conn)
+	s.mu.Unlock()
+	s.handlers.Done()
+}
+
+// isDraining reports whether the server is shutting down.
+func (s *Server) isDraining() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.draining
+}
+
+// admit serves a connection once it fits in the connection limit. Waiting
+// in the queue ends with ctx, serving it does not.
+func (s *Server) admit(ctx, connCtx context.Context, conn net.Conn) {
+	if s.connections != nil {
+		if !s.connections.TryAcquire() && !s.wait(ctx) {
+			s.rejected.Add(1)
+			s.reject(conn)
+			return
+		}
+		defer s.connections.Release()
+	}
+
+	if !s.track(conn) {
+		if err := conn.Close(); err != nil {
+			s.logger.Info("could not close connection", "error", err)
+		}
+		return
+	}
+	defer s.untrack(conn)
+
+	s.active.Add(1)
+	defer s.active.Add(-1)
+	s.handleConnection(connCtx, conn)
+}
+
+// wait queues a connection over the limit when the policy allows it, and
+// reports whether a slot freed up in time.
+func (s *Server) wait(ctx context.Context) bool {
+	if s.admission != QueuePolicy {
+		return false
+	}
+	s.queued.Add(1)
+
+	if s.queueTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.queueTimeout)
+		defer cancel()
+	}
+	return s.connections.AcquireContext(ctx) == nil
+}
+
+func (s *Server) reject(conn net.Conn) {
+	s.logger.Infow("rejected connection over the limit", "remote", conn.RemoteAddr())
+	_ = s.write(conn, s.errorReply(maxClientsReply))
+	if err := conn.Close(); err != nil {
+		s.logger.Info("could not close connection", "error", err)
+	}
 }
 
 func (s *Server) handleConnection(ctx context.Context, conn net.Conn) {
@@ -83,37 +326,162 @@ func (s *Server) handleConnection(ctx context.Context, conn net.Conn) {
 		}
 	}()
 
-	buf := make([]byte, s.bufferSize)
+	// the session keeps what the client selected, such as its namespace,
+	// for as long as the connection lives
+	session := &domain.Session{}
+	ctx = domain.WithSession(ctx, session)
+
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		if err := s.handshake(ctx, tlsConn, session); err != nil {
+			s.logger.Infow("failed TLS handshake", "error", err)
+			return
+		}
+	}
+
+	switch s.protocol {
+	case BinaryProtocol:
+		s.serveStream(ctx, conn, streamProtocol{
+			read:    func(r *bufio.Reader, limit int) ([]string, error) { return frame.ReadRequest(r, limit) },
+			execute: s.handler.ExecuteArgs,
+		})
+	case RESPProtocol:
+		s.serveStream(ctx, conn, streamProtocol{
+			read:    resp.ReadCommand,
+			execute: s.handler.ExecuteRESP,
+		})
+	default:
+		s.serveStream(ctx, conn, streamProtocol{
+			read: readLine,
+			execute: func(ctx context.Context, args []string) []byte {
+				return s.handler.Execute(ctx, []byte(args[0]))
+			},
+		})
+	}
+}
+
+// readLine reads a command of the text protocol, skipping blank lines. The
+// last line of the stream needs no line ending. A line of more than limit
+// bytes fails with ErrLineTooLong; zero means no limit.
+func readLine(r *bufio.Reader, limit int) ([]string, error) {
+	var line []byte
 	for {
-		if s.readTimeout != 0 {
-			if err := conn.SetReadDeadline(time.Now().Add(s.readTimeout)); err != nil {
-				s.logger.Infow("failed to set read timeout", "error", err)
-				return
-			}
+		chunk, err := r.ReadSlice('\n')
+		line = append(line, chunk...)
+		if limit > 0 && len(line) > limit {
+			return nil, ErrLineTooLong
+		}
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+		if err != nil && !(errors.Is(err, io.EOF) && len(line) > 0) {
+			return nil, err
 		}
 
-		count, err := conn.Read(buf)
+		if cmd := bytes.TrimSpace(line); len(cmd) > 0 {
+			return []string{string(cmd)}, nil
+		}
 		if err != nil {
-			s.logger.Infow("failed to read data", "error", err)
-			return
+			return nil, err
 		}
+		line = line[:0]
+	}
+}
 
-		if count == s.bufferSize {
-			s.logger.Info("buffer is full")
+// serveStream answers requests until the client goes away or the server
+// shuts down, in the order they came, so clients may send many before
+// reading the replies. The buffer size bounds a whole request rather than a
+// single read.
+func (s *Server) serveStream(ctx context.Context, conn net.Conn, p streamProtocol) {
+	reader := bufio.NewReader(conn)
+	for {
+		// the deadline is set before checking for shutdown, which sets the
+		// flag before expiring the deadlines, so either one stops the read
+		if err := s.setReadDeadline(conn); err != nil {
+			return
+		}
+		if s.isDraining() {
 			return
 		}
 
-		if s.writeTimeout != 0 {
-			if err := conn.SetWriteDeadline(time.Now().Add(s.writeTimeout)); err != nil {
-				s.logger.Infow("failed to set write timeout", "error", err)
-				return
+		args, err := p.read(reader, s.bufferSize)
+		if errors.Is(err, frame.ErrTooLarge) || errors.Is(err, resp.ErrTooLarge) ||
+			errors.Is(err, resp.ErrProtocol) || errors.Is(err, ErrLineTooLong) {
+			// the rest of the request is not read, so the stream cannot go on
+			s.logger.Infow("failed to read request", "error", err)
+			_ = s.write(conn, s.errorReply("ERR "+err.Error()))
+			return
+		}
+		if err != nil {
+			if !s.isDraining() {
+				s.logger.Infow("failed to read data", "error", err)
 			}
+			return
 		}
 
-		resp := s.handler.Execute(ctx, buf[:count])
-		if _, err := conn.Write(resp); err != nil {
-			s.logger.Infow("failed to write data", "error", err)
+		reply := s.execute(func() []byte { return p.execute(ctx, args) })
+		if err := s.write(conn, reply); err != nil {
 			return
 		}
 	}
 }
+
+// execute runs a command, first waiting for a free slot when the commands
+// in flight are capped.
+func (s *Server) execute(run func() []byte) []byte {
+	if s.inFlight != nil {
+		s.inFlight.Acquire()
+		defer s.inFlight.Release()
+	}
+	return run()
+}
+
+// errorReply encodes an error message in the protocol of the server.
+func (s *Server) errorReply(message string) []byte {
+	switch s.protocol {
+	case BinaryProtocol:
+		return frame.AppendError(nil, message)
+	case RESPProtocol:
+		return resp.AppendError(nil, message)
+	}
+	return []byte(message + "\n")
+}
+
+// handshake completes the TLS handshake before the first request, so that
+// the client certificate is known to every command.
+func (s *Server) handshake(ctx context.Context, conn *tls.Conn, session *domain.Session) error {
+	if err := s.setReadDeadline(conn); err != nil {
+		return err
+	}
+	if err := conn.HandshakeContext(ctx); err != nil {
+		return err
+	}
+	if certs := conn.ConnectionState().PeerCertificates; len(certs) > 0 {
+		session.Subject = certs[0].Subject.String()
+	}
+	return nil
+}
+
+func (s *Server) setReadDeadline(conn net.Conn) error {
+	if s.readTimeout == 0 {
+		return nil
+	}
+	err := conn.SetReadDeadline(time.Now().Add(s.readTimeout))
+	if err != nil {
+		s.logger.Infow("failed to set read timeout", "error", err)
+	}
+	return err
+}
+
+func (s *Server) write(conn net.Conn, resp []byte) error {
+	if s.writeTimeout != 0 {
+		if err := conn.SetWriteDeadline(time.Now().Add(s.writeTimeout)); err != nil {
+			s.logger.Infow("failed to set write timeout", "error", err)
+			return err
+		}
+	}
+	if _, err := conn.Write(resp); err != nil {
+		s.logger.Infow("failed to write data", "error", err)
+		return err
+	}
+	return nil
+}
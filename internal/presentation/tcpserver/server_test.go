@@ -1,34 +1,45 @@
 package tcpserver
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"io"
 	"net"
 	"testing"
 	"time"
 
+	"github.com/rdimidov/kvstore/internal/domain"
+	"github.com/rdimidov/kvstore/internal/presentation/frame"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
 )
 
-func startTestServer(t *testing.T, handler handler) (addr string, cancel context.CancelFunc) {
+func startTestServer(t *testing.T, handler handler, options ...Option) (addr string, cancel context.CancelFunc) {
+	t.Helper()
+	server, cancel := runTestServer(t, handler, options...)
+	return server.listener.Addr().String(), cancel
+}
+
+func runTestServer(t *testing.T, handler handler, options ...Option) (*Server, context.CancelFunc) {
 	t.Helper()
 
 	ln, err := net.Listen("tcp", "127.0.0.1:0")
 	require.NoError(t, err)
-	addr = ln.Addr().String()
+	addr := ln.Addr().String()
 	_ = ln.Close()
 
 	ctx, cancel := context.WithCancel(context.Background())
 	logger := zap.NewNop().Sugar()
 
-	server, err := New(addr, handler, logger)
+	server, err := New(addr, handler, logger, options...)
 	require.NoError(t, err)
 
 	go server.Start(ctx)
 	time.Sleep(100 * time.Millisecond) // Give time to start
 
-	return addr, cancel
+	return server, cancel
 }
 
 func TestServer_HandleRequest(t *testing.T) {
@@ -47,7 +58,7 @@ func TestServer_HandleRequest(t *testing.T) {
 	require.NoError(t, err)
 	defer conn.Close()
 
-	_, err = conn.Write([]byte("test-input"))
+	_, err = conn.Write([]byte("test-input\n"))
 	require.NoError(t, err)
 
 	buf := make([]byte, 1024)
@@ -75,3 +86,486 @@ func TestServer_GracefulShutdown(t *testing.T) {
 	_, err = net.Dial("tcp", addr)
 	require.Error(t, err, "expected connection to fail after shutdown")
 }
+
+func TestServer_ShutdownDrainsConnections(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	mockHandler := newMockhandler(t)
+	mockHandler.
+		EXPECT().
+		Execute(mock.Anything, []byte("slow")).
+		RunAndReturn(func(ctx context.Context, _ []byte) []byte {
+			close(started)
+			<-release
+			if ctx.Err() != nil {
+				return []byte("cancelled")
+			}
+			return []byte("slow done")
+		}).
+		Once()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := ln.Addr().String()
+	_ = ln.Close()
+	server, err := New(addr, mockHandler, zap.NewNop().Sugar(), WithShutdownTimeout(5*time.Second))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		server.Start(ctx)
+		close(stopped)
+	}()
+
+	busy, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer busy.Close()
+	idle, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer idle.Close()
+
+	_, err = busy.Write([]byte("slow\n"))
+	require.NoError(t, err)
+	<-started
+	cancel()
+
+	// the idle connection is closed without waiting for the busy one
+	require.NoError(t, idle.SetReadDeadline(time.Now().Add(time.Second)))
+	_, err = idle.Read(make([]byte, 16))
+	require.ErrorIs(t, err, io.EOF)
+
+	select {
+	case <-stopped:
+		t.Fatal("server stopped before the command in flight finished")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// the command in flight completes and is answered, then the connection
+	// is closed
+	close(release)
+	require.NoError(t, busy.SetReadDeadline(time.Now().Add(time.Second)))
+	reply, err := io.ReadAll(busy)
+	require.NoError(t, err)
+	require.Equal(t, "slow done", string(reply))
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("server did not stop once drained")
+	}
+}
+
+func TestServer_ShutdownTimeout(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	mockHandler := newMockhandler(t)
+	mockHandler.
+		EXPECT().
+		Execute(mock.Anything, []byte("stuck")).
+		RunAndReturn(func(context.Context, []byte) []byte {
+			close(started)
+			<-release
+			return []byte("too late")
+		}).
+		Once()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := ln.Addr().String()
+	_ = ln.Close()
+	server, err := New(addr, mockHandler, zap.NewNop().Sugar(), WithShutdownTimeout(50*time.Millisecond))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		server.Start(ctx)
+		close(stopped)
+	}()
+
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("stuck\n"))
+	require.NoError(t, err)
+	<-started
+	cancel()
+
+	// the connection is closed once the grace period is over
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	_, err = conn.Read(make([]byte, 16))
+	require.ErrorIs(t, err, io.EOF)
+
+	// but the server waits for the command to finish before it stops
+	select {
+	case <-stopped:
+		t.Fatal("server stopped while a command was still running")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("server did not stop once the command finished")
+	}
+}
+
+func TestServer_SessionPerConnection(t *testing.T) {
+	// The first request of a connection selects a namespace in its session,
+	// later requests of the same connection must see it.
+	mockHandler := newMockhandler(t)
+	mockHandler.
+		EXPECT().
+		Execute(mock.Anything, []byte("USE team")).
+		Run(func(ctx context.Context, _ []byte) {
+			domain.SessionFrom(ctx).Namespace = "team"
+		}).
+		Return([]byte("OK")).
+		Once()
+	mockHandler.
+		EXPECT().
+		Execute(mock.Anything, []byte("NS")).
+		RunAndReturn(func(ctx context.Context, _ []byte) []byte {
+			return []byte(domain.NamespaceFrom(ctx))
+		}).
+		Twice()
+
+	addr, cancel := startTestServer(t, mockHandler)
+	defer cancel()
+
+	send := func(conn net.Conn, msg string) string {
+		_, err := conn.Write([]byte(msg + "\n"))
+		require.NoError(t, err)
+		buf := make([]byte, 1024)
+		n, err := conn.Read(buf)
+		require.NoError(t, err)
+		return string(buf[:n])
+	}
+
+	first, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer first.Close()
+	second, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer second.Close()
+
+	require.Equal(t, "OK", send(first, "USE team"))
+	require.Equal(t, "team", send(first, "NS"))
+	require.Equal(t, string(domain.DefaultNamespace), send(second, "NS"))
+}
+
+func TestServer_TextPipelining(t *testing.T) {
+	mockHandler := newMockhandler(t)
+	for _, cmd := range []string{"SET a 1", "GET a", "GET b", "DEL a"} {
+		mockHandler.
+			EXPECT().
+			Execute(mock.Anything, []byte(cmd)).
+			Return([]byte("reply to " + cmd + "\n")).
+			Once()
+	}
+
+	addr, cancel := startTestServer(t, mockHandler, WithBufferSize(64))
+	defer cancel()
+
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+
+	// commands coalesced in one write, split over several, and blank lines
+	for _, chunk := range []string{"SET a 1\r\nGET a\n\nGE", "T", " b\r", "\nDEL a\n"} {
+		_, err = conn.Write([]byte(chunk))
+		require.NoError(t, err)
+		time.Sleep(10 * time.Millisecond)
+	}
+	for _, cmd := range []string{"SET a 1", "GET a", "GET b", "DEL a"} {
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		require.Equal(t, "reply to "+cmd+"\n", line)
+	}
+
+	// a line over the buffer size is refused and ends the connection
+	_, err = conn.Write([]byte("SET a " + string(bytes.Repeat([]byte("x"), 100)) + "\n"))
+	require.NoError(t, err)
+	line, err := reader.ReadString('\n')
+	require.NoError(t, err)
+	require.Equal(t, "ERR "+ErrLineTooLong.Error()+"\n", line)
+	_, err = reader.ReadByte()
+	require.Error(t, err)
+}
+
+func TestServer_BinaryProtocol(t *testing.T) {
+	args := []string{"SET", "key with spaces", string(make([]byte, 3000))}
+	mockHandler := newMockhandler(t)
+	mockHandler.
+		EXPECT().
+		ExecuteArgs(mock.Anything, args).
+		Return(frame.AppendResult(nil, domain.OKResult())).
+		Once()
+	mockHandler.
+		EXPECT().
+		ExecuteArgs(mock.Anything, mock.MatchedBy(func(args []string) bool { return args[0] == "GET" })).
+		RunAndReturn(func(_ context.Context, args []string) []byte {
+			return frame.AppendResult(nil, domain.ValueResult(domain.Value(args[1])))
+		}).
+		Times(3)
+
+	addr, cancel := startTestServer(t, mockHandler, WithProtocol(BinaryProtocol), WithBufferSize(4096))
+	defer cancel()
+
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// a frame may take several reads
+	req := frame.AppendRequest(nil, args)
+	_, err = conn.Write(req[:10])
+	require.NoError(t, err)
+	time.Sleep(10 * time.Millisecond)
+	_, err = conn.Write(req[10:])
+	require.NoError(t, err)
+
+	result, err := frame.ReadResponse(conn, 0)
+	require.NoError(t, err)
+	require.Equal(t, domain.OKResult(), result)
+
+	// frames coalesced in one write are replied to in order
+	var pipeline []byte
+	for _, key := range []string{"a", "b", "c"} {
+		pipeline = frame.AppendRequest(pipeline, []string{"GET", key})
+	}
+	_, err = conn.Write(pipeline)
+	require.NoError(t, err)
+	reader := bufio.NewReader(conn)
+	for _, key := range []string{"a", "b", "c"} {
+		result, err := frame.ReadResponse(reader, 0)
+		require.NoError(t, err)
+		require.Equal(t, domain.ValueResult(domain.Value(key)), result)
+	}
+
+	// a frame over the size limit is refused and ends the connection
+	_, err = conn.Write(frame.AppendRequest(nil, []string{"SET", "foo", string(make([]byte, 5000))}))
+	require.NoError(t, err)
+	_, err = frame.ReadResponse(reader, 0)
+	require.Equal(t, frame.Error("ERR "+frame.ErrTooLarge.Error()), err)
+	_, err = reader.ReadByte()
+	require.Error(t, err)
+}
+
+func TestServer_RESPProtocol(t *testing.T) {
+	mockHandler := newMockhandler(t)
+	mockHandler.
+		EXPECT().
+		ExecuteRESP(mock.Anything, []string{"SET", "foo", "bar baz"}).
+		Return([]byte("+OK\r\n")).
+		Once()
+	mockHandler.
+		EXPECT().
+		ExecuteRESP(mock.Anything, []string{"PING"}).
+		Return([]byte("$4\r\nPONG\r\n")).
+		Once()
+
+	addr, cancel := startTestServer(t, mockHandler, WithProtocol(RESPProtocol))
+	defer cancel()
+
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+
+	// a request may take several reads, and inline commands work as well
+	_, err = conn.Write([]byte("*3\r\n$3\r\nSET\r\n$3\r\nfoo\r\n$7\r\nbar"))
+	require.NoError(t, err)
+	time.Sleep(10 * time.Millisecond)
+	_, err = conn.Write([]byte(" baz\r\nPING\r\n"))
+	require.NoError(t, err)
+
+	line, err := reader.ReadString('\n')
+	require.NoError(t, err)
+	require.Equal(t, "+OK\r\n", line)
+	line, err = reader.ReadString('\n')
+	require.NoError(t, err)
+	require.Equal(t, "$4\r\n", line)
+	line, err = reader.ReadString('\n')
+	require.NoError(t, err)
+	require.Equal(t, "PONG\r\n", line)
+
+	// a malformed request is answered with an error and ends the connection
+	_, err = conn.Write([]byte("*1\r\n:1\r\n"))
+	require.NoError(t, err)
+	line, err = reader.ReadString('\n')
+	require.NoError(t, err)
+	require.Contains(t, line, "-ERR protocol error")
+	_, err = reader.ReadByte()
+	require.Error(t, err)
+}
+
+func TestServer_MaxConnections(t *testing.T) {
+	send := func(conn net.Conn, msg string) (string, error) {
+		if _, err := conn.Write([]byte(msg + "\n")); err != nil {
+			return "", err
+		}
+		buf := make([]byte, 1024)
+		n, err := conn.Read(buf)
+		return string(buf[:n]), err
+	}
+	echo := func(t *testing.T) *mockhandler {
+		mockHandler := newMockhandler(t)
+		mockHandler.
+			EXPECT().
+			Execute(mock.Anything, mock.Anything).
+			RunAndReturn(func(_ context.Context, b []byte) []byte { return b }).
+			Maybe()
+		return mockHandler
+	}
+
+	t.Run("reject", func(t *testing.T) {
+		server, cancel := runTestServer(t, echo(t), WithMaxConnections(1, RejectPolicy, 0))
+		defer cancel()
+		addr := server.listener.Addr().String()
+
+		first, err := net.Dial("tcp", addr)
+		require.NoError(t, err)
+		defer first.Close()
+		reply, err := send(first, "a")
+		require.NoError(t, err)
+		require.Equal(t, "a", reply)
+
+		second, err := net.Dial("tcp", addr)
+		require.NoError(t, err)
+		defer second.Close()
+		reply, err = send(second, "b")
+		require.NoError(t, err)
+		require.Equal(t, maxClientsReply+"\n", reply)
+		_, err = second.Read(make([]byte, 1))
+		require.Error(t, err)
+
+		require.Equal(t, Stats{Active: 1, Rejected: 1}, server.Stats())
+	})
+
+	t.Run("queue", func(t *testing.T) {
+		server, cancel := runTestServer(t, echo(t), WithMaxConnections(1, QueuePolicy, 2*time.Second))
+		defer cancel()
+		addr := server.listener.Addr().String()
+
+		first, err := net.Dial("tcp", addr)
+		require.NoError(t, err)
+		_, err = send(first, "a")
+		require.NoError(t, err)
+
+		// the second connection is served once the first one closes
+		second, err := net.Dial("tcp", addr)
+		require.NoError(t, err)
+		defer second.Close()
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			_ = first.Close()
+		}()
+		reply, err := send(second, "b")
+		require.NoError(t, err)
+		require.Equal(t, "b", reply)
+
+		require.Equal(t, Stats{Active: 1, Queued: 1}, server.Stats())
+	})
+
+	t.Run("queue timeout", func(t *testing.T) {
+		server, cancel := runTestServer(t, echo(t), WithMaxConnections(1, QueuePolicy, 50*time.Millisecond))
+		defer cancel()
+		addr := server.listener.Addr().String()
+
+		first, err := net.Dial("tcp", addr)
+		require.NoError(t, err)
+		defer first.Close()
+		_, err = send(first, "a")
+		require.NoError(t, err)
+
+		second, err := net.Dial("tcp", addr)
+		require.NoError(t, err)
+		defer second.Close()
+		reply, err := send(second, "b")
+		require.NoError(t, err)
+		require.Equal(t, maxClientsReply+"\n", reply)
+
+		require.Equal(t, Stats{Active: 1, Rejected: 1, Queued: 1}, server.Stats())
+	})
+}
+
+func TestServer_MaxInFlight(t *testing.T) {
+	// the first command holds the only slot until it is released
+	release := make(chan struct{})
+	started := make(chan struct{})
+	mockHandler := newMockhandler(t)
+	mockHandler.
+		EXPECT().
+		Execute(mock.Anything, []byte("slow")).
+		RunAndReturn(func(context.Context, []byte) []byte {
+			close(started)
+			<-release
+			return []byte("slow done")
+		}).
+		Once()
+	mockHandler.
+		EXPECT().
+		Execute(mock.Anything, []byte("fast")).
+		Return([]byte("fast done")).
+		Once()
+
+	addr, cancel := startTestServer(t, mockHandler, WithMaxInFlight(1))
+	defer cancel()
+
+	slow, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer slow.Close()
+	fast, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer fast.Close()
+
+	_, err = slow.Write([]byte("slow\n"))
+	require.NoError(t, err)
+	<-started
+	_, err = fast.Write([]byte("fast\n"))
+	require.NoError(t, err)
+
+	require.NoError(t, fast.SetReadDeadline(time.Now().Add(100*time.Millisecond)))
+	_, err = fast.Read(make([]byte, 16))
+	require.Error(t, err, "command ran while the slot was taken")
+
+	close(release)
+	require.NoError(t, fast.SetReadDeadline(time.Now().Add(time.Second)))
+	buf := make([]byte, 16)
+	n, err := fast.Read(buf)
+	require.NoError(t, err)
+	require.Equal(t, "fast done", string(buf[:n]))
+}
+
+func TestParseAdmissionPolicy(t *testing.T) {
+	p, err := ParseAdmissionPolicy("")
+	require.NoError(t, err)
+	require.Equal(t, RejectPolicy, p)
+
+	p, err = ParseAdmissionPolicy("queue")
+	require.NoError(t, err)
+	require.Equal(t, QueuePolicy, p)
+
+	_, err = ParseAdmissionPolicy("drop")
+	require.Error(t, err)
+}
+
+func TestParseProtocol(t *testing.T) {
+	p, err := ParseProtocol("")
+	require.NoError(t, err)
+	require.Equal(t, TextProtocol, p)
+
+	p, err = ParseProtocol("binary")
+	require.NoError(t, err)
+	require.Equal(t, BinaryProtocol, p)
+
+	p, err = ParseProtocol("resp")
+	require.NoError(t, err)
+	require.Equal(t, RESPProtocol, p)
+
+	_, err = ParseProtocol("http")
+	require.Error(t, err)
+}
@@ -0,0 +1,121 @@
+package tcpserver
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rdimidov/kvstore/internal/domain"
+	"github.com/rdimidov/kvstore/internal/presentation/tcpclient"
+	"github.com/rdimidov/kvstore/pkg/tlsconfig"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// writeCert writes the certificate of template and its key to dir, signed by
+// parent or self-signed when parent is nil.
+func writeCert(t *testing.T, dir, name string, template *x509.Certificate, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name+".crt"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name+".key"), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return cert, key
+}
+
+func TestServer_MutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	path := func(name string) string { return filepath.Join(dir, name) }
+
+	ca, caKey := writeCert(t, dir, "ca", &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}, nil, nil)
+	writeCert(t, dir, "server", &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "kvstore"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca, caKey)
+	writeCert(t, dir, "client", &x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		Subject:      pkix.Name{CommonName: "alice", Organization: []string{"ops"}},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca, caKey)
+
+	serverTLS, err := tlsconfig.Server(path("server.crt"), path("server.key"), path("ca.crt"), true)
+	require.NoError(t, err)
+
+	// the subject of the client certificate is known to the handler
+	mockHandler := newMockhandler(t)
+	mockHandler.
+		EXPECT().
+		Execute(mock.Anything, []byte("WHOAMI")).
+		RunAndReturn(func(ctx context.Context, _ []byte) []byte {
+			return []byte(domain.SessionFrom(ctx).Subject + "\n")
+		}).
+		Once()
+
+	addr, cancel := startTestServer(t, mockHandler, WithTLS(serverTLS))
+	defer cancel()
+
+	clientTLS, err := tlsconfig.Client(path("client.crt"), path("client.key"), path("ca.crt"))
+	require.NoError(t, err)
+	client, err := tcpclient.New(addr, tcpclient.WithTLS(clientTLS), tcpclient.WithTimeout(time.Second), tcpclient.WithBufferSize(1024))
+	require.NoError(t, err)
+	defer client.Close()
+
+	resp, err := client.Send([]byte("WHOAMI"))
+	require.NoError(t, err)
+	require.Equal(t, "CN=alice,O=ops", string(resp))
+
+	// a client without a certificate is refused
+	anonymousTLS, err := tlsconfig.Client("", "", path("ca.crt"))
+	require.NoError(t, err)
+	conn, err := tls.Dial("tcp", addr, anonymousTLS)
+	if err == nil {
+		defer conn.Close()
+		_, err = conn.Write([]byte("WHOAMI"))
+		if err == nil {
+			_, err = conn.Read(make([]byte, 16))
+		}
+	}
+	require.Error(t, err)
+
+	// and so is a plaintext one
+	plain, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer plain.Close()
+	_, err = plain.Write([]byte("WHOAMI\n"))
+	require.NoError(t, err)
+	_, err = plain.Read(make([]byte, 16))
+	require.Error(t, err)
+}
@@ -1,5 +1,7 @@
 package concurrency
 
+import "context"
+
 type token struct{}
 
 type Semaphore struct {
@@ -16,6 +18,26 @@ func (s *Semaphore) Acquire() {
 	s.queue <- token{}
 }
 
+// TryAcquire takes a token only when one is free right away.
+func (s *Semaphore) TryAcquire() bool {
+	select {
+	case s.queue <- token{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// AcquireContext waits for a token until ctx is done.
+func (s *Semaphore) AcquireContext(ctx context.Context) error {
+	select {
+	case s.queue <- token{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 func (s *Semaphore) Release() {
 	<-s.queue
 }